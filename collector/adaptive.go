@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// latencyTracker keeps moving average of how late stats
+// from docker arrive, it is safe for concurrent use
+type latencyTracker struct {
+	// average is in nanoseconds, it is the first field
+	// to be 64-bit aligned for atomic access
+	average int64
+}
+
+// observe adds latency of a single sample to moving average,
+// recent samples weigh more, so average follows changes of load
+func (t *latencyTracker) observe(d time.Duration) {
+	for {
+		old := atomic.LoadInt64(&t.average)
+
+		avg := int64(d)
+		if old != 0 {
+			avg = old + (int64(d)-old)/8
+		}
+
+		if atomic.CompareAndSwapInt64(&t.average, old, avg) {
+			return
+		}
+	}
+}
+
+// value returns moving average of latency
+func (t *latencyTracker) value() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.average))
+}
+
+// SetAdaptiveSampling makes interval of monitored containers stretch
+// when stats from docker arrive later than threshold on average, so work
+// doesn't pile up behind busy daemon, interval is stretched up to max times,
+// 0 threshold disables stretching, it should be called before Run
+func (c *Collector) SetAdaptiveSampling(threshold time.Duration, max int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.slowAfter = threshold
+	c.maxStretch = max
+}
+
+// adapt stretches interval of monitors according to latency of stats
+func (c *Collector) adapt(ctx context.Context) {
+	every(ctx, dockerCheckInterval, func(time.Time) {
+		c.mutex.Lock()
+
+		stretch := intervalStretch(c.latency.value(), c.slowAfter, c.maxStretch)
+		if stretch == c.stretch {
+			c.mutex.Unlock()
+			return
+		}
+
+		c.stretch = stretch
+		for _, m := range c.registered {
+			m.setInterval(c.stretched(c.interval))
+		}
+
+		c.mutex.Unlock()
+
+		if stretch > 1 {
+			warnf("docker is slow with stats latency of %s, interval is stretched %d times", c.latency.value(), stretch)
+		} else {
+			infof("docker is fast again, interval is not stretched")
+		}
+	})
+}
+
+// stretched returns interval stretched according to latency of stats,
+// it should be called with mutex held
+func (c *Collector) stretched(interval time.Duration) time.Duration {
+	if c.stretch > 1 {
+		return interval * time.Duration(c.stretch)
+	}
+
+	return interval
+}
+
+// intervalStretch returns how many times interval should be stretched
+// for latency, every threshold of latency stretches interval once more
+func intervalStretch(latency time.Duration, threshold time.Duration, max int) int {
+	if threshold <= 0 || latency <= threshold {
+		return 1
+	}
+
+	stretch := int(latency/threshold) + 1
+	if stretch > max {
+		return max
+	}
+
+	return stretch
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalStretch(t *testing.T) {
+	tests := []struct {
+		latency  time.Duration
+		expected int
+	}{
+		{0, 1},
+		{100 * time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{700 * time.Millisecond, 2},
+		{1200 * time.Millisecond, 3},
+		{time.Minute, 4},
+	}
+
+	for _, test := range tests {
+		if stretch := intervalStretch(test.latency, 500*time.Millisecond, 4); stretch != test.expected {
+			t.Errorf("expected stretch %d for latency %s, got %d", test.expected, test.latency, stretch)
+		}
+	}
+
+	if stretch := intervalStretch(time.Minute, 0, 4); stretch != 1 {
+		t.Errorf("expected no stretch without threshold, got %d", stretch)
+	}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	l := &latencyTracker{}
+
+	l.observe(time.Second)
+	if l.value() != time.Second {
+		t.Errorf("expected the first observation to be the average, got %s", l.value())
+	}
+
+	for i := 0; i < 100; i++ {
+		l.observe(10 * time.Millisecond)
+	}
+
+	if l.value() > 20*time.Millisecond {
+		t.Errorf("expected average to follow recent observations, got %s", l.value())
+	}
+}
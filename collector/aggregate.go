@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// aggregateTask is the task name of app level rollups
+const aggregateTask = "_all"
+
+// AggregateWriter is responsible for writing app level rollups along
+// with samples of individual tasks, rollups are sums of the latest
+// metrics of all tasks of an app and they are written as task _all
+type AggregateWriter struct {
+	writer   Writer
+	interval time.Duration
+	// group returns app of rollup that sample is part of,
+	// samples with empty group are not aggregated
+	group func(s *Stats) string
+	// task is the task name of rollups
+	task string
+	// families are metric families that are aggregated, nil for all
+	families map[string]bool
+
+	mutex sync.Mutex
+	tasks map[string]map[string]Stats
+	last  time.Time
+}
+
+// NewAggregateWriter creates new AggregateWriter on top of specified
+// writer with interval between rollups, tasks that haven't reported
+// for two intervals are left out of rollups
+func NewAggregateWriter(writer Writer, interval time.Duration) *AggregateWriter {
+	return &AggregateWriter{
+		writer:   writer,
+		interval: interval,
+		group:    func(s *Stats) string { return s.App },
+		task:     aggregateTask,
+		tasks:    map[string]map[string]Stats{},
+	}
+}
+
+// Write passes sample to wrapped writer and writes rollups when
+// interval passes since the previous ones according to sample time,
+// samples that only carry metrics are not aggregated
+func (w *AggregateWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.writer.Write(s)
+	if err != nil {
+		return err
+	}
+
+	if s.MetricsOnly {
+		return nil
+	}
+
+	group := w.group(&s)
+	if group == "" {
+		return nil
+	}
+
+	if w.tasks[group] == nil {
+		w.tasks[group] = map[string]Stats{}
+	}
+
+	w.tasks[group][s.App+"/"+s.Task] = s
+
+	t := s.Time
+	if w.last.IsZero() {
+		w.last = t
+		return nil
+	}
+
+	if t.Sub(w.last) < w.interval {
+		return nil
+	}
+
+	w.last = t
+
+	return w.writeRollups(t)
+}
+
+func (w *AggregateWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w *AggregateWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *AggregateWriter) Close() error {
+	return w.writer.Close()
+}
+
+func (w *AggregateWriter) writeRollups(t time.Time) error {
+	for group, tasks := range w.tasks {
+		metrics := map[string]uint64{}
+
+		for task, s := range tasks {
+			if t.Sub(s.Time) > 2*w.interval {
+				delete(tasks, task)
+				continue
+			}
+
+			for k, v := range intMetrics(s) {
+				if w.families == nil || w.families[metricFamily(k)] {
+					metrics[k] += v
+				}
+			}
+		}
+
+		if len(tasks) == 0 {
+			delete(w.tasks, group)
+			continue
+		}
+
+		rollup := Stats{
+			App:         group,
+			Task:        w.task,
+			Metrics:     metrics,
+			MetricsOnly: true,
+		}
+
+		rollup.Time = t
+
+		err := w.writer.Write(rollup)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewAggregateWriter(r, time.Minute)
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(app, task string, offset time.Duration, cpu uint64) {
+		s := Stats{App: app, Task: task}
+		s.Time = start.Add(offset)
+		s.CPU.Total = cpu
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	write("myapp", "task1", 0, 1)
+	write("myapp", "task2", 0, 2)
+	write("other", "task1", 0, 4)
+
+	if len(r.written) != 3 {
+		t.Fatalf("expected 3 written samples before interval passes, got %d", len(r.written))
+	}
+
+	write("myapp", "task1", time.Minute, 10)
+
+	rollups := map[string]uint64{}
+	for _, s := range r.written[4:] {
+		if s.Task != aggregateTask {
+			t.Errorf("expected rollup task to be %s, got %s", aggregateTask, s.Task)
+		}
+
+		rollups[s.App] = intMetrics(s)["cpu.total"]
+	}
+
+	if rollups["myapp"] != 12 {
+		t.Errorf("expected myapp rollup cpu.total to be 12, got %d", rollups["myapp"])
+	}
+
+	if rollups["other"] != 4 {
+		t.Errorf("expected other rollup cpu.total to be 4, got %d", rollups["other"])
+	}
+
+	written := len(r.written)
+
+	// task2 and other app are stale by now
+	write("myapp", "task1", 4*time.Minute, 20)
+
+	if len(r.written) != written+2 {
+		t.Fatalf("expected sample and a single rollup, got %d samples", len(r.written)-written)
+	}
+
+	if cpu := intMetrics(r.written[written+1])["cpu.total"]; cpu != 20 {
+		t.Errorf("expected myapp rollup cpu.total to be 20, got %d", cpu)
+	}
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+type fakeAMQPConn struct {
+	closed bool
+}
+
+func (c *fakeAMQPConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+type fakeAMQPChannel struct {
+	published []amqp.Publishing
+	keys      []string
+	notify    chan *amqp.Error
+	err       error
+}
+
+func (c *fakeAMQPChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.keys = append(c.keys, key)
+	c.published = append(c.published, msg)
+
+	return nil
+}
+
+func (c *fakeAMQPChannel) NotifyClose(ch chan *amqp.Error) chan *amqp.Error {
+	c.notify = ch
+	return ch
+}
+
+func (c *fakeAMQPChannel) Close() error {
+	return nil
+}
+
+// fakeAMQPDialer hands out new fake connection and channel on every dial
+type fakeAMQPDialer struct {
+	conns    []*fakeAMQPConn
+	channels []*fakeAMQPChannel
+}
+
+func (d *fakeAMQPDialer) dial(url string) (io.Closer, amqpChannel, error) {
+	conn := &fakeAMQPConn{}
+	channel := &fakeAMQPChannel{}
+
+	d.conns = append(d.conns, conn)
+	d.channels = append(d.channels, channel)
+
+	return conn, channel, nil
+}
+
+func TestAMQPWriter(t *testing.T) {
+	d := &fakeAMQPDialer{}
+	w, err := newAMQPWriter("myhost", "amqp://test", "docker_stats", "{host}.{app}.{task}", d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	s.CPU.Total = 42
+
+	err = w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	c := d.channels[0]
+	if len(c.published) != 1 || c.keys[0] != "myhost.myapp.mytask" {
+		t.Fatalf("expected single message with expanded routing key, got %v", c.keys)
+	}
+
+	m := c.published[0]
+	if !m.Timestamp.Equal(s.Time) || m.ContentType != "application/json" {
+		t.Errorf("expected json message stamped with sample time, got %s at %s", m.ContentType, m.Timestamp)
+	}
+
+	sample := jsonSample{}
+	err = json.Unmarshal(m.Body, &sample)
+	if err != nil {
+		t.Fatalf("error decoding message: %s", err)
+	}
+
+	if sample.Host != "myhost" || sample.Metrics["cpu.total"] != 42 {
+		t.Errorf("unexpected sample: %#v", sample)
+	}
+}
+
+func TestAMQPWriterRedial(t *testing.T) {
+	d := &fakeAMQPDialer{}
+	w, err := newAMQPWriter("myhost", "amqp://test", "docker_stats", "{app}", d.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+
+	// channel closed by server is replaced on the next write
+	d.channels[0].notify <- amqp.ErrClosed
+
+	err = w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(d.channels) != 2 || !d.conns[0].closed || len(d.channels[1].published) != 1 {
+		t.Fatalf("expected closed channel to be replaced, got %d dials", len(d.channels))
+	}
+
+	// failed publish drops connection, next write connects again
+	d.channels[1].err = errors.New("connection reset")
+
+	if w.Write(s) == nil {
+		t.Fatal("expected publish error")
+	}
+
+	if !d.conns[1].closed {
+		t.Errorf("expected connection to be closed after publish error")
+	}
+
+	err = w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(d.channels) != 3 || len(d.channels[2].published) != 1 {
+		t.Errorf("expected write after publish error to connect again, got %d dials", len(d.channels))
+	}
+
+	w.Close()
+
+	if !d.conns[2].closed {
+		t.Errorf("expected connection to be closed on close")
+	}
+}
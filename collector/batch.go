@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchWriter is responsible for buffering samples and writing them
+// to wrapped writer in batches, batch is written and wrapped writer
+// is flushed when batch is full or when flush interval passes
+type BatchWriter struct {
+	writer Writer
+	size   int
+
+	mutex sync.Mutex
+	batch []Stats
+
+	// writeMutex serializes access to wrapped writer
+	writeMutex sync.Mutex
+
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// NewBatchWriter creates new BatchWriter on top of specified writer with
+// max number of samples in a batch and flush interval, zero interval
+// disables time based flushing and batch size of 1 flushes every sample
+func NewBatchWriter(writer Writer, size int, interval time.Duration) *BatchWriter {
+	if size < 1 {
+		size = 1
+	}
+
+	w := &BatchWriter{
+		writer: writer,
+		size:   size,
+		batch:  make([]Stats, 0, size),
+		done:   make(chan struct{}),
+	}
+
+	if interval > 0 {
+		w.ticker = time.NewTicker(interval)
+		go w.flushLoop()
+	}
+
+	return w
+}
+
+func (w *BatchWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	w.batch = append(w.batch, s)
+	full := len(w.batch) >= w.size
+	w.mutex.Unlock()
+
+	if !full {
+		return nil
+	}
+
+	return w.Flush()
+}
+
+// Notify writes notification to wrapped writer right away,
+// notifications are not batched
+func (w *BatchWriter) Notify(n Notification) error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	return Notify(w.writer, n)
+}
+
+// Flush writes buffered samples to wrapped writer and flushes it,
+// the first write error is returned and the rest of batch is dropped
+func (w *BatchWriter) Flush() error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	w.mutex.Lock()
+	batch := w.batch
+	w.batch = make([]Stats, 0, w.size)
+	w.mutex.Unlock()
+
+	for _, s := range batch {
+		err := w.writer.Write(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.writer.Flush()
+}
+
+// Close stops periodic flushing, writes buffered
+// samples and closes wrapped writer
+func (w *BatchWriter) Close() error {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		close(w.done)
+	}
+
+	err := w.Flush()
+
+	cerr := w.writer.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (w *BatchWriter) flushLoop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			err := w.Flush()
+			if err != nil {
+				errorf("error flushing batch with %T: %s", w.writer, err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
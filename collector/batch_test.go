@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+type recordingWriter struct {
+	written  []Stats
+	notified []Notification
+	flushes  int
+}
+
+func (w *recordingWriter) Write(s Stats) error {
+	w.written = append(w.written, s)
+	return nil
+}
+
+func (w *recordingWriter) Notify(n Notification) error {
+	w.notified = append(w.notified, n)
+	return nil
+}
+
+func (w *recordingWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func (w *recordingWriter) Close() error {
+	return nil
+}
+
+func TestBatchWriterSize(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewBatchWriter(r, 3, 0)
+
+	for i := 0; i < 5; i++ {
+		w.Write(Stats{App: "myapp"})
+	}
+
+	if len(r.written) != 3 || r.flushes != 1 {
+		t.Errorf("expected single flushed batch of 3 samples, got %d samples and %d flushes", len(r.written), r.flushes)
+	}
+
+	w.Close()
+
+	if len(r.written) != 5 || r.flushes != 2 {
+		t.Errorf("expected remaining samples to be flushed on close, got %d samples and %d flushes", len(r.written), r.flushes)
+	}
+}
+
+func TestBatchWriterInterval(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewBatchWriter(r, 100, 10*time.Millisecond)
+
+	w.Write(Stats{App: "myapp"})
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.writeMutex.Lock()
+	written := len(r.written)
+	w.writeMutex.Unlock()
+
+	if written != 1 {
+		t.Errorf("expected incomplete batch to be flushed by interval, got %d samples", written)
+	}
+
+	w.Close()
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// containerInspector is the part of docker client that inspects containers
+type containerInspector interface {
+	InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error)
+}
+
+// inspectEntry is a cached result of inspecting container
+type inspectEntry struct {
+	container *docker.Container
+	expires   time.Time
+}
+
+// inspectCache caches results of inspecting containers by id for ttl,
+// so repeated identity lookups don't hit docker, errors are not cached
+type inspectCache struct {
+	inspector containerInspector
+	ttl       time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]inspectEntry
+	pruned  time.Time
+}
+
+// newInspectCache creates inspectCache on top of
+// specified inspector with ttl of cached results
+func newInspectCache(inspector containerInspector, ttl time.Duration) *inspectCache {
+	return &inspectCache{
+		inspector: inspector,
+		ttl:       ttl,
+		entries:   map[string]inspectEntry{},
+	}
+}
+
+func (c *inspectCache) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	entry, ok := c.entries[id]
+	c.mutex.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.container, nil
+	}
+
+	container, err := c.inspector.InspectContainerWithContext(id, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// containers that are not started yet are inspected
+	// again, so retries of monitors see them start
+	if !containerStarted(container) {
+		return container, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[id] = inspectEntry{container: container, expires: now.Add(c.ttl)}
+	c.prune(now)
+
+	return container, nil
+}
+
+// evict forgets result of inspecting container with id
+func (c *inspectCache) evict(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, id)
+}
+
+// prune forgets expired results
+func (c *inspectCache) prune(now time.Time) {
+	if now.Sub(c.pruned) < c.ttl {
+		return
+	}
+
+	c.pruned = now
+
+	for id, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+type countingInspector struct {
+	calls int
+}
+
+func (i *countingInspector) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	i.calls++
+	return &docker.Container{ID: id}, nil
+}
+
+func TestInspectCache(t *testing.T) {
+	i := &countingInspector{}
+	c := newInspectCache(i, 50*time.Millisecond)
+
+	for n := 0; n < 3; n++ {
+		container, err := c.InspectContainerWithContext("abcdef", context.Background())
+		if err != nil {
+			t.Fatalf("error inspecting container: %s", err)
+		}
+
+		if container.ID != "abcdef" {
+			t.Errorf("expected container abcdef, got %s", container.ID)
+		}
+	}
+
+	if i.calls != 1 {
+		t.Errorf("expected single inspect call, got %d", i.calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err := c.InspectContainerWithContext("abcdef", context.Background())
+	if err != nil {
+		t.Fatalf("error inspecting container: %s", err)
+	}
+
+	if i.calls != 2 {
+		t.Errorf("expected expired result to be inspected again, got %d calls", i.calls)
+	}
+
+	c.evict("abcdef")
+
+	_, err = c.InspectContainerWithContext("abcdef", context.Background())
+	if err != nil {
+		t.Fatalf("error inspecting container: %s", err)
+	}
+
+	if i.calls != 3 {
+		t.Errorf("expected evicted result to be inspected again, got %d calls", i.calls)
+	}
+}
@@ -0,0 +1,83 @@
+package collector
+
+// cadvisorMetricNames are names of container metrics used by storage
+// drivers of cadvisor, like influxdb and statsd, units of values match
+var cadvisorMetricNames = map[string]string{
+	"cpu.total":  "cpu_usage_total",
+	"cpu.system": "cpu_usage_system",
+	"cpu.user":   "cpu_usage_user",
+
+	"memory.usage": "memory_usage",
+
+	"net.rx_bytes":  "rx_bytes",
+	"net.rx_errors": "rx_errors",
+	"net.tx_bytes":  "tx_bytes",
+	"net.tx_errors": "tx_errors",
+}
+
+// CAdvisorWriter is responsible for renaming container metrics to names
+// that cadvisor uses before they reach wrapped writer, so dashboards
+// built for cadvisor work with metrics of collector, container metrics
+// that cadvisor doesn't have are dropped and the rest are passed as is
+type CAdvisorWriter struct {
+	writer Writer
+}
+
+// NewCAdvisorWriter creates new CAdvisorWriter on top of specified writer
+func NewCAdvisorWriter(writer Writer) CAdvisorWriter {
+	return CAdvisorWriter{writer: writer}
+}
+
+// Write passes sample with renamed metrics to wrapped writer, working
+// set of memory is added like cadvisor calculates it: usage without
+// inactive file cache that kernel can reclaim first, samples carrying
+// metrics only, like rates and rollups, are renamed the same way
+func (w CAdvisorWriter) Write(s Stats) error {
+	current := intMetrics(s)
+
+	metrics := make(map[string]uint64, len(current))
+	for k, v := range current {
+		if name, ok := cadvisorMetricNames[k]; ok {
+			metrics[name] = v
+		} else if !isContainerMetric(k) {
+			metrics[k] = v
+		}
+	}
+
+	if usage, ok := current["memory.usage"]; ok {
+		workingSet := uint64(0)
+		if inactive := current["memory.inactive_file"]; inactive < usage {
+			workingSet = usage - inactive
+		}
+
+		metrics["memory_working_set"] = workingSet
+	}
+
+	s.Metrics = metrics
+	s.MetricsOnly = true
+
+	return w.writer.Write(s)
+}
+
+func (w CAdvisorWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w CAdvisorWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w CAdvisorWriter) Close() error {
+	return w.writer.Close()
+}
+
+// isContainerMetric returns whether metric comes from docker stats
+func isContainerMetric(name string) bool {
+	for _, n := range containerMetricNames {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCAdvisorWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewCAdvisorWriter(r)
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.CPU.Total = 42
+	s.Memory.Usage = 100
+	s.Memory.InactiveFile = 30
+	s.Network.RxBytes = 7
+	s.Metrics = map[string]uint64{"custom": 3}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(r.written) != 1 {
+		t.Fatalf("expected 1 written sample, got %d", len(r.written))
+	}
+
+	metrics := intMetrics(r.written[0])
+
+	expected := map[string]uint64{
+		"cpu_usage_total":    42,
+		"memory_usage":       100,
+		"memory_working_set": 70,
+		"rx_bytes":           7,
+		"custom":             3,
+	}
+
+	for k, v := range expected {
+		if metrics[k] != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	for _, name := range []string{"cpu.total", "memory.rss", "net.rx_bytes"} {
+		if _, ok := metrics[name]; ok {
+			t.Errorf("unexpected metric %s in %v", name, metrics)
+		}
+	}
+}
+
+func TestCAdvisorWriterPipeline(t *testing.T) {
+	r := &recordingWriter{}
+
+	// rates and families run before renaming in the pipeline
+	w := NewRateWriter(NewFamilyWriter(NewCAdvisorWriter(r), []string{"net"}))
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 1000
+	s.Memory.Usage = 100
+	s.Memory.InactiveFile = 30
+	s.Network.RxBytes = 7
+
+	next := s
+	next.Time = s.Time.Add(10 * time.Second)
+	next.CPU.Total = 2000
+
+	for _, s := range []Stats{s, next} {
+		if err := w.Write(s); err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	if len(r.written) != 2 {
+		t.Fatalf("expected 2 written samples, got %d", len(r.written))
+	}
+
+	metrics := intMetrics(r.written[1])
+
+	expected := map[string]uint64{
+		"cpu_usage_total":    100,
+		"memory_usage":       100,
+		"memory_working_set": 70,
+	}
+
+	for k, v := range expected {
+		if metrics[k] != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	for _, name := range []string{"cpu.total", "memory.usage", "rx_bytes"} {
+		if _, ok := metrics[name]; ok {
+			t.Errorf("unexpected metric %s in %v", name, metrics)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// overflowTask is the task that samples of tasks over limits are written as
+const overflowTask = "_overflow"
+
+// cardinalityTTL is how long tasks are remembered after their last
+// sample, so tasks of containers that are gone free their slots
+const cardinalityTTL = 15 * time.Minute
+
+// cardinalityTask is a task admitted by CardinalityWriter
+type cardinalityTask struct {
+	series  int
+	written time.Time
+}
+
+// CardinalityWriter is responsible for capping the number of distinct
+// tasks of every app and the total number of series, samples of tasks
+// that don't fit are written as _overflow task of their app, so churn
+// of containers can't flood the backend with series
+type CardinalityWriter struct {
+	writer    Writer
+	maxTasks  int
+	maxSeries int
+
+	mutex  sync.Mutex
+	tasks  map[string]map[string]*cardinalityTask
+	series int
+	warned map[string]bool
+	pruned time.Time
+}
+
+// NewCardinalityWriter creates new CardinalityWriter on top of specified
+// writer with max number of tasks per app and max number of series of
+// all tasks, 0 disables either limit
+func NewCardinalityWriter(writer Writer, maxTasks int, maxSeries int) *CardinalityWriter {
+	return &CardinalityWriter{
+		writer:    writer,
+		maxTasks:  maxTasks,
+		maxSeries: maxSeries,
+		tasks:     map[string]map[string]*cardinalityTask{},
+		warned:    map[string]bool{},
+	}
+}
+
+// Write passes sample to wrapped writer, sample of a task over limits
+// is written as _overflow task and counted in self metrics
+func (w *CardinalityWriter) Write(s Stats) error {
+	if s.App == selfApp {
+		return w.writer.Write(s)
+	}
+
+	w.mutex.Lock()
+	w.prune(s.Time)
+	admitted := w.admit(s.App, s.Task, s.Time, len(intMetrics(s)))
+	w.mutex.Unlock()
+
+	if !admitted {
+		atomic.AddUint64(&selfCounters.overflowSamples, 1)
+		s.Task = overflowTask
+	}
+
+	return w.writer.Write(s)
+}
+
+// Notify passes notification to wrapped writer, notifications
+// of tasks over limits are written as _overflow task
+func (w *CardinalityWriter) Notify(n Notification) error {
+	w.mutex.Lock()
+	_, ok := w.tasks[n.App][n.Task]
+	overflow := !ok && w.warned[n.App]
+	w.mutex.Unlock()
+
+	if overflow {
+		n.Task = overflowTask
+	}
+
+	return Notify(w.writer, n)
+}
+
+func (w *CardinalityWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *CardinalityWriter) Close() error {
+	return w.writer.Close()
+}
+
+// admit returns whether task of app with number of series is within
+// limits, tasks are admitted in order they are first seen, series of
+// admitted tasks are tracked as their highest number of metrics
+func (w *CardinalityWriter) admit(app string, task string, t time.Time, series int) bool {
+	tasks := w.tasks[app]
+	if tasks == nil {
+		tasks = map[string]*cardinalityTask{}
+		w.tasks[app] = tasks
+	}
+
+	if known, ok := tasks[task]; ok {
+		known.written = t
+		if series > known.series {
+			w.series += series - known.series
+			known.series = series
+		}
+
+		return true
+	}
+
+	reason := ""
+	switch {
+	case w.maxTasks > 0 && len(tasks) >= w.maxTasks:
+		reason = "it has too many tasks"
+	case w.maxSeries > 0 && w.series+series > w.maxSeries:
+		reason = "there are too many series"
+	default:
+		tasks[task] = &cardinalityTask{series: series, written: t}
+		w.series += series
+		return true
+	}
+
+	if !w.warned[app] {
+		w.warned[app] = true
+		LogFields{"app": app, "task": task}.Logf(LogWarn, "writing new tasks of app as %s task, %s", overflowTask, reason)
+	}
+
+	return false
+}
+
+// prune forgets tasks that weren't written for cardinality ttl
+// every ttl, so their slots and series can be taken by new tasks
+func (w *CardinalityWriter) prune(t time.Time) {
+	if t.Sub(w.pruned) < cardinalityTTL {
+		return
+	}
+
+	w.pruned = t
+
+	for app, tasks := range w.tasks {
+		for task, known := range tasks {
+			if t.Sub(known.written) >= cardinalityTTL {
+				w.series -= known.series
+				delete(tasks, task)
+			}
+		}
+
+		if len(tasks) == 0 {
+			delete(w.tasks, app)
+			delete(w.warned, app)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCardinalityWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewCardinalityWriter(r, 2, 5)
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(offset time.Duration, app, task string, n int) string {
+		s := Stats{App: app, Task: task, MetricsOnly: true, Metrics: map[string]uint64{}}
+		s.Time = start.Add(offset)
+		for i := 0; i < n; i++ {
+			s.Metrics[string(rune('a'+i))] = 1
+		}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+
+		return r.written[len(r.written)-1].Task
+	}
+
+	cases := []struct {
+		app      string
+		task     string
+		series   int
+		expected string
+	}{
+		{"web", "1", 1, "1"},
+		{"web", "2", 1, "2"},
+		// web has 2 tasks already
+		{"web", "3", 1, overflowTask},
+		// known tasks are written even with more series
+		{"web", "1", 2, "1"},
+		// 3 series are taken, 3 more don't fit
+		{"db", "1", 3, overflowTask},
+		{"db", "1", 2, "1"},
+		{selfApp, selfTask, 10, selfTask},
+	}
+
+	for i, c := range cases {
+		if task := write(0, c.app, c.task, c.series); task != c.expected {
+			t.Errorf("case %d: expected %s/%s to be written as task %s, got %s", i, c.app, c.task, c.expected, task)
+		}
+	}
+
+	err := w.Notify(Notification{App: "web", Task: "3"})
+	if err != nil {
+		t.Fatalf("error writing notification: %s", err)
+	}
+
+	if task := r.notified[0].Task; task != overflowTask {
+		t.Errorf("expected notification of overflowing task to be written as %s, got %s", overflowTask, task)
+	}
+
+	// tasks that are gone free their slots
+	write(cardinalityTTL, "web", "2", 1)
+	if task := write(cardinalityTTL, "web", "3", 1); task != "3" {
+		t.Errorf("expected new task to be written after old tasks are gone, got %s", task)
+	}
+}
+
+func TestCardinalityWriterCAdvisor(t *testing.T) {
+	r := &recordingWriter{}
+
+	// series are counted after renaming, like in the pipeline
+	w := NewCAdvisorWriter(NewCardinalityWriter(r, 0, len(cadvisorMetricNames)+1))
+
+	for _, task := range []string{"1", "2"} {
+		s := Stats{App: "web", Task: task, Time: time.Unix(1431000000, 0)}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	if task := r.written[0].Task; task != "1" {
+		t.Errorf("expected renamed series of the first task to fit, got task %s", task)
+	}
+
+	if task := r.written[1].Task; task != overflowTask {
+		t.Errorf("expected the second task to overflow, got task %s", task)
+	}
+
+	if _, ok := r.written[1].Metrics["cpu_usage_total"]; !ok {
+		t.Errorf("expected overflowing task to have cadvisor names, got %v", r.written[1].Metrics)
+	}
+}
@@ -0,0 +1,271 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// daemonProcesses are commands of processes of docker daemon whose
+// usage is reported, old docker releases named containerd differently
+var daemonProcesses = []string{"dockerd", "containerd", "docker-containerd"}
+
+// errNoProcess is returned when process with command is not running
+var errNoProcess = errors.New("process is not running")
+
+// SetDaemonUsage enables reporting of cpu and memory usage of docker
+// daemon processes every interval, usage is read from cgroups of processes
+// under proc and cgroup mounts, like /proc and /sys/fs/cgroup, empty proc
+// disables it, it should be called before Run
+func (c *Collector) SetDaemonUsage(proc string, cgroup string) {
+	c.daemonProc = proc
+	c.daemonCgroup = cgroup
+}
+
+// reportDaemonUsage periodically sends usage of docker daemon processes
+// as metrics of _docker app with command as task, failures are logged
+// once per process, processes that are not running are skipped
+func (c *Collector) reportDaemonUsage(ctx context.Context) {
+	c.mutex.Lock()
+	interval := c.interval
+	c.mutex.Unlock()
+
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	warned := map[string]bool{}
+
+	every(ctx, interval, func(t time.Time) {
+		for _, command := range daemonProcesses {
+			metrics, err := daemonUsage(c.daemonProc, c.daemonCgroup, command)
+			if err == errNoProcess {
+				continue
+			}
+
+			if err != nil {
+				if !warned[command] {
+					warned[command] = true
+					Logf(LogWarn, "error reading usage of %s: %s", command, err)
+				}
+
+				continue
+			}
+
+			c.send(Stats{App: daemonApp, Task: command, Time: t, Metrics: metrics, MetricsOnly: true})
+		}
+	})
+}
+
+// daemonUsage returns cpu and memory usage of cgroup of process
+// with command, metrics are named like metrics of containers
+func daemonUsage(proc string, cgroup string, command string) (map[string]uint64, error) {
+	pid, err := findProcess(proc, command)
+	if err != nil {
+		return nil, err
+	}
+
+	return processUsage(proc, cgroup, pid)
+}
+
+// processUsage returns cpu and memory usage of cgroup of process with pid
+func processUsage(proc string, cgroup string, pid string) (map[string]uint64, error) {
+	paths, err := processCgroups(filepath.Join(proc, pid, "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+
+	// cgroup v2 has a single hierarchy without controllers, hybrid
+	// setups have it next to v1 hierarchies, which have the usage
+	if path, ok := paths[""]; ok && len(paths) == 1 {
+		if path == "/" {
+			return nil, errRootCgroup
+		}
+
+		return cgroupV2Usage(filepath.Join(cgroup, path))
+	}
+
+	return cgroupV1Usage(cgroup, paths)
+}
+
+// findProcess returns pid of the oldest process with command
+func findProcess(proc string, command string) (string, error) {
+	entries, err := ioutil.ReadDir(proc)
+	if err != nil {
+		return "", err
+	}
+
+	oldest := 0
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || (oldest != 0 && pid > oldest) {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(proc, entry.Name(), "comm"))
+		if err == nil && strings.TrimSpace(string(b)) == command {
+			oldest = pid
+		}
+	}
+
+	if oldest == 0 {
+		return "", errNoProcess
+	}
+
+	return strconv.Itoa(oldest), nil
+}
+
+// processCgroups parses /proc/<pid>/cgroup into paths
+// of cgroups keyed by controllers, like cpu,cpuacct
+func processCgroups(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	paths := map[string]string{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		paths[parts[1]] = parts[2]
+	}
+
+	return paths, scanner.Err()
+}
+
+// errRootCgroup is returned for processes in root cgroup,
+// since usage of root cgroup is usage of the whole host
+var errRootCgroup = errors.New("process is in root cgroup, run docker as systemd service to have its own cgroup")
+
+// cgroupV2Usage reads usage of cgroup of unified hierarchy
+func cgroupV2Usage(dir string) (map[string]uint64, error) {
+	stat, err := readCgroupStat(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := readCgroupValue(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]uint64{
+		"cpu.total":    stat["usage_usec"] * uint64(time.Microsecond),
+		"cpu.user":     stat["user_usec"] * uint64(time.Microsecond),
+		"cpu.system":   stat["system_usec"] * uint64(time.Microsecond),
+		"memory.usage": memory,
+	}, nil
+}
+
+// userHZ is the unit of cpu time in cpuacct.stat
+const userHZ = 100
+
+// cgroupV1Usage reads usage of cgroups of cpuacct and memory controllers
+func cgroupV1Usage(root string, paths map[string]string) (map[string]uint64, error) {
+	cpuacct, err := controllerDir(root, paths, "cpuacct")
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := controllerDir(root, paths, "memory")
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := readCgroupValue(filepath.Join(cpuacct, "cpuacct.usage"))
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := readCgroupStat(filepath.Join(cpuacct, "cpuacct.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := readCgroupValue(filepath.Join(memory, "memory.usage_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]uint64{
+		"cpu.total":    total,
+		"cpu.user":     stat["user"] * uint64(time.Second/userHZ),
+		"cpu.system":   stat["system"] * uint64(time.Second/userHZ),
+		"memory.usage": usage,
+	}, nil
+}
+
+// controllerDir returns directory of cgroup of controller, controllers
+// mounted together like cpu,cpuacct are in directory named after all
+// of them, which often has symlinks named after every controller
+func controllerDir(root string, paths map[string]string, controller string) (string, error) {
+	for controllers, path := range paths {
+		for _, c := range strings.Split(controllers, ",") {
+			if c != controller {
+				continue
+			}
+
+			if path == "/" {
+				return "", errRootCgroup
+			}
+
+			for _, mount := range []string{controllers, controller} {
+				dir := filepath.Join(root, mount, path)
+				if _, err := os.Stat(dir); err == nil {
+					return dir, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no cgroup of %s controller under %s", controller, root)
+}
+
+// readCgroupValue reads file with a single number
+func readCgroupValue(file string) (uint64, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readCgroupStat reads file with lines of key and number
+func readCgroupStat(file string) (map[string]uint64, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := map[string]uint64{}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in %s: %q", file, line)
+		}
+
+		stat[fields[0]] = value
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files with contents under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("error creating directory: %s", err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("error writing file: %s", err)
+		}
+	}
+}
+
+func TestDaemonUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"proc/1/comm":     "systemd\n",
+		"proc/1/cgroup":   "0::/init.scope\n",
+		"proc/812/comm":   "dockerd\n",
+		"proc/812/cgroup": "0::/system.slice/docker.service\n",
+		"proc/640/comm":   "containerd\n",
+		"proc/640/cgroup": "12:memory:/system.slice/containerd.service\n4:cpu,cpuacct:/system.slice/containerd.service\n2:net_cls,net_prio:/\n0::/system.slice/containerd.service\n",
+		"proc/700/comm":   "docker-containerd\n",
+		"proc/700/cgroup": "0::/\n",
+
+		"cgroup/system.slice/docker.service/cpu.stat":       "usage_usec 3000\nuser_usec 2000\nsystem_usec 1000\n",
+		"cgroup/system.slice/docker.service/memory.current": "104857600\n",
+
+		"cgroup/cpu,cpuacct/system.slice/containerd.service/cpuacct.usage":    "5000000000\n",
+		"cgroup/cpu,cpuacct/system.slice/containerd.service/cpuacct.stat":     "user 300\nsystem 150\n",
+		"cgroup/memory/system.slice/containerd.service/memory.usage_in_bytes": "52428800\n",
+	})
+
+	proc, cgroup := filepath.Join(dir, "proc"), filepath.Join(dir, "cgroup")
+
+	expected := map[string]map[string]uint64{
+		"dockerd": {
+			"cpu.total":    3000000,
+			"cpu.user":     2000000,
+			"cpu.system":   1000000,
+			"memory.usage": 104857600,
+		},
+		"containerd": {
+			"cpu.total":    5000000000,
+			"cpu.user":     3000000000,
+			"cpu.system":   1500000000,
+			"memory.usage": 52428800,
+		},
+	}
+
+	for command, want := range expected {
+		metrics, err := daemonUsage(proc, cgroup, command)
+		if err != nil {
+			t.Fatalf("error reading usage of %s: %s", command, err)
+		}
+
+		for k, v := range want {
+			if value, ok := metrics[k]; !ok || value != v {
+				t.Errorf("expected %s of %s to be %d, got %v", k, command, v, metrics)
+			}
+		}
+	}
+
+	if _, err := daemonUsage(proc, cgroup, "docker-containerd"); err != errRootCgroup {
+		t.Errorf("expected root cgroup to be refused, got %v", err)
+	}
+
+	if _, err := daemonUsage(proc, cgroup, "podman"); err != errNoProcess {
+		t.Errorf("expected missing process to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// chronosJob is the part of chronos job definition used for metadata
+type chronosJob struct {
+	Name      string `json:"name"`
+	Owner     string `json:"owner"`
+	OwnerName string `json:"ownerName"`
+	Schedule  string `json:"schedule"`
+}
+
+// chronosEntry is cached metadata of a job, nil metadata
+// is cached when chronos doesn't know the job
+type chronosEntry struct {
+	meta    map[string]string
+	expires time.Time
+}
+
+// ChronosAPIExtractor finds metadata of chronos jobs for containers with
+// CHRONOS_JOB_NAME in job definitions from chronos REST API, owner,
+// owner_name and schedule of job become metadata of samples, so usage
+// of batch jobs can be reported per team, metadata is cached for ttl
+type ChronosAPIExtractor struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mutex   sync.Mutex
+	entries map[string]chronosEntry
+}
+
+// NewChronosAPIExtractor creates extractor for chronos at specified url
+func NewChronosAPIExtractor(url string, ttl time.Duration) *ChronosAPIExtractor {
+	return &ChronosAPIExtractor{
+		url:     strings.TrimSuffix(url, "/"),
+		ttl:     ttl,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		entries: map[string]chronosEntry{},
+	}
+}
+
+// Metadata returns metadata of chronos job, containers that are not
+// chronos jobs and jobs that can't be fetched have no metadata
+func (e *ChronosAPIExtractor) Metadata(c *docker.Container) map[string]string {
+	name := extractEnv(c, "CHRONOS_JOB_NAME")
+	if name == "" {
+		return nil
+	}
+
+	now := time.Now()
+
+	e.mutex.Lock()
+	entry, ok := e.entries[name]
+	e.mutex.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.meta
+	}
+
+	job, err := e.fetch(name)
+	if err != nil {
+		containerFields(c.ID, "", "").Logf(LogDebug, "error getting chronos job %s: %s", name, err)
+		return nil
+	}
+
+	var meta map[string]string
+	if job != nil {
+		meta = map[string]string{}
+		for k, v := range map[string]string{"owner": job.Owner, "owner_name": job.OwnerName, "schedule": job.Schedule} {
+			if v != "" {
+				meta[k] = v
+			}
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// entries of jobs that are gone are dropped on the way
+	for k, v := range e.entries {
+		if !now.Before(v.expires) {
+			delete(e.entries, k)
+		}
+	}
+
+	e.entries[name] = chronosEntry{meta: meta, expires: now.Add(e.ttl)}
+
+	return meta
+}
+
+// fetch finds job definition in chronos,
+// nil is returned if chronos doesn't know the job
+func (e *ChronosAPIExtractor) fetch(name string) (*chronosJob, error) {
+	resp, err := e.client.Get(e.url + "/v1/scheduler/jobs/search?name=" + url.QueryEscape(name))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	jobs := []chronosJob{}
+
+	err = json.NewDecoder(resp.Body).Decode(&jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	// search matches substrings of names
+	for _, job := range jobs {
+		if job.Name == name {
+			return &job, nil
+		}
+	}
+
+	return nil, nil
+}
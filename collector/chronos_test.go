@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestChronosAPIExtractor(t *testing.T) {
+	requests := int32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.URL.Path != "/v1/scheduler/jobs/search" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.URL.Query().Get("name") {
+		case "report":
+			w.Write([]byte(`[
+				{"name":"report-daily","owner":"other@example.com"},
+				{"name":"report","owner":"data@example.com","ownerName":"data","schedule":"R/2015-01-01T00:00:00Z/PT24H"}
+			]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+
+	defer server.Close()
+
+	tests := []struct {
+		env  []string
+		meta map[string]string
+	}{
+		{
+			env:  []string{"CHRONOS_JOB_NAME=report"},
+			meta: map[string]string{"owner": "data@example.com", "owner_name": "data", "schedule": "R/2015-01-01T00:00:00Z/PT24H"},
+		},
+		{
+			env: []string{"CHRONOS_JOB_NAME=unknown"},
+		},
+		{
+			env: []string{"MARATHON_APP_ID=/web"},
+		},
+	}
+
+	extractor := NewChronosAPIExtractor(server.URL, time.Minute)
+
+	for _, test := range tests {
+		c := &docker.Container{Config: &docker.Config{Env: test.env}}
+
+		for i := 0; i < 2; i++ {
+			if meta := extractor.Metadata(c); !reflect.DeepEqual(meta, test.meta) {
+				t.Errorf("expected metadata %v for %v, got %v", test.meta, test.env, meta)
+			}
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("expected metadata of 2 jobs to be fetched once, got %d requests", requests)
+	}
+}
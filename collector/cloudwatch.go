@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// cloudWatchMaxBatch is max number of metrics in a single PutMetricData call
+const cloudWatchMaxBatch = 1000
+
+// cloudWatchClient is the part of cloudwatch client that puts metrics
+type cloudWatchClient interface {
+	PutMetricDataWithContext(ctx aws.Context, input *cloudwatch.PutMetricDataInput, opts ...request.Option) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// CloudWatchWriter is responsible for pushing data to aws cloudwatch
+// with host, app and task dimensions, metrics of samples are buffered
+// and sent in batches on flush, every interval and once there is a full
+// batch, PutMetricData calls are rate limited
+type CloudWatchWriter struct {
+	host      string
+	namespace string
+	timeout   time.Duration
+	client    cloudWatchClient
+	limiter   *time.Ticker
+	ticker    *time.Ticker
+	done      chan struct{}
+
+	mutex   sync.Mutex
+	pending []*cloudwatch.MetricDatum
+}
+
+// NewCloudWatchWriter creates new CloudWatchWriter with specified hostname,
+// aws region, cloudwatch namespace, max rate of PutMetricData calls per
+// second, timeout of calls and interval of sending buffered metrics,
+// credentials are taken from the default aws credentials chain
+func NewCloudWatchWriter(host string, region string, namespace string, rate int, timeout time.Duration, interval time.Duration) (*CloudWatchWriter, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	return newCloudWatchWriter(host, namespace, cloudwatch.New(sess), rate, timeout, interval), nil
+}
+
+func newCloudWatchWriter(host string, namespace string, client cloudWatchClient, rate int, timeout time.Duration, interval time.Duration) *CloudWatchWriter {
+	if rate < 1 {
+		rate = 1
+	}
+
+	w := &CloudWatchWriter{
+		host:      host,
+		namespace: namespace,
+		timeout:   timeout,
+		client:    client,
+		limiter:   time.NewTicker(time.Second / time.Duration(rate)),
+		done:      make(chan struct{}),
+	}
+
+	if interval > 0 {
+		w.ticker = time.NewTicker(interval)
+		go w.flushLoop()
+	}
+
+	return w
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "cloudwatch",
+		Usage: "aws cloudwatch metrics",
+		Options: []WriterOption{
+			{Name: "region", Default: "us-east-1", Usage: "aws region for cloudwatch"},
+			{Name: "namespace", Default: "Docker", Usage: "cloudwatch metrics namespace"},
+			{Name: "rate", Default: "10", Usage: "max PutMetricData calls per second"},
+			{Name: "timeout", Default: "10s", Usage: "timeout of PutMetricData calls"},
+			{Name: "interval", Default: "10s", Usage: "interval of sending buffered metrics, 0 to send only full batches and on flush"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			rate := p.int("rate")
+			timeout := p.duration("timeout")
+			interval := p.duration("interval")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewCloudWatchWriter(host, p.string("region"), p.string("namespace"), rate, timeout, interval)
+		},
+	})
+}
+
+// Write buffers metrics of sample, full batches are sent right away
+func (w *CloudWatchWriter) Write(s Stats) error {
+	dimensions := []*cloudwatch.Dimension{
+		{Name: aws.String("Host"), Value: aws.String(w.host)},
+		{Name: aws.String("App"), Value: aws.String(s.App)},
+		{Name: aws.String("Task"), Value: aws.String(s.Task)},
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for k, v := range intMetrics(s) {
+		w.pending = append(w.pending, &cloudwatch.MetricDatum{
+			MetricName: aws.String(k),
+			Dimensions: dimensions,
+			Timestamp:  aws.Time(s.Time),
+			Value:      aws.Float64(float64(v)),
+		})
+	}
+
+	for len(w.pending) >= cloudWatchMaxBatch {
+		err := w.putNext()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush sends buffered metrics in batches, batch that failed
+// to be sent is dropped, the rest is sent on the next flush
+func (w *CloudWatchWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for len(w.pending) > 0 {
+		err := w.putNext()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close sends buffered metrics and stops timers of the writer
+func (w *CloudWatchWriter) Close() error {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		close(w.done)
+	}
+
+	err := w.Flush()
+
+	w.limiter.Stop()
+
+	return err
+}
+
+func (w *CloudWatchWriter) flushLoop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			err := w.Flush()
+			if err != nil {
+				errorf("error sending metrics to cloudwatch: %s", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// putNext sends the next batch of buffered metrics,
+// it is called with mutex held
+func (w *CloudWatchWriter) putNext() error {
+	n := len(w.pending)
+	if n > cloudWatchMaxBatch {
+		n = cloudWatchMaxBatch
+	}
+
+	batch := w.pending[:n]
+	w.pending = w.pending[n:]
+
+	if len(w.pending) == 0 {
+		w.pending = nil
+	}
+
+	<-w.limiter.C
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	_, err := w.client.PutMetricDataWithContext(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(w.namespace),
+		MetricData: batch,
+	})
+
+	return err
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+type fakeCloudWatch struct {
+	batches []int
+	fail    bool
+}
+
+func (c *fakeCloudWatch) PutMetricDataWithContext(ctx aws.Context, input *cloudwatch.PutMetricDataInput, opts ...request.Option) (*cloudwatch.PutMetricDataOutput, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("expected PutMetricData call to have deadline")
+	}
+
+	if *input.Namespace != "Docker" {
+		return nil, errors.New("unexpected namespace " + *input.Namespace)
+	}
+
+	c.batches = append(c.batches, len(input.MetricData))
+
+	if c.fail {
+		return nil, errors.New("throttled")
+	}
+
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestCloudWatchWriter(t *testing.T) {
+	c := &fakeCloudWatch{}
+	w := newCloudWatchWriter("myhost", "Docker", c, 1000, time.Second, 0)
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	n := len(intMetrics(s))
+
+	samples := 2*cloudWatchMaxBatch/n + 1
+	for i := 0; i < samples; i++ {
+		if err := w.Write(s); err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	if len(c.batches) != 2 || c.batches[0] != cloudWatchMaxBatch || c.batches[1] != cloudWatchMaxBatch {
+		t.Fatalf("expected 2 full batches to be sent on write, got %v", c.batches)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if rest := samples*n - 2*cloudWatchMaxBatch; len(c.batches) != 3 || c.batches[2] != rest {
+		t.Errorf("expected the rest %d metrics to be sent on close, got %v", rest, c.batches)
+	}
+}
+
+func TestCloudWatchWriterFailure(t *testing.T) {
+	c := &fakeCloudWatch{fail: true}
+	w := newCloudWatchWriter("myhost", "Docker", c, 1000, time.Second, 0)
+	defer w.Close()
+
+	if err := w.Write(Stats{App: "myapp", Task: "mytask"}); err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if err := w.Flush(); err == nil {
+		t.Fatal("expected error flushing to failing cloudwatch")
+	}
+
+	c.fail = false
+
+	if err := w.Flush(); err != nil || len(c.batches) != 1 {
+		t.Errorf("expected failed batch to be dropped, got %v calls and error %v", c.batches, err)
+	}
+}
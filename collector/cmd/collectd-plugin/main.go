@@ -0,0 +1,107 @@
+//go:build collectd
+// +build collectd
+
+// Command collectd-plugin is collector built as collectd plugin that
+// runs inside of collectd instead of being started by exec plugin:
+//
+//	go build -tags collectd -buildmode=c-shared -o docker.so ./cmd/collectd-plugin
+//
+// Values are dispatched to collectd directly instead of being written
+// in exec plugin format and parsed back. Docker is found with DOCKER_HOST
+// and related env variables of collectd, values are named like with the
+// default naming of collectd writer and host is set by collectd.
+//
+//	LoadPlugin docker
+//
+// Plugin needs collectd.org bindings and collectd headers to build.
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/plugin"
+	collector "github.com/bobrik/collectd-docker/collector"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// interval is the interval of sampling stats of containers
+const interval = 10 * time.Second
+
+func init() {
+	plugin.RegisterRead("docker", &reader{})
+}
+
+// reader starts collector on the first read callback, the next
+// callbacks report health of collector to collectd
+type reader struct {
+	once sync.Once
+	col  *collector.Collector
+	err  error
+}
+
+func (r *reader) Read(ctx context.Context) error {
+	r.once.Do(func() {
+		client, err := docker.NewClientFromEnv()
+		if err != nil {
+			r.err = err
+			return
+		}
+
+		r.col = collector.NewCollector(client, dispatchWriter{}, interval)
+
+		go func() {
+			err := r.col.Run(context.Background())
+			plugin.Errorf("collector stopped: %s", err)
+		}()
+	})
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.col.Health()
+}
+
+// dispatchWriter dispatches values of samples to collectd as gauges
+type dispatchWriter struct{}
+
+func (dispatchWriter) Write(s collector.Stats) error {
+	replacer := strings.NewReplacer("{app}", s.App, "{task}", s.Task)
+
+	var err error
+	s.EachMetric(func(name string, value uint64) {
+		if err != nil {
+			return
+		}
+
+		err = plugin.Write(context.Background(), &api.ValueList{
+			Identifier: api.Identifier{
+				Plugin:       replacer.Replace(collector.DefaultCollectdNaming.Plugin),
+				Type:         "gauge",
+				TypeInstance: name,
+			},
+			Time:     s.Time,
+			Interval: interval,
+			Values:   []api.Value{api.Gauge(value)},
+			DSNames:  []string{"value"},
+		})
+	})
+
+	return err
+}
+
+// Flush is no-op, values are dispatched on every write
+func (dispatchWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, dispatchWriter has nothing to release
+func (dispatchWriter) Close() error {
+	return nil
+}
+
+func main() {}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// adminOptions configure admin endpoints, they are disabled without token
+type adminOptions struct {
+	// token has to be sent as bearer token with requests
+	token string
+	// rules are filter rules managed with admin endpoints
+	rules *collector.RuleSet
+	// discover discovers containers of apps that rules include
+	discover func() error
+}
+
+// pausedResponse is the response of admin endpoints
+type pausedResponse struct {
+	Containers []string `json:"containers"`
+	Apps       []string `json:"apps"`
+}
+
+// readAdminToken reads token of admin endpoints from file
+func readAdminToken(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading admin token: %s", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("admin token file %s is empty", file)
+	}
+
+	return token, nil
+}
+
+// handleAdmin registers admin endpoints that pause and resume monitoring
+// of containers and apps and manage filter rules under /admin/, requests
+// have to carry token as bearer token in authorization header
+func handleAdmin(mux *http.ServeMux, col *collector.Collector, admin adminOptions) {
+	authorized := func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(admin.token)) != 1 {
+				http.Error(w, "invalid admin token", http.StatusUnauthorized)
+				return
+			}
+
+			f(w, r)
+		}
+	}
+
+	paused := func(w http.ResponseWriter) {
+		containers, apps := col.Paused()
+		writeJSON(w, pausedResponse{Containers: containers, Apps: apps})
+	}
+
+	change := func(pause bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "use POST to pause and resume monitoring", http.StatusMethodNotAllowed)
+				return
+			}
+
+			container, app := r.FormValue("container"), r.FormValue("app")
+			if (container == "") == (app == "") {
+				http.Error(w, "either container or app parameter is required", http.StatusBadRequest)
+				return
+			}
+
+			action := "resumed"
+			switch {
+			case pause && container != "":
+				col.PauseContainer(container)
+				action = "paused"
+			case pause:
+				col.PauseApp(app)
+				action = "paused"
+			case container != "" && !col.ResumeContainer(container):
+				http.Error(w, "container "+container+" is not paused", http.StatusNotFound)
+				return
+			case container == "" && !col.ResumeApp(app):
+				http.Error(w, "app "+app+" is not paused", http.StatusNotFound)
+				return
+			}
+
+			fields := collector.LogFields{"app": app}
+			if container != "" {
+				fields = collector.LogFields{"container": container}
+			}
+
+			fields.Logf(collector.LogInfo, "monitoring is %s from %s", action, r.RemoteAddr)
+
+			paused(w)
+		}
+	}
+
+	mux.HandleFunc("/admin/pause", authorized(change(true)))
+	mux.HandleFunc("/admin/resume", authorized(change(false)))
+	mux.HandleFunc("/admin/paused", authorized(func(w http.ResponseWriter, r *http.Request) {
+		paused(w)
+	}))
+
+	if admin.rules != nil {
+		mux.HandleFunc("/admin/rules", authorized(rulesHandler(admin)))
+	}
+}
+
+// rulesHandler lists filter rules on GET, adds rule on POST and removes
+// it on DELETE, rules are taken from action and app parameters
+func rulesHandler(admin adminOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule := collector.FilterRule{Action: r.FormValue("action"), App: r.FormValue("app")}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, admin.rules.Rules())
+			return
+		case http.MethodPost:
+			err := admin.rules.Add(rule)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("error adding filter rule: %s", err), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			removed, err := admin.rules.Remove(rule)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("error removing filter rule: %s", err), http.StatusInternalServerError)
+				return
+			}
+
+			if !removed {
+				http.Error(w, "no such filter rule", http.StatusNotFound)
+				return
+			}
+		default:
+			http.Error(w, "use GET, POST or DELETE to manage filter rules", http.StatusMethodNotAllowed)
+			return
+		}
+
+		collector.Logf(collector.LogInfo, "filter rules are changed from %s: %s %s %s", r.RemoteAddr, r.Method, rule.Action, rule.App)
+
+		// containers of newly included apps are not monitored yet
+		if admin.discover != nil {
+			err := admin.discover()
+			if err != nil {
+				collector.Logf(collector.LogWarn, "error discovering containers after changing filter rules: %s", err)
+			}
+		}
+
+		writeJSON(w, admin.rules.Rules())
+	}
+}
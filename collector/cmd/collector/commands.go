@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// listContainers prints table of containers with identities
+// that collector assigns to them
+func listContainers(c *collector.Collector, out io.Writer) error {
+	containers, err := c.Containers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER ID\tNAME\tAPP\tTASK\tRUNNING\tMONITORED\tIMAGE")
+
+	for _, c := range containers {
+		app := c.App
+		if app == "" {
+			app = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\t%s\n", c.ID[:12], c.Name, app, c.Task, c.Running, c.Monitored, c.Image)
+	}
+
+	return w.Flush()
+}
+
+// explain prints identity of container and why it is or isn't monitored
+func explain(c *collector.Collector, id string, out io.Writer) error {
+	container, err := c.Explain(id)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "container:\t%s\n", container.ID)
+	fmt.Fprintf(w, "name:\t%s\n", container.Name)
+	fmt.Fprintf(w, "image:\t%s\n", container.Image)
+	fmt.Fprintf(w, "app:\t%s\n", container.App)
+	fmt.Fprintf(w, "app source:\t%s\n", container.Source)
+	fmt.Fprintf(w, "task:\t%s\n", container.Task)
+	fmt.Fprintf(w, "monitored:\t%t\n", container.Monitored)
+	fmt.Fprintf(w, "reason:\t%s\n", container.Reason)
+
+	return w.Flush()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// commandLine holds names of flags set on command line,
+// they take precedence over environment and config file
+var commandLine = map[string]bool{}
+
+// rememberCommandLine records flags set on command line,
+// it should be called right after flag.Parse
+func rememberCommandLine() {
+	flag.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+}
+
+// loadSettings resets flags that were not set on command line to
+// defaults and sets them from environment and config file again,
+// environment takes precedence over config file
+func loadSettings(flags *flag.FlagSet, config *string) error {
+	flags.VisitAll(func(f *flag.Flag) {
+		if !commandLine[f.Name] {
+			f.Value.Set(f.DefValue)
+		}
+	})
+
+	env, err := loadEnv(flags, commandLine)
+	if err != nil {
+		return err
+	}
+
+	if *config == "" {
+		return nil
+	}
+
+	skip := map[string]bool{}
+	for name := range commandLine {
+		skip[name] = true
+	}
+
+	for name := range env {
+		skip[name] = true
+	}
+
+	return loadConfig(flags, *config, skip)
+}
+
+// loadConfig reads yaml config file and sets flags except skipped ones,
+// keys of nested maps are joined with dashes, so "batch: {size: 100}"
+// sets -batch-size, lists are joined with commas
+func loadConfig(flags *flag.FlagSet, path string, skip map[string]bool) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values, err := parseConfig(b)
+	if err != nil {
+		return fmt.Errorf("error parsing config %s: %s", path, err)
+	}
+
+	_, err = setFlags(flags, values, "config "+path, skip)
+	return err
+}
+
+// envPrefix is the prefix of environment variables that set flags
+const envPrefix = "COLLECTD_DOCKER_"
+
+// loadEnv sets flags except skipped ones from environment and returns
+// names of flags that were set, flag -batch-size is set by
+// COLLECTD_DOCKER_BATCH_SIZE for example
+func loadEnv(flags *flag.FlagSet, skip map[string]bool) (map[string]bool, error) {
+	values := map[string]string{}
+	flags.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			values[f.Name] = v
+		}
+	})
+
+	return setFlags(flags, values, "environment", skip)
+}
+
+// envName returns name of environment variable for flag
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// parseConfig parses yaml config into flag values keyed by flag name
+func parseConfig(b []byte) (map[string]string, error) {
+	config := map[interface{}]interface{}{}
+
+	err := yaml.Unmarshal(b, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+
+	err = flattenConfig("", config, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func flattenConfig(prefix string, v interface{}, values map[string]string) error {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, nested := range v {
+			name := fmt.Sprint(k)
+			if prefix != "" {
+				name = prefix + "-" + name
+			}
+
+			err := flattenConfig(name, nested, values)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return fmt.Errorf("option %s should be a list of values", prefix)
+			}
+
+			items = append(items, fmt.Sprint(item))
+		}
+
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+
+	return nil
+}
+
+// setFlags sets flags from values except skipped ones
+// and returns names of flags that were set
+func setFlags(flags *flag.FlagSet, values map[string]string, source string, skip map[string]bool) (map[string]bool, error) {
+	set := map[string]bool{}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown option %s in %s", name, source)
+		}
+
+		if skip[name] {
+			continue
+		}
+
+		err := flags.Set(name, values[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid option %s in %s: %s", name, source, err)
+		}
+
+		set[name] = true
+	}
+
+	return set, nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestParseConfig(t *testing.T) {
+	values, err := parseConfig([]byte(`
+endpoint: tcp://127.0.0.1:2375
+interval: 10
+writer: [opentsdb, json]
+batch:
+  size: 100
+  interval: 10s
+opentsdb:
+  addr: tsdb:4242
+  tls: true
+`))
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	expected := map[string]string{
+		"endpoint":       "tcp://127.0.0.1:2375",
+		"interval":       "10",
+		"writer":         "opentsdb,json",
+		"batch-size":     "100",
+		"batch-interval": "10s",
+		"opentsdb-addr":  "tsdb:4242",
+		"opentsdb-tls":   "true",
+	}
+
+	if len(values) != len(expected) {
+		t.Errorf("expected %d values, got %v", len(expected), values)
+	}
+
+	for k, v := range expected {
+		if values[k] != v {
+			t.Errorf("expected %s to be %q, got %q", k, v, values[k])
+		}
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	flags := flag.NewFlagSet("collector", flag.ContinueOnError)
+	cli := flags.String("reload-cli", "default", "")
+	env := flags.String("reload-env", "default", "")
+	config := flags.String("reload-config", "default", "")
+	removed := flags.String("reload-removed", "default", "")
+
+	defer func(previous map[string]bool) {
+		commandLine = previous
+	}(commandLine)
+
+	err := flags.Parse([]string{"-reload-cli=cli"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commandLine = map[string]bool{"reload-cli": true}
+
+	t.Setenv("COLLECTD_DOCKER_RELOAD_CLI", "env")
+	t.Setenv("COLLECTD_DOCKER_RELOAD_ENV", "env")
+
+	path := filepath.Join(t.TempDir(), "collector.yml")
+	write := func(config string) {
+		err := ioutil.WriteFile(path, []byte(config), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("reload: {cli: config, env: config, config: config, removed: config}\n")
+
+	err = loadSettings(flags, &path)
+	if err != nil {
+		t.Fatalf("error loading settings: %s", err)
+	}
+
+	if *cli != "cli" || *env != "env" || *config != "config" || *removed != "config" {
+		t.Errorf("expected command line, then environment, then config, got %s, %s, %s and %s", *cli, *env, *config, *removed)
+	}
+
+	// reload picks up changed config and resets options removed from it
+	write("reload: {config: changed}\n")
+
+	err = loadSettings(flags, &path)
+	if err != nil {
+		t.Fatalf("error loading settings: %s", err)
+	}
+
+	if *cli != "cli" || *env != "env" || *config != "changed" || *removed != "default" {
+		t.Errorf("expected reload to apply changed config over defaults, got %s, %s, %s and %s", *cli, *env, *config, *removed)
+	}
+
+	write("reload: {unknown: true}\n")
+
+	err = loadSettings(flags, &path)
+	if err == nil || !strings.Contains(err.Error(), "unknown option reload-unknown") {
+		t.Errorf("expected unknown option in config to be rejected, got %v", err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if name := envName("batch-size"); name != "COLLECTD_DOCKER_BATCH_SIZE" {
+		t.Errorf("expected COLLECTD_DOCKER_BATCH_SIZE, got %s", name)
+	}
+}
+
+func TestSecondsValue(t *testing.T) {
+	tests := map[string]time.Duration{
+		"10":    10 * time.Second,
+		"500ms": 500 * time.Millisecond,
+		"1m":    time.Minute,
+	}
+
+	for s, expected := range tests {
+		v := secondsValue(0)
+
+		err := v.Set(s)
+		if err != nil {
+			t.Errorf("error parsing %q: %s", s, err)
+			continue
+		}
+
+		if time.Duration(v) != expected {
+			t.Errorf("expected %q to be %s, got %s", s, expected, time.Duration(v))
+		}
+	}
+
+	v := secondsValue(0)
+	if err := v.Set("soon"); err == nil {
+		t.Errorf("expected error parsing invalid interval")
+	}
+}
+
+func TestCollectdInterval(t *testing.T) {
+	defer os.Unsetenv("COLLECTD_INTERVAL")
+
+	tests := map[string]time.Duration{
+		"":       0,
+		"10.000": 10 * time.Second,
+		"2.5":    2500 * time.Millisecond,
+		"nope":   0,
+	}
+
+	for s, expected := range tests {
+		os.Setenv("COLLECTD_INTERVAL", s)
+
+		if interval := collectdInterval(); interval != expected {
+			t.Errorf("expected %q to be %s, got %s", s, expected, interval)
+		}
+	}
+
+	os.Unsetenv("COLLECTD_INTERVAL")
+	if interval := defaultInterval(); interval != time.Second {
+		t.Errorf("expected default interval of 1s without collectd, got %s", interval)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	defer os.Unsetenv("COLLECTD_HOSTNAME")
+
+	os.Setenv("COLLECTD_HOSTNAME", "collectd.example.com")
+	if h := hostname(); h != "collectd.example.com" {
+		t.Errorf("expected host from COLLECTD_HOSTNAME, got %q", h)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	filters, err := parseFilters("label=monitored, label=team=infra,ancestor=myimage")
+	if err != nil {
+		t.Fatalf("error parsing filters: %s", err)
+	}
+
+	if len(filters["label"]) != 2 || filters["label"][1] != "team=infra" || filters["ancestor"][0] != "myimage" {
+		t.Errorf("unexpected filters %v", filters)
+	}
+
+	if _, err := parseFilters("monitored"); err == nil {
+		t.Errorf("expected error parsing filter without value")
+	}
+}
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints, err := parseEndpoints("edge1.=tcp://edge1:2376, edge2.=tcp://edge2:2376")
+	if err != nil {
+		t.Fatalf("error parsing endpoints: %s", err)
+	}
+
+	expected := []endpoint{
+		{namespace: "edge1.", endpoint: "tcp://edge1:2376"},
+		{namespace: "edge2.", endpoint: "tcp://edge2:2376"},
+	}
+
+	if len(endpoints) != len(expected) {
+		t.Fatalf("expected endpoints %v, got %v", expected, endpoints)
+	}
+
+	for i := range expected {
+		if endpoints[i] != expected[i] {
+			t.Errorf("expected endpoint %v, got %v", expected[i], endpoints[i])
+		}
+	}
+
+	endpoints, err = parseEndpoints("unix:///var/run/docker.sock")
+	if err != nil || len(endpoints) != 1 || endpoints[0].namespace != "" {
+		t.Errorf("expected single endpoint without namespace, got %v and %v", endpoints, err)
+	}
+
+	for _, invalid := range []string{"", "edge1.=", "tcp://a:2376,tcp://b:2376"} {
+		if _, err := parseEndpoints(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		tls      bool
+		expected string
+	}{
+		{"unix:///var/run/docker.sock", false, "unix:///var/run/docker.sock"},
+		{"unix:@docker", false, "unix://@docker"},
+		{"unix://@docker", false, "unix://@docker"},
+		{"tcp://edge1:2376", true, "tcp://edge1:2376"},
+		{"tcp://edge1", false, "tcp://edge1:2375"},
+		{"tcp://edge1", true, "tcp://edge1:2376"},
+		{"https://edge1/docker", true, "https://edge1/docker"},
+		{"edge1:2376", false, "tcp://edge1:2376"},
+		{"tcp://[::1]:2376", false, "tcp://[::1]:2376"},
+		{"tcp://[::1]", false, "tcp://[::1]:2375"},
+		{"tcp://::1", true, "tcp://[::1]:2376"},
+		{"[2001:db8::1]:2376", false, "tcp://[2001:db8::1]:2376"},
+		{"tcp://[fe80::1%eth0]:2376", false, "tcp://[fe80::1%25eth0]:2376"},
+		{"tcp://[fe80::1%25eth0]:2376", false, "tcp://[fe80::1%25eth0]:2376"},
+	}
+
+	for _, test := range tests {
+		endpoint, err := normalizeEndpoint(test.endpoint, test.tls)
+		if err != nil {
+			t.Errorf("error normalizing %s: %s", test.endpoint, err)
+			continue
+		}
+
+		if endpoint != test.expected {
+			t.Errorf("expected %s to become %s, got %s", test.endpoint, test.expected, endpoint)
+		}
+
+		if _, err := docker.NewClient(endpoint); err != nil {
+			t.Errorf("error creating client of %s: %s", endpoint, err)
+		}
+	}
+
+	for _, invalid := range []string{"tcp://[::1:2376", "tcp://[::zz]:2376"} {
+		if _, err := normalizeEndpoint(invalid, false); err == nil {
+			t.Errorf("expected error normalizing %s", invalid)
+		}
+	}
+}
+
+func TestAbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract unix sockets are only supported on linux")
+	}
+
+	name := fmt.Sprintf("@collector-test-%d", os.Getpid())
+
+	l, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	endpoint := "unix://" + name
+	if socket := abstractSocket(endpoint); socket != name {
+		t.Fatalf("expected abstract socket %s, got %q", name, socket)
+	}
+
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Dialer = abstractDialer(name)
+
+	err = client.Ping()
+	if err != nil {
+		t.Errorf("error pinging docker on abstract socket: %s", err)
+	}
+
+	if socket := abstractSocket("unix:///var/run/docker.sock"); socket != "" {
+		t.Errorf("expected no abstract socket of regular socket, got %q", socket)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// consulAgent registers collector as a service in local consul agent
+// with ttl check that is updated from health of collector
+type consulAgent struct {
+	addr    string
+	service string
+	id      string
+	ttl     time.Duration
+	client  *http.Client
+}
+
+// newConsulAgent creates agent of consul at addr, like
+// http://127.0.0.1:8500, service id includes reported host
+func newConsulAgent(addr string, service string, host string, ttl time.Duration) *consulAgent {
+	return &consulAgent{
+		addr:    strings.TrimSuffix(addr, "/"),
+		service: service,
+		id:      service + "-" + host,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// consulService is service definition for /v1/agent/service/register
+type consulService struct {
+	ID    string
+	Name  string
+	Tags  []string
+	Check consulCheck
+}
+
+// consulCheck is ttl check of service, consul deregisters
+// services that stay critical, like ones of collectors that are gone
+type consulCheck struct {
+	TTL                            string
+	DeregisterCriticalServiceAfter string
+}
+
+// register registers service with ttl check
+func (a *consulAgent) register() error {
+	body, err := json.Marshal(consulService{
+		ID:   a.id,
+		Name: a.service,
+		Tags: []string{version},
+		Check: consulCheck{
+			TTL:                            a.ttl.String(),
+			DeregisterCriticalServiceAfter: (a.ttl * 10).String(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.put("/v1/agent/service/register", body)
+}
+
+// update updates ttl check with health of collector
+func (a *consulAgent) update(health error) error {
+	status, note := "pass", "collector is healthy"
+	if health != nil {
+		status, note = "fail", health.Error()
+	}
+
+	return a.put("/v1/agent/check/"+status+"/service:"+url.PathEscape(a.id)+"?note="+url.QueryEscape(note), nil)
+}
+
+func (a *consulAgent) put(path string, body []byte) error {
+	req, err := http.NewRequest("PUT", a.addr+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from consul: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// reportConsul registers collector in consul and updates its check
+// with health of collector a few times every ttl, registration is
+// retried on every update until it succeeds or when agent loses it
+func reportConsul(a *consulAgent, c *collector.Collector) {
+	registered := false
+
+	for {
+		if !registered {
+			err := a.register()
+			if err != nil {
+				collector.Logf(collector.LogWarn, "error registering in consul: %s", err)
+			} else {
+				registered = true
+				collector.Logf(collector.LogInfo, "registered in consul as %s", a.id)
+			}
+		}
+
+		if registered {
+			err := a.update(c.Health())
+			if err != nil {
+				// agent restarts lose services that are not in its config
+				registered = false
+				collector.Logf(collector.LogWarn, "error updating consul check: %s", err)
+			}
+		}
+
+		time.Sleep(a.ttl / 3)
+	}
+}
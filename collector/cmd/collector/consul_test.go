@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConsulAgent(t *testing.T) {
+	requests := []string{}
+	service := consulService{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("note"))
+
+		if r.URL.Path == "/v1/agent/service/register" {
+			json.NewDecoder(r.Body).Decode(&service)
+		}
+	}))
+
+	defer server.Close()
+
+	a := newConsulAgent(server.URL+"/", "collectd-docker", "myhost", 30*time.Second)
+
+	err := a.register()
+	if err != nil {
+		t.Fatalf("error registering: %s", err)
+	}
+
+	if service.ID != "collectd-docker-myhost" || service.Name != "collectd-docker" || service.Check.TTL != "30s" {
+		t.Errorf("unexpected service definition %#v", service)
+	}
+
+	for _, health := range []error{nil, errors.New("docker is unavailable")} {
+		err = a.update(health)
+		if err != nil {
+			t.Fatalf("error updating check: %s", err)
+		}
+	}
+
+	expected := []string{
+		"PUT /v1/agent/service/register ",
+		"PUT /v1/agent/check/pass/service:collectd-docker-myhost collector is healthy",
+		"PUT /v1/agent/check/fail/service:collectd-docker-myhost docker is unavailable",
+	}
+
+	if len(requests) != len(expected) {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("expected request %q, got %q", expected[i], requests[i])
+		}
+	}
+}
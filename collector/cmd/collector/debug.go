@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// triggerTimeout is how long triggered samples are waited for
+const triggerTimeout = 10 * time.Second
+
+// newDebugMux creates handler of debug listener with pprof endpoints
+// under /debug/pprof/, like net/http/pprof registers them by default,
+// expvar variables under /debug/vars, trigger of samples of collector
+// under /trigger, monitored containers as json under /containers,
+// status page of collector on host at / and admin endpoints under
+// /admin/ if admin token is set
+func newDebugMux(col *collector.Collector, host string, admin adminOptions) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle("/", statusHandler(col, host))
+
+	if admin.token != "" {
+		handleAdmin(mux, col, admin)
+	}
+
+	mux.HandleFunc("/containers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, col.Snapshot())
+	})
+
+	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/containers/")
+		id, stats := strings.TrimSuffix(id, "/stats"), strings.HasSuffix(id, "/stats")
+
+		snapshot, ok := col.ContainerSnapshot(id)
+		if !ok {
+			http.Error(w, "no monitored container with id "+id, http.StatusNotFound)
+			return
+		}
+
+		if !stats {
+			writeJSON(w, snapshot)
+			return
+		}
+
+		if snapshot.Sample == nil {
+			http.Error(w, "no samples of container "+id+" yet", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, snapshot.Sample)
+	})
+
+	mux.HandleFunc("/trigger", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST to trigger samples", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), triggerTimeout)
+		defer cancel()
+
+		n, err := col.Trigger(ctx, r.FormValue("container"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error triggering samples of %d containers: %s", n, err), http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintf(w, "triggered samples of %d containers\n", n)
+	})
+
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// writeJSON writes value as json response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		collector.Logf(collector.LogWarn, "error writing debug response: %s", err)
+	}
+}
+
+// listenDebug starts debug listener on addr, listening errors
+// are returned right away, serving errors are logged, metrics
+// of collector are published as collector expvar variable
+func listenDebug(addr string, col *collector.Collector, host string, admin adminOptions) error {
+	expvar.Publish("collector", expvar.Func(func() interface{} {
+		return col.SelfMetrics()
+	}))
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	collector.Logf(collector.LogInfo, "debug listener is on http://%s/debug/", l.Addr())
+
+	go func() {
+		err := http.Serve(l, newDebugMux(col, host, admin))
+		collector.Logf(collector.LogError, "debug listener stopped: %s", err)
+	}()
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+func TestDebugContainers(t *testing.T) {
+	col := collector.NewCollector(nil, collector.NewDryRunWriter("", "", nil), time.Second)
+	mux := newDebugMux(col, "myhost", adminOptions{})
+
+	tests := map[string]int{
+		"/containers":                http.StatusOK,
+		"/containers/0123abcd":       http.StatusNotFound,
+		"/containers/0123abcd/stats": http.StatusNotFound,
+	}
+
+	for path, status := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		if rec.Code != status {
+			t.Errorf("expected status %d for %s, got %d", status, path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/containers", nil))
+
+	snapshots := []collector.ContainerSnapshot{}
+
+	err := json.Unmarshal(rec.Body.Bytes(), &snapshots)
+	if err != nil || len(snapshots) != 0 {
+		t.Errorf("expected empty list of containers, got %q (error: %v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/trigger", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected trigger to require POST, got status %d", rec.Code)
+	}
+}
+
+func TestDebugStatus(t *testing.T) {
+	col := collector.NewCollector(nil, collector.NewDryRunWriter("", "", nil), time.Second)
+
+	rec := httptest.NewRecorder()
+	newDebugMux(col, "myhost", adminOptions{}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	body := rec.Body.String()
+	for _, expected := range []string{"on myhost", "unhealthy: containers are not discovered yet", "last write: never", "collector.write_errors", "Containers (0)"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected status page to contain %q, got %s", expected, body)
+		}
+	}
+}
+
+func TestDebugAdmin(t *testing.T) {
+	col := collector.NewCollector(nil, collector.NewDryRunWriter("", "", nil), time.Second)
+
+	rules, err := collector.LoadRuleSet("")
+	if err != nil {
+		t.Fatalf("error creating rules: %s", err)
+	}
+
+	col.SetFilterRules(rules)
+	mux := newDebugMux(col, "myhost", adminOptions{token: "secret", rules: rules})
+
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"POST", "/admin/pause?app=web", "", http.StatusUnauthorized},
+		{"POST", "/admin/pause?app=web", "wrong", http.StatusUnauthorized},
+		{"GET", "/admin/pause?app=web", "secret", http.StatusMethodNotAllowed},
+		{"POST", "/admin/pause", "secret", http.StatusBadRequest},
+		{"POST", "/admin/pause?app=web&container=0123", "secret", http.StatusBadRequest},
+		{"POST", "/admin/pause?app=web", "secret", http.StatusOK},
+		{"POST", "/admin/pause?container=0123", "secret", http.StatusOK},
+		{"POST", "/admin/resume?container=0123", "secret", http.StatusOK},
+		{"POST", "/admin/resume?container=0123", "secret", http.StatusNotFound},
+		{"GET", "/admin/rules", "", http.StatusUnauthorized},
+		{"POST", "/admin/rules?action=drop&app=web", "secret", http.StatusBadRequest},
+		{"POST", "/admin/rules?action=exclude&app=noisy", "secret", http.StatusOK},
+		{"POST", "/admin/rules?action=include&app=web", "secret", http.StatusOK},
+		{"DELETE", "/admin/rules?action=include&app=web", "secret", http.StatusOK},
+		{"DELETE", "/admin/rules?action=include&app=web", "secret", http.StatusNotFound},
+		{"PUT", "/admin/rules", "secret", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		if rec := request(test.method, test.path, test.token); rec.Code != test.status {
+			t.Errorf("expected status %d for %s %s, got %d: %s", test.status, test.method, test.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := request("GET", "/admin/paused", "secret")
+
+	paused := pausedResponse{}
+
+	err = json.Unmarshal(rec.Body.Bytes(), &paused)
+	if err != nil || len(paused.Apps) != 1 || paused.Apps[0] != "web" || len(paused.Containers) != 0 {
+		t.Errorf("expected paused app web, got %q (error: %v)", rec.Body.String(), err)
+	}
+
+	rec = request("GET", "/admin/rules", "secret")
+
+	listed := []collector.FilterRule{}
+
+	err = json.Unmarshal(rec.Body.Bytes(), &listed)
+	if err != nil || len(listed) != 1 || listed[0].App != "noisy" {
+		t.Errorf("expected exclude rule of noisy, got %q (error: %v)", rec.Body.String(), err)
+	}
+
+	// admin endpoints are disabled without token
+	mux = newDebugMux(col, "myhost", adminOptions{})
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/admin/pause?app=web", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected admin endpoints to be disabled without token, got status %d", rec.Code)
+	}
+}
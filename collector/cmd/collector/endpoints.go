@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// endpoint is docker endpoint with namespace of its apps
+type endpoint struct {
+	namespace string
+	endpoint  string
+}
+
+// parseEndpoints parses comma separated docker endpoints, every endpoint
+// can have namespace, like edge1.=tcp://edge1:2376, namespaces have to be
+// unique when there are several endpoints, so their metrics don't collide
+func parseEndpoints(s string) ([]endpoint, error) {
+	endpoints := []endpoint{}
+	namespaces := map[string]bool{}
+
+	for _, item := range splitList(s) {
+		e := endpoint{endpoint: item}
+
+		// urls of endpoints don't have = before scheme
+		if i := strings.Index(item, "="); i != -1 && !strings.Contains(item[:i], "://") {
+			e.namespace, e.endpoint = item[:i], item[i+1:]
+		}
+
+		if e.endpoint == "" {
+			return nil, fmt.Errorf("endpoint of namespace %q is empty", e.namespace)
+		}
+
+		if namespaces[e.namespace] {
+			return nil, fmt.Errorf("namespace %q is used by several endpoints", e.namespace)
+		}
+
+		namespaces[e.namespace] = true
+		endpoints = append(endpoints, e)
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no docker endpoint is set")
+	}
+
+	return endpoints, nil
+}
+
+// normalizeEndpoint rewrites endpoint so docker client accepts it, bare
+// ipv6 literals are bracketed, zones of link local addresses are escaped
+// and default port of docker is added to tcp endpoints without port,
+// unix:@name becomes unix://@name for abstract unix sockets
+func normalizeEndpoint(endpoint string, tls bool) (string, error) {
+	if strings.HasPrefix(endpoint, "unix:@") {
+		return "unix://" + strings.TrimPrefix(endpoint, "unix:"), nil
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "tcp://" + endpoint
+	}
+
+	i := strings.Index(endpoint, "://")
+	scheme, host, path := endpoint[:i], endpoint[i+3:], ""
+	if scheme != "tcp" && scheme != "http" && scheme != "https" {
+		return endpoint, nil
+	}
+
+	if j := strings.Index(host, "/"); j != -1 {
+		host, path = host[:j], host[j:]
+	}
+
+	address, port := host, ""
+	switch {
+	case strings.HasPrefix(host, "["):
+		end := strings.Index(host, "]")
+		if end == -1 {
+			return "", fmt.Errorf("endpoint %s has unclosed [ in address", endpoint)
+		}
+
+		address, port = host[1:end], strings.TrimPrefix(host[end+1:], ":")
+	case strings.Count(host, ":") > 1:
+		// bare ipv6 literal can't have port, it is ambiguous
+	default:
+		if j := strings.LastIndex(host, ":"); j != -1 {
+			address, port = host[:j], host[j+1:]
+		}
+	}
+
+	ipv6 := strings.Contains(address, ":")
+	if ipv6 {
+		ip := address
+		if j := strings.Index(ip, "%"); j != -1 {
+			ip = ip[:j]
+			address = ip + "%25" + strings.TrimPrefix(address[j+1:], "25")
+		}
+
+		if net.ParseIP(ip) == nil {
+			return "", fmt.Errorf("endpoint %s has invalid ipv6 address %s", endpoint, ip)
+		}
+	}
+
+	if port == "" && scheme == "tcp" {
+		port = "2375"
+		if tls {
+			port = "2376"
+		}
+	}
+
+	if ipv6 {
+		address = "[" + address + "]"
+	}
+
+	if port != "" {
+		address += ":" + port
+	}
+
+	return scheme + "://" + address + path, nil
+}
+
+// abstractSocket returns name of abstract unix socket of endpoint
+// like unix://@docker or empty string for other endpoints
+func abstractSocket(endpoint string) string {
+	if !strings.HasPrefix(endpoint, "unix://@") {
+		return ""
+	}
+
+	return strings.TrimPrefix(endpoint, "unix://")
+}
+
+// abstractDialer connects to abstract unix socket, docker client dials
+// path of url of endpoint, which abstract sockets don't have
+type abstractDialer string
+
+func (d abstractDialer) Dial(network, address string) (net.Conn, error) {
+	return net.Dial("unix", string(d))
+}
+
+// sharedWriter lets collectors of several endpoints write to the same
+// pipeline, which expects to be called from a single goroutine, pipeline
+// can be replaced on reload while collectors are running
+type sharedWriter struct {
+	mutex  sync.Mutex
+	writer collector.Writer
+}
+
+func newSharedWriter(writer collector.Writer) *sharedWriter {
+	return &sharedWriter{writer: writer}
+}
+
+// set replaces wrapped writer and returns the previous one
+func (w *sharedWriter) set(writer collector.Writer) collector.Writer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	previous := w.writer
+	w.writer = writer
+
+	return previous
+}
+
+func (w *sharedWriter) Write(s collector.Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Write(s)
+}
+
+func (w *sharedWriter) Notify(n collector.Notification) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return collector.Notify(w.writer, n)
+}
+
+func (w *sharedWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Flush()
+}
+
+func (w *sharedWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Close()
+}
@@ -1,46 +1,1053 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
-	collector "../.."
+	collector "github.com/bobrik/collectd-docker/collector"
 	"github.com/fsouza/go-dockerclient"
 	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// version, commit and buildDate are set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// commands are optional subcommands given before flags,
+// collector runs normally without subcommand
+var commands = map[string]string{
+	"check-config":    "validate configuration, connect to docker and writers and exit",
+	"list-containers": "list containers with their app, task and whether they are monitored",
+	"explain":         "explain why container given after flags is or isn't monitored",
+	"preflight":       "check access to docker, its api version, cgroups and writers and exit",
+}
+
+// lockRetryInterval is how often standby collector tries to take -lock-file
+const lockRetryInterval = 5 * time.Second
+
 func main() {
-	e := flag.String("endpoint", "unix:///var/run/docker.sock", "docker endpoint")
-	c := flag.String("cert", "", "cert path for tls")
-	h := flag.String("host", "", "host to report")
-	i := flag.Int("interval", 1, "interval to report")
+	command := parseCommand()
+
+	cf := flag.String("config", "", "yaml config file with flag values, flags from command line take precedence")
+	e := flag.String("endpoint", dockerEndpoint(), "docker endpoint, DOCKER_HOST is used by default if set, comma separated namespace=endpoint pairs monitor several endpoints with namespaces prepended to app names")
+	c := flag.String("cert", dockerCertPath(), "cert path for tls, DOCKER_CERT_PATH is used by default if set")
+	dx := flag.String("docker-proxy", "", "url of http or socks5 proxy to connect to tcp docker endpoints through, HTTP_PROXY and HTTPS_PROXY are used when empty, NO_PROXY is honored")
+	h := flag.String("host", hostname(), "host to report, COLLECTD_HOSTNAME of exec plugin is used by default if set")
+	hd := flag.Bool("host-from-docker", false, "report name of docker host instead of -host, for collector running in a container")
+	i := newSecondsFlag("interval", defaultInterval(), "interval to report, plain number is seconds, COLLECTD_INTERVAL of exec plugin is used by default if set")
+	al := flag.Bool("interval-align", false, "take samples on interval boundaries and align their timestamps to them")
+	ck := flag.Duration("max-clock-skew", time.Minute, "max difference of read time of stats from docker and local clock, stats read further away are timestamped with local time, 0 to always use read time")
+	ij := flag.Duration("interval-jitter", 0, "max random offset of sampling for every container to spread writes, 0 to disable")
+	di := flag.Duration("discovery-interval", 0, "interval to list containers in addition to watching docker events, 0 to disable")
+	sl := flag.Duration("slow-stats-latency", 0, "average lateness of stats from docker to stretch interval after, 0 to disable")
+	sx := flag.Int("slow-stats-max-stretch", 8, "max number of times interval is stretched when docker is slow")
+	ut := flag.Duration("docker-unavailable-timeout", time.Minute, "how long docker has to be unavailable to write a notification, 0 to disable")
+	ep := flag.Bool("exec-probes", false, "run probe commands that containers declare with collectd_docker_probe label every interval and write their key value output as metrics")
+	se := flag.Bool("scrape", false, "fetch metrics endpoints that containers declare with collectd_docker_scrape label every interval and write their values as metrics")
+	lp := flag.String("log-patterns", "", "semicolon separated name=regexp patterns, lines of container logs matching them are counted in log.<name> metrics")
+	rp := flag.String("reachability", "", "comma separated reachability probes of containers to run every interval: tcp to connect to published ports, icmp to ping container ip")
+	tp := flag.Int("top-processes", 0, "number of commands with the most cpu time to report cpu time and rss of in every container every interval, 0 to disable")
+	el := flag.String("end-of-life", "none", "what to write when container stops: none, zero to write the last metrics as zeros or marker to write lifecycle.ended metric")
+	dd := flag.Duration("daemon-info-interval", 0, "interval of writing docker daemon info like counts of containers and images and docker version, 0 to disable")
+	du := flag.Bool("daemon-usage", false, "write cpu and memory usage of dockerd and containerd read from their cgroups every interval")
+	pp := flag.String("proc-path", "/proc", "path of proc filesystem of the host, like /host/proc when collector runs in container")
+	cp := flag.String("cgroup-path", "/sys/fs/cgroup", "path of cgroup filesystem of the host, like /host/sys/fs/cgroup when collector runs in container")
+	hi := flag.Bool("high-resolution", false, "sample cpu and memory usage of containers with collectd_docker_high_resolution label at sub-second interval from the label, read from cgroups")
+	hm := flag.Bool("host-metrics", false, "write cpu and memory usage of the host and disk usage of docker data root every interval")
+	hr := flag.String("docker-root-path", "", "path of docker data root for disk usage of -host-metrics, docker reports it when empty")
+	ne := flag.String("notify-events", "oom", "comma separated docker events of monitored containers to write as notifications, like start,die,kill,restart,oom,health_status")
+	ff := flag.String("container-filters", "", "comma separated docker filters containers have to pass to be inspected, like label=monitored,ancestor=myimage")
+	rc := flag.Int("inspect-retries", 3, "number of times to retry inspecting new containers that fail to inspect or are not started yet, 0 to disable")
+	rb := flag.Duration("inspect-retry-backoff", 500*time.Millisecond, "backoff before the first retry of inspecting container, it doubles with every retry")
+	ic := flag.Duration("inspect-cache-ttl", 5*time.Second, "how long results of inspecting containers are reused for identity lookups, 0 to disable")
+	mu := flag.String("marathon-url", "", "url of marathon to find identity of marathon apps in their definitions, empty to disable")
+	mg := flag.Bool("marathon-groups", false, "make app names of marathon apps reflect their groups, like prod.search.web")
+	mt := flag.Duration("marathon-cache-ttl", time.Minute, "how long marathon app definitions are cached")
+	ir := flag.String("image-regexp", "", "regexp of image names to take app from when nothing else names it, with app and optional task named groups, like registry:5000/(?P<app>[^:]+):, empty for the default")
+	cn := flag.String("containers", "", "comma separated ids or names of the only containers to monitor, they are polled every discovery interval instead of discovering containers")
+	sf := flag.String("identity-file", "", "file with lines of regexp of container names or ids, app and optional task, consulted before labels and env variables")
+	im := flag.String("image-apps", "", "comma separated image=app pairs to name apps of containers of images with no labels or env variables, images are ids, references or repositories, like sha256:4f53...=web")
+	mc := flag.String("marathon-colors", "", "regexp of blue/green or canary suffixes to strip from marathon app ids, like "+collector.DefaultMarathonColors+", the first group becomes color tag, empty to disable")
+	cu := flag.String("chronos-url", "", "url of chronos to attach owner and schedule of jobs to their metrics, empty to disable")
+	ct := flag.Duration("chronos-cache-ttl", time.Minute, "how long metadata of chronos jobs is cached")
+	ku := flag.String("kubelet-url", "", "url of local kubelet to discover containers of pods with instead of docker events, like https://127.0.0.1:10250, empty to disable")
+	kt := flag.String("kubelet-token-file", "", "file with bearer token to authenticate to kubelet, like service account token")
+	kc := flag.String("kubelet-ca", "", "ca file to verify kubelet certificate with instead of system roots")
+	ia := flag.String("include-apps", "", "regexp app names have to match to be monitored, empty to monitor all apps")
+	ea := flag.String("exclude-apps", "", "regexp of app names not to monitor, empty to monitor all apps")
+	sh := flag.Int("shard", 0, "index of shard of containers to monitor out of -shards, from 0")
+	sn := flag.Int("shards", 1, "number of collectors splitting containers of the same endpoints by consistent hash of container ids, only shard 0 reports daemon and host metrics")
+	fr := flag.String("filter-rules-file", "", "file to save filter rules managed with admin endpoints to, so they are kept across restarts, empty to keep them in memory")
+	w := flag.String("writer", "collectd", "comma separated writers to use: "+writerNames())
+	b := flag.Int("writer-buffer", 1000, "number of samples to buffer for every writer when several writers are used")
+	sq := flag.Int("stage-queue-size", 1000, "number of samples queued between pipeline stages, 0 runs stages in a single goroutine")
+	bs := flag.Int("batch-size", 1, "number of samples to write in a single batch")
+	bi := flag.Duration("batch-interval", collectdInterval(), "interval to write incomplete batches, 0 to disable, COLLECTD_INTERVAL of exec plugin is used by default if set")
+	sd := flag.String("spool-dir", "", "directory to spool samples to when writer fails, empty to disable")
+	sm := flag.Int64("spool-max-size", 256<<20, "max size of spool for every writer in bytes")
+	qs := flag.Int("queue-size", 1000, "number of samples waiting to be written before drop policy applies")
+	dp := flag.String("drop-policy", "block", "what to do with samples when queue is full: block, drop-oldest or drop-newest")
+	mp := flag.String("metric-prefix", "", "prefix to add to names of all metrics")
+	ms := flag.String("metric-suffix", "", "suffix to add to names of all metrics")
+	mn := flag.String("metric-naming", "native", "naming of container metrics: native or cadvisor to match metrics of cadvisor")
+	dw := flag.Duration("downsample-window", 0, "window to combine samples of every task over before writing, 0 to disable")
+	dm := flag.String("downsample-mode", "avg", "how to combine samples in a window: avg, max or summary")
+	dn := flag.String("downsample-writers", "", "comma separated writers to downsample for, empty for all writers")
+	mk := flag.Int("max-tasks-per-app", 0, "max number of distinct tasks of an app, samples of new tasks over it are written as _overflow task, 0 to disable")
+	mx := flag.Int("max-series", 0, "max number of series of all tasks, samples of new tasks over it are written as _overflow task, 0 to disable")
+	su := flag.Bool("suppress-unchanged", false, "skip metrics with values that haven't changed since they were written")
+	sa := flag.Duration("suppress-max-age", 5*time.Minute, "interval to write unchanged values anyway")
+	th := flag.String("thresholds", "", "comma separated threshold rules to notify about, like \"memory.usage > 90% of memory.limit for 5m\"")
+	rt := flag.Bool("rates", false, "convert counters to per second rates before writing")
+	rs := flag.String("rate-state-file", "", "file to save counters of -rates to, so rates are calculated right after restart, empty to disable")
+	ai := flag.Duration("aggregate-interval", 0, "interval to write app level rollups across tasks, 0 to disable")
+	cr := flag.Duration("compose-rollup-interval", 0, "interval to write rollups of cpu and memory of docker compose projects, 0 to disable")
+	ll := flag.String("log-level", "info", "minimal level of logged messages: debug, info, warn or error")
+	lf := flag.String("log-format", "text", "format of logged messages: text or json")
+	lo := flag.String("log-output", "stderr", "where to write logged messages: stderr, syslog or journald")
+	da := flag.String("debug-addr", "", "address of debug listener with status page and pprof, expvar, trigger and containers endpoints, like 127.0.0.1:6060, empty to disable")
+	at := flag.String("admin-token-file", "", "file with bearer token that enables admin endpoints to pause and resume monitoring on debug listener, empty to disable")
+	lk := flag.String("lock-file", "", "file to lock, so only one of collectors started against the same docker endpoint streams stats and others wait for it to exit, empty to disable")
+	ca := flag.String("consul-addr", "", "address of consul agent to register collector in, like http://127.0.0.1:8500, empty to disable")
+	cs := flag.String("consul-service", "collectd-docker", "name of service to register collector as in consul")
+	cl := flag.Duration("consul-ttl", 30*time.Second, "ttl of consul health check of collector")
+	vf := flag.Bool("version", false, "print version and build info and exit")
+	dr := flag.Bool("dry-run", false, "print metrics that would be written instead of writing them")
+	sc := flag.Int("synthetic-containers", 0, "number of fake containers to write synthetic stats of instead of monitoring docker, 0 to disable")
+	sp := flag.String("synthetic-pattern", "ramp", "how load of synthetic containers changes: flat, ramp or spike")
+	spp := flag.Duration("synthetic-period", 5*time.Minute, "period of synthetic load pattern")
+	ma := flag.String("mesos-agent-url", "", "url of local mesos agent to read stats of containers from instead of docker, like http://127.0.0.1:5051, empty to disable")
+	mf := map[string]*bool{}
+	for _, family := range collector.MetricFamilies() {
+		mf[family] = flag.Bool("metrics-"+family, true, "write "+family+" metrics of containers")
+	}
+	registerWriterFlags()
 	flag.Parse()
+	rememberCommandLine()
+
+	if *vf {
+		fmt.Printf("collector %s (commit %s, built %s)\n", version, commit, buildDate)
+		return
+	}
 
-	if *h == "" {
+	err := loadSettings(flag.CommandLine, cf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *h == "" && !*hd {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	var client *docker.Client
-	var err error
+	level, err := collector.ParseLogLevel(*ll)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	format, err := collector.ParseLogFormat(*lf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	collector.SetLogLevel(level)
+	collector.SetLogFormat(format)
+
+	err = collector.SetLogOutput(*lo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command != "" && commands[command] == "" {
+		log.Fatalf("unknown command: %s", command)
+	}
+
+	// newFilter creates app filter from flags
+	newFilter := func() (*regexp.Regexp, *regexp.Regexp, error) {
+		include, err := compileRegexp(*ia)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exclude, err := compileRegexp(*ea)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return include, exclude, nil
+	}
+
+	// kubelet discovers containers of pods if it is set with flags
+	var kubelet *collector.Kubelet
+
+	// colors strips deployment suffixes of marathon apps if it is set with flags
+	var colors *collector.MarathonColors
+
+	// image takes app from image name, its regexp can be set with flags
+	image := collector.ImageExtractor{}
+
+	// static names containers listed in identity file if it is set with flags
+	var static collector.StaticExtractor
+
+	// images names apps of images listed with flags
+	images, err := collector.ParseImageMap(*im)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// identityExtractor finds identity of containers as configured with flags
+	identityExtractor := func() collector.IdentityExtractor {
+		var extractor collector.IdentityExtractor = collector.DefaultIdentityExtractor
+		if *mu != "" || colors != nil || image.Regexp != nil || len(images) > 0 {
+			var marathon collector.IdentityExtractor = collector.MarathonExtractor{}
+			if colors != nil {
+				marathon = colors
+			}
+
+			chain := collector.ChainExtractor{
+				collector.LabelExtractor{},
+				collector.EnvExtractor{},
+				collector.ChronosExtractor{},
+			}
+
+			if *mu != "" {
+				api := collector.NewMarathonAPIExtractor(*mu, *mg, *mt)
+				api.SetColors(colors)
+				chain = append(chain, api)
+			}
+
+			chain = append(chain, marathon)
+			if len(images) > 0 {
+				chain = append(chain, images)
+			}
+
+			extractor = append(chain, image)
+		}
+
+		if kubelet != nil {
+			extractor = collector.ChainExtractor{kubelet, extractor}
+		}
+
+		if static != nil {
+			extractor = collector.ChainExtractor{static, extractor}
+		}
+
+		return extractor
+	}
+
+	// disabledFamilies returns metric families turned off with flags
+	disabledFamilies := func() []string {
+		disabled := []string{}
+		for family, enabled := range mf {
+			if !*enabled {
+				disabled = append(disabled, family)
+			}
+		}
+
+		return disabled
+	}
+
+	// host is reported in metrics, it is resolved from flags
+	// once docker client is created and on every reload
+	host := ""
+
+	// stage runs writer in its own pipeline stage unless stages are disabled
+	stage := func(name string, writer collector.Writer) collector.Writer {
+		if *sq <= 0 {
+			return writer
+		}
+
+		return collector.NewStageWriter(name, writer, *sq)
+	}
+
+	// newPipeline creates writers with their wrappers from flags
+	newPipeline := func() (collector.Writer, error) {
+		mode, err := collector.ParseDownsampleMode(*dm)
+		if err != nil {
+			return nil, err
+		}
+
+		thresholds := []collector.Threshold{}
+		for _, rule := range splitList(*th) {
+			threshold, err := collector.ParseThreshold(rule)
+			if err != nil {
+				return nil, err
+			}
+
+			thresholds = append(thresholds, threshold)
+		}
+
+		downsampled := map[string]bool{}
+		for _, name := range splitList(*dn) {
+			downsampled[name] = true
+		}
+
+		writers := []collector.Writer{}
+		for _, name := range strings.Split(*w, ",") {
+			name = strings.TrimSpace(name)
+
+			var writer collector.Writer
+			var err error
+			if *dr {
+				if _, ok := writerFlags[name]; !ok {
+					err = fmt.Errorf("unknown writer: %s", name)
+				}
+
+				writer = collector.NewDryRunWriter(name, host, os.Stdout)
+			} else {
+				writer, err = newWriter(name, host)
+				if err == nil && *sd != "" {
+					writer, err = collector.NewSpoolWriter(writer, path.Join(*sd, name), *sm)
+				}
+			}
+
+			if err != nil {
+				for _, writer := range writers {
+					writer.Close()
+				}
+
+				return nil, err
+			}
+
+			writer = collector.NewBatchWriter(writer, *bs, *bi)
+
+			if *dw > 0 && (len(downsampled) == 0 || downsampled[name]) {
+				downsample := collector.NewDownsampleWriter(writer, *dw, mode)
+				downsample.SetRates(*rt)
+				writer = downsample
+			}
+
+			writers = append(writers, writer)
+		}
+
+		var writer collector.Writer
+		if len(writers) == 1 {
+			writer = writers[0]
+		} else {
+			writer = collector.NewMultiWriter(*b, writers...)
+		}
+
+		writer = stage("write", writer)
+
+		// writers wrapped later run earlier, prefix is applied
+		// last, aggregation needs container metrics
+		if *mp != "" || *ms != "" {
+			writer = collector.NewPrefixWriter(writer, *mp, *ms)
+		}
+
+		// series are counted as written, after families are disabled
+		// and metrics are renamed, cadvisor naming has fewer series
+		if *mk > 0 || *mx > 0 {
+			writer = collector.NewCardinalityWriter(writer, *mk, *mx)
+		}
+
+		// metrics are renamed after families are disabled by native names
+		switch *mn {
+		case "native":
+		case "cadvisor":
+			writer = collector.NewCAdvisorWriter(writer)
+		default:
+			writer.Close()
+			return nil, fmt.Errorf("unknown metric naming: %s", *mn)
+		}
+
+		if disabled := disabledFamilies(); len(disabled) > 0 {
+			writer = collector.NewFamilyWriter(writer, disabled)
+		}
+
+		if *su {
+			writer = collector.NewSuppressWriter(writer, *sa)
+		}
+
+		// thresholds see rates, but not prefixed names
+		if len(thresholds) > 0 {
+			writer = collector.NewThresholdWriter(writer, thresholds)
+		}
+
+		if *rt {
+			if *rs == "" {
+				writer = collector.NewRateWriter(writer)
+			} else {
+				rates, err := collector.NewPersistentRateWriter(writer, *rs)
+				if err != nil {
+					writer.Close()
+					return nil, err
+				}
+
+				writer = rates
+			}
+		}
+
+		// transformations run in a stage of their own even without
+		// aggregation, so they don't slow down reading the queue
+		writer = stage("transform", writer)
+
+		if *ai > 0 {
+			writer = collector.NewAggregateWriter(writer, *ai)
+		}
+
+		if *cr > 0 {
+			writer = collector.NewComposeProjectWriter(writer, *cr)
+		}
+
+		return writer, nil
+	}
+
+	include, exclude, err := newFilter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rules, err := collector.LoadRuleSet(*fr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = collector.ValidateShard(*sh, *sn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *rc > 0 && *rb <= 0 {
+		log.Fatalf("-inspect-retry-backoff should be positive, got %s", *rb)
+	}
+
+	filters, err := parseFilters(*ff)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := collector.ParseDropPolicy(*dp)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *qs < 1 && policy != collector.DropPolicyBlock {
+		log.Fatalf("queue size should be positive with drop policy %s, got %d", *dp, *qs)
+	}
+
+	pattern, err := collector.ParseSyntheticPattern(*sp)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	patterns, err := collector.ParseLogPatterns(*lp)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	endOfLife, err := collector.ParseEndOfLife(*el)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reachTCP, reachICMP := false, false
+	for _, probe := range splitList(*rp) {
+		switch probe {
+		case "tcp":
+			reachTCP = true
+		case "icmp":
+			reachICMP = true
+		default:
+			log.Fatalf("unknown reachability probe: %s", probe)
+		}
+	}
+
+	if *ir != "" {
+		image, err = collector.NewImageExtractor(*ir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *sf != "" {
+		static, err = collector.LoadStaticIdentities(*sf)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *mc != "" {
+		colors, err = collector.NewMarathonColors(*mc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *ku != "" && *cn != "" {
+		log.Fatal("-kubelet-url and -containers can't be used together")
+	}
 
-	if *c != "" {
-		client, err = docker.NewTLSClient(*e, path.Join(*c, "cert.pem"), path.Join(*c, "key.pem"), path.Join(*c, "ca.pem"))
-	} else {
-		client, err = docker.NewClient(*e)
+	if *ku != "" {
+		kubelet, err = newKubelet(*ku, *kt, *kc)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
+	endpoints, err := parseEndpoints(*e)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	writer := collector.NewCollectdWriter(*h, os.Stdout)
+	proxy, err := dockerProxy(*dx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// newClient creates docker client of endpoint, certs are shared by endpoints
+	newClient := func(endpoint string) (*docker.Client, error) {
+		endpoint, err := normalizeEndpoint(endpoint, *c != "")
+		if err != nil {
+			return nil, err
+		}
+
+		var client *docker.Client
+		if *c != "" {
+			client, err = docker.NewTLSClient(endpoint, path.Join(*c, "cert.pem"), path.Join(*c, "key.pem"), path.Join(*c, "ca.pem"))
+		} else {
+			client, err = docker.NewClient(endpoint)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if socket := abstractSocket(endpoint); socket != "" {
+			client.Dialer = abstractDialer(socket)
+		}
 
-	collector := collector.NewCollector(client, writer, *i)
+		return client, setProxy(client, endpoint, proxy)
+	}
 
-	err = collector.Run(5)
+	client, err := newClient(endpoints[0].endpoint)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// resolveHost returns host to report, docker daemon is
+	// asked for its name if -host-from-docker is set
+	resolveHost := func() (string, error) {
+		if !*hd {
+			return *h, nil
+		}
+
+		info, err := client.Info()
+		if err != nil {
+			return "", fmt.Errorf("error getting name of docker host: %s", err)
+		}
+
+		if info.Name == "" {
+			return "", errors.New("docker host has no name")
+		}
+
+		return info.Name, nil
+	}
+
+	if command == "list-containers" || command == "explain" {
+		col := collector.NewCollector(client, nil, time.Duration(*i))
+		col.SetIdentityExtractor(identityExtractor())
+		col.SetAppFilter(include, exclude)
+		col.SetFilterRules(rules)
+		col.SetShard(*sh, *sn)
+
+		if command == "explain" {
+			if flag.NArg() != 1 {
+				log.Fatal("usage: collector explain [flags] <container>")
+			}
+
+			err = explain(col, flag.Arg(0), os.Stdout)
+		} else {
+			err = listContainers(col, os.Stdout)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if command == "preflight" {
+		checks := append(dockerChecks(endpoints[0].endpoint, client), preflightCheck{"writers", func() error {
+			host, err = resolveHost()
+			if err != nil {
+				return err
+			}
+
+			writer, err := newPipeline()
+			if err != nil {
+				return fmt.Errorf("%s, check addresses and credentials of writers", err)
+			}
+
+			return writer.Close()
+		}})
+
+		if !preflight(checks, os.Stdout) {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if command == "check-config" {
+		err = client.Ping()
+		if err != nil {
+			log.Fatalf("error connecting to docker at %s: %s", endpoints[0].endpoint, err)
+		}
+	}
+
+	// standby collector waits for the lock before it connects
+	// to writers and binds debug listener, so it holds nothing
+	if *lk != "" && command != "check-config" {
+		lock, err := collector.WaitLock(context.Background(), *lk, lockRetryInterval)
+		if err != nil {
+			log.Fatalf("error locking %s: %s", *lk, err)
+		}
+
+		defer lock.Release()
+	}
+
+	host, err = resolveHost()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writer, err := newPipeline()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command == "check-config" {
+		err = writer.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Println("configuration is valid")
+		return
+	}
+
+	if *sc > 0 {
+		collector.Logf(collector.LogInfo, "writing synthetic stats of %d containers", *sc)
+
+		load := collector.NewSyntheticLoad(*sc, pattern, *spp, time.Now())
+		load.Run(context.Background(), time.Duration(*i), writer)
+
+		return
+	}
+
+	if *ma != "" {
+		collector.Logf(collector.LogInfo, "writing stats of containers of mesos agent at %s", *ma)
+
+		collector.NewMesosAgent(*ma).Run(context.Background(), time.Duration(*i), writer)
+
+		return
+	}
+
+	// shared passes samples of collectors of all endpoints to the same pipeline
+	shared := newSharedWriter(writer)
+
+	// newCol creates collector of docker endpoint configured with flags
+	newCol := func(client *docker.Client, namespace string) *collector.Collector {
+		var w collector.Writer = shared
+		if namespace != "" {
+			w = collector.NewNamespaceWriter(shared, namespace)
+		}
+
+		col := collector.NewCollector(client, w, time.Duration(*i))
+		col.SetJitter(*ij)
+		col.SetAligned(*al)
+		col.SetMaxClockSkew(*ck)
+		col.SetDiscoveryInterval(*di)
+		col.SetExecProbes(*ep)
+		col.SetScraping(*se)
+		col.SetLogPatterns(patterns)
+		col.SetReachability(reachTCP, reachICMP)
+		col.SetTopProcesses(*tp)
+		col.SetEndOfLife(endOfLife)
+		if *sh == 0 {
+			col.SetDaemonInfoInterval(*dd)
+		}
+		col.SetNotifiedEvents(splitList(*ne))
+		col.SetUnavailableTimeout(*ut)
+		col.SetAdaptiveSampling(*sl, *sx)
+		col.SetDisabledFamilies(disabledFamilies())
+		col.SetContainerFilters(filters)
+		col.SetInspectCacheTTL(*ic)
+		col.SetInspectRetries(*rc, *rb)
+		col.SetIdentityExtractor(identityExtractor())
+		if *cn != "" {
+			col.SetDiscoverer(collector.NewListDiscoverer(client, splitList(*cn)))
+		}
+		metadata := collector.ChainMetadataExtractor{}
+		if colors != nil {
+			metadata = append(metadata, colors)
+		}
+		if *cu != "" {
+			metadata = append(metadata, collector.NewChronosAPIExtractor(*cu, *ct))
+		}
+		if *cr > 0 {
+			metadata = append(metadata, collector.ComposeExtractor{})
+		}
+		if len(metadata) > 0 {
+			col.SetMetadataExtractor(metadata)
+		}
+		col.SetQueue(*qs, policy)
+		col.SetVersion(version)
+		col.SetAppFilter(include, exclude)
+		col.SetFilterRules(rules)
+		col.SetShard(*sh, *sn)
+
+		return col
+	}
+
+	cols := []*collector.Collector{newCol(client, endpoints[0].namespace)}
+	for _, endpoint := range endpoints[1:] {
+		client, err := newClient(endpoint.endpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cols = append(cols, newCol(client, endpoint.namespace))
+	}
+
+	// the first collector reports the host it runs on and
+	// is the one that health checks and debug listener see
+	col := cols[0]
+	if *du && *sh == 0 {
+		col.SetDaemonUsage(*pp, *cp)
+	}
+	if *hm && *sh == 0 {
+		col.SetHostMetrics(*pp, *hr)
+	}
+	if *hi {
+		col.SetHighResolution(*pp, *cp)
+	}
+	if kubelet != nil {
+		col.SetDiscoverer(kubelet)
+	}
+
+	targets := make([]reloadTarget, len(cols))
+	for n, col := range cols {
+		targets[n] = col
+	}
+
+	// reload applies filters, interval and writers from flags, environment
+	// and config file again, monitored containers keep their stats streams
+	reload := func() error {
+		err := loadSettings(flag.CommandLine, cf)
+		if err != nil {
+			return err
+		}
+
+		level, err := collector.ParseLogLevel(*ll)
+		if err != nil {
+			return err
+		}
+
+		format, err := collector.ParseLogFormat(*lf)
+		if err != nil {
+			return err
+		}
+
+		include, exclude, err := newFilter()
+		if err != nil {
+			return err
+		}
+
+		host, err = resolveHost()
+		if err != nil {
+			return err
+		}
+
+		writer, err := newPipeline()
+		if err != nil {
+			return err
+		}
+
+		err = collector.SetLogOutput(*lo)
+		if err != nil {
+			return err
+		}
+
+		collector.SetLogLevel(level)
+		collector.SetLogFormat(format)
+
+		return applyReload(targets, shared, writer, reloadSettings{
+			include:  include,
+			exclude:  exclude,
+			interval: time.Duration(*i),
+			jitter:   *ij,
+			aligned:  *al,
+			disabled: disabledFamilies(),
+		})
+	}
+
+	if *da != "" {
+		admin := adminOptions{rules: rules, discover: func() error {
+			for _, col := range cols {
+				err := col.Discover()
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}}
+
+		if *at != "" {
+			admin.token, err = readAdminToken(*at)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		err = listenDebug(*da, col, host, admin)
+		if err != nil {
+			log.Fatalf("error starting debug listener: %s", err)
+		}
+	}
+
+	go notifySystemd(col)
+
+	if *ca != "" {
+		go reportConsul(newConsulAgent(*ca, *cs, host, *cl), col)
+	}
+
+	collector.Logf(collector.LogInfo, "starting collector %s (commit %s, built %s)", version, commit, buildDate)
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+
+		for range ch {
+			err := reload()
+			if err != nil {
+				collector.Logf(collector.LogError, "error reloading configuration: %s", err)
+				continue
+			}
+
+			collector.Logf(collector.LogInfo, "configuration reloaded")
+		}
+	}()
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGUSR1)
+
+		for range ch {
+			for _, col := range cols {
+				ctx, cancel := context.WithTimeout(context.Background(), triggerTimeout)
+				n, err := col.Trigger(ctx, "")
+				cancel()
+
+				if err != nil {
+					collector.Logf(collector.LogWarn, "error triggering samples: %s", err)
+					continue
+				}
+
+				collector.Logf(collector.LogInfo, "triggered samples of %d containers", n)
+			}
+		}
+	}()
+
+	for _, col := range cols[1:] {
+		go func(col *collector.Collector) {
+			err := col.Run(context.Background())
+			if err != nil {
+				log.Fatal(err)
+			}
+		}(col)
+	}
+
+	err = col.Run(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseCommand removes subcommand from arguments and returns it,
+// empty string is returned when the first argument is a flag
+func parseCommand() string {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		return ""
+	}
+
+	command := os.Args[1]
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+
+	return command
+}
+
+// hostname returns COLLECTD_HOSTNAME that exec plugin of collectd sets,
+// so identifiers match the rest of metrics of collectd, or hostname
+// of the machine or empty string if it is unknown, so -host has
+// to be set explicitly
+func hostname() string {
+	if h := os.Getenv("COLLECTD_HOSTNAME"); h != "" {
+		return h
+	}
+
+	h, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return h
+}
+
+// collectdInterval returns interval of collectd from COLLECTD_INTERVAL
+// that exec plugin sets for processes it starts, in seconds with
+// fractions, zero is returned if collector is not started by collectd
+func collectdInterval() time.Duration {
+	seconds, err := strconv.ParseFloat(os.Getenv("COLLECTD_INTERVAL"), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// defaultInterval returns interval of collectd if collector
+// is started by exec plugin, so intervals don't drift apart
+func defaultInterval() time.Duration {
+	if interval := collectdInterval(); interval > 0 {
+		return interval
+	}
+
+	return time.Second
+}
+
+// dockerEndpoint returns docker endpoint from DOCKER_HOST
+// like docker cli does, local unix socket is used by default
+func dockerEndpoint() string {
+	if e := os.Getenv("DOCKER_HOST"); e != "" {
+		return e
+	}
+
+	return "unix:///var/run/docker.sock"
+}
+
+// dockerCertPath returns path to tls certs from DOCKER_CERT_PATH,
+// ~/.docker is used if only DOCKER_TLS_VERIFY is set like docker cli does
+func dockerCertPath() string {
+	if p := os.Getenv("DOCKER_CERT_PATH"); p != "" {
+		return p
+	}
+
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		return path.Join(os.Getenv("HOME"), ".docker")
+	}
+
+	return ""
+}
+
+// newKubelet creates kubelet client with token read from file
+// and certificate verified with ca if they are set
+func newKubelet(url string, tokenFile string, ca string) (*collector.Kubelet, error) {
+	token := ""
+	if tokenFile != "" {
+		b, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubelet token: %s", err)
+		}
+
+		token = strings.TrimSpace(string(b))
+	}
+
+	var config *tls.Config
+	if ca != "" {
+		var err error
+		config, err = collector.NewTLSConfig(ca, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubelet ca: %s", err)
+		}
+	}
+
+	return collector.NewKubelet(url, token, config), nil
+}
+
+// splitList splits comma separated list skipping empty items
+func splitList(s string) []string {
+	result := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// parseFilters parses comma separated docker filters
+// like label=monitored,ancestor=myimage
+func parseFilters(s string) (map[string][]string, error) {
+	filters := map[string][]string{}
+	for _, f := range splitList(s) {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("filter should look like name=value, got %q", f)
+		}
+
+		filters[parts[0]] = append(filters[parts[0]], parts[1])
+	}
+
+	return filters, nil
+}
+
+// compileRegexp compiles regexp unless it's empty
+func compileRegexp(s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile(s)
+}
+
+// secondsValue is a duration flag that also accepts plain numbers
+// as seconds, like -interval used to before it became a duration
+type secondsValue time.Duration
+
+func newSecondsFlag(name string, value time.Duration, usage string) *secondsValue {
+	v := secondsValue(value)
+	flag.Var(&v, name, usage)
+	return &v
+}
+
+func (v *secondsValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		*v = secondsValue(time.Duration(n) * time.Second)
+		return nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*v = secondsValue(d)
+
+	return nil
+}
+
+func (v *secondsValue) String() string {
+	return time.Duration(*v).String()
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// minAPIVersion is the oldest docker api version that reports
+// network stats of containers that collector reads
+const minAPIVersion = "1.21"
+
+// preflightClient is the part of docker client used by preflight checks
+type preflightClient interface {
+	Ping() error
+	Version() (*docker.Env, error)
+	Info() (*docker.DockerInfo, error)
+	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+	Stats(opts docker.StatsOptions) error
+}
+
+// preflightCheck is a named check of deployment, errors of
+// checks should say what to do to fix the problem
+type preflightCheck struct {
+	name  string
+	check func() error
+}
+
+// preflight runs checks in order and prints their results,
+// checks after the first failed required docker access are
+// skipped, since they would fail for the same reason
+func preflight(checks []preflightCheck, out io.Writer) bool {
+	ok := true
+
+	for i, c := range checks {
+		err := c.check()
+		if err == nil {
+			fmt.Fprintf(out, "ok    %s\n", c.name)
+			continue
+		}
+
+		ok = false
+		fmt.Fprintf(out, "FAIL  %s: %s\n", c.name, err)
+
+		if i == 0 {
+			for _, skipped := range checks[1:] {
+				fmt.Fprintf(out, "skip  %s\n", skipped.name)
+			}
+
+			break
+		}
+	}
+
+	return ok
+}
+
+// dockerChecks returns checks of access to docker at endpoint, the first
+// check is access to docker, the rest depend on it
+func dockerChecks(endpoint string, client preflightClient) []preflightCheck {
+	return []preflightCheck{
+		{"docker access", func() error { return checkDockerAccess(endpoint, client) }},
+		{"docker api version", func() error { return checkAPIVersion(client) }},
+		{"cgroup visibility", func() error { return checkCgroups(client) }},
+	}
+}
+
+// checkDockerAccess checks that docker socket exists,
+// collector is allowed to use it and docker responds
+func checkDockerAccess(endpoint string, client preflightClient) error {
+	if strings.HasPrefix(endpoint, "unix://") && abstractSocket(endpoint) == "" {
+		socket := strings.TrimPrefix(endpoint, "unix://")
+
+		_, err := os.Stat(socket)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("socket %s doesn't exist, mount it into container of collector with -v %s:%s or set -endpoint", socket, socket, socket)
+		}
+	}
+
+	err := client.Ping()
+	if err == nil {
+		return nil
+	}
+
+	if os.IsPermission(errors.Unwrap(err)) || strings.Contains(err.Error(), "permission denied") {
+		return fmt.Errorf("%s, run collector as user in docker group or as root", err)
+	}
+
+	return fmt.Errorf("%s, check that docker is running and -endpoint and -cert are right", err)
+}
+
+// checkAPIVersion checks that docker is new enough for collector
+func checkAPIVersion(client preflightClient) error {
+	env, err := client.Version()
+	if err != nil {
+		return err
+	}
+
+	version := env.Get("ApiVersion")
+
+	current, err := docker.NewAPIVersion(version)
+	if err != nil {
+		return fmt.Errorf("docker reported unexpected api version %q: %s", version, err)
+	}
+
+	min, _ := docker.NewAPIVersion(minAPIVersion)
+	if current.LessThan(min) {
+		return fmt.Errorf("docker api version %s is older than %s, upgrade docker to 1.9 or newer", version, minAPIVersion)
+	}
+
+	return nil
+}
+
+// checkCgroups checks that docker can read cgroups of containers,
+// otherwise stats of containers are zero and nothing useful is collected
+func checkCgroups(client preflightClient) error {
+	info, err := client.Info()
+	if err != nil {
+		return err
+	}
+
+	if !info.MemoryLimit {
+		return errors.New("docker has no memory cgroup support, boot kernel with cgroup_enable=memory")
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{})
+	if err != nil {
+		return err
+	}
+
+	// cgroups can only be checked on a running container
+	if len(containers) == 0 {
+		return nil
+	}
+
+	ch := make(chan *docker.Stats, 1)
+
+	err = client.Stats(docker.StatsOptions{ID: containers[0].ID, Stats: ch, Stream: false, Timeout: 10 * time.Second})
+	if err != nil {
+		return fmt.Errorf("error getting stats of container %s: %s", containers[0].ID, err)
+	}
+
+	stats := <-ch
+	if stats == nil || stats.MemoryStats.Usage == 0 || stats.CPUStats.CPUUsage.TotalUsage == 0 {
+		return fmt.Errorf("stats of container %s are empty, docker can't read cgroups, check cgroup mounts and driver of docker", containers[0].ID)
+	}
+
+	return nil
+}
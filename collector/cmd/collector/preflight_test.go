@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// fakePreflightClient is docker that reports specified
+// api version, memory cgroup support and stats
+type fakePreflightClient struct {
+	pingErr     error
+	apiVersion  string
+	memoryLimit bool
+	stats       *docker.Stats
+}
+
+func (c fakePreflightClient) Ping() error {
+	return c.pingErr
+}
+
+func (c fakePreflightClient) Version() (*docker.Env, error) {
+	return &docker.Env{"ApiVersion=" + c.apiVersion}, nil
+}
+
+func (c fakePreflightClient) Info() (*docker.DockerInfo, error) {
+	return &docker.DockerInfo{MemoryLimit: c.memoryLimit}, nil
+}
+
+func (c fakePreflightClient) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	return []docker.APIContainers{{ID: "0123456789ab"}}, nil
+}
+
+func (c fakePreflightClient) Stats(opts docker.StatsOptions) error {
+	opts.Stats <- c.stats
+	close(opts.Stats)
+	return nil
+}
+
+func TestPreflight(t *testing.T) {
+	healthy := &docker.Stats{}
+	healthy.MemoryStats.Usage = 1
+	healthy.CPUStats.CPUUsage.TotalUsage = 1
+
+	tests := []struct {
+		client   fakePreflightClient
+		ok       bool
+		expected string
+	}{
+		{fakePreflightClient{apiVersion: "1.24", memoryLimit: true, stats: healthy}, true, "ok    cgroup visibility"},
+		{fakePreflightClient{pingErr: errors.New("dial unix /var/run/docker.sock: connect: permission denied")}, false, "docker group"},
+		{fakePreflightClient{pingErr: errors.New("no")}, false, "skip  cgroup visibility"},
+		{fakePreflightClient{apiVersion: "1.18", memoryLimit: true, stats: healthy}, false, "upgrade docker"},
+		{fakePreflightClient{apiVersion: "1.24", memoryLimit: false}, false, "cgroup_enable=memory"},
+		{fakePreflightClient{apiVersion: "1.24", memoryLimit: true, stats: &docker.Stats{}}, false, "can't read cgroups"},
+	}
+
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+
+		ok := preflight(dockerChecks("tcp://127.0.0.1:2375", test.client), out)
+		if ok != test.ok {
+			t.Errorf("expected preflight to return %t, got %t:\n%s", test.ok, ok, out)
+		}
+
+		if !strings.Contains(out.String(), test.expected) {
+			t.Errorf("expected %q in output:\n%s", test.expected, out)
+		}
+	}
+}
+
+// TestPreflightCommand runs preflight subcommand of the test binary,
+// which runs main when COLLECTOR_TEST_MAIN is set, so subcommands
+// go through the same dispatch as when collector is started
+func TestPreflightCommand(t *testing.T) {
+	if os.Getenv("COLLECTOR_TEST_MAIN") != "" {
+		os.Args = append([]string{os.Args[0]}, strings.Fields(os.Getenv("COLLECTOR_TEST_MAIN"))...)
+		main()
+		return
+	}
+
+	socket := filepath.Join(os.TempDir(), "collector-test-missing.sock")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPreflightCommand$")
+	cmd.Env = append(os.Environ(), "COLLECTOR_TEST_MAIN=preflight -endpoint unix://"+socket)
+
+	out, err := cmd.CombinedOutput()
+
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("expected preflight to exit with 1 when docker is missing, got %v:\n%s", err, out)
+	}
+
+	if !strings.Contains(string(out), "FAIL  docker access: socket "+socket+" doesn't exist") {
+		t.Errorf("expected preflight to check docker access, got:\n%s", out)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+	"github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/http/httpproxy"
+	xproxy "golang.org/x/net/proxy"
+)
+
+// proxyDialTimeout is how long connecting to proxy can take
+const proxyDialTimeout = 30 * time.Second
+
+// proxyFunc returns proxy to use for request to url or nil to connect directly
+type proxyFunc func(*url.URL) (*url.URL, error)
+
+// dockerProxy returns function choosing proxy of docker endpoints from
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY, explicit proxy url is used instead
+// of the first two, NO_PROXY is honored either way
+func dockerProxy(explicit string) (proxyFunc, error) {
+	config := httpproxy.FromEnvironment()
+
+	if explicit != "" {
+		u, err := url.Parse(explicit)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("docker proxy should be url like http://proxy:3128, got %q", explicit)
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported scheme of docker proxy: %s", u.Scheme)
+		}
+
+		config.HTTPProxy = explicit
+		config.HTTPSProxy = explicit
+	}
+
+	return config.ProxyFunc(), nil
+}
+
+// setProxy makes requests of client to tcp endpoint go through proxy,
+// docker client dials endpoint itself to watch events, so its dialer
+// is replaced with one tunneling through proxy, which docker client
+// doesn't support for tls endpoints
+func setProxy(client *docker.Client, endpoint string, proxy proxyFunc) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "unix" {
+		return nil
+	}
+
+	scheme := "http"
+	if client.TLSConfig != nil || u.Scheme == "https" {
+		scheme = "https"
+	}
+
+	if transport, ok := client.HTTPClient.Transport.(*http.Transport); ok {
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			return proxy(r.URL)
+		}
+	}
+
+	through, err := proxy(&url.URL{Scheme: scheme, Host: u.Host})
+	if err != nil || through == nil {
+		return err
+	}
+
+	if client.TLSConfig != nil {
+		collector.Logf(collector.LogWarn, "docker events of %s are not watched through proxy %s with tls, set -discovery-interval to find new containers", endpoint, through.Redacted())
+		return nil
+	}
+
+	collector.Logf(collector.LogInfo, "connecting to docker at %s through proxy %s", endpoint, through.Redacted())
+
+	if through.Scheme == "socks5" {
+		dialer, err := xproxy.FromURL(through, &net.Dialer{Timeout: proxyDialTimeout})
+		if err != nil {
+			return err
+		}
+
+		client.Dialer = dialer
+		return nil
+	}
+
+	client.Dialer = connectDialer{proxy: through}
+
+	return nil
+}
+
+// connectDialer tunnels connections through http proxy with CONNECT
+type connectDialer struct {
+	proxy *url.URL
+}
+
+func (d connectDialer) Dial(network, address string) (net.Conn, error) {
+	host := d.proxy.Host
+	if d.proxy.Port() == "" {
+		host = net.JoinHostPort(d.proxy.Hostname(), map[string]string{"http": "80", "https": "443"}[d.proxy.Scheme])
+	}
+
+	dialer := &net.Dialer{Timeout: proxyDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if d.proxy.Scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: d.proxy.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+
+	conn.SetDeadline(time.Now().Add(proxyDialTimeout))
+
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", d.proxy.Redacted(), address, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return tunnelConn{Conn: conn, r: r}, nil
+}
+
+// tunnelConn reads what proxy sent after its response first
+type tunnelConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c tunnelConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestDockerProxy(t *testing.T) {
+	os.Setenv("NO_PROXY", "docker.internal")
+	defer os.Unsetenv("NO_PROXY")
+
+	proxy, err := dockerProxy("http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		endpoint string
+		proxied  bool
+	}{
+		{"http://docker.example.com:2375", true},
+		{"https://docker.example.com:2376", true},
+		{"http://docker.internal:2375", false},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.endpoint)
+
+		through, err := proxy(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if proxied := through != nil; proxied != test.proxied {
+			t.Errorf("expected %s to be proxied %v, got proxy %v", test.endpoint, test.proxied, through)
+		}
+	}
+
+	for _, invalid := range []string{"proxy.example.com:3128", "ftp://proxy.example.com"} {
+		if _, err := dockerProxy(invalid); err == nil {
+			t.Errorf("expected error with proxy %s", invalid)
+		}
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	defer daemon.Close()
+
+	forwarded, tunneled := int64(0), int64(0)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			atomic.AddInt64(&forwarded, 1)
+
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			defer resp.Body.Close()
+
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+
+		atomic.AddInt64(&tunneled, 1)
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+
+		go func() {
+			io.Copy(upstream, buf)
+			upstream.Close()
+		}()
+
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+
+	defer proxy.Close()
+
+	through, _ := url.Parse(proxy.URL)
+	endpoint := "tcp://" + daemon.Listener.Addr().String()
+
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = setProxy(client, endpoint, func(*url.URL) (*url.URL, error) { return through, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Ping()
+	if err != nil {
+		t.Fatalf("error pinging docker through proxy: %s", err)
+	}
+
+	if n := atomic.LoadInt64(&forwarded); n != 1 {
+		t.Errorf("expected 1 request forwarded by proxy, got %d", n)
+	}
+
+	conn, err := client.Dialer.Dial("tcp", daemon.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("error connecting to docker through proxy: %s", err)
+	}
+
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", "/_ping", nil)
+	req.Host = daemon.Listener.Addr().String()
+
+	err = req.Write(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected ping through tunnel to succeed, got %s", resp.Status)
+	}
+
+	if n := atomic.LoadInt64(&tunneled); n != 1 {
+		t.Errorf("expected 1 connection tunneled by proxy, got %d", n)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// reloadSettings are settings of running collectors changed on reload
+type reloadSettings struct {
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+	interval time.Duration
+	jitter   time.Duration
+	aligned  bool
+	disabled []string
+}
+
+// reloadTarget is the part of collector that reload reconfigures
+type reloadTarget interface {
+	SetAppFilter(include, exclude *regexp.Regexp)
+	SetInterval(interval time.Duration)
+	SetJitter(jitter time.Duration)
+	SetAligned(aligned bool)
+	SetDisabledFamilies(families []string)
+	Discover() error
+}
+
+// applyReload applies settings to collectors and replaces pipeline of
+// shared writer, previous pipeline is closed to write samples it holds,
+// containers are discovered again to apply new filters to them
+func applyReload(targets []reloadTarget, shared *sharedWriter, writer collector.Writer, settings reloadSettings) error {
+	for _, target := range targets {
+		target.SetAppFilter(settings.include, settings.exclude)
+		target.SetInterval(settings.interval)
+		target.SetJitter(settings.jitter)
+		target.SetAligned(settings.aligned)
+		target.SetDisabledFamilies(settings.disabled)
+	}
+
+	err := shared.set(writer).Close()
+	if err != nil {
+		collector.Logf(collector.LogWarn, "error closing previous writer: %s", err)
+	}
+
+	for _, target := range targets {
+		err = target.Discover()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+type fakeReloadTarget struct {
+	include     *regexp.Regexp
+	exclude     *regexp.Regexp
+	interval    time.Duration
+	jitter      time.Duration
+	aligned     bool
+	disabled    []string
+	discovered  int
+	discoverErr error
+}
+
+func (t *fakeReloadTarget) SetAppFilter(include, exclude *regexp.Regexp) {
+	t.include = include
+	t.exclude = exclude
+}
+
+func (t *fakeReloadTarget) SetInterval(interval time.Duration) {
+	t.interval = interval
+}
+
+func (t *fakeReloadTarget) SetJitter(jitter time.Duration) {
+	t.jitter = jitter
+}
+
+func (t *fakeReloadTarget) SetAligned(aligned bool) {
+	t.aligned = aligned
+}
+
+func (t *fakeReloadTarget) SetDisabledFamilies(families []string) {
+	t.disabled = families
+}
+
+func (t *fakeReloadTarget) Discover() error {
+	t.discovered++
+	return t.discoverErr
+}
+
+type pipelineWriter struct {
+	written  int
+	closed   bool
+	closeErr error
+}
+
+func (w *pipelineWriter) Write(s collector.Stats) error {
+	w.written++
+	return nil
+}
+
+func (w *pipelineWriter) Flush() error {
+	return nil
+}
+
+func (w *pipelineWriter) Close() error {
+	w.closed = true
+	return w.closeErr
+}
+
+func TestApplyReload(t *testing.T) {
+	previous := &pipelineWriter{closeErr: errors.New("backend is down")}
+	shared := newSharedWriter(previous)
+
+	targets := []*fakeReloadTarget{{}, {}}
+
+	settings := reloadSettings{
+		include:  regexp.MustCompile("^web"),
+		exclude:  regexp.MustCompile("canary$"),
+		interval: 10 * time.Second,
+		jitter:   time.Second,
+		aligned:  true,
+		disabled: []string{"network"},
+	}
+
+	next := &pipelineWriter{}
+
+	err := applyReload([]reloadTarget{targets[0], targets[1]}, shared, next, settings)
+	if err != nil {
+		t.Fatalf("unexpected reload error: %s", err)
+	}
+
+	for _, target := range targets {
+		if target.include != settings.include || target.exclude != settings.exclude {
+			t.Errorf("expected app filter to be applied, got %v and %v", target.include, target.exclude)
+		}
+
+		if target.interval != 10*time.Second || target.jitter != time.Second || !target.aligned {
+			t.Errorf("expected interval settings to be applied, got %s, %s and %v", target.interval, target.jitter, target.aligned)
+		}
+
+		if len(target.disabled) != 1 || target.disabled[0] != "network" {
+			t.Errorf("expected disabled families to be applied, got %v", target.disabled)
+		}
+
+		if target.discovered != 1 {
+			t.Errorf("expected containers to be discovered again, got %d discoveries", target.discovered)
+		}
+	}
+
+	if !previous.closed {
+		t.Errorf("expected previous pipeline to be closed, error closing it is only logged")
+	}
+
+	err = shared.Write(collector.Stats{App: "web"})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if next.written != 1 || previous.written != 0 {
+		t.Errorf("expected samples to go to new pipeline, got %d new and %d previous", next.written, previous.written)
+	}
+}
+
+func TestApplyReloadDiscoverError(t *testing.T) {
+	failing := &fakeReloadTarget{discoverErr: errors.New("docker is down")}
+	other := &fakeReloadTarget{}
+
+	next := &pipelineWriter{}
+	shared := newSharedWriter(&pipelineWriter{})
+
+	err := applyReload([]reloadTarget{failing, other}, shared, next, reloadSettings{interval: time.Second})
+	if err == nil {
+		t.Fatal("expected discovery error to be returned")
+	}
+
+	if other.interval != time.Second || other.discovered != 0 {
+		t.Errorf("expected settings to be applied before discovery stops, got %s and %d discoveries", other.interval, other.discovered)
+	}
+
+	shared.Write(collector.Stats{})
+
+	if next.written != 1 {
+		t.Errorf("expected new pipeline to be in place after discovery error")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// statusTemplate is the status page of debug listener
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>collectd-docker on {{.Host}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 12px 2px 0; text-align: left; }
+.unhealthy { color: #c00; }
+</style>
+</head>
+<body>
+<h1>collectd-docker {{.Version}} on {{.Host}}</h1>
+{{if .Health}}<p class="unhealthy">unhealthy: {{.Health}}</p>{{else}}<p>healthy</p>{{end}}
+<p>last write: {{if .LastWrite.IsZero}}never{{else}}{{.LastWrite.Format "2006-01-02 15:04:05"}} ({{.Since .LastWrite}} ago){{end}}</p>
+<h2>Counters</h2>
+<table>
+{{range .Counters}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+<h2>Containers ({{len .Containers}})</h2>
+<table>
+<tr><th>id</th><th>app</th><th>task</th><th>image</th><th>last sample</th></tr>
+{{range .Containers}}<tr><td><a href="/containers/{{.ID}}">{{printf "%.12s" .ID}}</a></td><td>{{.App}}</td><td>{{.Task}}</td><td>{{.Image}}</td><td>{{if .Sample}}{{$.Since .Sample.Time}} ago{{else}}none yet{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// statusCounter is a self metric of collector on status page
+type statusCounter struct {
+	Name  string
+	Value uint64
+}
+
+// status is what status page shows
+type status struct {
+	Host       string
+	Version    string
+	Health     error
+	LastWrite  time.Time
+	Counters   []statusCounter
+	Containers []collector.ContainerSnapshot
+}
+
+// Since returns time since t rounded to seconds for the page
+func (s status) Since(t time.Time) time.Duration {
+	return time.Since(t).Truncate(time.Second)
+}
+
+// statusHandler serves status page of collector with its health,
+// self metrics and monitored containers with times of their last samples
+func statusHandler(col *collector.Collector, host string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		s := status{
+			Host:       host,
+			Version:    version,
+			Health:     col.Health(),
+			LastWrite:  col.LastWrite(),
+			Containers: col.Snapshot(),
+		}
+
+		for name, value := range col.SelfMetrics() {
+			s.Counters = append(s.Counters, statusCounter{Name: name, Value: value})
+		}
+
+		sort.Slice(s.Counters, func(i, j int) bool {
+			return s.Counters[i].Name < s.Counters[j].Name
+		})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		err := statusTemplate.Execute(w, s)
+		if err != nil {
+			collector.Logf(collector.LogWarn, "error rendering status page: %s", err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// sdNotify sends state to systemd if collector is started as
+// a service with Type=notify, it's no-op without NOTIFY_SOCKET
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// abstract socket names start with @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns interval of systemd watchdog
+// from WATCHDOG_USEC or zero if watchdog is not enabled
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifySystemd signals readiness once collector discovers containers
+// and then pings systemd watchdog while samples are being written,
+// wedged collector stops pinging and gets restarted by systemd
+func notifySystemd(c *collector.Collector) {
+	<-c.Ready()
+
+	err := sdNotify("READY=1")
+	if err != nil {
+		collector.Logf(collector.LogWarn, "error notifying systemd: %s", err)
+	}
+
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	for range time.Tick(interval / 2) {
+		if time.Since(c.LastWrite()) > interval {
+			collector.Logf(collector.LogWarn, "nothing was written for %s, skipping watchdog ping", interval)
+			continue
+		}
+
+		err := sdNotify("WATCHDOG=1")
+		if err != nil {
+			collector.Logf(collector.LogWarn, "error pinging systemd watchdog: %s", err)
+		}
+	}
+}
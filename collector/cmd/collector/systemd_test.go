@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	err := sdNotify("READY=1")
+	if err != nil {
+		t.Errorf("expected no-op without NOTIFY_SOCKET, got %s", err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "notify")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	err = sdNotify("READY=1")
+	if err != nil {
+		t.Fatalf("error notifying systemd: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatalf("error reading notification: %s", err)
+	}
+
+	if state := string(b[:n]); state != "READY=1" {
+		t.Errorf("expected READY=1, got %q", state)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing"))
+
+	if sdNotify("READY=1") == nil {
+		t.Errorf("expected error notifying missing socket")
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		usec     string
+		pid      string
+		expected time.Duration
+	}{
+		{"", "", 0},
+		{"nope", "", 0},
+		{"0", "", 0},
+		{"-1", "", 0},
+		{"30000000", "", 30 * time.Second},
+		{"30000000", pid, 30 * time.Second},
+		{"30000000", "1", 0},
+	}
+
+	for _, test := range tests {
+		t.Setenv("WATCHDOG_USEC", test.usec)
+		t.Setenv("WATCHDOG_PID", test.pid)
+
+		if interval := watchdogInterval(); interval != test.expected {
+			t.Errorf("expected %s for WATCHDOG_USEC=%q and WATCHDOG_PID=%q, got %s", test.expected, test.usec, test.pid, interval)
+		}
+	}
+}
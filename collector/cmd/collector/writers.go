@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	collector "github.com/bobrik/collectd-docker/collector"
+)
+
+// writerFlags holds flag values of writer options
+// keyed by writer name and then by option name
+var writerFlags = map[string]map[string]*string{}
+
+// registerWriterFlags defines -<writer>-<option> flag
+// for every option of every registered writer
+func registerWriterFlags() {
+	for _, r := range collector.RegisteredWriters() {
+		writerFlags[r.Name] = map[string]*string{}
+		for _, o := range r.Options {
+			writerFlags[r.Name][o.Name] = flag.String(r.Name+"-"+o.Name, o.Default, o.Usage+" ("+r.Name+" writer)")
+		}
+	}
+}
+
+// writerNames returns comma separated names of registered writers
+func writerNames() string {
+	names := []string{}
+	for _, r := range collector.RegisteredWriters() {
+		names = append(names, r.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// newWriter creates writer by name with options from flags
+func newWriter(name string, host string) (collector.Writer, error) {
+	options := collector.WriterOptions{}
+	for k, v := range writerFlags[name] {
+		options[k] = *v
+	}
+
+	return collector.NewWriter(name, host, options)
+}
@@ -1,46 +1,381 @@
 package collector
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 )
 
+// selfApp and selfTask are app and task of collector's own metrics
+const (
+	selfApp  = "_collector"
+	selfTask = "self"
+)
+
+// eventsTask is the task of app level counters of docker events
+const eventsTask = "_events"
+
+// countedEvents are docker events that are counted for every app
+// and reported as events.<event> counters of eventsTask
+var countedEvents = map[string]bool{
+	"start":   true,
+	"restart": true,
+	"stop":    true,
+	"die":     true,
+	"kill":    true,
+	"oom":     true,
+}
+
+// dockerCheckInterval is the interval of checking
+// that docker daemon is available
+const dockerCheckInterval = 5 * time.Second
+
+// DropPolicy defines what happens to new samples
+// when the queue of samples to write is full
+type DropPolicy int
+
+const (
+	// DropPolicyBlock blocks monitors until there is room in the queue
+	DropPolicyBlock DropPolicy = iota
+	// DropPolicyDropOldest drops the oldest queued sample
+	DropPolicyDropOldest
+	// DropPolicyDropNewest drops the new sample
+	DropPolicyDropNewest
+)
+
+// ParseDropPolicy parses drop policy from its name:
+// block, drop-oldest or drop-newest
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch s {
+	case "block":
+		return DropPolicyBlock, nil
+	case "drop-oldest":
+		return DropPolicyDropOldest, nil
+	case "drop-newest":
+		return DropPolicyDropNewest, nil
+	default:
+		return DropPolicyBlock, fmt.Errorf("unknown drop policy: %s", s)
+	}
+}
+
 // Collector is responsible for discovering containers
 // for monitoring and writing stats
 type Collector struct {
-	client     *docker.Client
-	ch         chan Stats
-	mutex      sync.Mutex
-	registered map[string]struct{}
-	interval   int
-}
+	// lastWrite is unix time in nanoseconds of the last written sample,
+	// unavailableSince is unix time in nanoseconds since when docker daemon
+	// is unavailable or zero, discoveryErrors and streamErrors count failed
+	// discoveries and stats streams, heartbeats count reports of self
+	// metrics, they are the first fields to be 64-bit aligned for atomic
+	// access
+	lastWrite        int64
+	unavailableSince int64
+	discoveryErrors  uint64
+	streamErrors     uint64
+	heartbeats       uint64
 
-// NewCollector creates new Collector with specified docker client,
-// collectd stats writer and stat updating interval
-func NewCollector(client *docker.Client, w CollectdWriter, interval int) *Collector {
-	ch := make(chan Stats)
+	client       *docker.Client
+	writer       Writer
+	ch           chan Stats
+	policy       DropPolicy
+	dropped      uint64
+	blocked      uint64
+	mutex        sync.Mutex
+	registered   map[string]*Monitor
+	interval     time.Duration
+	jitter       time.Duration
+	aligned      bool
+	maxSkew      time.Duration
+	retries      int
+	retryBackoff time.Duration
+	discovery    time.Duration
+	notified     map[string]bool
+	events       map[string]map[string]uint64
+	disabled     []string
+	filters      map[string][]string
+	identity     IdentityExtractor
+	filter       Filter
+	shard        int
+	shards       int
+	metadata     MetadataExtractor
+	hooks        Hooks
+	discoverer   Discoverer
+	probes       bool
+	scraping     bool
+	logPatterns  []LogPattern
+	reachTCP     bool
+	reachICMP    bool
+	topProcesses int
+	endOfLife    EndOfLife
+	started      time.Time
+	daemonInfo   time.Duration
+	daemonProc   string
+	daemonCgroup string
+	hiresProc    string
+	hiresCgroup  string
+	hostProc     string
+	hostDataRoot string
+	cache        *inspectCache
+	downAfter    time.Duration
+	latency      *latencyTracker
+	slowAfter    time.Duration
+	maxStretch   int
+	stretch      int
+	include      *regexp.Regexp
+	exclude      *regexp.Regexp
+	rules        *RuleSet
+	version      string
+	ready        chan struct{}
+	ctx          context.Context
 
-	// TODO: this can be better, need to figure out how
-	go func() {
-		for s := range ch {
-			w.Write(s)
-		}
-	}()
+	// writerMutex guards writer that can be replaced while running
+	writerMutex sync.Mutex
+	// flushes are requests to flush writer, see Trigger
+	flushes chan chan error
+	// pauses are containers and apps with paused monitoring
+	pauses pauseSet
+}
 
+// NewCollector creates new Collector with specified docker client,
+// stats writer and stat updating interval
+func NewCollector(client *docker.Client, w Writer, interval time.Duration) *Collector {
 	return &Collector{
 		client:     client,
-		ch:         ch,
+		writer:     w,
+		ch:         make(chan Stats),
+		policy:     DropPolicyBlock,
 		mutex:      sync.Mutex{},
-		registered: map[string]struct{}{},
+		registered: map[string]*Monitor{},
 		interval:   interval,
+		identity:   DefaultIdentityExtractor,
+		latency:    &latencyTracker{},
+		stretch:    1,
+		ready:      make(chan struct{}),
+		flushes:    make(chan chan error),
+		started:    time.Now(),
+	}
+}
+
+// SetQueue sets size of the queue of samples waiting to be written
+// and policy to apply when it is full, unbuffered queue can't hold
+// samples to drop, so it always blocks, it should be called before Run
+func (c *Collector) SetQueue(size int, policy DropPolicy) {
+	if size < 1 {
+		size = 0
+		policy = DropPolicyBlock
+	}
+
+	c.ch = make(chan Stats, size)
+	c.policy = policy
+}
+
+// SetAppFilter sets regexps that app names have to match and not
+// to match to be monitored, nil regexp is not checked, samples of
+// already monitored containers are filtered as well
+func (c *Collector) SetAppFilter(include, exclude *regexp.Regexp) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.include = include
+	c.exclude = exclude
+}
+
+// SetVersion sets version of collector that is reported as
+// collector.version.<version> metric, it should be called before Run
+func (c *Collector) SetVersion(version string) {
+	c.version = version
+}
+
+// SetInterval sets stat updating interval of new
+// and already monitored containers
+func (c *Collector) SetInterval(interval time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interval = interval
+	for _, m := range c.registered {
+		m.setInterval(c.stretched(interval))
+	}
+}
+
+// SetJitter sets max random offset of sampling of new containers,
+// so many containers are not sampled and written at the same instant
+func (c *Collector) SetJitter(jitter time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.jitter = jitter
+}
+
+// SetAligned makes samples of new containers taken on interval
+// boundaries, like :00, :10 and :20 for 10s interval, with timestamps
+// truncated to boundaries, so samples from many hosts line up
+func (c *Collector) SetAligned(aligned bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.aligned = aligned
+}
+
+// SetMaxClockSkew sets how far read time of stats from docker can be
+// from local clock for samples of new containers, samples read further
+// away are timestamped with local time, 0 trusts docker completely
+func (c *Collector) SetMaxClockSkew(skew time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.maxSkew = skew
+}
+
+// SetInspectRetries sets how many times inspection of new containers is
+// retried when it fails or container is not started yet, with jittered
+// backoff doubling from backoff, so containers inspected right as they
+// start aren't skipped, 0 retries or non-positive backoff doesn't retry,
+// it should be called before Run
+func (c *Collector) SetInspectRetries(retries int, backoff time.Duration) {
+	c.retries = retries
+	c.retryBackoff = backoff
+}
+
+// SetDiscoveryInterval sets interval of listing containers to find
+// the ones missed in docker events, 0 disables periodic discovery,
+// it should be called before Run
+func (c *Collector) SetDiscoveryInterval(interval time.Duration) {
+	c.discovery = interval
+}
+
+// SetNotifiedEvents sets docker events of monitored containers, like
+// start or die, that are written as notifications, it should be
+// called before Run
+func (c *Collector) SetNotifiedEvents(events []string) {
+	c.notified = map[string]bool{}
+	for _, e := range events {
+		c.notified[e] = true
+	}
+}
+
+// SetUnavailableTimeout sets how long docker daemon has to be unavailable
+// to write a notification about it, 0 disables notification,
+// it should be called before Run
+func (c *Collector) SetUnavailableTimeout(timeout time.Duration) {
+	c.downAfter = timeout
+}
+
+// SetDisabledFamilies sets metric families that are not decoded
+// from stats of new containers, it should match families that
+// are dropped by FamilyWriter to save cpu on decoding
+func (c *Collector) SetDisabledFamilies(families []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.disabled = families
+}
+
+// SetContainerFilters sets docker filters, like label or ancestor,
+// that containers have to pass to be inspected and monitored, so
+// containers that are not supposed to be monitored are skipped
+// by docker without inspecting them, it should be called before Run
+func (c *Collector) SetContainerFilters(filters map[string][]string) {
+	c.filters = filters
+}
+
+// SetIdentityExtractor sets how app and task of containers are
+// found instead of DefaultIdentityExtractor, it should be called
+// before Run
+func (c *Collector) SetIdentityExtractor(extractor IdentityExtractor) {
+	c.identity = extractor
+}
+
+// SetFilter sets filter that inspected containers have to match
+// to be monitored, nil filter matches every container,
+// it should be called before Run
+func (c *Collector) SetFilter(filter Filter) {
+	c.filter = filter
+}
+
+// SetMetadataExtractor sets how metadata attached to samples of
+// containers is found, nil disables metadata, it should be called
+// before Run
+func (c *Collector) SetMetadataExtractor(extractor MetadataExtractor) {
+	c.metadata = extractor
+}
+
+// SetDiscoverer sets where containers to monitor are discovered instead
+// of docker, docker events are not watched then and discoverer is polled
+// every discovery interval or every 10s if it is not set, it should be
+// called before Run
+func (c *Collector) SetDiscoverer(discoverer Discoverer) {
+	c.discoverer = discoverer
+}
+
+// SetHooks sets callbacks that are called on lifecycle events
+// of monitors, errors and samples, it should be called before Run
+func (c *Collector) SetHooks(hooks Hooks) {
+	c.hooks = hooks
+}
+
+// SetInspectCacheTTL sets how long results of inspecting containers
+// are reused for identity lookups, 0 disables caching, it should
+// be called before Run
+func (c *Collector) SetInspectCacheTTL(ttl time.Duration) {
+	c.cache = nil
+	if ttl > 0 {
+		c.cache = newInspectCache(c.client, ttl)
 	}
 }
 
-// Run stats loop that discovers containers and runs
-// monitoring tasks for them
-func (c *Collector) Run(interval int) error {
+// SetWriter replaces writer while collector is running and returns
+// the previous one, it is up to caller to close previous writer
+func (c *Collector) SetWriter(w Writer) Writer {
+	c.writerMutex.Lock()
+	defer c.writerMutex.Unlock()
+
+	previous := c.writer
+	c.writer = w
+
+	return previous
+}
+
+// Run stats loop that discovers containers and runs monitoring tasks
+// for them until ctx is done, stats streams of monitored containers are
+// stopped along with it and ctx error is returned
+func (c *Collector) Run(ctx context.Context) error {
+	c.mutex.Lock()
+	c.ctx = ctx
+	c.mutex.Unlock()
+
+	go c.write(ctx)
+	go c.reportSelf(ctx)
+	go c.checkDocker(ctx)
+
+	if c.slowAfter > 0 {
+		go c.adapt(ctx)
+	}
+
+	if c.daemonInfo > 0 {
+		go c.reportDaemonInfo(ctx)
+	}
+
+	if c.daemonProc != "" {
+		go c.reportDaemonUsage(ctx)
+	}
+
+	if c.hostProc != "" {
+		go c.reportHost(ctx)
+	}
+
+	if c.discoverer != nil {
+		return c.poll(ctx)
+	}
+
 	ch := make(chan *docker.APIEvents)
 	err := c.client.AddEventListener(ch)
 	if err != nil {
@@ -49,52 +384,462 @@ func (c *Collector) Run(interval int) error {
 
 	defer c.client.RemoveEventListener(ch)
 
-	containers, err := c.client.ListContainers(docker.ListContainersOptions{})
+	err = c.Discover()
 	if err != nil {
 		return err
 	}
 
-	for _, container := range containers {
-		go c.handle(container.ID)
+	close(c.ready)
+
+	if c.discovery > 0 {
+		go c.discoverEvery(ctx, c.discovery)
 	}
 
-	for e := range ch {
-		switch e.Status {
-		case "start", "restart":
-			go c.handle(e.ID)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			switch e.Status {
+			case "start", "restart", "die", "destroy":
+				// state and pid change, cached inspection is stale
+				if c.cache != nil {
+					c.cache.evict(e.ID)
+				}
+			}
+
+			switch e.Status {
+			case "start", "restart":
+				go c.handleFiltered(e.ID)
+			}
+
+			if name := eventName(e); countedEvents[name] || c.notified[name] {
+				go c.handleEvent(e)
+			}
 		}
 	}
+}
+
+// defaultPollInterval is the interval of polling discoverer
+// when discovery interval is not set
+const defaultPollInterval = 10 * time.Second
+
+// poll discovers containers with discoverer until ctx is done
+func (c *Collector) poll(ctx context.Context) error {
+	err := c.Discover()
+	if err != nil {
+		return err
+	}
+
+	close(c.ready)
+
+	interval := c.discovery
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	c.discoverEvery(ctx, interval)
+
+	return ctx.Err()
+}
+
+// context returns context of Run, contexts of stats streams and
+// inspections are derived from it, background context is returned
+// before Run is called
+func (c *Collector) context() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
+// every calls f on every tick of interval until ctx is done
+func every(ctx context.Context, interval time.Duration, f func(t time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			f(t)
+		}
+	}
+}
+
+// Ready returns channel that is closed once Run
+// subscribes to docker events and discovers containers
+func (c *Collector) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// LastWrite returns time when the last sample was passed to writer,
+// collector's own metrics are written every interval even on idle hosts,
+// zero time is returned if nothing was written yet
+func (c *Collector) LastWrite() time.Time {
+	t := atomic.LoadInt64(&c.lastWrite)
+	if t == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, t)
+}
+
+// staleWrites is how many reporting intervals can pass
+// without writes before collector is unhealthy
+const staleWrites = 3
+
+// Health returns nil if collector is healthy or error telling what is
+// wrong: containers are not discovered yet, docker daemon is unavailable
+// or nothing was written for several intervals, collector's own metrics
+// are written every interval, so idle hosts are healthy
+func (c *Collector) Health() error {
+	select {
+	case <-c.ready:
+	default:
+		return errors.New("containers are not discovered yet")
+	}
+
+	if since := atomic.LoadInt64(&c.unavailableSince); since != 0 {
+		return fmt.Errorf("docker is unavailable since %s", time.Unix(0, since).Format(time.RFC3339))
+	}
+
+	c.mutex.Lock()
+	interval := c.interval
+	c.mutex.Unlock()
+
+	// collector's own metrics are reported at least every second
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	last := c.LastWrite()
+	if last.IsZero() {
+		return errors.New("nothing was written yet")
+	}
+
+	if stale := time.Since(last); stale > staleWrites*interval {
+		return fmt.Errorf("nothing was written for %s", stale.Truncate(time.Second))
+	}
 
 	return nil
 }
 
+// Discoverer finds ids of running containers to monitor
+type Discoverer interface {
+	Containers(ctx context.Context) ([]string, error)
+}
+
+// Discover starts monitoring of running containers that are not
+// monitored yet, for example after app filter is changed
+func (c *Collector) Discover() error {
+	if c.discoverer != nil {
+		ids, err := c.discoverer.Containers(c.context())
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if !c.monitored(id) {
+				go c.handle(id)
+			}
+		}
+
+		return nil
+	}
+
+	containers, err := c.client.ListContainers(docker.ListContainersOptions{Filters: c.filters})
+	if err != nil {
+		return err
+	}
+
+	for _, container := range containers {
+		if !c.monitored(container.ID) {
+			go c.handle(container.ID)
+		}
+	}
+
+	return nil
+}
+
+// discoverEvery runs discovery with specified interval,
+// containers that are gone stop being monitored when
+// their stats streams end, so they are not looked for
+func (c *Collector) discoverEvery(ctx context.Context, interval time.Duration) {
+	every(ctx, interval, func(time.Time) {
+		err := c.Discover()
+		if err != nil {
+			atomic.AddUint64(&c.discoveryErrors, 1)
+			Logf(LogWarn, "error discovering containers: %s", err)
+			c.hooks.error("", err)
+		}
+	})
+}
+
+// ContainerIdentity describes app and task that collector
+// assigns to container and whether container is monitored
+type ContainerIdentity struct {
+	ID        string
+	Name      string
+	Image     string
+	App       string
+	Task      string
+	Running   bool
+	Monitored bool
+	// Source describes where app name was found
+	Source string
+	// Reason explains why container is or isn't monitored
+	Reason string
+}
+
+// Containers returns identities of all containers on
+// the host including stopped ones, sorted by name
+func (c *Collector) Containers() ([]ContainerIdentity, error) {
+	containers, err := c.client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ContainerIdentity, 0, len(containers))
+	for _, container := range containers {
+		identity, err := c.Explain(container.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, identity)
+	}
+
+	sort.Sort(containerIdentities(result))
+
+	return result, nil
+}
+
+// Explain returns identity of container by id or name
+// with explanation of why it is or isn't monitored
+func (c *Collector) Explain(id string) (ContainerIdentity, error) {
+	info, err := c.client.InspectContainerWithContext(id, c.context())
+	if err != nil {
+		return ContainerIdentity{}, err
+	}
+
+	app, task, source, err := admit(c.containerFilter(), c.identity, info)
+
+	identity := ContainerIdentity{
+		ID:      info.ID,
+		Name:    strings.TrimPrefix(info.Name, "/"),
+		Image:   info.Config.Image,
+		App:     app,
+		Task:    task,
+		Running: info.State.Running,
+		Source:  source,
+	}
+
+	var skip *SkipError
+
+	switch {
+	case errors.As(err, &skip):
+		identity.Reason = skip.Detail
+	case err != nil:
+		identity.Reason = "error finding identity: " + err.Error()
+	case !info.State.Running:
+		identity.Reason = "container is not running"
+	default:
+		identity.Reason = c.filterReason(app)
+	}
+
+	if identity.Reason == "" {
+		identity.Monitored = true
+		identity.Reason = "app " + app + " from " + source + " is monitored"
+	}
+
+	return identity, nil
+}
+
+type containerIdentities []ContainerIdentity
+
+func (c containerIdentities) Len() int           { return len(c) }
+func (c containerIdentities) Less(i, j int) bool { return c[i].Name < c[j].Name }
+func (c containerIdentities) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// handleFiltered starts monitoring of container from docker event
+// if it passes container filters, docker checks filters when listing
+// containers, which is cheaper than inspecting them
+func (c *Collector) handleFiltered(id string) {
+	if len(c.filters) > 0 {
+		filters := map[string][]string{"id": {id}}
+		for k, v := range c.filters {
+			filters[k] = v
+		}
+
+		containers, err := c.client.ListContainers(docker.ListContainersOptions{Filters: filters})
+		if err != nil {
+			containerFields(id, "", "").Logf(LogWarn, "error checking container filters: %s", err)
+			c.hooks.error(id, err)
+			return
+		}
+
+		if len(containers) == 0 {
+			containerFields(id, "", "").Logf(LogDebug, "skipping container: container filters don't match")
+			return
+		}
+	}
+
+	c.handle(id)
+}
+
 func (c *Collector) handle(id string) {
-	m, err := NewMonitor(c.client, id, c.interval)
+	c.mutex.Lock()
+	interval := c.stretched(c.interval)
+	jitter := c.jitter
+	aligned := c.aligned
+	maxSkew := c.maxSkew
+	disabled := c.disabled
+	c.mutex.Unlock()
+
+	client := newStatsClient(c.client, disabled)
+	client.latency = c.latency
+
+	ctx := c.context()
+
+	m, err := NewMonitor(ctx, client, id, WithInterval(interval), WithIdentityExtractor(c.identity), WithFilter(c.containerFilter()), WithMetadataExtractor(c.metadata), WithMaxClockSkew(maxSkew), WithInspectRetries(c.retries, c.retryBackoff))
 	if err != nil {
-		if err == ErrNoNeedToMonitor {
+		if errors.Is(err, ErrNoNeedToMonitor) || containerGone(err) {
+			containerFields(id, "", "").Logf(LogDebug, "skipping container: %s", err)
 			return
 		}
 
-		log.Printf("error handling %s: %s\n", id, err)
+		atomic.AddUint64(&c.discoveryErrors, 1)
+		containerFields(id, "", "").Logf(LogWarn, "error handling container: %s", err)
+		c.hooks.error(id, err)
 
 		return
 	}
 
-	go func() {
-		if !c.register(id) {
-			return
+	if jitter > 0 {
+		m.offset = time.Duration(rand.Int63n(int64(jitter)))
+	}
+
+	m.aligned = aligned
+
+	fields := containerFields(id, m.app, m.task)
+
+	if reason := c.filterReason(m.app); reason != "" {
+		fields.Logf(LogDebug, "skipping container: %s", reason)
+		return
+	}
+
+	go c.monitor(ctx, id, m)
+}
+
+// monitor registers monitor of container and sends its samples
+// until stats stream ends, containers can be monitored only once
+func (c *Collector) monitor(ctx context.Context, id string, m *Monitor) {
+	if !c.register(id, m) {
+		return
+	}
+
+	fields := containerFields(id, m.app, m.task)
+	fields.Logf(LogDebug, "monitoring container")
+
+	c.hooks.monitorStart(id, m.app, m.task)
+
+	probeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if c.probes && len(m.probe) > 0 {
+		go c.probe(probeCtx, m, "probe "+strings.Join(m.probe, " "), func(ctx context.Context) (map[string]uint64, error) {
+			return c.execProbe(ctx, id, m.probe)
+		})
+	}
+
+	if c.scraping && m.scrape != "" {
+		c.startScrape(probeCtx, m)
+	}
+
+	if len(c.logPatterns) > 0 {
+		go c.countLogs(probeCtx, m)
+	}
+
+	if c.reachTCP || c.reachICMP {
+		c.startReach(probeCtx, m)
+	}
+
+	if c.topProcesses > 0 {
+		go c.probe(probeCtx, m, "top", func(ctx context.Context) (map[string]uint64, error) {
+			return c.top(ctx, id)
+		})
+	}
+
+	if c.hiresProc != "" && m.highResolution != "" {
+		c.startHighResolution(probeCtx, m)
+	}
+
+	err := m.handle(ctx, func(s Stats) {
+		if c.filtered(s.App) && !c.paused(id, s.App) {
+			c.hooks.sample(s)
+			c.send(s)
+			m.remember(s)
+		}
+	})
+	switch {
+	case errors.Is(err, ErrContainerGone), err == ctx.Err():
+		fields.Logf(LogDebug, "stats stream ended: %s", err)
+		if last, ok := m.lastSample(); ok && ctx.Err() == nil && !c.paused(id, m.app) {
+			cancel()
+			if s, ok := endOfLifeSample(c.endOfLife, last, time.Now()); ok {
+				c.send(s)
+			}
 		}
+	default:
+		atomic.AddUint64(&c.streamErrors, 1)
+		fields.Logf(LogWarn, "error handling container: %s", err)
+		c.hooks.error(id, err)
+	}
 
-		err := m.handle(c.ch)
-		if err != nil {
-			log.Printf("error handling container for app %s: %s\n", m.app, err)
+	c.unregister(id)
+
+	c.hooks.monitorStop(id, m.app, m.task, err)
+}
+
+// filtered checks app name against app filter
+func (c *Collector) filtered(app string) bool {
+	return c.filterReason(app) == ""
+}
+
+// filterReason returns why app is excluded by filter rules or
+// app filter or empty string if app passes them
+func (c *Collector) filterReason(app string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.rules != nil {
+		if reason, matched := c.rules.reason(app); matched {
+			return reason
 		}
+	}
+
+	if c.include != nil && !c.include.MatchString(app) {
+		return fmt.Sprintf("app %s doesn't match include filter %s", app, c.include)
+	}
+
+	if c.exclude != nil && c.exclude.MatchString(app) {
+		return fmt.Sprintf("app %s matches exclude filter %s", app, c.exclude)
+	}
 
-		c.unregister(id)
-	}()
+	return ""
 }
 
-func (c *Collector) register(id string) bool {
+func (c *Collector) register(id string, m *Monitor) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -102,12 +847,457 @@ func (c *Collector) register(id string) bool {
 		return false
 	}
 
-	c.registered[id] = struct{}{}
+	c.registered[id] = m
 	return true
 }
 
+// monitored checks whether container is already monitored
+func (c *Collector) monitored(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.registered[id]
+	return ok
+}
+
 func (c *Collector) unregister(id string) {
 	c.mutex.Lock()
 	delete(c.registered, id)
 	c.mutex.Unlock()
 }
+
+// handleEvent counts docker event of container and writes notification
+// about it, app and task of containers that are gone are taken from
+// monitors, containers are inspected on oom events to report memory
+// limit and on health status changes to report the last check output
+func (c *Collector) handleEvent(e *docker.APIEvents) {
+	event := eventName(e)
+
+	app, task := "", ""
+
+	c.mutex.Lock()
+	if m, ok := c.registered[e.ID]; ok {
+		app, task = m.app, m.task
+	}
+	c.mutex.Unlock()
+
+	var info *docker.Container
+	if app == "" || event == "oom" || event == "health_status" {
+		var err error
+		if event == "oom" || event == "health_status" {
+			info, err = c.client.InspectContainerWithContext(e.ID, c.context())
+		} else {
+			info, err = c.inspect(c.context(), e.ID)
+		}
+
+		if err != nil && app == "" {
+			containerFields(e.ID, "", "").Logf(LogDebug, "skipping %s event: %s", event, err)
+			return
+		}
+
+		if app == "" {
+			app, task, _, err = admit(c.containerFilter(), c.identity, info)
+			if err != nil {
+				containerFields(e.ID, "", "").Logf(LogDebug, "skipping %s event: %s", event, err)
+				return
+			}
+		}
+	}
+
+	if app == "" || !c.filtered(app) || c.paused(e.ID, app) {
+		return
+	}
+
+	if countedEvents[event] {
+		c.countEvent(app, event)
+	}
+
+	if !c.notified[event] {
+		return
+	}
+
+	n := Notification{
+		App:      app,
+		Task:     task,
+		Time:     time.Unix(e.Time, 0),
+		Severity: eventSeverity(e),
+		Event:    event,
+		Message:  eventMessage(e),
+	}
+
+	if event == "oom" && info != nil && info.HostConfig != nil && info.HostConfig.Memory > 0 {
+		n.Message += fmt.Sprintf(", memory limit is %d bytes", info.HostConfig.Memory)
+	}
+
+	if event == "health_status" && info != nil {
+		if checks := info.State.Health.Log; len(checks) > 0 {
+			if output := strings.TrimSpace(checks[len(checks)-1].Output); output != "" {
+				n.Message += ", last check output: " + output
+			}
+		}
+	}
+
+	c.notify(n)
+}
+
+// inspect inspects container for identity lookups,
+// results are cached if inspect cache is enabled
+func (c *Collector) inspect(ctx context.Context, id string) (*docker.Container, error) {
+	if c.cache != nil {
+		return c.cache.InspectContainerWithContext(id, ctx)
+	}
+
+	return c.client.InspectContainerWithContext(id, ctx)
+}
+
+// countEvent increments counter of docker event of app
+func (c *Collector) countEvent(app string, event string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.events == nil {
+		c.events = map[string]map[string]uint64{}
+	}
+
+	if c.events[app] == nil {
+		c.events[app] = map[string]uint64{}
+	}
+
+	c.events[app]["events."+event]++
+}
+
+// eventCounts returns samples with counters of docker events of apps
+func (c *Collector) eventCounts(t time.Time) []Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	result := make([]Stats, 0, len(c.events))
+	for app, counts := range c.events {
+		metrics := make(map[string]uint64, len(counts))
+		for k, v := range counts {
+			metrics[k] = v
+		}
+
+		s := Stats{App: app, Task: eventsTask, MetricsOnly: true, Metrics: metrics}
+		s.Time = t
+
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// notify writes notification with current writer
+func (c *Collector) notify(n Notification) {
+	c.writerMutex.Lock()
+	err := Notify(c.writer, n)
+	c.writerMutex.Unlock()
+
+	if err != nil {
+		countWriteError()
+		LogFields{"app": n.App, "task": n.Task}.Logf(LogError, "error writing notification: %s", err)
+		c.hooks.error("", err)
+	}
+}
+
+// eventName returns name of docker event, health status changes
+// are reported by docker as "health_status: <status>" and they
+// are all named health_status
+func eventName(e *docker.APIEvents) string {
+	if strings.HasPrefix(e.Status, "health_status") {
+		return "health_status"
+	}
+
+	return e.Status
+}
+
+// healthStatus returns health status from health_status event
+func healthStatus(e *docker.APIEvents) string {
+	return strings.TrimSpace(strings.TrimPrefix(e.Status, "health_status:"))
+}
+
+// eventSeverity returns severity of notification about docker event,
+// containers that exit with non-zero code are failures
+func eventSeverity(e *docker.APIEvents) Severity {
+	switch eventName(e) {
+	case "start":
+		return SeverityOkay
+	case "oom":
+		return SeverityFailure
+	case "health_status":
+		if healthStatus(e) == "healthy" {
+			return SeverityOkay
+		}
+
+		return SeverityFailure
+	case "die":
+		switch e.Actor.Attributes["exitCode"] {
+		case "0":
+			return SeverityOkay
+		case "":
+			return SeverityWarning
+		default:
+			return SeverityFailure
+		}
+	default:
+		return SeverityWarning
+	}
+}
+
+// eventMessage returns human readable description of docker event
+func eventMessage(e *docker.APIEvents) string {
+	name := e.Actor.Attributes["name"]
+	if name == "" {
+		name = e.ID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+	}
+
+	switch eventName(e) {
+	case "start":
+		return "container " + name + " started"
+	case "restart":
+		return "container " + name + " restarted"
+	case "kill":
+		if signal := e.Actor.Attributes["signal"]; signal != "" {
+			return "container " + name + " was killed with signal " + signal
+		}
+
+		return "container " + name + " was killed"
+	case "oom":
+		return "container " + name + " ran out of memory"
+	case "health_status":
+		return "container " + name + " is " + healthStatus(e)
+	case "die":
+		if code := e.Actor.Attributes["exitCode"]; code != "" {
+			return "container " + name + " died with exit code " + code
+		}
+
+		return "container " + name + " died"
+	default:
+		return "container " + name + ": " + e.Status
+	}
+}
+
+// send enqueues sample to be written according to drop policy
+func (c *Collector) send(s Stats) {
+	switch c.policy {
+	case DropPolicyDropNewest:
+		select {
+		case c.ch <- s:
+		default:
+			atomic.AddUint64(&c.dropped, 1)
+		}
+	case DropPolicyDropOldest:
+		for {
+			select {
+			case c.ch <- s:
+				return
+			default:
+			}
+
+			select {
+			case <-c.ch:
+				atomic.AddUint64(&c.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case c.ch <- s:
+		default:
+			// the queue is full, waiting for writers is counted,
+			// so backpressure is visible in collector's own metrics
+			atomic.AddUint64(&c.blocked, 1)
+			c.ch <- s
+		}
+	}
+}
+
+func (c *Collector) write(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-c.ch:
+			c.writeSample(s)
+		case done := <-c.flushes:
+			// samples queued before flush was requested go first
+			for n := len(c.ch); n > 0; n-- {
+				c.writeSample(<-c.ch)
+			}
+
+			c.writerMutex.Lock()
+			done <- c.writer.Flush()
+			c.writerMutex.Unlock()
+		}
+	}
+}
+
+// writeSample writes sample with current writer
+func (c *Collector) writeSample(s Stats) {
+	c.writerMutex.Lock()
+	err := c.writer.Write(s)
+	c.writerMutex.Unlock()
+
+	atomic.StoreInt64(&c.lastWrite, time.Now().UnixNano())
+
+	if err != nil {
+		countWriteError()
+		LogFields{"app": s.App, "task": s.Task}.Logf(LogError, "error writing stats: %s", err)
+		c.hooks.error("", err)
+	}
+}
+
+// reportSelf periodically sends collector's own metrics,
+// they bypass drop policy to be reported during overload
+func (c *Collector) reportSelf(ctx context.Context) {
+	c.mutex.Lock()
+	interval := c.interval
+	c.mutex.Unlock()
+
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	every(ctx, interval, func(t time.Time) {
+		atomic.AddUint64(&c.heartbeats, 1)
+
+		s := Stats{
+			App:         selfApp,
+			Task:        c.selfTaskName(),
+			MetricsOnly: true,
+			Metrics:     c.SelfMetrics(),
+		}
+
+		s.Time = t
+
+		select {
+		case c.ch <- s:
+		case <-ctx.Done():
+			return
+		}
+
+		for _, s := range c.eventCounts(t) {
+			select {
+			case c.ch <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// selfCounters count failures in writers and stats streams that
+// are not owned by collector, they are reported in SelfMetrics
+var selfCounters struct {
+	decodeErrors uint64
+	writeErrors  uint64
+	reconnects   uint64
+	// overflowSamples are samples written as _overflow task
+	overflowSamples uint64
+	// skewedSamples are stats read too far from local clock
+	skewedSamples uint64
+}
+
+// countWriteError counts failed write of sample or notification
+func countWriteError() {
+	atomic.AddUint64(&selfCounters.writeErrors, 1)
+}
+
+// SelfMetrics returns collector's own metrics keyed by metric name,
+// they are written as metrics of _collector app and self task
+func (c *Collector) SelfMetrics() map[string]uint64 {
+	c.mutex.Lock()
+	monitors := len(c.registered)
+	stretch := c.stretch
+	c.mutex.Unlock()
+
+	metrics := map[string]uint64{
+		"collector.dropped_samples":  atomic.LoadUint64(&c.dropped),
+		"collector.blocked_samples":  atomic.LoadUint64(&c.blocked),
+		"collector.queue_length":     uint64(len(c.ch)),
+		"collector.queue_size":       uint64(cap(c.ch)),
+		"collector.monitors":         uint64(monitors),
+		"collector.discovery_errors": atomic.LoadUint64(&c.discoveryErrors),
+		"collector.stream_errors":    atomic.LoadUint64(&c.streamErrors),
+		"collector.decode_errors":    atomic.LoadUint64(&selfCounters.decodeErrors),
+		"collector.write_errors":     atomic.LoadUint64(&selfCounters.writeErrors),
+		"collector.reconnects":       atomic.LoadUint64(&selfCounters.reconnects),
+		"collector.overflow_samples": atomic.LoadUint64(&selfCounters.overflowSamples),
+		"collector.skewed_samples":   atomic.LoadUint64(&selfCounters.skewedSamples),
+		"collector.interval_stretch": uint64(stretch),
+		"collector.heartbeat":        atomic.LoadUint64(&c.heartbeats),
+	}
+
+	if !c.started.IsZero() {
+		metrics["collector.uptime_seconds"] = uint64(time.Since(c.started) / time.Second)
+	}
+
+	if c.latency != nil {
+		metrics["collector.stats_latency_ms"] = uint64(c.latency.value() / time.Millisecond)
+	}
+
+	if atomic.LoadInt64(&c.unavailableSince) != 0 {
+		metrics["collector.docker_unavailable"] = 1
+	} else {
+		metrics["collector.docker_unavailable"] = 0
+	}
+
+	if c.version != "" {
+		metrics["collector.version."+sanitizeForGraphite(c.version)] = 1
+	}
+
+	return metrics
+}
+
+// checkDocker pings docker daemon periodically and writes notifications
+// when it is unavailable for longer than timeout and when it recovers,
+// so monitoring blackouts are not silent
+func (c *Collector) checkDocker(ctx context.Context) {
+	notified := false
+
+	every(ctx, dockerCheckInterval, func(t time.Time) {
+		err := c.client.PingWithContext(ctx)
+		if err == nil {
+			if atomic.SwapInt64(&c.unavailableSince, 0) == 0 {
+				return
+			}
+
+			infof("docker is available again")
+
+			if notified {
+				notified = false
+				c.notify(c.selfNotification(t, SeverityOkay, "docker daemon is available again"))
+			}
+
+			return
+		}
+
+		since := atomic.LoadInt64(&c.unavailableSince)
+		if since == 0 {
+			since = t.UnixNano()
+			atomic.StoreInt64(&c.unavailableSince, since)
+			warnf("docker is unavailable: %s", err)
+		}
+
+		down := t.Sub(time.Unix(0, since))
+		if !notified && c.downAfter > 0 && down >= c.downAfter {
+			notified = true
+			c.notify(c.selfNotification(t, SeverityFailure, fmt.Sprintf("docker daemon is unavailable for %s: %s", down, err)))
+		}
+	})
+}
+
+// selfNotification creates notification about collector itself
+func (c *Collector) selfNotification(t time.Time, severity Severity, message string) Notification {
+	return Notification{
+		App:      selfApp,
+		Task:     c.selfTaskName(),
+		Time:     t,
+		Severity: severity,
+		Event:    "docker_unavailable",
+		Message:  message,
+	}
+}
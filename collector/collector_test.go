@@ -0,0 +1,251 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestDropPolicies(t *testing.T) {
+	tests := map[DropPolicy]string{
+		DropPolicyDropOldest: "two",
+		DropPolicyDropNewest: "one",
+	}
+
+	for policy, expected := range tests {
+		c := &Collector{}
+		c.SetQueue(1, policy)
+
+		c.send(Stats{App: "one"})
+		c.send(Stats{App: "two"})
+
+		if c.dropped != 1 {
+			t.Errorf("expected 1 dropped sample for policy %d, got %d", policy, c.dropped)
+		}
+
+		s := <-c.ch
+		if s.App != expected {
+			t.Errorf("expected app %s to be queued for policy %d, got %s", expected, policy, s.App)
+		}
+	}
+}
+
+func TestUnbufferedQueuePolicy(t *testing.T) {
+	c := &Collector{}
+	c.SetQueue(0, DropPolicyDropOldest)
+
+	if c.policy != DropPolicyBlock {
+		t.Errorf("expected unbuffered queue to block, got policy %d", c.policy)
+	}
+
+	go c.send(Stats{App: "one"})
+
+	if s := <-c.ch; s.App != "one" || c.dropped != 0 {
+		t.Errorf("expected sample to be received without drops, got %s and %d dropped", s.App, c.dropped)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	c := &Collector{}
+	c.SetQueue(1, DropPolicyBlock)
+
+	c.send(Stats{App: "one"})
+
+	done := make(chan struct{})
+	go func() {
+		c.send(Stats{App: "two"})
+		close(done)
+	}()
+
+	for atomic.LoadUint64(&c.blocked) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, expected := range []string{"one", "two"} {
+		s := <-c.ch
+		if s.App != expected {
+			t.Errorf("expected app %s to be queued, got %s", expected, s.App)
+		}
+	}
+
+	<-done
+
+	if c.blocked != 1 || c.dropped != 0 {
+		t.Errorf("expected 1 blocked and 0 dropped samples, got %d and %d", c.blocked, c.dropped)
+	}
+}
+
+func TestSelfMetrics(t *testing.T) {
+	c := &Collector{registered: map[string]*Monitor{"one": nil, "two": nil}, started: time.Now().Add(-90 * time.Second)}
+	c.SetQueue(10, DropPolicyBlock)
+	c.send(Stats{App: "one"})
+	c.discoveryErrors = 3
+
+	metrics := c.SelfMetrics()
+
+	expected := map[string]uint64{
+		"collector.monitors":           2,
+		"collector.queue_length":       1,
+		"collector.queue_size":         10,
+		"collector.discovery_errors":   3,
+		"collector.docker_unavailable": 0,
+		"collector.uptime_seconds":     90,
+	}
+
+	for k, v := range expected {
+		if metrics[k] != v {
+			t.Errorf("expected %s to be %d, got %d", k, v, metrics[k])
+		}
+	}
+
+	for _, k := range []string{"collector.stream_errors", "collector.decode_errors", "collector.write_errors", "collector.reconnects", "collector.overflow_samples", "collector.skewed_samples", "collector.heartbeat"} {
+		if _, ok := metrics[k]; !ok {
+			t.Errorf("expected %s in self metrics", k)
+		}
+	}
+}
+
+func TestReportSelfStopsWhenBlocked(t *testing.T) {
+	c := &Collector{started: time.Now()}
+	c.SetQueue(1, DropPolicyBlock)
+	c.send(Stats{App: "stalled"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		c.reportSelf(ctx)
+		close(done)
+	}()
+
+	// self metrics are reported every second at most
+	time.Sleep(1500 * time.Millisecond)
+
+	if atomic.LoadUint64(&c.heartbeats) == 0 {
+		t.Fatal("expected self metrics to be reported")
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected reporting of self metrics to stop with full queue")
+	}
+}
+
+func TestAppFilter(t *testing.T) {
+	c := &Collector{}
+	c.SetAppFilter(regexp.MustCompile("^web"), regexp.MustCompile("canary$"))
+
+	tests := map[string]bool{
+		"web":        true,
+		"web-canary": false,
+		"db":         false,
+	}
+
+	for app, expected := range tests {
+		if c.filtered(app) != expected {
+			t.Errorf("expected filtered(%q) to be %v", app, expected)
+		}
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	r := &recordingWriter{}
+	c := &Collector{writer: NewBatchWriter(NewPrefixWriter(r, "p.", ""), 10, 0)}
+	c.SetNotifiedEvents([]string{"die"})
+	c.registered = map[string]*Monitor{
+		"abcdef": {app: "myapp", task: "mytask"},
+	}
+
+	e := &docker.APIEvents{ID: "abcdef", Status: "die", Time: 1431000000}
+	e.Actor.Attributes = map[string]string{"name": "web", "exitCode": "137"}
+
+	c.handleEvent(e)
+	c.handleEvent(e)
+
+	if len(r.notified) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(r.notified))
+	}
+
+	n := r.notified[0]
+	if n.App != "myapp" || n.Task != "mytask" || n.Event != "die" || n.Severity != SeverityFailure {
+		t.Errorf("unexpected notification %#v", n)
+	}
+
+	if n.Message != "container web died with exit code 137" {
+		t.Errorf("unexpected notification message %q", n.Message)
+	}
+
+	counts := c.eventCounts(time.Unix(1431000010, 0))
+	if len(counts) != 1 || counts[0].App != "myapp" || counts[0].Task != eventsTask {
+		t.Fatalf("expected event counts of myapp, got %v", counts)
+	}
+
+	if counts[0].Metrics["events.die"] != 2 {
+		t.Errorf("expected 2 die events, got %v", counts[0].Metrics)
+	}
+}
+
+func TestEventSeverity(t *testing.T) {
+	tests := []struct {
+		status   string
+		exitCode string
+		severity Severity
+	}{
+		{"start", "", SeverityOkay},
+		{"die", "0", SeverityOkay},
+		{"die", "1", SeverityFailure},
+		{"kill", "", SeverityWarning},
+		{"oom", "", SeverityFailure},
+		{"health_status: healthy", "", SeverityOkay},
+		{"health_status: unhealthy", "", SeverityFailure},
+	}
+
+	for _, test := range tests {
+		e := &docker.APIEvents{Status: test.status}
+		e.Actor.Attributes = map[string]string{"exitCode": test.exitCode}
+
+		if severity := eventSeverity(e); severity != test.severity {
+			t.Errorf("expected severity %s for %s event with exit code %q, got %s", test.severity, test.status, test.exitCode, severity)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c := &Collector{interval: time.Second, ready: make(chan struct{})}
+
+	if c.Health() == nil {
+		t.Errorf("expected collector to be unhealthy before discovery")
+	}
+
+	close(c.ready)
+
+	if c.Health() == nil {
+		t.Errorf("expected collector to be unhealthy before the first write")
+	}
+
+	c.lastWrite = time.Now().UnixNano()
+
+	if err := c.Health(); err != nil {
+		t.Errorf("expected collector to be healthy, got %s", err)
+	}
+
+	c.lastWrite = time.Now().Add(-time.Minute).UnixNano()
+
+	if c.Health() == nil {
+		t.Errorf("expected collector that doesn't write to be unhealthy")
+	}
+
+	c.lastWrite = time.Now().UnixNano()
+	c.unavailableSince = time.Now().UnixNano()
+
+	if c.Health() == nil {
+		t.Errorf("expected collector to be unhealthy when docker is unavailable")
+	}
+}
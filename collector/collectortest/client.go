@@ -0,0 +1,224 @@
+// Package collectortest provides utilities for testing integrations
+// with collector without docker daemon
+package collectortest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Client is a scripted fake docker client that implements
+// collector.MonitorDockerClient, containers, their stats and
+// errors are set up by tests, zero Client knows no containers
+type Client struct {
+	mutex         sync.Mutex
+	containers    map[string]*docker.Container
+	inspectErrors map[string]error
+	stats         map[string][]*docker.Stats
+	statsErrors   map[string]error
+	streams       map[string]*Stream
+	inspected     map[string]int
+}
+
+// NewClient creates fake client that knows specified containers
+func NewClient(containers ...*docker.Container) *Client {
+	c := &Client{}
+	for _, container := range containers {
+		c.AddContainer(container)
+	}
+
+	return c
+}
+
+// AddContainer makes container known to client by its id,
+// inspection returns a copy of container
+func (c *Client) AddContainer(container *docker.Container) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.containers == nil {
+		c.containers = map[string]*docker.Container{}
+	}
+
+	c.containers[container.ID] = container
+}
+
+// RemoveContainer makes client forget container, inspecting it
+// and streaming its stats fail with *docker.NoSuchContainer
+func (c *Client) RemoveContainer(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.containers, id)
+}
+
+// SetInspectError makes inspection of container fail with err,
+// nil err makes inspection succeed again
+func (c *Client) SetInspectError(id string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.inspectErrors == nil {
+		c.inspectErrors = map[string]error{}
+	}
+
+	c.inspectErrors[id] = err
+}
+
+// SetStats sets canned stats of container that the next stats streams
+// send right away and end with err, since stream ends right after the
+// last stats, monitors should have zero interval to send every stats
+func (c *Client) SetStats(id string, err error, stats ...*docker.Stats) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stats == nil {
+		c.stats = map[string][]*docker.Stats{}
+		c.statsErrors = map[string]error{}
+	}
+
+	c.stats[id] = stats
+	c.statsErrors[id] = err
+}
+
+// Stream returns programmable stats stream of container, stats streams
+// of container started after that receive stats from it until it ends,
+// canned stats are sent before stats of the stream
+func (c *Client) Stream(id string) *Stream {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.streams == nil {
+		c.streams = map[string]*Stream{}
+	}
+
+	if _, ok := c.streams[id]; !ok {
+		c.streams[id] = newStream()
+	}
+
+	return c.streams[id]
+}
+
+// Inspected returns how many times container was inspected
+func (c *Client) Inspected(id string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.inspected[id]
+}
+
+// InspectContainerWithContext returns copy of known container
+func (c *Client) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.inspected == nil {
+		c.inspected = map[string]int{}
+	}
+
+	c.inspected[id]++
+
+	if err := c.inspectErrors[id]; err != nil {
+		return nil, err
+	}
+
+	container, ok := c.containers[id]
+	if !ok {
+		return nil, &docker.NoSuchContainer{ID: id}
+	}
+
+	copied := *container
+
+	return &copied, nil
+}
+
+// Stats sends canned stats and stats of programmable stream
+// of container to opts.Stats and closes it when stream ends
+func (c *Client) Stats(opts docker.StatsOptions) error {
+	defer close(opts.Stats)
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	c.mutex.Lock()
+	_, known := c.containers[opts.ID]
+	stats, err := c.stats[opts.ID], c.statsErrors[opts.ID]
+	stream := c.streams[opts.ID]
+	c.mutex.Unlock()
+
+	if !known && stats == nil && stream == nil {
+		return &docker.NoSuchContainer{ID: opts.ID}
+	}
+
+	for _, s := range stats {
+		if !send(ctx, opts.Stats, s) {
+			return ctx.Err()
+		}
+	}
+
+	if stream == nil {
+		return err
+	}
+
+	for {
+		select {
+		case s := <-stream.ch:
+			if !send(ctx, opts.Stats, s) {
+				return ctx.Err()
+			}
+		case err := <-stream.end:
+			// stream can be ended once and stays ended
+			stream.end <- err
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// send sends copy of stats, since receivers reuse stats they get
+func send(ctx context.Context, ch chan<- *docker.Stats, s *docker.Stats) bool {
+	copied := *s
+
+	select {
+	case ch <- &copied:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Stream is programmable stats stream of container
+type Stream struct {
+	ch   chan *docker.Stats
+	end  chan error
+	once sync.Once
+}
+
+func newStream() *Stream {
+	return &Stream{
+		ch:  make(chan *docker.Stats),
+		end: make(chan error, 1),
+	}
+}
+
+// Send blocks until stats are received by stats stream
+func (s *Stream) Send(stats *docker.Stats) {
+	s.ch <- stats
+}
+
+// End ends stats streams with err, nil err is what docker
+// does when container stops, ending stream again does nothing
+func (s *Stream) End(err error) {
+	s.once.Do(func() {
+		s.end <- err
+	})
+}
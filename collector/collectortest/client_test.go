@@ -0,0 +1,91 @@
+package collectortest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bobrik/collectd-docker/collector"
+	"github.com/fsouza/go-dockerclient"
+)
+
+func container(id string) *docker.Container {
+	return &docker.Container{
+		ID:     id,
+		Config: &docker.Config{Env: []string{"COLLECTD_DOCKER_APP=myapp"}},
+	}
+}
+
+func TestCannedStats(t *testing.T) {
+	client := NewClient(container("abcdef"))
+	client.SetStats("abcdef", nil, &docker.Stats{Read: time.Unix(1000, 0)}, &docker.Stats{Read: time.Unix(1001, 0)})
+
+	m, err := collector.NewMonitor(context.Background(), client, "abcdef", collector.WithInterval(0))
+	if err != nil {
+		t.Fatalf("error creating monitor: %s", err)
+	}
+
+	ch := make(chan collector.Stats, 10)
+
+	err = m.Run(context.Background(), ch)
+	if !errors.Is(err, collector.ErrContainerGone) {
+		t.Errorf("expected container to be gone after canned stats, got %v", err)
+	}
+
+	if len(ch) != 2 {
+		t.Errorf("expected 2 samples, got %d", len(ch))
+	}
+
+	if client.Inspected("abcdef") != 1 {
+		t.Errorf("expected container to be inspected once, got %d", client.Inspected("abcdef"))
+	}
+}
+
+func TestStream(t *testing.T) {
+	client := NewClient(container("abcdef"))
+	stream := client.Stream("abcdef")
+
+	m, err := collector.NewMonitor(context.Background(), client, "abcdef", collector.WithInterval(0))
+	if err != nil {
+		t.Fatalf("error creating monitor: %s", err)
+	}
+
+	ch := make(chan collector.Stats, 10)
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(context.Background(), ch)
+	}()
+
+	stream.Send(&docker.Stats{Read: time.Unix(1000, 0)})
+
+	if s := <-ch; s.App != "myapp" || !s.Time.Equal(time.Unix(1000, 0)) {
+		t.Errorf("unexpected sample %#v", s)
+	}
+
+	failed := errors.New("connection refused")
+	stream.End(failed)
+
+	if err := <-done; !errors.Is(err, collector.ErrDaemonUnreachable) || !errors.Is(err, failed) {
+		t.Errorf("expected stream error to be returned, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client := NewClient()
+
+	_, err := collector.NewMonitor(context.Background(), client, "missing")
+	if _, ok := err.(*docker.NoSuchContainer); !ok {
+		t.Errorf("expected unknown container to be missing, got %v", err)
+	}
+
+	failed := errors.New("timeout")
+
+	client.AddContainer(container("abcdef"))
+	client.SetInspectError("abcdef", failed)
+
+	if _, err = collector.NewMonitor(context.Background(), client, "abcdef"); err != failed {
+		t.Errorf("expected inspect error, got %v", err)
+	}
+}
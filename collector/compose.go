@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// composeProjectLabel and composeServiceLabel are set
+	// by docker compose on containers it starts
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+
+	// composeProjectMeta and composeServiceMeta are metadata
+	// keys of compose project and service of containers
+	composeProjectMeta = "compose_project"
+	composeServiceMeta = "compose_service"
+
+	// composeProjectTask is the task name of project rollups
+	composeProjectTask = "_project"
+)
+
+// ComposeExtractor finds compose project and service of containers
+// started by docker compose from labels that compose sets, they become
+// compose_project and compose_service metadata of samples
+type ComposeExtractor struct{}
+
+// Metadata returns compose project and service of container,
+// containers not started by compose have no metadata
+func (ComposeExtractor) Metadata(c *docker.Container) map[string]string {
+	project := c.Config.Labels[composeProjectLabel]
+	if project == "" {
+		return nil
+	}
+
+	return map[string]string{
+		composeProjectMeta: project,
+		composeServiceMeta: c.Config.Labels[composeServiceLabel],
+	}
+}
+
+func (ComposeExtractor) String() string {
+	return "label " + composeProjectLabel
+}
+
+// NewComposeProjectWriter creates AggregateWriter on top of specified
+// writer that writes project level rollups of cpu and memory of containers
+// with compose_project metadata from ComposeExtractor along with samples,
+// rollups are written as task _project of app named after project, so
+// multi-service applications can be tracked as a unit
+func NewComposeProjectWriter(writer Writer, interval time.Duration) *AggregateWriter {
+	w := NewAggregateWriter(writer, interval)
+	w.group = func(s *Stats) string {
+		return sanitizeForGraphite(s.Meta[composeProjectMeta])
+	}
+	w.task = composeProjectTask
+	w.families = map[string]bool{"cpu": true, "memory": true}
+
+	return w
+}
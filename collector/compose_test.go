@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestComposeExtractor(t *testing.T) {
+	c := &docker.Container{Config: &docker.Config{Labels: map[string]string{
+		composeProjectLabel: "shop",
+		composeServiceLabel: "web",
+	}}}
+
+	meta := ComposeExtractor{}.Metadata(c)
+	if meta[composeProjectMeta] != "shop" || meta[composeServiceMeta] != "web" {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+
+	if meta := (ComposeExtractor{}).Metadata(&docker.Container{Config: &docker.Config{}}); meta != nil {
+		t.Errorf("expected no metadata without compose labels, got %v", meta)
+	}
+}
+
+func TestComposeProjectWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewComposeProjectWriter(r, time.Minute)
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(app, project string, offset time.Duration, cpu uint64) {
+		s := Stats{App: app, Task: "1", Time: start.Add(offset)}
+		if project != "" {
+			s.Meta = map[string]string{composeProjectMeta: project}
+		}
+		s.CPU.Total = cpu
+		s.Network.RxBytes = 100
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	write("web", "shop", 0, 1)
+	write("db", "shop", 0, 2)
+	write("other", "", 0, 4)
+	write("web", "shop", time.Minute, 10)
+
+	if len(r.written) != 5 {
+		t.Fatalf("expected 4 samples and a single rollup, got %d samples", len(r.written))
+	}
+
+	rollup := r.written[4]
+
+	if rollup.App != "shop" || rollup.Task != composeProjectTask {
+		t.Errorf("expected rollup of shop.%s, got %s.%s", composeProjectTask, rollup.App, rollup.Task)
+	}
+
+	metrics := intMetrics(rollup)
+
+	if metrics["cpu.total"] != 12 {
+		t.Errorf("expected rollup cpu.total to be 12, got %d", metrics["cpu.total"])
+	}
+
+	if _, ok := metrics["net.rx_bytes"]; ok {
+		t.Errorf("unexpected network metrics in rollup %v", metrics)
+	}
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// redialConn is a connection for stream writers that is dialed
+// again on the next write after any write error, so writers
+// recover when backend comes back after an outage
+type redialConn struct {
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// dialRedialConn dials the first connection, so misconfigured
+// address is reported immediately, tls is used if tlsConfig is set
+func dialRedialConn(network string, addr string, tlsConfig *tls.Config) (*redialConn, error) {
+	c := &redialConn{
+		network:   network,
+		addr:      addr,
+		tlsConfig: tlsConfig,
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+
+	return c, nil
+}
+
+func (c *redialConn) dial() (net.Conn, error) {
+	return dial(c.network, c.addr, c.tlsConfig)
+}
+
+func (c *redialConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		debugf("reconnecting to %s", c.addr)
+		atomic.AddUint64(&selfCounters.reconnects, 1)
+
+		conn, err := c.dial()
+		if err != nil {
+			return 0, err
+		}
+
+		debugf("reconnected to %s", c.addr)
+
+		c.conn = conn
+	}
+
+	n, err := c.conn.Write(b)
+	if err != nil {
+		debugf("connection to %s failed: %s", c.addr, err)
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	return n, err
+}
+
+func (c *redialConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// dial connects to address with tls if tlsConfig is set
+func dial(network string, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	if tlsConfig == nil {
+		return net.Dial(network, addr)
+	}
+
+	return tls.Dial(network, addr, tlsConfig)
+}
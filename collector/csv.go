@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var csvHeader = []string{"timestamp", "host", "app", "task", "metric", "value"}
+
+// CSVWriter is responsible for appending data to csv files
+// in specified directory, rotating files by size and age
+type CSVWriter struct {
+	host    string
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mutex   sync.Mutex
+	file    *os.File
+	writer  *csv.Writer
+	size    int64
+	created time.Time
+}
+
+// NewCSVWriter creates new CSVWriter with specified hostname,
+// directory for files, max size in bytes and max age of a single file,
+// zero max size or max age disables corresponding rotation
+func NewCSVWriter(host string, dir string, maxSize int64, maxAge time.Duration) (*CSVWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSVWriter{
+		host:    host,
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "csv",
+		Usage: "csv files rotated by size and age",
+		Options: []WriterOption{
+			{Name: "dir", Usage: "directory for csv files"},
+			{Name: "max-size", Default: "67108864", Usage: "max size of a single csv file in bytes, 0 to disable"},
+			{Name: "max-age", Default: "1h", Usage: "max age of a single csv file, 0 to disable"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			maxSize := p.int64("max-size")
+			maxAge := p.duration("max-age")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewCSVWriter(host, p.string("dir"), maxSize, maxAge)
+		},
+	})
+}
+
+func (w *CSVWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.rotate()
+	if err != nil {
+		return err
+	}
+
+	t := strconv.FormatInt(s.Time.Unix(), 10)
+	for k, v := range intMetrics(s) {
+		err := w.writeRecord([]string{t, w.host, s.App, s.Task, k, strconv.FormatUint(v, 10)})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// Flush is no-op, samples are flushed to file on every write
+func (w *CSVWriter) Flush() error {
+	return nil
+}
+
+// Close closes current csv file
+func (w *CSVWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.close()
+}
+
+func (w *CSVWriter) writeRecord(record []string) error {
+	err := w.writer.Write(record)
+	if err != nil {
+		return err
+	}
+
+	// separators and line ending, quoting is not expected in values
+	w.size += int64(len(record))
+	for _, f := range record {
+		w.size += int64(len(f))
+	}
+
+	return nil
+}
+
+func (w *CSVWriter) rotate() error {
+	if w.file != nil {
+		if w.maxSize > 0 && w.size >= w.maxSize {
+			return w.open()
+		}
+
+		if w.maxAge > 0 && time.Since(w.created) >= w.maxAge {
+			return w.open()
+		}
+
+		return nil
+	}
+
+	return w.open()
+}
+
+func (w *CSVWriter) open() error {
+	err := w.close()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := filepath.Join(w.dir, fmt.Sprintf("docker_stats-%s.csv", now.UTC().Format("20060102T150405.000000000")))
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.writer = csv.NewWriter(file)
+	w.size = 0
+	w.created = now
+
+	return w.writeRecord(csvHeader)
+}
+
+func (w *CSVWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	w.writer.Flush()
+	err := w.writer.Error()
+
+	cerr := w.file.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	w.file = nil
+	w.writer = nil
+
+	return err
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readCSVFiles(t *testing.T, dir string) [][][]string {
+	names, err := filepath.Glob(filepath.Join(dir, "docker_stats-*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := [][][]string{}
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatalf("error reading %s: %s", name, err)
+		}
+
+		files = append(files, records)
+	}
+
+	return files
+}
+
+func TestCSVWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewCSVWriter("myhost", dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	s.CPU.Total = 42
+
+	for i := 0; i < 2; i++ {
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	files := readCSVFiles(t, dir)
+	if len(files) != 1 || len(files[0]) != 1+2*len(intMetrics(s)) {
+		t.Fatalf("expected single file with header and 2 samples, got %v", files)
+	}
+
+	if files[0][0][0] != "timestamp" || files[0][0][5] != "value" {
+		t.Errorf("expected header as the first record, got %v", files[0][0])
+	}
+
+	for _, r := range files[0][1:] {
+		if r[4] == "cpu.total" {
+			if r[0] != "1431000000" || r[1] != "myhost" || r[2] != "myapp" || r[3] != "mytask" || r[5] != "42" {
+				t.Errorf("unexpected record: %v", r)
+			}
+
+			return
+		}
+	}
+
+	t.Errorf("expected cpu.total record in %v", files[0])
+}
+
+func TestCSVWriterRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewCSVWriter("myhost", dir, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := w.Write(Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)})
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+
+		info, err := w.file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Size() != w.size {
+			t.Errorf("expected tracked size %d to match file size %d", w.size, info.Size())
+		}
+	}
+
+	w.Close()
+
+	files := readCSVFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("expected file per sample once max size is reached, got %d files", len(files))
+	}
+
+	for _, f := range files {
+		if len(f) == 0 || f[0][0] != "timestamp" {
+			t.Errorf("expected every file to start with header, got %v", f)
+		}
+	}
+}
+
+func TestCSVWriterRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewCSVWriter("myhost", dir, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+
+	w.Write(s)
+	w.Write(s)
+
+	if files := readCSVFiles(t, dir); len(files) != 1 {
+		t.Fatalf("expected single file before max age, got %d files", len(files))
+	}
+
+	w.created = w.created.Add(-time.Hour)
+
+	w.Write(s)
+	w.Close()
+
+	files := readCSVFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("expected new file after max age, got %d files", len(files))
+	}
+
+	if len(files[1]) != 1+len(intMetrics(s)) {
+		t.Errorf("expected header and single sample in new file, got %d records", len(files[1]))
+	}
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// daemonApp and daemonTask are app and task of metrics of docker daemon
+const (
+	daemonApp  = "_docker"
+	daemonTask = "daemon"
+)
+
+// SetDaemonInfoInterval enables reporting of docker daemon info every
+// interval, like counts of containers and images and version of docker,
+// 0 disables it, it should be called before Run
+func (c *Collector) SetDaemonInfoInterval(interval time.Duration) {
+	c.daemonInfo = interval
+}
+
+// reportDaemonInfo periodically sends info of docker daemon
+func (c *Collector) reportDaemonInfo(ctx context.Context) {
+	every(ctx, c.daemonInfo, func(t time.Time) {
+		info, err := c.client.Info()
+		if err != nil {
+			Logf(LogWarn, "error getting docker daemon info: %s", err)
+			return
+		}
+
+		c.send(Stats{
+			App:         daemonApp,
+			Task:        daemonTask,
+			Time:        t,
+			Meta:        daemonMeta(info),
+			Metrics:     daemonMetrics(info),
+			MetricsOnly: true,
+		})
+	})
+}
+
+// daemonMetrics returns metrics of docker daemon info, version and
+// storage driver are metrics with value of 1 like version of collector,
+// goroutines and file descriptors are only reported in debug mode
+func daemonMetrics(info *docker.DockerInfo) map[string]uint64 {
+	metrics := map[string]uint64{
+		"daemon.containers":         uint64(info.Containers),
+		"daemon.containers_running": uint64(info.ContainersRunning),
+		"daemon.containers_paused":  uint64(info.ContainersPaused),
+		"daemon.containers_stopped": uint64(info.ContainersStopped),
+		"daemon.images":             uint64(info.Images),
+		"daemon.event_listeners":    uint64(info.NEventsListener),
+		"daemon.cpus":               uint64(info.NCPU),
+		"daemon.memory_total":       uint64(info.MemTotal),
+	}
+
+	if info.NGoroutines > 0 {
+		metrics["daemon.goroutines"] = uint64(info.NGoroutines)
+	}
+
+	if info.NFd > 0 {
+		metrics["daemon.fds"] = uint64(info.NFd)
+	}
+
+	if info.ServerVersion != "" {
+		metrics["daemon.version."+sanitizeForGraphite(info.ServerVersion)] = 1
+	}
+
+	if info.Driver != "" {
+		metrics["daemon.storage_driver."+sanitizeForGraphite(info.Driver)] = 1
+	}
+
+	return metrics
+}
+
+// daemonMeta returns docker daemon info for writers that support tags
+func daemonMeta(info *docker.DockerInfo) map[string]string {
+	meta := map[string]string{}
+
+	if info.ServerVersion != "" {
+		meta["docker_version"] = info.ServerVersion
+	}
+
+	if info.Driver != "" {
+		meta["storage_driver"] = info.Driver
+	}
+
+	if info.CgroupDriver != "" {
+		meta["cgroup_driver"] = info.CgroupDriver
+	}
+
+	return meta
+}
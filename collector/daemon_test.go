@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestDaemonMetrics(t *testing.T) {
+	info := &docker.DockerInfo{
+		Containers:        5,
+		ContainersRunning: 3,
+		ContainersStopped: 2,
+		Images:            12,
+		Driver:            "overlay2",
+		ServerVersion:     "20.10.7",
+		NGoroutines:       -1,
+	}
+
+	metrics := daemonMetrics(info)
+
+	expected := map[string]uint64{
+		"daemon.containers":              5,
+		"daemon.containers_running":      3,
+		"daemon.containers_stopped":      2,
+		"daemon.images":                  12,
+		"daemon.version.20_10_7":         1,
+		"daemon.storage_driver.overlay2": 1,
+	}
+	for k, v := range expected {
+		if value, ok := metrics[k]; !ok || value != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	if _, ok := metrics["daemon.goroutines"]; ok {
+		t.Errorf("expected no goroutines outside of debug mode, got %v", metrics)
+	}
+
+	meta := daemonMeta(info)
+	if meta["docker_version"] != "20.10.7" || meta["storage_driver"] != "overlay2" {
+		t.Errorf("unexpected meta of daemon: %v", meta)
+	}
+}
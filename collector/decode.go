@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// statsClient is docker client that decodes only fields of stats
+// that are used for metrics of enabled families, instead of the
+// whole docker.Stats that takes most of the time of collector
+type statsClient struct {
+	*docker.Client
+	disabled map[string]bool
+	latency  *latencyTracker
+}
+
+// newStatsClient creates statsClient on top of docker client with
+// metric families that are not decoded, monitors inspect containers
+// without inspect cache, since state of containers changes on restart
+func newStatsClient(client *docker.Client, disabled []string) statsClient {
+	c := statsClient{
+		Client:   client,
+		disabled: map[string]bool{},
+	}
+
+	for _, family := range disabled {
+		c.disabled[family] = true
+	}
+
+	return c
+}
+
+// Stats streams stats like docker.Client.Stats does, it falls back
+// to docker.Client.Stats for endpoints it doesn't know how to reach
+func (c statsClient) Stats(opts docker.StatsOptions) error {
+	u, err := c.statsURL(opts.ID, opts.Stream)
+	if err != nil {
+		return c.Client.Stats(opts)
+	}
+
+	defer close(opts.Stats)
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	if opts.Context != nil {
+		req = req.WithContext(opts.Context)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &docker.NoSuchContainer{ID: opts.ID}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from docker: %s", resp.Status)
+	}
+
+	if opts.Done != nil {
+		finished := make(chan struct{})
+		defer close(finished)
+
+		go func() {
+			select {
+			case <-opts.Done:
+				resp.Body.Close()
+			case <-finished:
+			}
+		}()
+	}
+
+	p := payloadPool.Get().(*statsPayload)
+	defer payloadPool.Put(p)
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		p.reset(c.disabled)
+
+		err := decoder.Decode(p)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				atomic.AddUint64(&selfCounters.decodeErrors, 1)
+			}
+
+			return err
+		}
+
+		stats := p.stats()
+		if c.latency != nil && !stats.Read.IsZero() {
+			c.latency.observe(time.Since(stats.Read))
+		}
+
+		opts.Stats <- stats
+	}
+}
+
+// statsURL returns url of stats of container,
+// only unix sockets and tcp endpoints are supported
+func (c statsClient) statsURL(id string, stream bool) (string, error) {
+	endpoint, err := url.Parse(c.Endpoint())
+	if err != nil {
+		return "", err
+	}
+
+	path := fmt.Sprintf("/containers/%s/stats?stream=%v", url.QueryEscape(id), stream)
+
+	switch endpoint.Scheme {
+	case "unix":
+		return "http://unix.sock" + path, nil
+	case "tcp", "http", "https":
+		scheme := "http"
+		if c.TLSConfig != nil || endpoint.Scheme == "https" {
+			scheme = "https"
+		}
+
+		return scheme + "://" + endpoint.Host + path, nil
+	default:
+		return "", fmt.Errorf("unsupported docker endpoint: %s", c.Endpoint())
+	}
+}
+
+// statsPayload has only fields of docker stats that are used for
+// metrics, families of metrics that are disabled are skipped
+type statsPayload struct {
+	Read        time.Time     `json:"read"`
+	CPUStats    familyPayload `json:"cpu_stats"`
+	MemoryStats familyPayload `json:"memory_stats"`
+	Network     familyPayload `json:"network"`
+	Networks    familyPayload `json:"networks"`
+
+	cpu      cpuStatsPayload
+	memory   memoryStatsPayload
+	network  networkStatsPayload
+	networks map[string]networkStatsPayload
+}
+
+// familyPayload decodes json object into value unless it is nil,
+// so objects of disabled families are only scanned, not decoded
+type familyPayload struct {
+	value interface{}
+}
+
+func (p *familyPayload) UnmarshalJSON(b []byte) error {
+	if p.value == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, p.value)
+}
+
+type cpuStatsPayload struct {
+	CPUUsage struct {
+		TotalUsage        uint64 `json:"total_usage"`
+		UsageInKernelmode uint64 `json:"usage_in_kernelmode"`
+		UsageInUsermode   uint64 `json:"usage_in_usermode"`
+	} `json:"cpu_usage"`
+}
+
+type memoryStatsPayload struct {
+	Usage    uint64 `json:"usage"`
+	MaxUsage uint64 `json:"max_usage"`
+	Limit    uint64 `json:"limit"`
+	Stats    struct {
+		TotalActiveAnon   uint64 `json:"total_active_anon"`
+		TotalActiveFile   uint64 `json:"total_active_file"`
+		TotalCache        uint64 `json:"total_cache"`
+		TotalInactiveAnon uint64 `json:"total_inactive_anon"`
+		TotalInactiveFile uint64 `json:"total_inactive_file"`
+		TotalMappedFile   uint64 `json:"total_mapped_file"`
+		TotalPgfault      uint64 `json:"total_pgfault"`
+		TotalPgpgin       uint64 `json:"total_pgpgin"`
+		TotalPgpgout      uint64 `json:"total_pgpgout"`
+		TotalRss          uint64 `json:"total_rss"`
+		TotalRssHuge      uint64 `json:"total_rss_huge"`
+		TotalUnevictable  uint64 `json:"total_unevictable"`
+		TotalWriteback    uint64 `json:"total_writeback"`
+	} `json:"stats"`
+}
+
+type networkStatsPayload struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxDropped uint64 `json:"rx_dropped"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxDropped uint64 `json:"tx_dropped"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxPackets uint64 `json:"tx_packets"`
+}
+
+// reset prepares payload for decoding, disabled families are skipped
+func (p *statsPayload) reset(disabled map[string]bool) {
+	networks := p.networks
+	for k := range networks {
+		delete(networks, k)
+	}
+
+	*p = statsPayload{networks: networks}
+
+	if !disabled["cpu"] {
+		p.CPUStats.value = &p.cpu
+	}
+
+	if !disabled["memory"] {
+		p.MemoryStats.value = &p.memory
+	}
+
+	if !disabled["net"] {
+		p.Network.value = &p.network
+		p.Networks.value = &p.networks
+	}
+}
+
+// stats converts payload to docker stats from the pool, newer docker
+// versions report networks per interface and they are summed up
+func (p *statsPayload) stats() *docker.Stats {
+	s := statsPool.Get().(*docker.Stats)
+	*s = docker.Stats{Read: p.Read}
+
+	s.CPUStats.CPUUsage.TotalUsage = p.cpu.CPUUsage.TotalUsage
+	s.CPUStats.CPUUsage.UsageInKernelmode = p.cpu.CPUUsage.UsageInKernelmode
+	s.CPUStats.CPUUsage.UsageInUsermode = p.cpu.CPUUsage.UsageInUsermode
+
+	s.MemoryStats.Usage = p.memory.Usage
+	s.MemoryStats.MaxUsage = p.memory.MaxUsage
+	s.MemoryStats.Limit = p.memory.Limit
+	s.MemoryStats.Stats.TotalActiveAnon = p.memory.Stats.TotalActiveAnon
+	s.MemoryStats.Stats.TotalActiveFile = p.memory.Stats.TotalActiveFile
+	s.MemoryStats.Stats.TotalCache = p.memory.Stats.TotalCache
+	s.MemoryStats.Stats.TotalInactiveAnon = p.memory.Stats.TotalInactiveAnon
+	s.MemoryStats.Stats.TotalInactiveFile = p.memory.Stats.TotalInactiveFile
+	s.MemoryStats.Stats.TotalMappedFile = p.memory.Stats.TotalMappedFile
+	s.MemoryStats.Stats.TotalPgfault = p.memory.Stats.TotalPgfault
+	s.MemoryStats.Stats.TotalPgpgin = p.memory.Stats.TotalPgpgin
+	s.MemoryStats.Stats.TotalPgpgout = p.memory.Stats.TotalPgpgout
+	s.MemoryStats.Stats.TotalRss = p.memory.Stats.TotalRss
+	s.MemoryStats.Stats.TotalRssHuge = p.memory.Stats.TotalRssHuge
+	s.MemoryStats.Stats.TotalUnevictable = p.memory.Stats.TotalUnevictable
+	s.MemoryStats.Stats.TotalWriteback = p.memory.Stats.TotalWriteback
+
+	network := p.network
+	for _, n := range p.networks {
+		network.RxBytes += n.RxBytes
+		network.RxDropped += n.RxDropped
+		network.RxErrors += n.RxErrors
+		network.RxPackets += n.RxPackets
+		network.TxBytes += n.TxBytes
+		network.TxDropped += n.TxDropped
+		network.TxErrors += n.TxErrors
+		network.TxPackets += n.TxPackets
+	}
+
+	s.Network.RxBytes = network.RxBytes
+	s.Network.RxDropped = network.RxDropped
+	s.Network.RxErrors = network.RxErrors
+	s.Network.RxPackets = network.RxPackets
+	s.Network.TxBytes = network.TxBytes
+	s.Network.TxDropped = network.TxDropped
+	s.Network.TxErrors = network.TxErrors
+	s.Network.TxPackets = network.TxPackets
+
+	return s
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const statsPayloadSample = `{
+	"read": "2015-05-07T12:00:00Z",
+	"cpu_stats": {"cpu_usage": {"total_usage": 42, "percpu_usage": [20, 22], "usage_in_usermode": 30, "usage_in_kernelmode": 12}},
+	"memory_stats": {"usage": 100, "limit": 1000, "stats": {"total_rss": 80, "total_pgfault": 7}},
+	"networks": {"eth0": {"rx_bytes": 1, "tx_bytes": 2}, "eth1": {"rx_bytes": 10, "tx_bytes": 20}},
+	"blkio_stats": {"io_service_bytes_recursive": [{"major": 8, "minor": 0, "op": "Read", "value": 1}]}
+}`
+
+func TestStatsClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/abcdef/stats" || r.URL.Query().Get("stream") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+
+		for i := 0; i < 2; i++ {
+			fmt.Fprintln(w, statsPayloadSample)
+		}
+	}))
+
+	defer server.Close()
+
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("error creating docker client: %s", err)
+	}
+
+	c := newStatsClient(client, []string{"memory"})
+
+	ch := make(chan *docker.Stats)
+	result := []*docker.Stats{}
+	done := make(chan struct{})
+
+	go func() {
+		for s := range ch {
+			result = append(result, s)
+		}
+
+		close(done)
+	}()
+
+	err = c.Stats(docker.StatsOptions{ID: "abcdef", Stats: ch, Stream: true})
+	if err != nil {
+		t.Fatalf("error getting stats: %s", err)
+	}
+
+	<-done
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(result))
+	}
+
+	s := result[0]
+
+	if s.Read.Unix() != 1431000000 {
+		t.Errorf("expected read time 1431000000, got %d", s.Read.Unix())
+	}
+
+	if s.CPUStats.CPUUsage.TotalUsage != 42 || s.CPUStats.CPUUsage.UsageInUsermode != 30 {
+		t.Errorf("unexpected cpu stats: %#v", s.CPUStats.CPUUsage)
+	}
+
+	if s.MemoryStats.Usage != 0 || s.MemoryStats.Stats.TotalRss != 0 {
+		t.Errorf("expected memory stats of disabled family to be skipped, got %#v", s.MemoryStats)
+	}
+
+	if s.Network.RxBytes != 11 || s.Network.TxBytes != 22 {
+		t.Errorf("expected networks to be summed up, got %#v", s.Network)
+	}
+}
+
+func TestStatsClientContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, statsPayloadSample)
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+
+	defer server.Close()
+
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("error creating docker client: %s", err)
+	}
+
+	c := newStatsClient(client, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan *docker.Stats)
+
+	go func() {
+		<-ch
+		cancel()
+
+		for range ch {
+		}
+	}()
+
+	err = c.Stats(docker.StatsOptions{ID: "abcdef", Stats: ch, Stream: true, Context: ctx})
+	if err == nil {
+		t.Errorf("expected stats stream to end with error after cancellation")
+	}
+}
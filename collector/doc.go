@@ -0,0 +1,37 @@
+// Package collector collects resource usage of docker containers and
+// writes it to monitoring backends, it is what collector command is
+// built from and it can be embedded into other programs instead of
+// running the command.
+//
+// Collector discovers containers, runs a Monitor for every container
+// it finds identity of and passes samples to Writer, that is usually
+// a pipeline of wrappers around backend writers:
+//
+//	client, _ := docker.NewClient("unix:///var/run/docker.sock")
+//	writer, _ := collector.NewWriter("collectd", "myhost", nil)
+//	col := collector.NewCollector(client, collector.NewRateWriter(writer), 10*time.Second)
+//	col.Run(ctx)
+//
+// Single containers can be monitored without Collector with NewMonitor
+// and Monitor.Run, package collectortest has fake docker client to test
+// integrations without docker daemon.
+//
+// # API stability
+//
+// Exported identifiers of this package and package collectortest follow
+// semantic versioning of repository tags: they are not removed and their
+// behavior doesn't change in incompatible ways within a major version,
+// new identifiers and options are added in minor versions. This covers:
+//
+//   - Collector, its setters and Run, ContainerIdentity, Hooks
+//   - Monitor, MonitorOption, MonitorError and its kinds
+//   - IdentityExtractor, Filter and their built-in implementations
+//   - Stats, Notification, Writer, Notifier and writer registry
+//   - writers and wrapping writers with their constructors
+//   - names of metrics, see MetricFamilies
+//
+// Unexported identifiers, command line flags of collector command and
+// formats of spool files are not part of the API, spool files are only
+// read by the version that wrote them. Fields of Stats and ContainerIdentity
+// can be added, so they should be created by field names.
+package collector
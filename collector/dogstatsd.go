@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"bytes"
+	"io"
+	"net"
+)
+
+// dogStatsDMaxPacketSize keeps packets under common udp mtu
+const dogStatsDMaxPacketSize = 1432
+
+// DogStatsDWriter is responsible for writing data to wrapped writer
+// in dogstatsd format with host, app, task, image and metadata tags,
+// wrapped writer is usually udp connection to datadog agent
+type DogStatsDWriter struct {
+	host   string
+	writer io.Writer
+}
+
+// NewDogStatsDWriter creates new DogStatsDWriter
+// with specified hostname and writer
+func NewDogStatsDWriter(host string, writer io.Writer) DogStatsDWriter {
+	return DogStatsDWriter{
+		host:   host,
+		writer: writer,
+	}
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "dogstatsd",
+		Usage: "dogstatsd gauges over udp",
+		Options: []WriterOption{
+			{Name: "addr", Default: "127.0.0.1:8125", Usage: "dogstatsd udp address"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			conn, err := net.Dial("udp", o["addr"])
+			if err != nil {
+				return nil, err
+			}
+
+			// packets are formed by writer, so udp writes are not buffered
+			return closingWriter{NewDogStatsDWriter(host, conn), conn}, nil
+		},
+	})
+}
+
+func (w DogStatsDWriter) Write(s Stats) error {
+	tags := getBuffer()
+	defer putBuffer(tags)
+
+	tags.WriteString("|g|#host:")
+	tags.WriteString(w.host)
+	tags.WriteString(",app:")
+	tags.WriteString(s.App)
+	tags.WriteString(",task:")
+	tags.WriteString(s.Task)
+	if s.Image != "" {
+		tags.WriteString(",image:")
+		appendDogStatsDTag(tags, s.Image)
+	}
+	for k, v := range s.Meta {
+		tags.WriteByte(',')
+		appendDogStatsDTag(tags, k)
+		tags.WriteByte(':')
+		appendDogStatsDTag(tags, v)
+	}
+
+	packet := getBuffer()
+	defer putBuffer(packet)
+
+	var err error
+	eachMetric(&s, func(k string, v uint64) {
+		if err != nil {
+			return
+		}
+
+		n := packet.Len()
+		if n > 0 {
+			packet.WriteByte('\n')
+		}
+
+		packet.WriteString("docker_stats.")
+		packet.WriteString(k)
+		packet.WriteByte(':')
+		appendUint(packet, v)
+		packet.Write(tags.Bytes())
+
+		if n == 0 || packet.Len() <= dogStatsDMaxPacketSize {
+			return
+		}
+
+		// line doesn't fit, so the packet before it is sent and
+		// the line is moved to the start of the next packet
+		line := packet.Bytes()[n+1:]
+		_, err = w.writer.Write(packet.Bytes()[:n])
+		copy(packet.Bytes(), line)
+		packet.Truncate(len(line))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if packet.Len() == 0 {
+		return nil
+	}
+
+	_, err = w.writer.Write(packet.Bytes())
+	return err
+}
+
+// Flush is no-op, DogStatsDWriter doesn't buffer
+func (w DogStatsDWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w DogStatsDWriter) Close() error {
+	return nil
+}
+
+// appendDogStatsDTag writes tag key or value with characters
+// that separate tags and fields of packets replaced with underscores
+func appendDogStatsDTag(b *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ',', '|', '#', '\n':
+			b.WriteByte('_')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// packetRecorder records every write as a separate packet
+type packetRecorder struct {
+	packets []string
+}
+
+func (r *packetRecorder) Write(p []byte) (int, error) {
+	r.packets = append(r.packets, string(p))
+	return len(p), nil
+}
+
+func TestDogStatsDWriter(t *testing.T) {
+	r := &packetRecorder{}
+	w := NewDogStatsDWriter("myhost", r)
+
+	s := benchmarkSample()
+	s.Image = "registry/my,app:1"
+	s.Metrics = map[string]uint64{}
+	for _, c := range "abcdefghijklmnopqrstuvwxyz" {
+		s.Metrics["custom."+string(c)+strings.Repeat("x", 40)] = 1
+	}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(r.packets) < 2 {
+		t.Fatalf("expected metrics to be split into several packets, got %d", len(r.packets))
+	}
+
+	lines := 0
+	for _, p := range r.packets {
+		if len(p) > dogStatsDMaxPacketSize {
+			t.Errorf("expected packets under %d bytes, got %d", dogStatsDMaxPacketSize, len(p))
+		}
+
+		for _, l := range strings.Split(p, "\n") {
+			lines++
+			if !strings.HasSuffix(l, "|g|#host:myhost,app:myapp,task:mytask,image:registry/my_app:1") {
+				t.Errorf("unexpected line %q", l)
+			}
+		}
+	}
+
+	if lines != len(intMetrics(s)) {
+		t.Errorf("expected %d lines, got %d", len(intMetrics(s)), lines)
+	}
+
+	expected := "docker_stats.cpu.total:123456789|g|#host:myhost,app:myapp,task:mytask,image:registry/my_app:1"
+	for _, p := range r.packets {
+		for _, l := range strings.Split(p, "\n") {
+			if l == expected {
+				return
+			}
+		}
+	}
+
+	t.Errorf("expected line %q in packets:\n%s", expected, strings.Join(r.packets, "\n--\n"))
+}
+
+func BenchmarkDogStatsDWriter(b *testing.B) {
+	benchmarkWriter(b, NewDogStatsDWriter("myhost", ioutil.Discard))
+}
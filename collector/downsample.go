@@ -0,0 +1,275 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DownsampleMode defines how metrics of samples
+// in a window are combined into a single sample
+type DownsampleMode int
+
+const (
+	// DownsampleAverage writes average of values in a window
+	DownsampleAverage DownsampleMode = iota
+	// DownsampleMax writes max of values in a window
+	DownsampleMax
+	// DownsampleSummary writes average of values in a window along
+	// with min, max and p95 as metrics with .min, .max and .p95 suffixes,
+	// counters only get average unless they are converted to rates
+	DownsampleSummary
+)
+
+// ParseDownsampleMode parses downsample mode from its name: avg, max or summary
+func ParseDownsampleMode(s string) (DownsampleMode, error) {
+	switch s {
+	case "avg":
+		return DownsampleAverage, nil
+	case "max":
+		return DownsampleMax, nil
+	case "summary":
+		return DownsampleSummary, nil
+	default:
+		return DownsampleAverage, fmt.Errorf("unknown downsample mode: %s", s)
+	}
+}
+
+// downsampleWindow keeps values of metrics of a task in current window
+type downsampleWindow struct {
+	start  time.Time
+	last   Stats
+	values map[string][]uint64
+}
+
+// DownsampleWriter is responsible for combining samples of every task
+// over a longer window before writing them to wrapped writer, so
+// metrics can be collected often and stored with lower resolution
+type DownsampleWriter struct {
+	writer Writer
+	window time.Duration
+	mode   DownsampleMode
+	rates  bool
+
+	// mutex guards windows and serializes access to wrapped writer
+	mutex   sync.Mutex
+	windows map[string]*downsampleWindow
+	now     func() time.Time
+
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// NewDownsampleWriter creates new DownsampleWriter on top of specified
+// writer with window size and downsample mode, windows that ended are
+// written every half of window even if their tasks stopped sending
+func NewDownsampleWriter(writer Writer, window time.Duration, mode DownsampleMode) *DownsampleWriter {
+	w := &DownsampleWriter{
+		writer:  writer,
+		window:  window,
+		mode:    mode,
+		windows: map[string]*downsampleWindow{},
+		now:     time.Now,
+		done:    make(chan struct{}),
+	}
+
+	if window/2 > 0 {
+		w.ticker = time.NewTicker(window / 2)
+		go w.flushLoop()
+	}
+
+	return w
+}
+
+// SetRates tells that counters are converted to rates before they are
+// downsampled, so they are gauges and get summaries like other gauges
+func (w *DownsampleWriter) SetRates(rates bool) {
+	w.rates = rates
+}
+
+// Write adds sample to the window of its task, combined sample
+// is written when sample time is past the end of the window
+func (w *DownsampleWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	key := s.App + "/" + s.Task
+
+	window, ok := w.windows[key]
+	if ok && s.Time.Sub(window.start) >= w.window {
+		delete(w.windows, key)
+
+		err := w.writeWindow(window)
+		if err != nil {
+			return err
+		}
+
+		ok = false
+	}
+
+	if !ok {
+		window = &downsampleWindow{
+			start:  s.Time,
+			values: map[string][]uint64{},
+		}
+
+		w.windows[key] = window
+	}
+
+	window.last = s
+
+	for k, v := range intMetrics(s) {
+		window.values[k] = append(window.values[k], v)
+	}
+
+	return nil
+}
+
+// Notify writes notification to wrapped writer right away
+func (w *DownsampleWriter) Notify(n Notification) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return Notify(w.writer, n)
+}
+
+// Flush writes windows that ended by now and flushes wrapped writer,
+// so windows of tasks that stopped sending samples are not kept and
+// tasks sampled less often than the window are written on time,
+// incomplete windows are kept
+func (w *DownsampleWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := w.now()
+
+	var err error
+	for key, window := range w.windows {
+		if now.Sub(window.start) < w.window {
+			continue
+		}
+
+		delete(w.windows, key)
+
+		werr := w.writeWindow(window)
+		if err == nil {
+			err = werr
+		}
+	}
+
+	ferr := w.writer.Flush()
+	if err == nil {
+		err = ferr
+	}
+
+	return err
+}
+
+// Close stops periodic flushing, writes incomplete
+// windows and closes wrapped writer
+func (w *DownsampleWriter) Close() error {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		close(w.done)
+	}
+
+	w.mutex.Lock()
+
+	var err error
+	for key, window := range w.windows {
+		delete(w.windows, key)
+
+		werr := w.writeWindow(window)
+		if err == nil {
+			err = werr
+		}
+	}
+
+	w.mutex.Unlock()
+
+	cerr := w.writer.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (w *DownsampleWriter) flushLoop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			err := w.Flush()
+			if err != nil {
+				errorf("error flushing downsampled windows with %T: %s", w.writer, err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// writeWindow writes combined sample with time of the last sample
+func (w *DownsampleWriter) writeWindow(window *downsampleWindow) error {
+	s := window.last
+	s.Metrics = map[string]uint64{}
+	s.MetricsOnly = true
+
+	for k, values := range window.values {
+		last := values[len(values)-1]
+
+		sort.Sort(uint64Slice(values))
+
+		max := values[len(values)-1]
+
+		if w.mode == DownsampleMax {
+			s.Metrics[k] = max
+			continue
+		}
+
+		// averages and summaries of counters that only grow mean
+		// nothing and break across resets, the last value is written
+		if !w.rates && counterMetrics[k] {
+			s.Metrics[k] = last
+			continue
+		}
+
+		// sum is float64, sum of large values can overflow uint64
+		sum := float64(0)
+		for _, v := range values {
+			sum += float64(v)
+		}
+
+		if mean := sum / float64(len(values)); mean < math.MaxUint64 {
+			s.Metrics[k] = uint64(mean)
+		} else {
+			s.Metrics[k] = math.MaxUint64
+		}
+
+		if w.mode == DownsampleSummary {
+			s.Metrics[k+".min"] = values[0]
+			s.Metrics[k+".max"] = max
+			s.Metrics[k+".p95"] = percentile(values, 0.95)
+		}
+	}
+
+	return w.writer.Write(s)
+}
+
+// percentile returns nearest rank percentile of sorted values
+func percentile(sorted []uint64, p float64) uint64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
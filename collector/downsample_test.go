@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDownsampleWriter(t *testing.T) {
+	for _, c := range []struct {
+		mode   DownsampleMode
+		first  uint64
+		second uint64
+	}{
+		{DownsampleAverage, 15, 65},
+		{DownsampleMax, 20, 100},
+	} {
+		r := &recordingWriter{}
+		w := NewDownsampleWriter(r, time.Minute, c.mode)
+
+		start := time.Unix(1431000000, 0)
+
+		for i, v := range []uint64{10, 20, 30, 100} {
+			s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+			s.Time = start.Add(time.Duration(i) * 30 * time.Second)
+			s.Metrics = map[string]uint64{"value": v}
+
+			err := w.Write(s)
+			if err != nil {
+				t.Fatalf("error writing stats: %s", err)
+			}
+		}
+
+		// third sample is at 60s, so the first window has two samples
+		if len(r.written) != 1 {
+			t.Fatalf("expected 1 written sample for mode %d, got %d", c.mode, len(r.written))
+		}
+
+		if !r.written[0].Time.Equal(start.Add(30 * time.Second)) {
+			t.Errorf("expected time of the last sample in window, got %s", r.written[0].Time)
+		}
+
+		err := w.Close()
+		if err != nil {
+			t.Fatalf("error closing writer: %s", err)
+		}
+
+		if len(r.written) != 2 {
+			t.Fatalf("expected 2 written samples for mode %d after close, got %d", c.mode, len(r.written))
+		}
+
+		if v := r.written[0].Metrics["value"]; v != c.first {
+			t.Errorf("expected value of the first window for mode %d to be %d, got %d", c.mode, c.first, v)
+		}
+
+		if v := r.written[1].Metrics["value"]; v != c.second {
+			t.Errorf("expected value of the second window for mode %d to be %d, got %d", c.mode, c.second, v)
+		}
+	}
+}
+
+func TestDownsampleWriterSummary(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewDownsampleWriter(r, time.Hour, DownsampleSummary)
+
+	start := time.Unix(1431000000, 0)
+
+	for i := 1; i <= 20; i++ {
+		s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+		s.Time = start.Add(time.Duration(i) * time.Second)
+		s.Metrics = map[string]uint64{"cpu": uint64(21-i) * 10, "cpu.total": uint64(i) * 1000}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if len(r.written) != 1 {
+		t.Fatalf("expected 1 written sample, got %d", len(r.written))
+	}
+
+	expected := map[string]uint64{
+		"cpu":     105,
+		"cpu.min": 10,
+		"cpu.max": 200,
+		"cpu.p95": 190,
+	}
+
+	for k, v := range expected {
+		if r.written[0].Metrics[k] != v {
+			t.Errorf("expected %s to be %d, got %d", k, v, r.written[0].Metrics[k])
+		}
+	}
+
+	if v := r.written[0].Metrics["cpu.total"]; v != 20000 {
+		t.Errorf("expected the last value of counter cpu.total 20000, got %d", v)
+	}
+
+	for _, suffix := range []string{".min", ".max", ".p95"} {
+		if v, ok := r.written[0].Metrics["cpu.total"+suffix]; ok {
+			t.Errorf("expected no summary of counter cpu.total, got cpu.total%s %d", suffix, v)
+		}
+	}
+}
+
+func TestDownsampleWriterLargeValues(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewDownsampleWriter(r, time.Hour, DownsampleAverage)
+
+	start := time.Unix(1431000000, 0)
+
+	for i, v := range []uint64{1 << 63, 1 << 63, math.MaxUint64} {
+		s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+		s.Time = start.Add(time.Duration(i) * time.Second)
+		s.Metrics = map[string]uint64{"value": v, "huge": math.MaxUint64}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if v := r.written[0].Metrics["value"]; v < 1<<63 {
+		t.Errorf("expected average of large values not to overflow, got %d", v)
+	}
+
+	if v := r.written[0].Metrics["huge"]; v != math.MaxUint64 {
+		t.Errorf("expected average of max values to be max value, got %d", v)
+	}
+}
+
+func TestDownsampleWriterFlush(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewDownsampleWriter(r, time.Minute, DownsampleAverage)
+
+	start := time.Unix(1431000000, 0)
+	now := start
+	w.now = func() time.Time { return now }
+
+	for _, task := range []string{"stopped", "running"} {
+		s := Stats{App: "myapp", Task: task, MetricsOnly: true}
+		s.Time = start
+		s.Metrics = map[string]uint64{"value": 10}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	now = start.Add(30 * time.Second)
+
+	s := Stats{App: "myapp", Task: "running", MetricsOnly: true}
+	s.Time = now
+	s.Metrics = map[string]uint64{"value": 20}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("error flushing writer: %s", err)
+	}
+
+	if len(r.written) != 0 {
+		t.Fatalf("expected incomplete windows to be kept, got %d written samples", len(r.written))
+	}
+
+	// stopped task sends nothing after its first sample
+	now = start.Add(time.Minute)
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("error flushing writer: %s", err)
+	}
+
+	if len(r.written) != 2 {
+		t.Fatalf("expected windows that ended to be written on flush, got %d written samples", len(r.written))
+	}
+
+	values := map[string]uint64{}
+	for _, s := range r.written {
+		values[s.Task] = s.Metrics["value"]
+	}
+
+	if values["stopped"] != 10 || values["running"] != 15 {
+		t.Errorf("expected values 10 of stopped and 15 of running task, got %v", values)
+	}
+
+	if len(w.windows) != 0 {
+		t.Errorf("expected written windows to be forgotten, got %d", len(w.windows))
+	}
+
+	r = &recordingWriter{}
+	w = NewDownsampleWriter(r, time.Hour, DownsampleSummary)
+	w.SetRates(true)
+
+	for i := 1; i <= 20; i++ {
+		s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+		s.Time = start.Add(time.Duration(i) * time.Second)
+		s.Metrics = map[string]uint64{"cpu.total": uint64(i) * 10}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if v := r.written[0].Metrics["cpu.total.p95"]; v != 190 {
+		t.Errorf("expected p95 of rate of cpu.total to be 190, got %d", v)
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DryRunWriter is responsible for printing metrics that would be
+// written by a writer in human readable form instead of writing them,
+// one line per metric with writer name, host, app, task and time
+type DryRunWriter struct {
+	name   string
+	host   string
+	writer io.Writer
+}
+
+// NewDryRunWriter creates new DryRunWriter with name of
+// writer it stands in for, hostname and output writer
+func NewDryRunWriter(name string, host string, writer io.Writer) DryRunWriter {
+	return DryRunWriter{
+		name:   name,
+		host:   host,
+		writer: writer,
+	}
+}
+
+func (w DryRunWriter) Write(s Stats) error {
+	metrics := intMetrics(s)
+
+	names := make([]string, 0, len(metrics))
+	for k := range metrics {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	b := getBuffer()
+	defer putBuffer(b)
+	for _, k := range names {
+		fmt.Fprintf(b, "%s: host=%s app=%s task=%s %s %d %d\n", w.name, w.host, s.App, s.Task, k, metrics[k], s.Time.Unix())
+	}
+
+	_, err := w.writer.Write(b.Bytes())
+	return err
+}
+
+func (w DryRunWriter) Notify(n Notification) error {
+	_, err := fmt.Fprintf(w.writer, "%s: host=%s app=%s task=%s notification %s %s %q %d\n", w.name, w.host, n.App, n.Task, n.Event, n.Severity, n.Message, n.Time.Unix())
+	return err
+}
+
+// Flush is no-op, DryRunWriter doesn't buffer
+func (w DryRunWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w DryRunWriter) Close() error {
+	return nil
+}
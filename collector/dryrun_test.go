@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDryRunWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewDryRunWriter("opentsdb", "myhost", b)
+
+	s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+	s.Time = time.Unix(1431000000, 0)
+	s.Metrics = map[string]uint64{"b": 2, "a": 1}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := "opentsdb: host=myhost app=myapp task=mytask a 1 1431000000\n" +
+		"opentsdb: host=myhost app=myapp task=mytask b 2 1431000000\n"
+
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
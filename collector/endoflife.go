@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// EndOfLife defines what is written when container stops,
+// so stopped containers can be told apart from broken collector
+type EndOfLife int
+
+const (
+	// EndOfLifeNone writes nothing, series just stop
+	EndOfLifeNone EndOfLife = iota
+	// EndOfLifeZero writes the last sample of container with all
+	// metrics set to zero, so series drop instead of flatlining
+	EndOfLifeZero
+	// EndOfLifeMarker writes lifecycle.ended metric set to 1
+	EndOfLifeMarker
+)
+
+// endedMetric is the metric of samples written by EndOfLifeMarker
+const endedMetric = "lifecycle.ended"
+
+// ParseEndOfLife parses end of life mode from its name: none, zero or marker
+func ParseEndOfLife(s string) (EndOfLife, error) {
+	switch s {
+	case "none":
+		return EndOfLifeNone, nil
+	case "zero":
+		return EndOfLifeZero, nil
+	case "marker":
+		return EndOfLifeMarker, nil
+	default:
+		return EndOfLifeNone, fmt.Errorf("unknown end of life mode: %s", s)
+	}
+}
+
+// SetEndOfLife sets what is written when monitored container stops,
+// nothing is written when collector stops, it should be called before Run
+func (c *Collector) SetEndOfLife(mode EndOfLife) {
+	c.endOfLife = mode
+}
+
+// endOfLifeSample returns the final sample of task after its last
+// sample for end of life mode, false is returned if there is none
+func endOfLifeSample(mode EndOfLife, last Stats, t time.Time) (Stats, bool) {
+	s := Stats{App: last.App, Task: last.Task, Meta: last.Meta, Time: t, MetricsOnly: true}
+
+	switch mode {
+	case EndOfLifeZero:
+		s.Metrics = map[string]uint64{}
+		for k := range intMetrics(last) {
+			s.Metrics[k] = 0
+		}
+	case EndOfLifeMarker:
+		s.Metrics = map[string]uint64{endedMetric: 1}
+	default:
+		return s, false
+	}
+
+	return s, true
+}
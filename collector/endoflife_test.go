@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndOfLifeSample(t *testing.T) {
+	last := Stats{App: "web", Task: "1", Time: time.Unix(1431000000, 0)}
+	last.Memory.Usage = 100
+	last.Metrics = map[string]uint64{"probe.requests": 5}
+
+	ended := last.Time.Add(time.Second)
+
+	if _, ok := endOfLifeSample(EndOfLifeNone, last, ended); ok {
+		t.Errorf("expected no sample without end of life mode")
+	}
+
+	s, ok := endOfLifeSample(EndOfLifeZero, last, ended)
+	if !ok {
+		t.Fatalf("expected sample in zero mode")
+	}
+
+	if s.App != "web" || s.Task != "1" || !s.Time.Equal(ended) {
+		t.Errorf("expected sample of web/1 at %s, got %s/%s at %s", ended, s.App, s.Task, s.Time)
+	}
+
+	if v, ok := s.Metrics["memory.usage"]; !ok || v != 0 {
+		t.Errorf("expected zero memory.usage, got %v", s.Metrics)
+	}
+
+	if v, ok := s.Metrics["probe.requests"]; !ok || v != 0 {
+		t.Errorf("expected zero probe.requests, got %v", s.Metrics)
+	}
+
+	s, ok = endOfLifeSample(EndOfLifeMarker, last, ended)
+	if !ok || len(s.Metrics) != 1 || s.Metrics[endedMetric] != 1 {
+		t.Errorf("expected only %s metric in marker mode, got %v", endedMetric, s.Metrics)
+	}
+}
+
+func TestParseEndOfLife(t *testing.T) {
+	for _, name := range []string{"none", "zero", "marker"} {
+		if _, err := ParseEndOfLife(name); err != nil {
+			t.Errorf("error parsing %s: %s", name, err)
+		}
+	}
+
+	if _, err := ParseEndOfLife("nan"); err == nil {
+		t.Errorf("expected error parsing unknown mode")
+	}
+}
@@ -0,0 +1,78 @@
+package collector_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bobrik/collectd-docker/collector"
+	"github.com/bobrik/collectd-docker/collector/collectortest"
+	"github.com/fsouza/go-dockerclient"
+)
+
+func ExampleMonitor_Run() {
+	client := collectortest.NewClient(&docker.Container{
+		ID:     "0123456789ab",
+		Config: &docker.Config{Env: []string{"COLLECTD_DOCKER_APP=web"}},
+	})
+
+	client.SetStats("0123456789ab", nil, &docker.Stats{Read: time.Unix(1431000000, 0)})
+
+	m, err := collector.NewMonitor(context.Background(), client, "0123456789ab", collector.WithInterval(0))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ch := make(chan collector.Stats, 1)
+
+	err = m.Run(context.Background(), ch)
+	fmt.Println(err)
+
+	s := <-ch
+	fmt.Println(s.App, s.Task, s.Time.Unix())
+
+	// Output:
+	// container is gone
+	// web default 1431000000
+}
+
+func ExampleNewWriter() {
+	writer, err := collector.NewWriter("json", "myhost", nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	writer = collector.NewPrefixWriter(writer, "docker.", "")
+
+	s := collector.Stats{
+		App:         "web",
+		Task:        "1",
+		Time:        time.Unix(1431000000, 0),
+		Metrics:     map[string]uint64{"requests": 42},
+		MetricsOnly: true,
+	}
+
+	writer.Write(s)
+	writer.Close()
+
+	// Output:
+	// {"host":"myhost","app":"web","task":"1","timestamp":1431000000,"metrics":{"docker.requests":42}}
+}
+
+func ExampleStats_EachMetric() {
+	s := collector.Stats{
+		App:         "web",
+		Task:        "1",
+		Metrics:     map[string]uint64{"requests": 42},
+		MetricsOnly: true,
+	}
+
+	s.EachMetric(func(name string, value uint64) {
+		fmt.Println(name, value)
+	})
+
+	// Output:
+	// requests 42
+}
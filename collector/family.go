@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetricFamilies returns sorted names of container metric families,
+// family is the part of metric name before the first dot
+func MetricFamilies() []string {
+	seen := map[string]bool{}
+	for name := range containerMetrics(Stats{}) {
+		seen[metricFamily(name)] = true
+	}
+
+	families := make([]string, 0, len(seen))
+	for family := range seen {
+		families = append(families, family)
+	}
+
+	sort.Strings(families)
+
+	return families
+}
+
+// metricFamily returns family of metric name
+func metricFamily(name string) string {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// FamilyWriter is responsible for dropping metrics of disabled
+// families before they reach wrapped writer, to control cardinality
+type FamilyWriter struct {
+	writer   Writer
+	disabled map[string]bool
+}
+
+// NewFamilyWriter creates new FamilyWriter on top of specified
+// writer with metric families that shouldn't be written
+func NewFamilyWriter(writer Writer, disabled []string) FamilyWriter {
+	w := FamilyWriter{
+		writer:   writer,
+		disabled: map[string]bool{},
+	}
+
+	for _, family := range disabled {
+		w.disabled[family] = true
+	}
+
+	return w
+}
+
+// Write passes sample with metrics of enabled families to wrapped
+// writer, samples without metrics left are skipped entirely
+func (w FamilyWriter) Write(s Stats) error {
+	metrics := map[string]uint64{}
+	for k, v := range intMetrics(s) {
+		if !w.disabled[metricFamily(k)] {
+			metrics[k] = v
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	s.Metrics = metrics
+	s.MetricsOnly = true
+
+	return w.writer.Write(s)
+}
+
+func (w FamilyWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w FamilyWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w FamilyWriter) Close() error {
+	return w.writer.Close()
+}
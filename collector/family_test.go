@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetricFamilies(t *testing.T) {
+	families := MetricFamilies()
+	expected := []string{"cpu", "memory", "net"}
+
+	if !reflect.DeepEqual(families, expected) {
+		t.Errorf("expected families %v, got %v", expected, families)
+	}
+}
+
+func TestFamilyWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewFamilyWriter(r, []string{"memory", "net"})
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.CPU.Total = 42
+	s.Memory.Usage = 100
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Write(Stats{App: "myapp", Task: "mytask", MetricsOnly: true, Metrics: map[string]uint64{"net.rx_bytes": 1}})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(r.written) != 1 {
+		t.Fatalf("expected 1 written sample, got %d", len(r.written))
+	}
+
+	metrics := intMetrics(r.written[0])
+
+	if metrics["cpu.total"] != 42 {
+		t.Errorf("expected cpu.total to be 42, got %v", metrics)
+	}
+
+	for name := range metrics {
+		if family := metricFamily(name); family != "cpu" {
+			t.Errorf("unexpected metric %s of disabled family %s", name, family)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Filter decides whether inspected container should be monitored,
+// it is checked before monitor is created and before identity
+// of container is extracted
+type Filter interface {
+	Match(c *docker.Container) bool
+}
+
+// FilterFunc adapts func to Filter
+type FilterFunc func(c *docker.Container) bool
+
+// Match calls f(c)
+func (f FilterFunc) Match(c *docker.Container) bool {
+	return f(c)
+}
+
+type labelFilter struct {
+	label string
+	value string
+}
+
+// LabelFilter matches containers that have label set to value,
+// any value of label matches if value is empty
+func LabelFilter(label, value string) Filter {
+	return labelFilter{label: label, value: value}
+}
+
+func (f labelFilter) Match(c *docker.Container) bool {
+	v, ok := c.Config.Labels[f.label]
+	return ok && (f.value == "" || v == f.value)
+}
+
+func (f labelFilter) String() string {
+	if f.value == "" {
+		return "label " + f.label
+	}
+
+	return "label " + f.label + "=" + f.value
+}
+
+type imageFilter struct {
+	regexp *regexp.Regexp
+}
+
+// ImageFilter matches containers with image name matching regexp
+func ImageFilter(r *regexp.Regexp) Filter {
+	return imageFilter{regexp: r}
+}
+
+func (f imageFilter) Match(c *docker.Container) bool {
+	return f.regexp.MatchString(c.Config.Image)
+}
+
+func (f imageFilter) String() string {
+	return "image matching " + f.regexp.String()
+}
+
+type stateFilter []string
+
+// StateFilter matches containers in one of states as reported
+// by docker: created, running, paused, restarting, exited or dead
+func StateFilter(states ...string) Filter {
+	return stateFilter(states)
+}
+
+func (f stateFilter) Match(c *docker.Container) bool {
+	state := c.State.StateString()
+	for _, s := range f {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f stateFilter) String() string {
+	return "state " + strings.Join(f, " or ")
+}
+
+type allFilter []Filter
+
+// AllFilters matches containers that match every filter
+func AllFilters(filters ...Filter) Filter {
+	return allFilter(filters)
+}
+
+func (f allFilter) Match(c *docker.Container) bool {
+	for _, filter := range f {
+		if !filter.Match(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f allFilter) String() string {
+	return joinFilters(f, " and ")
+}
+
+type anyFilter []Filter
+
+// AnyFilter matches containers that match at least one filter
+func AnyFilter(filters ...Filter) Filter {
+	return anyFilter(filters)
+}
+
+func (f anyFilter) Match(c *docker.Container) bool {
+	for _, filter := range f {
+		if filter.Match(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f anyFilter) String() string {
+	return joinFilters(f, " or ")
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+// NotFilter matches containers that don't match filter
+func NotFilter(filter Filter) Filter {
+	return notFilter{filter: filter}
+}
+
+func (f notFilter) Match(c *docker.Container) bool {
+	return !f.filter.Match(c)
+}
+
+func (f notFilter) String() string {
+	return "not " + describeFilter(f.filter)
+}
+
+// joinFilters describes filters joined with separator
+func joinFilters(filters []Filter, sep string) string {
+	names := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		names = append(names, "("+describeFilter(filter)+")")
+	}
+
+	return strings.Join(names, sep)
+}
+
+// describeFilter returns description of filter for explanations
+func describeFilter(filter Filter) string {
+	if s, ok := filter.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%T", filter)
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestFilters(t *testing.T) {
+	c := &docker.Container{
+		Config: &docker.Config{
+			Image:  "registry/web:1.0",
+			Labels: map[string]string{"team": "search"},
+		},
+		State: docker.State{Running: true},
+	}
+
+	tests := []struct {
+		filter Filter
+		match  bool
+	}{
+		{LabelFilter("team", ""), true},
+		{LabelFilter("team", "search"), true},
+		{LabelFilter("team", "ads"), false},
+		{LabelFilter("tier", ""), false},
+		{ImageFilter(regexp.MustCompile(`/web:`)), true},
+		{ImageFilter(regexp.MustCompile(`^nginx`)), false},
+		{StateFilter("running", "paused"), true},
+		{StateFilter("exited"), false},
+		{AllFilters(LabelFilter("team", ""), StateFilter("running")), true},
+		{AllFilters(LabelFilter("team", ""), StateFilter("exited")), false},
+		{AnyFilter(LabelFilter("tier", ""), StateFilter("running")), true},
+		{NotFilter(LabelFilter("team", "")), false},
+		{FilterFunc(func(c *docker.Container) bool { return true }), true},
+	}
+
+	for _, test := range tests {
+		if match := test.filter.Match(c); match != test.match {
+			t.Errorf("expected match %v of %s, got %v", test.match, describeFilter(test.filter), match)
+		}
+	}
+}
+
+func TestMonitorFilter(t *testing.T) {
+	client := fakeMonitorDockerClient{labels: map[string]string{appLabel: "myapp"}}
+
+	_, err := NewMonitor(context.Background(), client, "", WithFilter(LabelFilter("team", "")))
+
+	var skip *SkipError
+	if !errors.As(err, &skip) || skip.Reason != SkipFiltered {
+		t.Errorf("expected container without team label to be filtered, got error %v", err)
+	}
+
+	client.labels["team"] = "search"
+
+	m, err := NewMonitor(context.Background(), client, "", WithFilter(LabelFilter("team", "")))
+	if err != nil {
+		t.Fatalf("error creating monitor: %s", err)
+	}
+
+	if m.app != "myapp" {
+		t.Errorf("expected app myapp, got %s", m.app)
+	}
+}
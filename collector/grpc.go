@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const grpcStreamMethod = "/collectd_docker.StatsCollector/Stream"
+
+var grpcStreamDesc = &grpc.StreamDesc{
+	StreamName:    "Stream",
+	ClientStreams: true,
+}
+
+// grpcSample wraps json sample to be encoded
+// as collectd_docker.Sample protobuf message
+type grpcSample jsonSample
+
+func (s grpcSample) marshal() []byte {
+	b := appendProtoBytes(nil, 1, []byte(s.Host))
+	b = appendProtoBytes(b, 2, []byte(s.App))
+	b = appendProtoBytes(b, 3, []byte(s.Task))
+	b = appendProtoVarint(b, 4, uint64(s.Timestamp))
+
+	keys := make([]string, 0, len(s.Metrics))
+	for k := range s.Metrics {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		entry := appendProtoBytes(nil, 1, []byte(k))
+		entry = appendProtoVarint(entry, 2, s.Metrics[k])
+		b = appendProtoBytes(b, 5, entry)
+	}
+
+	return b
+}
+
+// grpcSummary is collectd_docker.StreamSummary protobuf message
+type grpcSummary struct {
+	Received uint64
+}
+
+func (s *grpcSummary) unmarshal(b []byte) error {
+	return walkProto(b, func(field uint64, v uint64, _ []byte) {
+		if field == 1 {
+			s.Received = v
+		}
+	})
+}
+
+// grpcCodec encodes hand written messages from stats.proto
+type grpcCodec struct{}
+
+func (grpcCodec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case grpcSample:
+		return m.marshal(), nil
+	default:
+		return nil, errMalformedProto
+	}
+}
+
+func (grpcCodec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case *grpcSummary:
+		return m.unmarshal(data)
+	default:
+		return errMalformedProto
+	}
+}
+
+func (grpcCodec) Name() string {
+	return "proto"
+}
+
+// GRPCWriter is responsible for streaming samples to remote
+// aggregator implementing StatsCollector service from stats.proto,
+// broken streams are reopened on the next write
+type GRPCWriter struct {
+	host string
+	conn *grpc.ClientConn
+
+	mutex  sync.Mutex
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// NewGRPCWriter creates new GRPCWriter with specified hostname,
+// aggregator address and optional tls config
+func NewGRPCWriter(host string, addr string, tlsConfig *tls.Config) (*GRPCWriter, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcCodec{})))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCWriter{
+		host: host,
+		conn: conn,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "grpc",
+		Usage: "samples streamed to StatsCollector grpc service",
+		Options: append([]WriterOption{
+			{Name: "addr", Usage: "grpc aggregator address"},
+		}, tlsOptions...),
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			tlsConfig := p.tlsConfig()
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewGRPCWriter(host, p.string("addr"), tlsConfig)
+		},
+	})
+}
+
+func (w *GRPCWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream, err := w.conn.NewStream(ctx, grpcStreamDesc, grpcStreamMethod)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		w.stream = stream
+		w.cancel = cancel
+	}
+
+	err := w.stream.SendMsg(grpcSample(newJSONSample(w.host, s)))
+	if err == io.EOF {
+		// stream was aborted by aggregator, its status is the real error
+		if rerr := w.stream.RecvMsg(&grpcSummary{}); rerr != nil {
+			err = rerr
+		}
+	}
+
+	if err != nil {
+		w.closeStream()
+	}
+
+	return err
+}
+
+// closeStream cancels context of current stream, so grpc
+// releases its resources, next write opens a new stream
+func (w *GRPCWriter) closeStream() {
+	w.cancel()
+	w.stream = nil
+	w.cancel = nil
+}
+
+// Flush is no-op, samples are sent on every write
+func (w *GRPCWriter) Flush() error {
+	return nil
+}
+
+// Close finishes current stream and closes connection to aggregator
+func (w *GRPCWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stream != nil {
+		err := w.stream.CloseSend()
+		if err == nil {
+			err = w.stream.RecvMsg(&grpcSummary{})
+		}
+
+		w.closeStream()
+
+		if err != nil {
+			w.conn.Close()
+			return err
+		}
+	}
+
+	return w.conn.Close()
+}
@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawGRPCCodec passes messages as bytes for fake aggregator
+type rawGRPCCodec struct{}
+
+func (rawGRPCCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (rawGRPCCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawGRPCCodec) Name() string {
+	return "proto"
+}
+
+func TestGRPCSampleMarshal(t *testing.T) {
+	s := grpcSample{Host: "myhost", App: "myapp", Task: "mytask", Timestamp: 1431000000, Metrics: map[string]uint64{"cpu.total": 42, "memory.usage": 7}}
+
+	fields := map[protowire.Number]interface{}{}
+	metrics := map[string]uint64{}
+
+	b := s.marshal()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("error parsing tag: %s", protowire.ParseError(n))
+		}
+
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				t.Fatalf("error parsing varint of field %d", num)
+			}
+
+			fields[num] = v
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatalf("error parsing bytes of field %d", num)
+			}
+
+			b = b[n:]
+
+			if num != 5 {
+				fields[num] = string(v)
+				continue
+			}
+
+			// map entries have key as field 1 and value as field 2
+			key, value := "", uint64(0)
+			for len(v) > 0 {
+				num, typ, n := protowire.ConsumeTag(v)
+				v = v[n:]
+
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					k, n := protowire.ConsumeBytes(v)
+					key = string(k)
+					v = v[n:]
+				case num == 2 && typ == protowire.VarintType:
+					value, n = protowire.ConsumeVarint(v)
+					v = v[n:]
+				default:
+					t.Fatalf("unexpected field %d of metrics entry", num)
+				}
+			}
+
+			metrics[key] = value
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", typ, num)
+		}
+	}
+
+	// field numbers of Sample in stats.proto
+	expected := map[protowire.Number]interface{}{1: "myhost", 2: "myapp", 3: "mytask", 4: uint64(1431000000)}
+	for num, v := range expected {
+		if fields[num] != v {
+			t.Errorf("expected field %d to be %v, got %v", num, v, fields[num])
+		}
+	}
+
+	if len(metrics) != 2 || metrics["cpu.total"] != 42 || metrics["memory.usage"] != 7 {
+		t.Errorf("unexpected metrics: %v", metrics)
+	}
+
+	summary := grpcSummary{}
+	if err := summary.unmarshal(protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 3)); err != nil || summary.Received != 3 {
+		t.Errorf("expected summary with 3 received samples, got %v and error %v", summary, err)
+	}
+}
+
+func TestGRPCWriterReopensAbortedStream(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := int64(0)
+
+	server := grpc.NewServer(grpc.ForceServerCodec(rawGRPCCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		b := []byte{}
+		if err := stream.RecvMsg(&b); err != nil {
+			return err
+		}
+
+		// the first stream is aborted after the first sample
+		if atomic.AddInt64(&streams, 1) == 1 {
+			return status.Error(codes.Unavailable, "aggregator is going away")
+		}
+
+		for stream.RecvMsg(&b) == nil {
+		}
+
+		return stream.SendMsg(protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1))
+	}))
+
+	go server.Serve(l)
+	defer server.Stop()
+
+	w, err := NewGRPCWriter("myhost", l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("error creating grpc writer: %s", err)
+	}
+
+	s := Stats{App: "myapp", Task: "mytask"}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err = w.Write(s)
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected status of aborted stream, got %v", err)
+	}
+
+	if err := w.Write(s); err != nil {
+		t.Fatalf("error writing to reopened stream: %s", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("error closing writer: %s", err)
+	}
+
+	if n := atomic.LoadInt64(&streams); n != 2 {
+		t.Errorf("expected 2 streams, got %d", n)
+	}
+}
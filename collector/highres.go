@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// highResolutionLabel is the label with sub-second interval, like 100ms,
+// to sample cpu and memory usage of container with for debugging
+const highResolutionLabel = "collectd_docker_high_resolution"
+
+// highResolutionFamily is the family of metrics of high resolution samples
+const highResolutionFamily = "hires"
+
+// minHighResolution is the shortest interval of high resolution samples
+const minHighResolution = 10 * time.Millisecond
+
+// SetHighResolution enables sampling of cpu and memory usage of
+// containers with collectd_docker_high_resolution label at interval
+// from the label, usage is read from cgroups of containers under proc
+// and cgroup mounts, like /proc and /sys/fs/cgroup, bypassing docker
+// stats, which doesn't go below a second, empty proc disables it,
+// it should be called before Run
+func (c *Collector) SetHighResolution(proc string, cgroup string) {
+	c.hiresProc = proc
+	c.hiresCgroup = cgroup
+}
+
+// startHighResolution starts high resolution sampling of container
+// in background until ctx is done, invalid labels are logged and ignored
+func (c *Collector) startHighResolution(ctx context.Context, m *Monitor) {
+	fields := containerFields(m.id, m.app, m.task)
+
+	interval, err := time.ParseDuration(m.highResolution)
+	if err != nil || interval < minHighResolution {
+		fields.Logf(LogWarn, "not sampling at high resolution, %s label should be an interval of at least %s, got %q", highResolutionLabel, minHighResolution, m.highResolution)
+		return
+	}
+
+	if m.pid == 0 {
+		return
+	}
+
+	pid := strconv.Itoa(m.pid)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		warned := false
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				usage, err := processUsage(c.hiresProc, c.hiresCgroup, pid)
+				if err != nil {
+					if !warned && ctx.Err() == nil {
+						warned = true
+						fields.Logf(LogWarn, "error sampling at high resolution: %s", err)
+					}
+
+					continue
+				}
+
+				if c.filtered(m.app) && !c.paused(m.id, m.app) {
+					c.send(Stats{App: m.app, Task: m.task, Meta: m.meta, Time: t, Metrics: highResolutionMetrics(usage), MetricsOnly: true})
+				}
+			}
+		}
+	}()
+}
+
+// highResolutionMetrics names usage of cgroup as metrics of hires
+// family, so they don't mix with samples of the regular interval
+func highResolutionMetrics(usage map[string]uint64) map[string]uint64 {
+	metrics := make(map[string]uint64, len(usage))
+	for k, v := range usage {
+		metrics[highResolutionFamily+"."+k] = v
+	}
+
+	return metrics
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHighResolution(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hires")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"proc/4242/cgroup": "0::/system.slice/docker-0123abcd.scope\n",
+
+		"cgroup/system.slice/docker-0123abcd.scope/cpu.stat":       "usage_usec 3000\nuser_usec 2000\nsystem_usec 1000\n",
+		"cgroup/system.slice/docker-0123abcd.scope/memory.current": "1048576\n",
+	})
+
+	c := &Collector{}
+	c.SetQueue(10, DropPolicyDropNewest)
+	c.SetHighResolution(filepath.Join(dir, "proc"), filepath.Join(dir, "cgroup"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// invalid intervals are ignored
+	for _, interval := range []string{"fast", "1ms"} {
+		c.startHighResolution(ctx, &Monitor{app: "myapp", task: "mytask", pid: 4242, highResolution: interval})
+	}
+
+	c.startHighResolution(ctx, &Monitor{app: "myapp", task: "mytask", pid: 4242, highResolution: "20ms"})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case s := <-c.ch:
+			if s.App != "myapp" || s.Task != "mytask" {
+				t.Errorf("expected sample of myapp/mytask, got %s/%s", s.App, s.Task)
+			}
+
+			if s.Metrics["hires.cpu.total"] != 3000000 || s.Metrics["hires.memory.usage"] != 1048576 {
+				t.Errorf("expected usage of cgroup as hires metrics, got %v", s.Metrics)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected high resolution samples")
+		}
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	for len(c.ch) > 0 {
+		<-c.ch
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if len(c.ch) != 0 {
+		t.Errorf("expected no samples after ctx is done, got %d", len(c.ch))
+	}
+}
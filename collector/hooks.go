@@ -0,0 +1,42 @@
+package collector
+
+// Hooks are optional callbacks of collector for embedding applications,
+// they are called synchronously from goroutines of collector, so they
+// should be quick and must not block, nil callbacks are not called
+type Hooks struct {
+	// OnMonitorStart is called when container starts being monitored
+	OnMonitorStart func(id, app, task string)
+	// OnMonitorStop is called when monitoring of container stops,
+	// err tells why, see Monitor.Run for possible errors
+	OnMonitorStop func(id, app, task string, err error)
+	// OnError is called on errors of discovery, stats streams and
+	// writes, id is empty for errors not tied to a container
+	OnError func(id string, err error)
+	// OnSample is called on every sample of monitored
+	// containers before it is queued for writing
+	OnSample func(s Stats)
+}
+
+func (h Hooks) monitorStart(id, app, task string) {
+	if h.OnMonitorStart != nil {
+		h.OnMonitorStart(id, app, task)
+	}
+}
+
+func (h Hooks) monitorStop(id, app, task string, err error) {
+	if h.OnMonitorStop != nil {
+		h.OnMonitorStop(id, app, task, err)
+	}
+}
+
+func (h Hooks) error(id string, err error) {
+	if h.OnError != nil {
+		h.OnError(id, err)
+	}
+}
+
+func (h Hooks) sample(s Stats) {
+	if h.OnSample != nil {
+		h.OnSample(s)
+	}
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	events := []string{}
+	samples := 0
+
+	c := &Collector{registered: map[string]*Monitor{}}
+	c.SetQueue(10, DropPolicyBlock)
+	c.SetHooks(Hooks{
+		OnMonitorStart: func(id, app, task string) {
+			events = append(events, "start "+id+" "+app+"."+task)
+		},
+		OnMonitorStop: func(id, app, task string, err error) {
+			events = append(events, "stop "+id+" "+app+"."+task)
+		},
+		OnError: func(id string, err error) {
+			events = append(events, "error "+id)
+		},
+		OnSample: func(s Stats) {
+			samples++
+		},
+	})
+
+	failed := errors.New("connection refused")
+
+	m := &Monitor{app: "myapp", task: "mytask", client: streamingDockerClient{err: failed}}
+	c.monitor(context.Background(), "abcdef", m)
+
+	expected := []string{"start abcdef myapp.mytask", "error abcdef", "stop abcdef myapp.mytask"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected hook calls %v, got %v", expected, events)
+	}
+
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected hook call %q, got %q", expected[i], events[i])
+		}
+	}
+
+	if samples != 1 || len(c.ch) != 1 {
+		t.Errorf("expected single sample to be seen by hook and queued, got %d and %d", samples, len(c.ch))
+	}
+
+	if c.monitored("abcdef") {
+		t.Errorf("expected container to be unregistered when monitor stops")
+	}
+}
@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hostApp and hostTask are app and task of baseline metrics of the host
+const (
+	hostApp  = "_host"
+	hostTask = "system"
+)
+
+// SetHostMetrics enables reporting of baseline metrics of the host every
+// interval: cpu and memory usage from proc mount, like /proc, and disk
+// usage of docker data root, which is asked from docker if it's empty,
+// empty proc disables it, it should be called before Run
+func (c *Collector) SetHostMetrics(proc string, dataRoot string) {
+	c.hostProc = proc
+	c.hostDataRoot = dataRoot
+}
+
+// reportHost periodically sends baseline metrics of the host, disk
+// usage is skipped with a warning if data root can't be read
+func (c *Collector) reportHost(ctx context.Context) {
+	c.mutex.Lock()
+	interval := c.interval
+	c.mutex.Unlock()
+
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	dataRoot := c.hostDataRoot
+	warned := false
+
+	every(ctx, interval, func(t time.Time) {
+		metrics, err := hostMetrics(c.hostProc)
+		if err != nil {
+			if !warned {
+				warned = true
+				Logf(LogWarn, "error reading metrics of the host: %s", err)
+			}
+
+			return
+		}
+
+		if dataRoot == "" {
+			info, err := c.client.Info()
+			if err == nil {
+				dataRoot = info.DockerRootDir
+			}
+		}
+
+		if dataRoot != "" {
+			total, free, err := diskUsage(dataRoot)
+			if err == nil {
+				metrics["disk.total"] = total
+				metrics["disk.free"] = free
+				metrics["disk.used"] = total - free
+			} else if !warned {
+				warned = true
+				Logf(LogWarn, "error reading disk usage of %s: %s", dataRoot, err)
+			}
+		}
+
+		c.send(Stats{App: hostApp, Task: hostTask, Time: t, Metrics: metrics, MetricsOnly: true})
+	})
+}
+
+// hostMetrics returns cpu and memory metrics of the host, they
+// are named like metrics of containers where it makes sense
+func hostMetrics(proc string) (map[string]uint64, error) {
+	metrics, err := readProcStat(filepath.Join(proc, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := readMeminfo(filepath.Join(proc, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range memory {
+		metrics[k] = v
+	}
+
+	return metrics, nil
+}
+
+// readProcStat reads cpu time of the host in nanoseconds and number of cpus
+func readProcStat(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	metrics := map[string]uint64{}
+	cpus := uint64(0)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		if fields[0] != "cpu" {
+			cpus++
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal
+		ticks := make([]uint64, 8)
+		for i := range ticks {
+			if i+1 >= len(fields) {
+				break
+			}
+
+			ticks[i], err = strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu line in %s: %q", file, scanner.Text())
+			}
+
+			ticks[i] *= uint64(time.Second / userHZ)
+		}
+
+		metrics["cpu.user"] = ticks[0] + ticks[1]
+		metrics["cpu.system"] = ticks[2] + ticks[5] + ticks[6]
+		metrics["cpu.idle"] = ticks[3] + ticks[4]
+		metrics["cpu.total"] = metrics["cpu.user"] + metrics["cpu.system"] + ticks[7]
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	if _, ok := metrics["cpu.total"]; !ok {
+		return nil, fmt.Errorf("no cpu line in %s", file)
+	}
+
+	metrics["cpu.cores"] = cpus
+
+	return metrics, nil
+}
+
+// readMeminfo reads memory of the host in bytes, available memory
+// is estimated from free memory and caches on old kernels
+func readMeminfo(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	values := map[string]uint64{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		values[strings.TrimSuffix(fields[0], ":")] = value << 10
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return nil, fmt.Errorf("no MemTotal in %s", file)
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+
+	if available > total {
+		available = total
+	}
+
+	return map[string]uint64{
+		"memory.total":     total,
+		"memory.available": available,
+		"memory.usage":     total - available,
+	}, nil
+}
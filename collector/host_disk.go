@@ -0,0 +1,19 @@
+//go:build linux
+// +build linux
+
+package collector
+
+import "syscall"
+
+// diskUsage returns size and space available to unprivileged
+// users of filesystem at path in bytes
+func diskUsage(path string) (uint64, uint64, error) {
+	stat := syscall.Statfs_t{}
+
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
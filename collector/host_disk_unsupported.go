@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package collector
+
+import "errors"
+
+func diskUsage(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform")
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "host")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"stat":    "cpu  100 20 30 1000 50 5 5 10 0 0\ncpu0 50 10 15 500 25 2 3 5 0 0\ncpu1 50 10 15 500 25 3 2 5 0 0\nintr 12345\n",
+		"meminfo": "MemTotal:        8000000 kB\nMemFree:         1000000 kB\nMemAvailable:    3000000 kB\nCached:          2500000 kB\n",
+	})
+
+	metrics, err := hostMetrics(dir)
+	if err != nil {
+		t.Fatalf("error reading metrics of the host: %s", err)
+	}
+
+	expected := map[string]uint64{
+		"cpu.user":         1200000000,
+		"cpu.system":       400000000,
+		"cpu.idle":         10500000000,
+		"cpu.total":        1700000000,
+		"cpu.cores":        2,
+		"memory.total":     8000000 << 10,
+		"memory.available": 3000000 << 10,
+		"memory.usage":     5000000 << 10,
+	}
+
+	for k, v := range expected {
+		if value, ok := metrics[k]; !ok || value != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"meminfo": "MemTotal:        8000000 kB\nMemFree:         1000000 kB\nBuffers:          500000 kB\nCached:          2500000 kB\n",
+	})
+
+	memory, err := readMeminfo(filepath.Join(dir, "meminfo"))
+	if err != nil {
+		t.Fatalf("error reading meminfo: %s", err)
+	}
+
+	if memory["memory.available"] != 4000000<<10 {
+		t.Errorf("expected available memory to be estimated from caches, got %v", memory)
+	}
+
+	if _, err := hostMetrics(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected error reading missing proc")
+	}
+}
@@ -0,0 +1,453 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	skipLabel         = "collectd_docker_skip"
+	appLabel          = "collectd_docker_app"
+	taskLabel         = "collectd_docker_task"
+	taskLocationLabel = "collectd_docker_task_label"
+
+	appEnvPrefix              = "COLLECTD_DOCKER_APP="
+	taskEnvPrefix             = "COLLECTD_DOCKER_TASK="
+	taskEnvLocationPrefix     = "COLLECTD_DOCKER_TASK_ENV="
+	taskEnvLocationTrimPrefix = "COLLECTD_DOCKER_TASK_ENV_TRIM_PREFIX="
+
+	// defaultTask is the task of containers that set app, but not task
+	defaultTask = "default"
+)
+
+var imageNameRegex = regexp.MustCompile(`.*\/([^\/]*):.*`)
+
+// IdentityExtractor finds app and task of container, empty app means
+// that extractor doesn't know the container, returning error stops
+// extraction, ErrNoNeedToMonitor or *SkipError skips container
+// without complaints
+type IdentityExtractor interface {
+	Extract(c *docker.Container) (app, task string, err error)
+}
+
+// MetadataExtractor finds metadata of container that is attached
+// to its samples as Stats.Meta, nil means no metadata
+type MetadataExtractor interface {
+	Metadata(c *docker.Container) map[string]string
+}
+
+// IdentityExtractorFunc adapts func to IdentityExtractor
+type IdentityExtractorFunc func(c *docker.Container) (app, task string, err error)
+
+// Extract calls f(c)
+func (f IdentityExtractorFunc) Extract(c *docker.Container) (app, task string, err error) {
+	return f(c)
+}
+
+// ChainExtractor asks extractors in order, the first app found wins
+type ChainExtractor []IdentityExtractor
+
+// Extract returns identity from the first extractor that knows container
+func (e ChainExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	app, task, _, err = e.extract(c)
+	return app, task, err
+}
+
+// extract is Extract that also returns extractor that found app
+func (e ChainExtractor) extract(c *docker.Container) (app, task string, found IdentityExtractor, err error) {
+	for _, extractor := range e {
+		app, task, err = extractor.Extract(c)
+		if err != nil || app != "" {
+			return app, task, extractor, err
+		}
+	}
+
+	return "", "", nil, nil
+}
+
+func (e ChainExtractor) String() string {
+	names := make([]string, 0, len(e))
+	for _, extractor := range e {
+		names = append(names, describeExtractor(extractor))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// ChainMetadataExtractor merges metadata of extractors,
+// earlier extractors win when keys are the same
+type ChainMetadataExtractor []MetadataExtractor
+
+// Metadata returns merged metadata of container
+func (e ChainMetadataExtractor) Metadata(c *docker.Container) map[string]string {
+	var merged map[string]string
+	for i := len(e) - 1; i >= 0; i-- {
+		meta := e[i].Metadata(c)
+		if len(meta) == 0 {
+			continue
+		}
+
+		if merged == nil {
+			merged = map[string]string{}
+		}
+
+		for k, v := range meta {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// LabelExtractor takes app from collectd_docker_app label and task from
+// collectd_docker_task label or from label that collectd_docker_task_label
+// label points to
+type LabelExtractor struct{}
+
+// Extract returns identity from labels
+func (LabelExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	labels := c.Config.Labels
+
+	app = labels[appLabel]
+	if app == "" {
+		return "", "", nil
+	}
+
+	task = labels[taskLabel]
+	if task == "" && labels[taskLocationLabel] != "" {
+		task = labels[labels[taskLocationLabel]]
+	}
+
+	if task == "" {
+		task = defaultTask
+	}
+
+	return app, task, nil
+}
+
+func (LabelExtractor) String() string {
+	return "label " + appLabel
+}
+
+// EnvExtractor takes app from COLLECTD_DOCKER_APP env variable and task
+// from COLLECTD_DOCKER_TASK or from variable that COLLECTD_DOCKER_TASK_ENV
+// points to with COLLECTD_DOCKER_TASK_ENV_TRIM_PREFIX trimmed
+type EnvExtractor struct{}
+
+// Extract returns identity from env variables
+func (EnvExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	app = extractEnvPrefix(c, appEnvPrefix)
+	if app == "" {
+		return "", "", nil
+	}
+
+	task = extractEnvPrefix(c, taskEnvPrefix)
+	if location := extractEnvPrefix(c, taskEnvLocationPrefix); task == "" && location != "" {
+		task = strings.TrimPrefix(extractEnv(c, location), extractEnvPrefix(c, taskEnvLocationTrimPrefix))
+	}
+
+	if task == "" {
+		task = defaultTask
+	}
+
+	return app, task, nil
+}
+
+func (EnvExtractor) String() string {
+	return "env " + strings.TrimSuffix(appEnvPrefix, "=")
+}
+
+// ChronosExtractor takes app from CHRONOS_JOB_NAME env variable
+// and task from short container id
+type ChronosExtractor struct{}
+
+// Extract returns identity of chronos job
+func (ChronosExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	app = extractEnv(c, "CHRONOS_JOB_NAME")
+	if app == "" {
+		return "", "", nil
+	}
+
+	return app, shortID(c.ID), nil
+}
+
+func (ChronosExtractor) String() string {
+	return "env CHRONOS_JOB_NAME"
+}
+
+// MarathonExtractor takes app from MARATHON_APP_ID env variable
+// without leading slash and task from short container id
+type MarathonExtractor struct{}
+
+// Extract returns identity of marathon app
+func (MarathonExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	app = strings.TrimPrefix(extractEnv(c, "MARATHON_APP_ID"), "/")
+	if app == "" {
+		return "", "", nil
+	}
+
+	return app, shortID(c.ID), nil
+}
+
+func (MarathonExtractor) String() string {
+	return "env MARATHON_APP_ID"
+}
+
+// ImageMapExtractor takes app from configured table of image ids,
+// references and repositories, like sha256:4f53..., registry/web@sha256:4f53...
+// or registry/web, for containers of digest pinned images that carry no
+// labels or env variables, task is short container id
+type ImageMapExtractor map[string]string
+
+// ParseImageMap parses comma separated image=app pairs, images
+// are matched by image id, full reference or repository
+func ParseImageMap(s string) (ImageMapExtractor, error) {
+	m := ImageMapExtractor{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// app names don't have =, image references don't either
+		i := strings.LastIndex(item, "=")
+		if i < 1 || i == len(item)-1 {
+			return nil, fmt.Errorf("image app should be like image=app, got %q", item)
+		}
+
+		m[item[:i]] = item[i+1:]
+	}
+
+	return m, nil
+}
+
+// Extract returns app of image id, reference or repository of container
+func (m ImageMapExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	for _, image := range []string{c.Image, c.Config.Image, imageRepository(c.Config.Image)} {
+		if app, ok := m[image]; ok && image != "" {
+			return app, shortID(c.ID), nil
+		}
+	}
+
+	return "", "", nil
+}
+
+func (ImageMapExtractor) String() string {
+	return "image apps table"
+}
+
+// imageRepository returns image reference without digest and tag
+func imageRepository(reference string) string {
+	if i := strings.Index(reference, "@"); i != -1 {
+		reference = reference[:i]
+	}
+
+	// colons before the last slash are ports of registries
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		reference = reference[:i]
+	}
+
+	return reference
+}
+
+// ImageExtractor takes app from image name of container and task
+// from short container id, Regexp replaces the default regexp of image
+// names, its app group becomes app and its task group, if it matches,
+// becomes task, Regexp is set by NewImageExtractor
+type ImageExtractor struct {
+	Regexp *regexp.Regexp
+}
+
+// NewImageExtractor creates new ImageExtractor with regexp of image
+// names that has app group with name of app, like (?P<app>[^/:]+)
+func NewImageExtractor(expr string) (ImageExtractor, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ImageExtractor{}, err
+	}
+
+	if re.SubexpIndex("app") == -1 {
+		return ImageExtractor{}, fmt.Errorf("image regexp %s has no app group, name it like (?P<app>...)", expr)
+	}
+
+	return ImageExtractor{Regexp: re}, nil
+}
+
+// Extract returns identity from image name
+func (e ImageExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	if e.Regexp == nil {
+		matches := imageNameRegex.FindStringSubmatch(c.Config.Image)
+		if matches == nil || len(matches) < 1 {
+			return "", "", nil
+		}
+
+		return matches[0], shortID(c.ID), nil
+	}
+
+	matches := e.Regexp.FindStringSubmatch(c.Config.Image)
+	if matches == nil || matches[e.Regexp.SubexpIndex("app")] == "" {
+		return "", "", nil
+	}
+
+	task = shortID(c.ID)
+	if i := e.Regexp.SubexpIndex("task"); i != -1 && matches[i] != "" {
+		task = matches[i]
+	}
+
+	return matches[e.Regexp.SubexpIndex("app")], task, nil
+}
+
+func (e ImageExtractor) String() string {
+	if e.Regexp != nil {
+		return "image matching " + e.Regexp.String()
+	}
+
+	return "image matching " + imageNameRegex.String()
+}
+
+// DefaultIdentityExtractor finds identity of containers in labels,
+// then env variables, then chronos and marathon env variables,
+// then image name
+var DefaultIdentityExtractor IdentityExtractor = ChainExtractor{
+	LabelExtractor{},
+	EnvExtractor{},
+	ChronosExtractor{},
+	MarathonExtractor{},
+	ImageExtractor{},
+}
+
+// identify returns sanitized app and task of container
+// found by extractor, app is empty if container should not
+// be monitored, source describes where app was found
+func identify(extractor IdentityExtractor, c *docker.Container) (app, task, source string, err error) {
+	found := extractor
+	if chain, ok := extractor.(ChainExtractor); ok {
+		app, task, found, err = chain.extract(c)
+	} else {
+		app, task, err = extractor.Extract(c)
+	}
+
+	if err != nil || app == "" {
+		return "", "", "", err
+	}
+
+	if h, ok := found.(hierarchicalExtractor); ok && h.hierarchical() {
+		app = sanitizeHierarchy(app)
+	} else {
+		app = sanitizeForGraphite(app)
+	}
+
+	return app, sanitizeForGraphite(task), describeExtractor(found), nil
+}
+
+// hierarchicalExtractor is implemented by extractors that return app
+// names with slashes separating levels of hierarchy, like groups of apps
+type hierarchicalExtractor interface {
+	hierarchical() bool
+}
+
+// sanitizeHierarchy sanitizes levels of hierarchy of app
+// name separated by slashes and joins them with dots
+func sanitizeHierarchy(app string) string {
+	levels := strings.Split(app, "/")
+	for i, level := range levels {
+		levels[i] = sanitizeForGraphite(level)
+	}
+
+	return strings.Join(levels, ".")
+}
+
+// admit checks that container is not opted out and matches filter and
+// returns its identity found by extractor, *SkipError is returned for
+// containers that should not be monitored
+func admit(filter Filter, extractor IdentityExtractor, c *docker.Container) (app, task, source string, err error) {
+	label, labeled := c.Config.Labels[skipLabel]
+	if skip, _ := strconv.ParseBool(label); skip {
+		return "", "", "", &SkipError{Reason: SkipOptOut, Detail: "label " + skipLabel + " is set"}
+	}
+
+	// containers with skip label set to false are monitored anyway
+	if !labeled {
+		if reason := infrastructure(c); reason != "" {
+			return "", "", "", &SkipError{Reason: SkipInfrastructure, Detail: reason + ", set label " + skipLabel + "=false to monitor it"}
+		}
+	}
+
+	if filter != nil && !filter.Match(c) {
+		return "", "", "", &SkipError{Reason: SkipFiltered, Detail: "container doesn't match filter " + describeFilter(filter)}
+	}
+
+	app, task, source, err = identify(extractor, c)
+	if err != nil {
+		var skip *SkipError
+		if !errors.As(err, &skip) && errors.Is(err, ErrNoNeedToMonitor) {
+			err = &SkipError{Reason: SkipOptOut, Detail: "skipped by identity extractor: " + err.Error()}
+		}
+
+		return "", "", "", err
+	}
+
+	if app == "" {
+		return "", "", "", &SkipError{Reason: SkipNoIdentity, Detail: "no app name found by " + describeExtractor(extractor)}
+	}
+
+	return app, task, source, nil
+}
+
+// infrastructureImageRegex matches last part of repositories of
+// pause images of kubernetes, rancher, openshift and ecs
+var infrastructureImageRegex = regexp.MustCompile(`^(pause(-[a-z0-9]+)?|mirrored-pause|origin-pod|amazon-ecs-pause)$`)
+
+// infrastructure tells why container is pause or sandbox container
+// of container runtime, they only hold namespaces of pods and their
+// series are meaningless, empty string is returned for the rest
+func infrastructure(c *docker.Container) string {
+	if c.Config.Labels["io.kubernetes.docker.type"] == "podsandbox" || c.Config.Labels[kubeletContainerLabel] == "POD" {
+		return "kubernetes pod sandbox"
+	}
+
+	repository := imageRepository(c.Config.Image)
+	if infrastructureImageRegex.MatchString(repository[strings.LastIndex(repository, "/")+1:]) {
+		return "pause image " + c.Config.Image
+	}
+
+	return ""
+}
+
+// describeExtractor returns description of extractor for explanations
+func describeExtractor(extractor IdentityExtractor) string {
+	if s, ok := extractor.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%T", extractor)
+}
+
+// shortID returns short form of container id
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+
+	return id
+}
+
+func extractEnv(c *docker.Container, envVar string) string {
+	return extractEnvPrefix(c, envVar+"=")
+}
+
+func extractEnvPrefix(c *docker.Container, envPrefix string) string {
+	for _, e := range c.Config.Env {
+		if strings.HasPrefix(e, envPrefix) {
+			return strings.TrimPrefix(e, envPrefix)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestDefaultIdentityExtractor(t *testing.T) {
+	tests := []struct {
+		env    []string
+		image  string
+		app    string
+		task   string
+		source string
+	}{
+		{
+			env:    []string{"CHRONOS_JOB_NAME=my.job"},
+			app:    "my_job",
+			task:   "0123abcd",
+			source: "env CHRONOS_JOB_NAME",
+		},
+		{
+			env:    []string{"MARATHON_APP_ID=/web"},
+			app:    "web",
+			task:   "0123abcd",
+			source: "env MARATHON_APP_ID",
+		},
+		{
+			env:    []string{"MARATHON_APP_ID=/web", appEnvPrefix + "explicit"},
+			app:    "explicit",
+			task:   defaultTask,
+			source: "env COLLECTD_DOCKER_APP",
+		},
+		{
+			image: "nginx",
+		},
+	}
+
+	for _, test := range tests {
+		c := &docker.Container{
+			ID:     "0123abcdef",
+			Config: &docker.Config{Env: test.env, Image: test.image},
+		}
+
+		app, task, source, err := identify(DefaultIdentityExtractor, c)
+		if err != nil {
+			t.Errorf("unexpected error for %v: %s", test.env, err)
+			continue
+		}
+
+		if app != test.app || task != test.task || source != test.source {
+			t.Errorf("expected %s.%s from %q for %v, got %s.%s from %q", test.app, test.task, test.source, test.env, app, task, source)
+		}
+	}
+}
+
+func TestChainExtractor(t *testing.T) {
+	failed := errors.New("no access to inventory")
+
+	custom := IdentityExtractorFunc(func(c *docker.Container) (string, string, error) {
+		if c.Config.Image == "secret" {
+			return "", "", failed
+		}
+
+		return "", "", nil
+	})
+
+	chain := ChainExtractor{custom, MarathonExtractor{}}
+
+	c := &docker.Container{Config: &docker.Config{Env: []string{"MARATHON_APP_ID=/web"}}}
+
+	app, task, err := chain.Extract(c)
+	if err != nil || app != "web" || task != "" {
+		t.Errorf("expected app web from the next extractor, got app %q, task %q and error %v", app, task, err)
+	}
+
+	c.Config.Image = "secret"
+
+	if _, _, err = chain.Extract(c); err != failed {
+		t.Errorf("expected error of extractor to stop the chain, got %v", err)
+	}
+}
+
+func TestImageExtractor(t *testing.T) {
+	e, err := NewImageExtractor(`^registry:5000/(?P<app>[^:]+):(?P<task>canary)?`)
+	if err != nil {
+		t.Fatalf("error creating image extractor: %s", err)
+	}
+
+	tests := map[string][2]string{
+		"registry:5000/team/project/service:1.2":    {"team/project/service", "01234567"},
+		"registry:5000/team/project/service:canary": {"team/project/service", "canary"},
+		"docker.io/library/redis:6":                 {"", ""},
+	}
+
+	for image, expected := range tests {
+		c := &docker.Container{ID: "0123456789abcdef", Config: &docker.Config{Image: image}}
+
+		app, task, err := e.Extract(c)
+		if err != nil || app != expected[0] || task != expected[1] {
+			t.Errorf("expected app %q and task %q of %s, got %q, %q and error %v", expected[0], expected[1], image, app, task, err)
+		}
+	}
+
+	if _, err := NewImageExtractor(`registry:5000/([^:]+):`); err == nil {
+		t.Errorf("expected error creating image extractor without app group")
+	}
+}
+
+func TestImageMapExtractor(t *testing.T) {
+	m, err := ParseImageMap("sha256:4f53cda18c2b=web, registry:5000/team/api=api,registry:5000/team/worker@sha256:9c7a=worker")
+	if err != nil {
+		t.Fatalf("error parsing image apps: %s", err)
+	}
+
+	tests := map[[2]string]string{
+		{"sha256:4f53cda18c2b", "registry:5000/team/web@sha256:1111"}: "web",
+		{"sha256:0000", "registry:5000/team/api@sha256:2222"}:         "api",
+		{"sha256:0000", "registry:5000/team/api:1.2"}:                 "api",
+		{"sha256:0000", "registry:5000/team/worker@sha256:9c7a"}:      "worker",
+		{"sha256:0000", "registry:5000/team/worker@sha256:ffff"}:      "",
+		{"sha256:0000", "registry:5000/team/unknown@sha256:9c7a"}:     "",
+	}
+
+	for images, expected := range tests {
+		c := &docker.Container{ID: "0123456789abcdef", Image: images[0], Config: &docker.Config{Image: images[1]}}
+
+		app, _, err := m.Extract(c)
+		if err != nil || app != expected {
+			t.Errorf("expected app %q of %v, got %q and error %v", expected, images, app, err)
+		}
+	}
+
+	for _, invalid := range []string{"web", "=web", "sha256:4f53="} {
+		if _, err := ParseImageMap(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestInfrastructure(t *testing.T) {
+	tests := map[string]bool{
+		"k8s.gcr.io/pause:3.2":              true,
+		"registry.k8s.io/pause-amd64:3.1":   true,
+		"rancher/mirrored-pause:3.6":        true,
+		"amazon/amazon-ecs-pause:0.1.0":     true,
+		"registry:5000/pause@sha256:4f53cd": true,
+		"registry:5000/team/pauser:1.0":     false,
+		"nginx:1.21":                        false,
+	}
+
+	for image, expected := range tests {
+		c := &docker.Container{Config: &docker.Config{Image: image, Labels: map[string]string{appLabel: "myapp"}}}
+
+		if reason := infrastructure(c); (reason != "") != expected {
+			t.Errorf("expected infrastructure of %s to be %v, got %q", image, expected, reason)
+		}
+	}
+
+	c := &docker.Container{Config: &docker.Config{Image: "k8s.gcr.io/pause:3.2", Labels: map[string]string{appLabel: "myapp", skipLabel: "false"}}}
+
+	if app, _, _, err := admit(nil, DefaultIdentityExtractor, c); err != nil || app != "myapp" {
+		t.Errorf("expected pause container with %s=false to be monitored, got app %q and error %v", skipLabel, app, err)
+	}
+}
+
+func TestSkipErrors(t *testing.T) {
+	custom := IdentityExtractorFunc(func(c *docker.Container) (string, string, error) {
+		return "", "", ErrNoNeedToMonitor
+	})
+
+	tests := []struct {
+		labels    map[string]string
+		filter    Filter
+		extractor IdentityExtractor
+		reason    SkipReason
+	}{
+		{map[string]string{}, nil, DefaultIdentityExtractor, SkipNoIdentity},
+		{map[string]string{appLabel: "myapp"}, LabelFilter("team", ""), DefaultIdentityExtractor, SkipFiltered},
+		{map[string]string{appLabel: "myapp", skipLabel: "true"}, nil, DefaultIdentityExtractor, SkipOptOut},
+		{map[string]string{appLabel: "myapp"}, nil, custom, SkipOptOut},
+		{map[string]string{appLabel: "myapp", "io.kubernetes.docker.type": "podsandbox"}, nil, DefaultIdentityExtractor, SkipInfrastructure},
+	}
+
+	for _, test := range tests {
+		c := &docker.Container{Config: &docker.Config{Labels: test.labels}}
+
+		_, _, _, err := admit(test.filter, test.extractor, c)
+		if !errors.Is(err, ErrNoNeedToMonitor) {
+			t.Errorf("expected error to match ErrNoNeedToMonitor for %v, got %v", test.labels, err)
+		}
+
+		var skip *SkipError
+		if !errors.As(err, &skip) || skip.Reason != test.reason {
+			t.Errorf("expected skip reason %s for %v, got %v", test.reason, test.labels, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// jsonSample is a single sample in json lines format
+type jsonSample struct {
+	Host      string            `json:"host"`
+	App       string            `json:"app"`
+	Task      string            `json:"task"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+	Metrics   map[string]uint64 `json:"metrics"`
+}
+
+func newJSONSample(host string, s Stats) jsonSample {
+	return jsonSample{
+		Host:      host,
+		App:       s.App,
+		Task:      s.Task,
+		Meta:      s.Meta,
+		Timestamp: s.Time.Unix(),
+		Metrics:   intMetrics(s),
+	}
+}
+
+// jsonNotification is a single notification in json lines format,
+// it has notification field to tell it apart from samples
+type jsonNotification struct {
+	Host         string `json:"host"`
+	App          string `json:"app"`
+	Task         string `json:"task"`
+	Timestamp    int64  `json:"timestamp"`
+	Notification string `json:"notification"`
+	Severity     string `json:"severity"`
+	Message      string `json:"message"`
+}
+
+func newJSONNotification(host string, n Notification) jsonNotification {
+	return jsonNotification{
+		Host:         host,
+		App:          n.App,
+		Task:         n.Task,
+		Timestamp:    n.Time.Unix(),
+		Notification: n.Event,
+		Severity:     n.Severity.String(),
+		Message:      n.Message,
+	}
+}
+
+// JSONWriter is responsible for writing data to wrapped
+// writer as json lines, one json object per sample
+type JSONWriter struct {
+	host    string
+	encoder *json.Encoder
+}
+
+// NewJSONWriter creates new JSONWriter
+// with specified hostname and writer
+func NewJSONWriter(host string, writer io.Writer) JSONWriter {
+	return JSONWriter{
+		host:    host,
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "json",
+		Usage: "json object per sample on stdout",
+		New: func(host string, o WriterOptions) (Writer, error) {
+			return newStreamWriter(os.Stdout, nil, func(w io.Writer) Writer {
+				return NewJSONWriter(host, w)
+			}), nil
+		},
+	})
+}
+
+func (w JSONWriter) Write(s Stats) error {
+	return w.encoder.Encode(newJSONSample(w.host, s))
+}
+
+func (w JSONWriter) Notify(n Notification) error {
+	return w.encoder.Encode(newJSONNotification(w.host, n))
+}
+
+// Flush is no-op, JSONWriter doesn't buffer
+func (w JSONWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w JSONWriter) Close() error {
+	return nil
+}
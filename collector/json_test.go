@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewJSONWriter("myhost", b)
+
+	s := Stats{App: "myapp", Task: "mytask", Meta: map[string]string{"team": "infra"}}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 42
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Notify(Notification{App: "myapp", Task: "mytask", Time: time.Unix(1431000001, 0), Event: "oom", Severity: SeverityWarning, Message: "out of memory"})
+	if err != nil {
+		t.Fatalf("error writing notification: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected json object per line, got:\n%s", b.String())
+	}
+
+	sample := jsonSample{}
+	err = json.Unmarshal([]byte(lines[0]), &sample)
+	if err != nil {
+		t.Fatalf("error decoding sample: %s", err)
+	}
+
+	if sample.Host != "myhost" || sample.App != "myapp" || sample.Task != "mytask" || sample.Timestamp != 1431000000 {
+		t.Errorf("unexpected sample: %#v", sample)
+	}
+
+	if sample.Meta["team"] != "infra" || len(sample.Metrics) != len(intMetrics(s)) || sample.Metrics["cpu.total"] != 42 {
+		t.Errorf("unexpected sample metadata or metrics: %#v", sample)
+	}
+
+	notification := jsonNotification{}
+	err = json.Unmarshal([]byte(lines[1]), &notification)
+	if err != nil {
+		t.Fatalf("error decoding notification: %s", err)
+	}
+
+	expected := jsonNotification{Host: "myhost", App: "myapp", Task: "mytask", Timestamp: 1431000001, Notification: "oom", Severity: SeverityWarning.String(), Message: "out of memory"}
+	if notification != expected {
+		t.Errorf("expected notification %#v, got %#v", expected, notification)
+	}
+}
+
+func TestJSONWriterNoMeta(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewJSONWriter("myhost", b)
+
+	err := w.Write(Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if strings.Contains(b.String(), `"meta"`) {
+		t.Errorf("expected meta to be omitted when empty, got:\n%s", b.String())
+	}
+}
@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// KafkaAvroSchema is avro schema of messages written
+// by KafkaWriter when avro format is used
+const KafkaAvroSchema = `{
+  "type": "record",
+  "name": "DockerStats",
+  "fields": [
+    {"name": "host", "type": "string"},
+    {"name": "app", "type": "string"},
+    {"name": "task", "type": "string"},
+    {"name": "timestamp", "type": "long"},
+    {"name": "metrics", "type": {"type": "map", "values": "long"}}
+  ]
+}`
+
+// KafkaWriter is responsible for publishing samples
+// to kafka topic in json or avro format
+type KafkaWriter struct {
+	host           string
+	topic          string
+	avro           bool
+	partitionByApp bool
+	producer       sarama.SyncProducer
+}
+
+// NewKafkaWriter creates new KafkaWriter with specified hostname,
+// kafka brokers, topic and message format (json or avro), messages
+// are keyed by app if partitionByApp is set so that all samples
+// of the same app end up in the same partition
+func NewKafkaWriter(host string, brokers []string, topic string, format string, partitionByApp bool) (*KafkaWriter, error) {
+	avro := false
+
+	switch format {
+	case "json":
+	case "avro":
+		avro = true
+	default:
+		return nil, fmt.Errorf("unknown kafka message format: %s", format)
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	if partitionByApp {
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	} else {
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	}
+
+	producer, err := sarama.NewSyncProducer(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaWriter{
+		host:           host,
+		topic:          topic,
+		avro:           avro,
+		partitionByApp: partitionByApp,
+		producer:       producer,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "kafka",
+		Usage: "json or avro messages to kafka topic",
+		Options: []WriterOption{
+			{Name: "brokers", Usage: "comma separated list of kafka brokers"},
+			{Name: "topic", Default: "docker_stats", Usage: "kafka topic to publish stats to"},
+			{Name: "format", Default: "json", Usage: "kafka message format: json or avro"},
+			{Name: "partition-by-app", Default: "true", Usage: "partition kafka messages by app"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			partitionByApp := p.bool("partition-by-app")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewKafkaWriter(host, p.strings("brokers"), p.string("topic"), p.string("format"), partitionByApp)
+		},
+	})
+}
+
+func (w *KafkaWriter) Write(s Stats) error {
+	sample := newJSONSample(w.host, s)
+
+	var value []byte
+	if w.avro {
+		value = encodeAvroSample(sample)
+	} else {
+		b, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+
+		value = b
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: w.topic,
+		Value: sarama.ByteEncoder(value),
+	}
+
+	if w.partitionByApp {
+		msg.Key = sarama.StringEncoder(s.App)
+	}
+
+	_, _, err := w.producer.SendMessage(msg)
+	return err
+}
+
+// Flush is no-op, messages are sent synchronously
+func (w *KafkaWriter) Flush() error {
+	return nil
+}
+
+// Close closes underlying kafka producer
+func (w *KafkaWriter) Close() error {
+	return w.producer.Close()
+}
+
+// encodeAvroSample encodes sample with avro binary
+// encoding according to KafkaAvroSchema
+func encodeAvroSample(s jsonSample) []byte {
+	b := []byte{}
+
+	b = appendAvroString(b, s.Host)
+	b = appendAvroString(b, s.App)
+	b = appendAvroString(b, s.Task)
+	b = appendAvroLong(b, s.Timestamp)
+
+	keys := make([]string, 0, len(s.Metrics))
+	for k := range s.Metrics {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	if len(keys) > 0 {
+		b = appendAvroLong(b, int64(len(keys)))
+		for _, k := range keys {
+			b = appendAvroString(b, k)
+			b = appendAvroLong(b, int64(s.Metrics[k]))
+		}
+	}
+
+	// end of map blocks
+	return appendAvroLong(b, 0)
+}
+
+func appendAvroLong(b []byte, v int64) []byte {
+	return appendVarint(b, v)
+}
+
+func appendAvroString(b []byte, s string) []byte {
+	b = appendAvroLong(b, int64(len(s)))
+	return append(b, s...)
+}
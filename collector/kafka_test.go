@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+type recordingProducer struct {
+	sarama.SyncProducer
+	messages []*sarama.ProducerMessage
+}
+
+func (p *recordingProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.messages = append(p.messages, msg)
+	return 0, int64(len(p.messages)), nil
+}
+
+func (p *recordingProducer) value(t *testing.T, i int) []byte {
+	b, err := p.messages[i].Value.Encode()
+	if err != nil {
+		t.Fatalf("error encoding message value: %s", err)
+	}
+
+	return b
+}
+
+// avroReader decodes avro binary encoding of primitive types
+type avroReader struct {
+	b   []byte
+	err bool
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = true
+		return 0
+	}
+
+	r.b = r.b[n:]
+
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	if n < 0 || int64(len(r.b)) < n {
+		r.err = true
+		return ""
+	}
+
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+
+	return s
+}
+
+func TestKafkaWriterAvro(t *testing.T) {
+	p := &recordingProducer{}
+	w := &KafkaWriter{host: "myhost", topic: "docker_stats", avro: true, partitionByApp: true, producer: p}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	s.CPU.Total = 42
+	s.Memory.Usage = 1 << 40
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(p.messages) != 1 || p.messages[0].Topic != "docker_stats" {
+		t.Fatalf("expected single message to docker_stats, got %v", p.messages)
+	}
+
+	if key, _ := p.messages[0].Key.Encode(); string(key) != "myapp" {
+		t.Errorf("expected message to be keyed by app, got key %q", key)
+	}
+
+	r := &avroReader{b: p.value(t, 0)}
+
+	if host, app, task := r.string(), r.string(), r.string(); host != "myhost" || app != "myapp" || task != "mytask" {
+		t.Errorf("unexpected host, app and task: %q, %q, %q", host, app, task)
+	}
+
+	if ts := r.long(); ts != 1431000000 {
+		t.Errorf("expected timestamp 1431000000, got %d", ts)
+	}
+
+	metrics := map[string]int64{}
+	prev := ""
+	for n := r.long(); n != 0 && !r.err; n = r.long() {
+		for i := int64(0); i < n; i++ {
+			k := r.string()
+			if k <= prev {
+				t.Errorf("expected metrics sorted by name, got %q after %q", k, prev)
+			}
+
+			prev = k
+			metrics[k] = r.long()
+		}
+	}
+
+	if r.err || len(r.b) != 0 {
+		t.Fatalf("malformed avro message, %d bytes left", len(r.b))
+	}
+
+	if len(metrics) != len(intMetrics(s)) {
+		t.Errorf("expected %d metrics, got %d", len(intMetrics(s)), len(metrics))
+	}
+
+	if metrics["cpu.total"] != 42 || metrics["memory.usage"] != 1<<40 {
+		t.Errorf("unexpected metric values: %v", metrics)
+	}
+}
+
+func TestKafkaWriterAvroNoMetrics(t *testing.T) {
+	b := encodeAvroSample(jsonSample{Host: "myhost", App: "myapp", Task: "mytask", Timestamp: 1})
+
+	r := &avroReader{b: b}
+	r.string()
+	r.string()
+	r.string()
+	r.long()
+
+	if n := r.long(); n != 0 || r.err || len(r.b) != 0 {
+		t.Errorf("expected empty map to be a single end of blocks marker, got %v", b)
+	}
+}
+
+func TestKafkaWriterJSON(t *testing.T) {
+	p := &recordingProducer{}
+	w := &KafkaWriter{host: "myhost", topic: "docker_stats", producer: p}
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	s.CPU.Total = 42
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if p.messages[0].Key != nil {
+		t.Errorf("expected message without key when not partitioned by app")
+	}
+
+	sample := jsonSample{}
+	err = json.Unmarshal(p.value(t, 0), &sample)
+	if err != nil {
+		t.Fatalf("error decoding json message: %s", err)
+	}
+
+	if sample.Host != "myhost" || sample.App != "myapp" || sample.Timestamp != 1431000000 || sample.Metrics["cpu.total"] != 42 {
+		t.Errorf("unexpected sample: %#v", sample)
+	}
+}
+
+func TestKafkaWriterFormat(t *testing.T) {
+	_, err := NewKafkaWriter("myhost", []string{"127.0.0.1:1"}, "docker_stats", "xml", false)
+	if err == nil || !strings.Contains(err.Error(), "unknown kafka message format") {
+		t.Errorf("expected unknown format to be rejected, got %v", err)
+	}
+}
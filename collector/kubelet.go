@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// kubeletPodLabel and kubeletContainerLabel are set
+	// by kubelet on docker containers it starts
+	kubeletPodLabel       = "io.kubernetes.pod.name"
+	kubeletContainerLabel = "io.kubernetes.container.name"
+)
+
+// kubeletPods is the part of response of kubelet /pods endpoint
+// that is used for discovery
+type kubeletPods struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			ContainerStatuses []struct {
+				Name        string `json:"name"`
+				ContainerID string `json:"containerID"`
+				State       struct {
+					Running *struct{} `json:"running"`
+				} `json:"state"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// kubeletIdentity is app and task of container found in pods
+type kubeletIdentity struct {
+	app  string
+	task string
+}
+
+// Kubelet discovers containers of pods running on the local
+// kubelet for clusters where docker is only the runtime under
+// kubernetes, with Collector.SetDiscoverer it replaces watching
+// docker events, as an identity extractor it makes pod names apps
+// and container names tasks
+type Kubelet struct {
+	url    string
+	token  string
+	client *http.Client
+
+	mutex      sync.Mutex
+	containers map[string]kubeletIdentity
+}
+
+// NewKubelet creates kubelet client with specified url, like
+// https://127.0.0.1:10250, bearer token and tls config, token
+// is not sent if it is empty and nil tls config uses system roots
+func NewKubelet(url string, token string, tlsConfig *tls.Config) *Kubelet {
+	return &Kubelet{
+		url:   strings.TrimSuffix(url, "/"),
+		token: token,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		containers: map[string]kubeletIdentity{},
+	}
+}
+
+// Containers returns ids of running docker containers of pods
+// and remembers their identities for Extract
+func (k *Kubelet) Containers(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", k.url+"/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	resp, err := k.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from kubelet: %s", resp.Status)
+	}
+
+	pods := kubeletPods{}
+
+	err = json.NewDecoder(resp.Body).Decode(&pods)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	containers := map[string]kubeletIdentity{}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Running == nil || !strings.HasPrefix(status.ContainerID, "docker://") {
+				continue
+			}
+
+			id := strings.TrimPrefix(status.ContainerID, "docker://")
+
+			ids = append(ids, id)
+			containers[id] = kubeletIdentity{app: pod.Metadata.Name, task: status.Name}
+		}
+	}
+
+	k.mutex.Lock()
+	k.containers = containers
+	k.mutex.Unlock()
+
+	return ids, nil
+}
+
+// Extract returns pod name as app and container name as task,
+// containers that were not discovered yet are identified by
+// labels that kubelet sets on containers
+func (k *Kubelet) Extract(c *docker.Container) (app, task string, err error) {
+	k.mutex.Lock()
+	identity, ok := k.containers[c.ID]
+	k.mutex.Unlock()
+
+	if ok {
+		return identity.app, identity.task, nil
+	}
+
+	// sandbox containers that hold namespaces of pods are named POD
+	if c.Config.Labels[kubeletContainerLabel] == "POD" {
+		return "", "", nil
+	}
+
+	return c.Config.Labels[kubeletPodLabel], c.Config.Labels[kubeletContainerLabel], nil
+}
+
+func (k *Kubelet) String() string {
+	return "kubelet " + k.url
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+const kubeletPodsResponse = `{"items":[
+	{
+		"metadata": {"name": "web-1", "namespace": "prod"},
+		"status": {"containerStatuses": [
+			{"name": "nginx", "containerID": "docker://aaaa", "state": {"running": {"startedAt": "2017-01-01T00:00:00Z"}}},
+			{"name": "init", "containerID": "docker://bbbb", "state": {"terminated": {"exitCode": 0}}}
+		]}
+	},
+	{
+		"metadata": {"name": "db-0", "namespace": "prod"},
+		"status": {"containerStatuses": [
+			{"name": "postgres", "containerID": "containerd://cccc", "state": {"running": {}}}
+		]}
+	}
+]}`
+
+func TestKubelet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pods" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(kubeletPodsResponse))
+	}))
+
+	defer server.Close()
+
+	k := NewKubelet(server.URL, "secret", nil)
+
+	ids, err := k.Containers(context.Background())
+	if err != nil {
+		t.Fatalf("error discovering containers: %s", err)
+	}
+
+	if !reflect.DeepEqual(ids, []string{"aaaa"}) {
+		t.Errorf("expected only running docker container to be discovered, got %v", ids)
+	}
+
+	tests := []struct {
+		id     string
+		labels map[string]string
+		app    string
+		task   string
+	}{
+		{"aaaa", nil, "web-1", "nginx"},
+		{"dddd", map[string]string{kubeletPodLabel: "api-2", kubeletContainerLabel: "app"}, "api-2", "app"},
+		{"eeee", map[string]string{kubeletPodLabel: "api-2", kubeletContainerLabel: "POD"}, "", ""},
+		{"ffff", nil, "", ""},
+	}
+
+	for _, test := range tests {
+		app, task, err := k.Extract(&docker.Container{ID: test.id, Config: &docker.Config{Labels: test.labels}})
+		if err != nil || app != test.app || task != test.task {
+			t.Errorf("expected %s.%s for %s, got %s.%s and error %v", test.app, test.task, test.id, app, task, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// ContainerLister lists running containers, it is implemented by docker client
+type ContainerLister interface {
+	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+}
+
+// ListDiscoverer discovers only running containers from explicit list
+// of ids, id prefixes and names, for tightly controlled appliances where
+// automatic pickup of containers is undesirable
+type ListDiscoverer struct {
+	client     ContainerLister
+	containers []string
+}
+
+// NewListDiscoverer creates new ListDiscoverer with specified docker
+// client and list of ids, id prefixes or names of containers to monitor
+func NewListDiscoverer(client ContainerLister, containers []string) *ListDiscoverer {
+	return &ListDiscoverer{
+		client:     client,
+		containers: containers,
+	}
+}
+
+// Containers returns ids of running containers from the list,
+// containers are found again by name after they are recreated
+func (d *ListDiscoverer) Containers(ctx context.Context) ([]string, error) {
+	running, err := d.client.ListContainers(docker.ListContainersOptions{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, container := range running {
+		if d.listed(container) {
+			ids = append(ids, container.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// listed checks whether container is in the list by id or name
+func (d *ListDiscoverer) listed(container docker.APIContainers) bool {
+	for _, listed := range d.containers {
+		if strings.HasPrefix(container.ID, listed) {
+			return true
+		}
+
+		for _, name := range container.Names {
+			if strings.TrimPrefix(name, "/") == listed {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (d *ListDiscoverer) String() string {
+	return "list of " + strings.Join(d.containers, ", ")
+}
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// fixedLister lists the same containers every time
+type fixedLister []docker.APIContainers
+
+func (l fixedLister) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	return l, nil
+}
+
+func TestListDiscoverer(t *testing.T) {
+	d := NewListDiscoverer(fixedLister{
+		{ID: "0123456789abcdef", Names: []string{"/appliance-db"}},
+		{ID: "fedcba9876543210", Names: []string{"/sidecar"}},
+		{ID: "abcdefabcdef0000", Names: []string{"/appliance-ui"}},
+	}, []string{"appliance-db", "abcdef", "stopped"})
+
+	ids, err := d.Containers(context.Background())
+	if err != nil {
+		t.Fatalf("error discovering containers: %s", err)
+	}
+
+	if len(ids) != 2 || ids[0] != "0123456789abcdef" || ids[1] != "abcdefabcdef0000" {
+		t.Errorf("expected listed containers by name and id prefix, got %v", ids)
+	}
+}
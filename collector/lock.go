@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrLocked is returned when lock file is held by another process
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an exclusive lock of a file, it is released
+// by the kernel when process holding it exits
+type Lock struct {
+	file *os.File
+}
+
+// TryLock takes lock of file, creating it if needed, and writes pid
+// of the process into it, ErrLocked is returned if it is already held
+func TryLock(file string) (*Lock, error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Lock{file: f}, nil
+}
+
+// WaitLock takes lock of file, waiting for the process holding it to
+// release it, lock is retried every interval until ctx is done, so
+// standby collector takes over when the active one exits,
+// interval has to be positive
+func WaitLock(ctx context.Context, file string, interval time.Duration) (*Lock, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval of lock retries should be positive, got %s", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	waiting := false
+
+	for {
+		lock, err := TryLock(file)
+		if err != ErrLocked {
+			return lock, err
+		}
+
+		if !waiting {
+			waiting = true
+			Logf(LogInfo, "another collector holds lock %s, waiting for it to exit", file)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release releases lock, pid is left in the file
+func (l *Lock) Release() error {
+	return l.file.Close()
+}
@@ -0,0 +1,82 @@
+//go:build linux
+// +build linux
+
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collector-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "collector.lock")
+
+	lock, err := TryLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pid := strings.TrimSpace(string(b)); pid != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expected pid %d in lock file, got %q", os.Getpid(), pid)
+	}
+
+	_, err = TryLock(file)
+	if err != ErrLocked {
+		t.Fatalf("expected lock to be held, got error %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = WaitLock(ctx, file, 10*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected waiting for lock to time out, got error %v", err)
+	}
+
+	_, err = WaitLock(context.Background(), file, 0)
+	if err == nil {
+		t.Fatal("expected error waiting for lock with zero interval")
+	}
+
+	taken := make(chan error, 1)
+	go func() {
+		lock, err := WaitLock(context.Background(), file, 10*time.Millisecond)
+		if err == nil {
+			err = lock.Release()
+		}
+
+		taken <- err
+	}()
+
+	err = lock.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-taken:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected lock to be taken after it is released")
+	}
+}
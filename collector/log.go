@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the minimal level of messages that are logged
+type LogLevel int32
+
+const (
+	// LogDebug is for messages that help to understand what collector
+	// does, like skipped containers and reconnects to backends
+	LogDebug LogLevel = iota
+	// LogInfo is for messages about normal operation
+	LogInfo
+	// LogWarn is for problems that collector recovers from
+	LogWarn
+	// LogError is for failures that lose data
+	LogError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDebug: "debug",
+	LogInfo:  "info",
+	LogWarn:  "warn",
+	LogError: "error",
+}
+
+// LogFormat defines how log messages are formatted
+type LogFormat int32
+
+const (
+	// LogFormatText formats messages as text lines with fields at the end
+	LogFormatText LogFormat = iota
+	// LogFormatJSON formats messages as json objects with fields as keys
+	LogFormatJSON
+)
+
+// LogFields are fields attached to log message, like container id,
+// app and task, to make logs indexable and easy to correlate
+type LogFields map[string]string
+
+// logLevel and logFormat are accessed atomically
+// to allow changing them on reload
+var (
+	logLevel  = int32(LogInfo)
+	logFormat = int32(LogFormatText)
+
+	logMutex  sync.Mutex
+	logOutput logSink = streamLogSink{os.Stderr}
+)
+
+// logSink is where log messages end up
+type logSink interface {
+	write(t time.Time, level LogLevel, msg string, fields LogFields) error
+	close() error
+}
+
+// ParseLogLevel parses log level from its name: debug, info, warn or error
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level, name := range logLevelNames {
+		if name == s {
+			return level, nil
+		}
+	}
+
+	return LogInfo, fmt.Errorf("unknown log level: %s", s)
+}
+
+// SetLogLevel sets the minimal level of logged messages
+func SetLogLevel(level LogLevel) {
+	atomic.StoreInt32(&logLevel, int32(level))
+}
+
+// ParseLogFormat parses log format from its name: text or json
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch s {
+	case "text":
+		return LogFormatText, nil
+	case "json":
+		return LogFormatJSON, nil
+	default:
+		return LogFormatText, fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+// SetLogFormat sets format of logged messages
+func SetLogFormat(format LogFormat) {
+	atomic.StoreInt32(&logFormat, int32(format))
+}
+
+// Logf logs message with specified level if it's not below
+// current log level, arguments are handled as in fmt.Printf
+func Logf(level LogLevel, format string, args ...interface{}) {
+	LogFields(nil).Logf(level, format, args...)
+}
+
+// Logf logs message with fields attached
+func (f LogFields) Logf(level LogLevel, format string, args ...interface{}) {
+	if int32(level) < atomic.LoadInt32(&logLevel) {
+		return
+	}
+
+	writeLog(time.Now(), level, fmt.Sprintf(format, args...), f)
+}
+
+// SetLogOutput sets where log messages are written:
+// stderr, syslog or journald
+func SetLogOutput(output string) error {
+	var sink logSink
+	var err error
+
+	switch output {
+	case "stderr":
+		sink = streamLogSink{os.Stderr}
+	case "syslog":
+		sink, err = newSyslogSink("collectd-docker")
+	case "journald":
+		sink, err = newJournaldSink("collectd-docker")
+	default:
+		err = fmt.Errorf("unknown log output: %s", output)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logMutex.Lock()
+	previous := logOutput
+	logOutput = sink
+	logMutex.Unlock()
+
+	return previous.close()
+}
+
+func writeLog(t time.Time, level LogLevel, msg string, fields LogFields) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	logOutput.write(t, level, msg, fields)
+}
+
+// formatLog formats message according to log format,
+// time is only added to text messages if withTime is set
+func formatLog(t time.Time, level LogLevel, msg string, fields LogFields, withTime bool) string {
+	if LogFormat(atomic.LoadInt32(&logFormat)) == LogFormatJSON {
+		entry := map[string]string{}
+		for k, v := range fields {
+			entry[k] = v
+		}
+
+		entry["time"] = t.UTC().Format(time.RFC3339Nano)
+		entry["level"] = logLevelNames[level]
+		entry["msg"] = msg
+
+		line, _ := json.Marshal(entry)
+		return string(line)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	parts := []string{logLevelNames[level] + ":", msg}
+	if withTime {
+		parts = append([]string{t.Format("2006/01/02 15:04:05")}, parts...)
+	}
+
+	for _, k := range keys {
+		parts = append(parts, k+"="+fields[k])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// streamLogSink writes log messages as lines to io.Writer
+type streamLogSink struct {
+	writer io.Writer
+}
+
+func (s streamLogSink) write(t time.Time, level LogLevel, msg string, fields LogFields) error {
+	_, err := io.WriteString(s.writer, formatLog(t, level, msg, fields, true)+"\n")
+	return err
+}
+
+// close is no-op, stderr is never closed
+func (s streamLogSink) close() error {
+	return nil
+}
+
+// containerFields returns log fields of container
+func containerFields(id string, app string, task string) LogFields {
+	fields := LogFields{"container": id}
+	if app != "" {
+		fields["app"] = app
+	}
+
+	if task != "" {
+		fields["task"] = task
+	}
+
+	return fields
+}
+
+func debugf(format string, args ...interface{}) {
+	Logf(LogDebug, format, args...)
+}
+
+func infof(format string, args ...interface{}) {
+	Logf(LogInfo, format, args...)
+}
+
+func warnf(format string, args ...interface{}) {
+	Logf(LogWarn, format, args...)
+}
+
+func errorf(format string, args ...interface{}) {
+	Logf(LogError, format, args...)
+}
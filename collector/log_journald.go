@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journaldSocket is the socket of journald native protocol
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldPriorities maps log levels to syslog priorities
+var journaldPriorities = map[LogLevel]int{
+	LogDebug: 7,
+	LogInfo:  6,
+	LogWarn:  4,
+	LogError: 3,
+}
+
+// journaldSink sends log messages to journald with native protocol,
+// fields are sent as journal fields, like CONTAINER, APP and TASK
+type journaldSink struct {
+	identifier string
+	conn       *net.UnixConn
+}
+
+func newJournaldSink(identifier string) (logSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return journaldSink{identifier: identifier, conn: conn}, nil
+}
+
+func (s journaldSink) write(t time.Time, level LogLevel, msg string, fields LogFields) error {
+	b := &bytes.Buffer{}
+
+	writeJournalField(b, "PRIORITY", strconv.Itoa(journaldPriorities[level]))
+	writeJournalField(b, "SYSLOG_IDENTIFIER", s.identifier)
+	writeJournalField(b, "MESSAGE", msg)
+
+	for k, v := range fields {
+		writeJournalField(b, journalFieldName(k), v)
+	}
+
+	_, err := s.conn.Write(b.Bytes())
+	return err
+}
+
+func (s journaldSink) close() error {
+	return s.conn.Close()
+}
+
+// writeJournalField writes field in native protocol format,
+// newlines are replaced to keep simple KEY=value format
+func writeJournalField(b *bytes.Buffer, name string, value string) {
+	b.WriteString(name)
+	b.WriteByte('=')
+	b.WriteString(strings.Replace(value, "\n", " ", -1))
+	b.WriteByte('\n')
+}
+
+// journalFieldName makes valid journal field name: uppercase
+// letters, digits and underscores not starting with underscore
+func journalFieldName(name string) string {
+	result := []byte(strings.ToUpper(name))
+	for i, c := range result {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			result[i] = '_'
+		}
+	}
+
+	return strings.TrimLeft(string(result), "_")
+}
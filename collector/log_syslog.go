@@ -0,0 +1,43 @@
+//go:build !windows && !plan9 && !nacl
+// +build !windows,!plan9,!nacl
+
+package collector
+
+import (
+	"log/syslog"
+	"time"
+)
+
+// syslogSink writes log messages to local syslog daemon,
+// syslog adds timestamps to messages by itself
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(tag string) (logSink, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return syslogSink{writer: w}, nil
+}
+
+func (s syslogSink) write(t time.Time, level LogLevel, msg string, fields LogFields) error {
+	line := formatLog(t, level, msg, fields, false)
+
+	switch level {
+	case LogDebug:
+		return s.writer.Debug(line)
+	case LogInfo:
+		return s.writer.Info(line)
+	case LogWarn:
+		return s.writer.Warning(line)
+	default:
+		return s.writer.Err(line)
+	}
+}
+
+func (s syslogSink) close() error {
+	return s.writer.Close()
+}
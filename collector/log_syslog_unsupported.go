@@ -0,0 +1,10 @@
+//go:build windows || plan9 || nacl
+// +build windows plan9 nacl
+
+package collector
+
+import "errors"
+
+func newSyslogSink(tag string) (logSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJSONLog(t *testing.T) {
+	b := &bytes.Buffer{}
+
+	logOutput = streamLogSink{b}
+	SetLogFormat(LogFormatJSON)
+
+	defer func() {
+		logOutput = streamLogSink{os.Stderr}
+		SetLogFormat(LogFormatText)
+	}()
+
+	writeLog(time.Unix(1431000000, 0), LogWarn, "something happened", containerFields("abc", "myapp", "mytask"))
+
+	entry := map[string]string{}
+
+	err := json.Unmarshal(b.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("error parsing log entry %q: %s", b.String(), err)
+	}
+
+	expected := map[string]string{
+		"time":      "2015-05-07T12:00:00Z",
+		"level":     "warn",
+		"msg":       "something happened",
+		"container": "abc",
+		"app":       "myapp",
+		"task":      "mytask",
+	}
+
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected %s to be %q, got %q", k, v, entry[k])
+		}
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"container": "CONTAINER",
+		"_app.name": "APP_NAME",
+	}
+
+	for name, expected := range tests {
+		if got := journalFieldName(name); got != expected {
+			t.Errorf("expected journal field name %s for %s, got %s", expected, name, got)
+		}
+	}
+}
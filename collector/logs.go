@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// logFamily is the family of metrics of log pattern counters
+const logFamily = "log"
+
+// maxLogLine is max length of log line that is matched,
+// the rest of longer lines is ignored
+const maxLogLine = 64 << 10
+
+// LogPattern is a named regexp that lines of container logs are
+// matched against, matching lines are counted in log.<name> metric
+type LogPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// ParseLogPatterns parses semicolon separated patterns like
+// errors=\bERROR\b;timeouts=timed out, semicolons separate patterns
+// since commas are common in regexps
+func ParseLogPatterns(s string) ([]LogPattern, error) {
+	patterns := []LogPattern{}
+
+	for _, item := range strings.Split(s, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		i := strings.Index(item, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("log pattern should be like name=regexp, got %q", item)
+		}
+
+		re, err := regexp.Compile(item[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid log pattern %s: %s", item[:i], err)
+		}
+
+		patterns = append(patterns, LogPattern{Name: strings.TrimSpace(item[:i]), Regexp: re})
+	}
+
+	return patterns, nil
+}
+
+// SetLogPatterns enables tailing of logs of containers, lines of stdout
+// and stderr matching patterns are counted and counters are written
+// every interval as log.<name> metrics of app and task, counters start
+// from zero when monitoring of container starts, it should be called
+// before Run
+func (c *Collector) SetLogPatterns(patterns []LogPattern) {
+	c.logPatterns = patterns
+}
+
+// countLogs follows logs of container and counts lines matching log
+// patterns until ctx is done, following is resumed after errors
+func (c *Collector) countLogs(ctx context.Context, m *Monitor) {
+	counter := newLogCounter(c.logPatterns)
+
+	go c.probe(ctx, m, "log counters", func(ctx context.Context) (map[string]uint64, error) {
+		return counter.metrics(), nil
+	})
+
+	fields := containerFields(m.id, m.app, m.task)
+	since := time.Now()
+
+	for {
+		err := c.client.Logs(docker.LogsOptions{
+			Context:      ctx,
+			Container:    m.id,
+			OutputStream: counter,
+			ErrorStream:  counter,
+			Since:        since.Unix(),
+			Follow:       true,
+			Stdout:       true,
+			Stderr:       true,
+			RawTerminal:  m.tty,
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			fields.Logf(LogWarn, "error following logs: %s", err)
+		}
+
+		// following is resumed from when the stream ended, since has
+		// second precision, so lines of the last second can be counted
+		// twice after resuming, that is tolerated
+		since = time.Now()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// logCounter counts lines written to it that match patterns
+type logCounter struct {
+	patterns []LogPattern
+
+	mutex  sync.Mutex
+	counts []uint64
+	line   []byte
+}
+
+func newLogCounter(patterns []LogPattern) *logCounter {
+	return &logCounter{
+		patterns: patterns,
+		counts:   make([]uint64, len(patterns)),
+	}
+}
+
+func (l *logCounter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			l.append(p)
+			break
+		}
+
+		l.append(p[:i])
+		l.match()
+		l.line = l.line[:0]
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// append adds chunk to current line up to max line length
+func (l *logCounter) append(chunk []byte) {
+	if room := maxLogLine - len(l.line); len(chunk) > room {
+		chunk = chunk[:room]
+	}
+
+	l.line = append(l.line, chunk...)
+}
+
+// match counts current line in counters of matching patterns
+func (l *logCounter) match() {
+	for i, p := range l.patterns {
+		if p.Regexp.Match(l.line) {
+			l.counts[i]++
+		}
+	}
+}
+
+// metrics returns counters of patterns as metrics of log family
+func (l *logCounter) metrics() map[string]uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	metrics := make(map[string]uint64, len(l.patterns))
+	for i, p := range l.patterns {
+		metrics[logFamily+"."+sanitizeForGraphite(p.Name)] = l.counts[i]
+	}
+
+	return metrics
+}
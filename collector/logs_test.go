@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestParseLogPatterns(t *testing.T) {
+	patterns, err := ParseLogPatterns(`errors=\bERROR\b; slow=took \d{2,}ms;`)
+	if err != nil {
+		t.Fatalf("error parsing log patterns: %s", err)
+	}
+
+	if len(patterns) != 2 || patterns[0].Name != "errors" || patterns[1].Name != "slow" {
+		t.Fatalf("unexpected patterns: %v", patterns)
+	}
+
+	if !patterns[1].Regexp.MatchString("query took 120ms") {
+		t.Errorf("expected regexp with comma to be kept intact, got %s", patterns[1].Regexp)
+	}
+
+	for _, invalid := range []string{"ERROR", "=ERROR", "errors=("} {
+		if _, err := ParseLogPatterns(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestLogCounter(t *testing.T) {
+	patterns, err := ParseLogPatterns("errors=ERROR;warnings=WARN")
+	if err != nil {
+		t.Fatalf("error parsing log patterns: %s", err)
+	}
+
+	counter := newLogCounter(patterns)
+
+	// lines are split across writes like frames of docker logs
+	for _, chunk := range []string{"INFO started\nERR", "OR failed\n", "WARN slow\nERROR again\n"} {
+		counter.Write([]byte(chunk))
+	}
+
+	counter.Write([]byte(strings.Repeat("x", maxLogLine) + "ERROR truncated\nERROR incomplete"))
+
+	metrics := counter.metrics()
+	if metrics["log.errors"] != 2 || metrics["log.warnings"] != 1 {
+		t.Errorf("expected 2 errors and 1 warning, got %v", metrics)
+	}
+}
+
+func TestCountLogsResume(t *testing.T) {
+	mutex := sync.Mutex{}
+	since := []int64{}
+	ended := int64(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/abcdef/logs") {
+			http.NotFound(w, r)
+			return
+		}
+
+		s, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+
+		mutex.Lock()
+		since = append(since, s)
+		first := len(since) == 1
+		mutex.Unlock()
+
+		if first {
+			// the first stream fails after following for a while
+			time.Sleep(1100 * time.Millisecond)
+
+			mutex.Lock()
+			ended = time.Now().Unix()
+			mutex.Unlock()
+
+			http.Error(w, "daemon restarted", http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintln(w, "ERROR after resuming")
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+
+	defer server.Close()
+
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("error creating docker client: %s", err)
+	}
+
+	patterns, err := ParseLogPatterns("errors=ERROR")
+	if err != nil {
+		t.Fatalf("error parsing log patterns: %s", err)
+	}
+
+	c := &Collector{client: client}
+	c.SetQueue(10, DropPolicyDropNewest)
+	c.SetLogPatterns(patterns)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		c.countLogs(ctx, &Monitor{id: "abcdef", app: "myapp", task: "mytask", tty: true})
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		n := len(since)
+		mutex.Unlock()
+
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(since) < 2 {
+		t.Fatalf("expected following of logs to be resumed, got %d requests", len(since))
+	}
+
+	if since[1] < ended {
+		t.Errorf("expected logs to be resumed from %d when stream ended, got since %d", ended, since[1])
+	}
+}
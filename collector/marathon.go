@@ -0,0 +1,241 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// marathonApp is the part of marathon app definition used for identity
+type marathonApp struct {
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels"`
+}
+
+// marathonEntry is a cached app definition, nil app
+// is cached when marathon doesn't know the app
+type marathonEntry struct {
+	app     *marathonApp
+	expires time.Time
+}
+
+// MarathonAPIExtractor finds identity of containers started by marathon
+// in app definitions from marathon REST API: collectd_docker_app and
+// collectd_docker_task labels of app set app and task, with groups
+// enabled app name reflects group tree of marathon, like prod.search.web
+// for /prod/search/web, otherwise it's app id with slashes replaced,
+// task is short container id unless label sets it; containers without
+// MARATHON_APP_ID and apps that can't be fetched are left to the next
+// extractor, definitions are cached for ttl
+type MarathonAPIExtractor struct {
+	url    string
+	groups bool
+	ttl    time.Duration
+	client *http.Client
+
+	colors *MarathonColors
+
+	mutex   sync.Mutex
+	entries map[string]marathonEntry
+}
+
+// NewMarathonAPIExtractor creates extractor for marathon at specified url
+func NewMarathonAPIExtractor(url string, groups bool, ttl time.Duration) *MarathonAPIExtractor {
+	return &MarathonAPIExtractor{
+		url:     strings.TrimSuffix(url, "/"),
+		groups:  groups,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		entries: map[string]marathonEntry{},
+	}
+}
+
+// SetColors sets deployment suffixes that are stripped from app ids,
+// apps named by labels are left as is, it should be called before use
+func (e *MarathonAPIExtractor) SetColors(colors *MarathonColors) {
+	e.colors = colors
+}
+
+// Extract returns identity from marathon app definition
+func (e *MarathonAPIExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	id := extractEnv(c, "MARATHON_APP_ID")
+	if id == "" {
+		return "", "", nil
+	}
+
+	definition, err := e.app(id)
+	if err != nil {
+		containerFields(c.ID, "", "").Logf(LogDebug, "error getting marathon app %s: %s", id, err)
+		return "", "", nil
+	}
+
+	if definition == nil {
+		return "", "", nil
+	}
+
+	app = definition.Labels[appLabel]
+	if app == "" {
+		app = strings.TrimPrefix(definition.ID, "/")
+		if e.colors != nil {
+			app, _ = e.colors.strip(app)
+		}
+	}
+
+	task = definition.Labels[taskLabel]
+	if task == "" {
+		task = shortID(c.ID)
+	}
+
+	return app, task, nil
+}
+
+func (e *MarathonAPIExtractor) String() string {
+	return "marathon api " + e.url
+}
+
+// hierarchical tells identify that slashes in app name separate groups
+func (e *MarathonAPIExtractor) hierarchical() bool {
+	return e.groups
+}
+
+// app returns cached or fetched app definition
+func (e *MarathonAPIExtractor) app(id string) (*marathonApp, error) {
+	now := time.Now()
+
+	e.mutex.Lock()
+	entry, ok := e.entries[id]
+	e.mutex.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.app, nil
+	}
+
+	app, err := e.fetch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// entries of apps that are gone are dropped on the way
+	for k, v := range e.entries {
+		if !now.Before(v.expires) {
+			delete(e.entries, k)
+		}
+	}
+
+	e.entries[id] = marathonEntry{app: app, expires: now.Add(e.ttl)}
+
+	return app, nil
+}
+
+// fetch gets app definition from marathon,
+// nil is returned if marathon doesn't know the app
+func (e *MarathonAPIExtractor) fetch(id string) (*marathonApp, error) {
+	path := (&url.URL{Path: "/v2/apps/" + strings.TrimPrefix(id, "/")}).EscapedPath()
+
+	resp, err := e.client.Get(e.url + path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	body := struct {
+		App marathonApp `json:"app"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &body.App, nil
+}
+
+// colorMeta is metadata key of deployment color of marathon apps
+const colorMeta = "color"
+
+// DefaultMarathonColors matches blue, green and canary suffixes
+const DefaultMarathonColors = "[-_](blue|green|canary)$"
+
+// MarathonColors strips blue/green or canary deployment suffixes from
+// marathon app ids, so both colors of a deployment report into the same
+// app, as identity extractor it takes app from MARATHON_APP_ID without
+// suffix and task from short container id, as metadata extractor it
+// returns the suffix as color metadata, so colors can still be told apart
+type MarathonColors struct {
+	re *regexp.Regexp
+}
+
+// NewMarathonColors creates MarathonColors with regexp matching suffixes,
+// whole match is stripped and the first group is the color, like
+// [-_](blue|green|canary)$ for web-blue and web-canary
+func NewMarathonColors(expr string) (*MarathonColors, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("regexp of marathon colors has no group for color: %s", expr)
+	}
+
+	return &MarathonColors{re: re}, nil
+}
+
+// Extract returns identity of marathon app without deployment suffix
+func (m *MarathonColors) Extract(c *docker.Container) (app, task string, err error) {
+	app = strings.TrimPrefix(extractEnv(c, "MARATHON_APP_ID"), "/")
+	if app == "" {
+		return "", "", nil
+	}
+
+	app, _ = m.strip(app)
+
+	return app, shortID(c.ID), nil
+}
+
+// Metadata returns deployment color of marathon app
+func (m *MarathonColors) Metadata(c *docker.Container) map[string]string {
+	_, color := m.strip(strings.TrimPrefix(extractEnv(c, "MARATHON_APP_ID"), "/"))
+	if color == "" {
+		return nil
+	}
+
+	return map[string]string{colorMeta: color}
+}
+
+func (m *MarathonColors) String() string {
+	return "env MARATHON_APP_ID without " + m.re.String()
+}
+
+// strip returns app id without suffix and color from the suffix
+func (m *MarathonColors) strip(id string) (app, color string) {
+	match := m.re.FindStringSubmatchIndex(id)
+	if match == nil || match[0] == 0 {
+		return id, ""
+	}
+
+	if match[2] >= 0 {
+		color = id[match[2]:match[3]]
+	}
+
+	return id[:match[0]] + id[match[1]:], color
+}
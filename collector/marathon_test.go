@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestMarathonAPIExtractor(t *testing.T) {
+	requests := int32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		switch r.URL.Path {
+		case "/v2/apps/prod/search/web.api":
+			w.Write([]byte(`{"app":{"id":"/prod/search/web.api","labels":{}}}`))
+		case "/v2/apps/prod/ads":
+			w.Write([]byte(`{"app":{"id":"/prod/ads","labels":{"collectd_docker_app":"adserver","collectd_docker_task":"main"}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer server.Close()
+
+	tests := []struct {
+		id     string
+		groups bool
+		app    string
+		task   string
+	}{
+		{"/prod/search/web.api", true, "prod.search.web_api", "0123abcd"},
+		{"/prod/search/web.api", false, "prod_search_web_api", "0123abcd"},
+		{"/prod/ads", true, "adserver", "main"},
+		{"/unknown", true, "", ""},
+	}
+
+	for _, test := range tests {
+		extractor := NewMarathonAPIExtractor(server.URL+"/", test.groups, time.Minute)
+
+		c := &docker.Container{
+			ID:     "0123abcdef",
+			Config: &docker.Config{Env: []string{"MARATHON_APP_ID=" + test.id}},
+		}
+
+		for i := 0; i < 2; i++ {
+			app, task, _, err := identify(extractor, c)
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", test.id, err)
+			}
+
+			if app != test.app || task != test.task {
+				t.Errorf("expected %s.%s for %s with groups %v, got %s.%s", test.app, test.task, test.id, test.groups, app, task)
+			}
+		}
+	}
+
+	if requests != int32(len(tests)) {
+		t.Errorf("expected app definitions to be cached, got %d requests for %d apps", requests, len(tests))
+	}
+}
+
+func TestMarathonColors(t *testing.T) {
+	colors, err := NewMarathonColors(DefaultMarathonColors)
+	if err != nil {
+		t.Fatalf("error creating marathon colors: %s", err)
+	}
+
+	tests := []struct {
+		id    string
+		app   string
+		color string
+	}{
+		{"/web-blue", "web", "blue"},
+		{"/prod/search_green", "prod/search", "green"},
+		{"/web-canary", "web", "canary"},
+		{"/bluebird", "bluebird", ""},
+		{"/-blue", "-blue", ""},
+	}
+
+	for _, test := range tests {
+		c := &docker.Container{ID: "0123456789abcdef", Config: &docker.Config{Env: []string{"MARATHON_APP_ID=" + test.id}}}
+
+		app, task, err := colors.Extract(c)
+		if err != nil || app != test.app || task != "01234567" {
+			t.Errorf("expected %s.01234567 for %s, got %s.%s and error %v", test.app, test.id, app, task, err)
+		}
+
+		if color := colors.Metadata(c)[colorMeta]; color != test.color {
+			t.Errorf("expected color %q for %s, got %q", test.color, test.id, color)
+		}
+	}
+
+	if _, err := NewMarathonColors("-(?:blue|green)$"); err == nil {
+		t.Errorf("expected error for regexp without color group")
+	}
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mesosContainer is the part of response of mesos agent
+// /containers endpoint that is used for samples
+type mesosContainer struct {
+	ContainerID string          `json:"container_id"`
+	ExecutorID  string          `json:"executor_id"`
+	Statistics  mesosStatistics `json:"statistics"`
+}
+
+// mesosStatistics are resource statistics of mesos container,
+// cpu times are in seconds and timestamp is unix time in seconds
+type mesosStatistics struct {
+	Timestamp          float64 `json:"timestamp"`
+	CPUsUserTimeSecs   float64 `json:"cpus_user_time_secs"`
+	CPUsSystemTimeSecs float64 `json:"cpus_system_time_secs"`
+	MemLimitBytes      uint64  `json:"mem_limit_bytes"`
+	MemTotalBytes      uint64  `json:"mem_total_bytes"`
+	MemRSSBytes        uint64  `json:"mem_rss_bytes"`
+	MemCacheBytes      uint64  `json:"mem_cache_bytes"`
+	MemMappedFileBytes uint64  `json:"mem_mapped_file_bytes"`
+	NetRxBytes         uint64  `json:"net_rx_bytes"`
+	NetRxDropped       uint64  `json:"net_rx_dropped"`
+	NetRxErrors        uint64  `json:"net_rx_errors"`
+	NetRxPackets       uint64  `json:"net_rx_packets"`
+	NetTxBytes         uint64  `json:"net_tx_bytes"`
+	NetTxDropped       uint64  `json:"net_tx_dropped"`
+	NetTxErrors        uint64  `json:"net_tx_errors"`
+	NetTxPackets       uint64  `json:"net_tx_packets"`
+}
+
+// MesosAgent reads resource statistics of containers from the local
+// mesos agent instead of docker, so containers of frameworks that
+// don't launch them with docker containerizer are monitored too
+type MesosAgent struct {
+	url    string
+	client *http.Client
+}
+
+// NewMesosAgent creates mesos agent client with specified url,
+// like http://127.0.0.1:5051
+func NewMesosAgent(url string) *MesosAgent {
+	return &MesosAgent{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Samples returns samples of containers running on mesos agent,
+// app is executor id without instance suffix that frameworks like
+// marathon add after the last dot and task is short container id
+func (a *MesosAgent) Samples(ctx context.Context) ([]Stats, error) {
+	req, err := http.NewRequest("GET", a.url+"/containers", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from mesos agent: %s", resp.Status)
+	}
+
+	containers := []mesosContainer{}
+
+	err = json.NewDecoder(resp.Body).Decode(&containers)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]Stats, 0, len(containers))
+	for _, c := range containers {
+		app := c.ExecutorID
+		if i := strings.LastIndex(app, "."); i > 0 {
+			app = app[:i]
+		}
+
+		samples = append(samples, newMesosStats(sanitizeForGraphite(app), sanitizeForGraphite(shortID(c.ContainerID)), c.Statistics))
+	}
+
+	return samples, nil
+}
+
+// Run writes samples of containers running on mesos agent
+// every interval until ctx is done
+func (a *MesosAgent) Run(ctx context.Context, interval time.Duration, w Writer) {
+	every(ctx, interval, func(t time.Time) {
+		samples, err := a.Samples(ctx)
+		if err != nil {
+			Logf(LogError, "error getting containers from mesos agent: %s", err)
+			return
+		}
+
+		for _, s := range samples {
+			err := w.Write(s)
+			if err != nil {
+				countWriteError()
+				LogFields{"app": s.App, "task": s.Task}.Logf(LogError, "error writing stats: %s", err)
+			}
+		}
+	})
+}
+
+// newMesosStats converts statistics of mesos container
+// into sample of specified app and task
+func newMesosStats(app, task string, m mesosStatistics) Stats {
+	user := uint64(m.CPUsUserTimeSecs * float64(time.Second))
+	system := uint64(m.CPUsSystemTimeSecs * float64(time.Second))
+
+	return Stats{
+		App:  app,
+		Task: task,
+		Time: time.Unix(0, int64(m.Timestamp*float64(time.Second))),
+		CPU: CPUStats{
+			User:   user,
+			System: system,
+			Total:  user + system,
+		},
+		Memory: MemoryStats{
+			Limit:      m.MemLimitBytes,
+			Usage:      m.MemTotalBytes,
+			Cache:      m.MemCacheBytes,
+			MappedFile: m.MemMappedFileBytes,
+			RSS:        m.MemRSSBytes,
+		},
+		Network: NetworkStats{
+			RxBytes:   m.NetRxBytes,
+			RxDropped: m.NetRxDropped,
+			RxErrors:  m.NetRxErrors,
+			RxPackets: m.NetRxPackets,
+			TxBytes:   m.NetTxBytes,
+			TxDropped: m.NetTxDropped,
+			TxErrors:  m.NetTxErrors,
+			TxPackets: m.NetTxPackets,
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const mesosContainersResponse = `[
+	{
+		"container_id": "f5d1a2b3-0000-4c4c-8a8a-123456789abc",
+		"executor_id": "prod_web.8e8b7f1c-1a2b-11e7-9f5c-0242ac110002",
+		"framework_id": "20170101-000000-1-5050-1-0000",
+		"statistics": {
+			"timestamp": 1500000000.5,
+			"cpus_user_time_secs": 1.5,
+			"cpus_system_time_secs": 0.25,
+			"mem_limit_bytes": 1073741824,
+			"mem_total_bytes": 536870912,
+			"mem_rss_bytes": 268435456,
+			"net_rx_bytes": 2048
+		}
+	}
+]`
+
+func TestMesosAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(mesosContainersResponse))
+	}))
+
+	defer server.Close()
+
+	samples, err := NewMesosAgent(server.URL + "/").Samples(context.Background())
+	if err != nil {
+		t.Fatalf("error getting samples: %s", err)
+	}
+
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+
+	s := samples[0]
+
+	if s.App != "prod_web" || s.Task != "f5d1a2b3" {
+		t.Errorf("expected prod_web.f5d1a2b3, got %s.%s", s.App, s.Task)
+	}
+
+	if !s.Time.Equal(time.Unix(1500000000, 500000000)) {
+		t.Errorf("unexpected time %s", s.Time)
+	}
+
+	if s.CPU.User != 1500000000 || s.CPU.System != 250000000 || s.CPU.Total != 1750000000 {
+		t.Errorf("unexpected cpu stats %#v", s.CPU)
+	}
+
+	if s.Memory.Usage != 536870912 || s.Memory.Limit != 1073741824 || s.Memory.RSS != 268435456 {
+		t.Errorf("unexpected memory stats %#v", s.Memory)
+	}
+
+	if s.Network.RxBytes != 2048 {
+		t.Errorf("unexpected network stats %#v", s.Network)
+	}
+}
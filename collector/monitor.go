@@ -1,9 +1,15 @@
 package collector
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
 	"strings"
-	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 )
@@ -12,108 +18,682 @@ import (
 // that shouldn't be monitored by collectd
 var ErrNoNeedToMonitor = errors.New("container is not supposed to be monitored")
 
-var imageNameRegex = regexp.MustCompile(`.*\/([^\/]*):.*`)
+// SkipReason tells why container is not monitored
+type SkipReason int
+
+const (
+	// SkipNoIdentity means that app of container is not found
+	SkipNoIdentity SkipReason = iota
+	// SkipFiltered means that container doesn't match filter
+	SkipFiltered
+	// SkipOptOut means that container has opt-out label
+	// or identity extractor skipped it on purpose
+	SkipOptOut
+	// SkipInfrastructure means that container is pause or sandbox
+	// container of container runtime, like of kubernetes pods
+	SkipInfrastructure
+)
+
+func (r SkipReason) String() string {
+	switch r {
+	case SkipNoIdentity:
+		return "no identity"
+	case SkipFiltered:
+		return "filtered"
+	case SkipOptOut:
+		return "opt-out"
+	case SkipInfrastructure:
+		return "infrastructure"
+	default:
+		return "unknown"
+	}
+}
+
+// SkipError is returned by NewMonitor for containers that should not be
+// monitored, errors.Is matches it against ErrNoNeedToMonitor
+type SkipError struct {
+	Reason SkipReason
+	// Detail explains reason in words
+	Detail string
+}
+
+func (e *SkipError) Error() string {
+	return ErrNoNeedToMonitor.Error() + ": " + e.Detail
+}
+
+// Is matches ErrNoNeedToMonitor
+func (e *SkipError) Is(target error) bool {
+	return target == ErrNoNeedToMonitor
+}
+
+var (
+	// ErrContainerGone is the kind of errors of Monitor.Run when
+	// stats stream ends because container is stopped or removed
+	ErrContainerGone = errors.New("container is gone")
+	// ErrDaemonUnreachable is the kind of errors of Monitor.Run when
+	// stats stream fails because docker daemon can't be reached
+	ErrDaemonUnreachable = errors.New("docker daemon is unreachable")
+	// ErrMonitorRunning is returned by Monitor.Run
+	// when it is called while monitor is running
+	ErrMonitorRunning = errors.New("monitor is already running")
+)
+
+// MonitorError describes why Monitor.Run stopped, Kind is either
+// ErrContainerGone or ErrDaemonUnreachable, Err is the underlying
+// error, errors.Is matches MonitorError against its kind
+type MonitorError struct {
+	Kind error
+	Err  error
+}
+
+func (e *MonitorError) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *MonitorError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches kind of error
+func (e *MonitorError) Is(target error) bool {
+	return e.Kind == target
+}
 
 // MonitorDockerClient represents restricted interface for docker client
 // that is used in monitor, docker.Client is a subset of this interface
 type MonitorDockerClient interface {
-	InspectContainer(id string) (*docker.Container, error)
+	InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error)
 	Stats(opts docker.StatsOptions) error
 }
 
+// Clock tells time to monitors, it is replaced in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock of time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// MonitorOption configures monitor created with NewMonitor
+type MonitorOption func(m *Monitor)
+
+// WithInterval sets stat updating interval, every stat
+// from stats stream is sent with zero interval
+func WithInterval(interval time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		m.interval = int64(interval)
+	}
+}
+
+// WithIdentityExtractor sets how app and task of container are
+// found instead of DefaultIdentityExtractor
+func WithIdentityExtractor(extractor IdentityExtractor) MonitorOption {
+	return func(m *Monitor) {
+		m.identify = extractor
+	}
+}
+
+// WithFilter sets filter that inspected container has to match,
+// NewMonitor returns *SkipError for other containers
+func WithFilter(filter Filter) MonitorOption {
+	return func(m *Monitor) {
+		m.filter = filter
+	}
+}
+
+// WithMetadataExtractor sets how metadata of container is
+// found, metadata is attached to every sample as Stats.Meta
+func WithMetadataExtractor(extractor MetadataExtractor) MonitorOption {
+	return func(m *Monitor) {
+		m.metadata = extractor
+	}
+}
+
+// WithClock sets clock that sampling is timed with
+func WithClock(clock Clock) MonitorOption {
+	return func(m *Monitor) {
+		m.clock = clock
+	}
+}
+
+// WithMaxClockSkew sets how far read time of stats from docker can be
+// from local clock, samples read further away are timestamped with
+// local time instead, 0 trusts read time of stats from docker
+func WithMaxClockSkew(skew time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		m.maxSkew = skew
+	}
+}
+
+// WithInspectRetries sets how many times inspection of container is
+// retried when it fails or container is not started yet, like when
+// inspect races with start of container, retries wait for jittered
+// backoff that doubles every time, containers that are gone are not
+// retried, 0 retries or non-positive backoff doesn't retry
+func WithInspectRetries(retries int, backoff time.Duration) MonitorOption {
+	return func(m *Monitor) {
+		if backoff <= 0 {
+			retries = 0
+		}
+
+		m.retries = retries
+		m.backoff = backoff
+	}
+}
+
 // Monitor is responsible for monitoring of a single container (task)
 type Monitor struct {
+	// interval is the first field to be 64-bit aligned for atomic access,
+	// it is stored in nanoseconds
+	interval int64
+	// offset shifts sampling from the start of stats stream,
+	// so containers are not sampled at the same instant
+	offset time.Duration
+	// running is set while monitor runs
+	running int32
+	// aligned makes samples taken on interval boundaries
+	// and their timestamps aligned to boundaries
+	aligned  bool
+	identify IdentityExtractor
+	filter   Filter
+	metadata MetadataExtractor
+	clock    Clock
 	client   MonitorDockerClient
 	id       string
 	app      string
 	task     string
-	interval int
+	image    string
+	meta     map[string]string
+	// probe is command from collectd_docker_probe label
+	probe []string
+	// scrape is endpoint from collectd_docker_scrape label
+	scrape string
+	// scrapeFilter is regexp from collectd_docker_scrape_filter label
+	scrapeFilter string
+	// address is ip address of container to scrape endpoint on
+	address string
+	// tty is set for containers with terminal, their logs aren't multiplexed
+	tty bool
+	// ports are published tcp ports of container for reachability probes
+	ports []publishedPort
+	// highResolution is interval from collectd_docker_high_resolution label
+	highResolution string
+	// pid is pid of the main process of container on the host
+	pid int
+	// maxSkew is max difference of read time of stats and local clock
+	maxSkew time.Duration
+	// skewed is set once skew of clock of docker is logged
+	skewed bool
+	// triggers request samples right away, see sampleNow
+	triggers chan chan struct{}
+	// retries and backoff are set with WithInspectRetries
+	retries int
+	backoff time.Duration
+	// last is the last sample of container that was sent
+	last      Stats
+	lastMutex sync.Mutex
 }
 
-// NewMonitor creates new monitor with specified docker client,
-// container id and stat updating interval
-func NewMonitor(c MonitorDockerClient, id string, interval int) (*Monitor, error) {
-	container, err := c.InspectContainer(id)
+// NewMonitor creates new monitor with specified docker client, container
+// id and options, stat updating interval is 1s unless options set it,
+// inspection of container is cancelled when ctx is done, *SkipError
+// is returned for containers that should not be monitored
+func NewMonitor(ctx context.Context, c MonitorDockerClient, id string, options ...MonitorOption) (*Monitor, error) {
+	m := &Monitor{
+		interval: int64(time.Second),
+		identify: DefaultIdentityExtractor,
+		clock:    realClock{},
+		client:   c,
+		triggers: make(chan chan struct{}),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	container, err := m.inspect(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	app := sanitizeForGraphite(extractApp(container))
-	if app == "" {
-		return nil, ErrNoNeedToMonitor
+	m.app, m.task, _, err = admit(m.filter, m.identify, container)
+	if err != nil {
+		return nil, err
 	}
 
-	task := sanitizeForGraphite(container.ID[:8])
+	if m.metadata != nil {
+		m.meta = m.metadata.Metadata(container)
+	}
 
-	return &Monitor{
-		client:   c,
-		id:       container.ID,
-		app:      app,
-		task:     task,
-		interval: interval,
-	}, nil
+	m.id = container.ID
+	m.image = container.Config.Image
+	m.probe = strings.Fields(container.Config.Labels[probeLabel])
+	m.scrape = container.Config.Labels[scrapeLabel]
+	m.scrapeFilter = container.Config.Labels[scrapeFilterLabel]
+	m.address = containerAddress(container)
+	m.tty = container.Config.Tty
+	m.ports = publishedPorts(container)
+	m.highResolution = container.Config.Labels[highResolutionLabel]
+	m.pid = container.State.Pid
+
+	return m, nil
 }
 
-func (m *Monitor) handle(ch chan<- Stats) error {
+// inspect inspects container retrying failures and containers
+// that are not started yet, containers that are gone are not retried
+func (m *Monitor) inspect(ctx context.Context, id string) (*docker.Container, error) {
+	backoff := m.backoff
+
+	for attempt := 0; ; attempt++ {
+		container, err := m.client.InspectContainerWithContext(id, ctx)
+		if err != nil {
+			if containerGone(err) {
+				return nil, err
+			}
+		} else if m.retries == 0 || containerStarted(container) {
+			return container, nil
+		} else {
+			err = fmt.Errorf("container is not started yet, its state is %s", container.State.StateString())
+		}
+
+		if attempt >= m.retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		backoff *= 2
+
+		containerFields(id, "", "").Logf(LogDebug, "retrying inspection of container in %s: %s", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-m.clock.After(delay):
+		}
+	}
+}
+
+// containerGone returns whether error of docker says that container is gone
+func containerGone(err error) bool {
+	return err != nil && errors.Is(streamError(err), ErrContainerGone)
+}
+
+// containerStarted returns whether container is past its start, exited
+// containers are started too, their stats streams end right away
+func containerStarted(container *docker.Container) bool {
+	switch {
+	case container.State.Restarting:
+		return false
+	case container.State.Running:
+		return container.State.Pid != 0
+	default:
+		return container.State.Status != "created"
+	}
+}
+
+// remember keeps sample as the last sample of container
+func (m *Monitor) remember(s Stats) {
+	m.lastMutex.Lock()
+	m.last = s
+	m.lastMutex.Unlock()
+}
+
+// lastSample returns the last sample of container,
+// false is returned if no samples were sent yet
+func (m *Monitor) lastSample() (Stats, bool) {
+	m.lastMutex.Lock()
+	defer m.lastMutex.Unlock()
+
+	return m.last, !m.last.Time.IsZero()
+}
+
+// setInterval changes stat updating interval of running monitor
+func (m *Monitor) setInterval(interval time.Duration) {
+	atomic.StoreInt64(&m.interval, int64(interval))
+}
+
+// Run streams stats of container and sends samples to ch until
+// stats stream ends or ctx is done, then *MonitorError or ctx error
+// is returned, Run can be called again once it returns, for example
+// to supervise monitor until container is gone
+func (m *Monitor) Run(ctx context.Context, ch chan<- Stats) error {
+	return m.handle(ctx, func(s Stats) {
+		select {
+		case ch <- s:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// handle streams stats of container and sends samples
+// until stats stream ends or ctx is done
+func (m *Monitor) handle(ctx context.Context, send func(Stats)) error {
+	if !atomic.CompareAndSwapInt32(&m.running, 0, 1) {
+		return ErrMonitorRunning
+	}
+
+	defer atomic.StoreInt32(&m.running, 0)
+
 	in := make(chan *docker.Stats)
+	done := make(chan struct{})
+
+	var final *docker.Stats
+	var read time.Time
 
 	go func() {
-		i := 0
+		final, read = m.sample(in, send)
+		close(done)
+	}()
+
+	err := m.client.Stats(docker.StatsOptions{
+		ID:      m.id,
+		Stats:   in,
+		Stream:  true,
+		Context: ctx,
+	})
+
+	// samples are not sent after handle returns
+	<-done
+
+	if ctx.Err() != nil {
+		if final != nil {
+			statsPool.Put(final)
+		}
+
+		return ctx.Err()
+	}
+
+	// stats received after the last tick are flushed when stream ends,
+	// so containers that exit between ticks still report their usage
+	if final != nil {
+		m.send(final, read, send)
+	}
+
+	return streamError(err)
+}
+
+// streamError classifies error of stats stream, streams
+// end without errors when containers are stopped
+func streamError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return &MonitorError{Kind: ErrContainerGone}
+	case *docker.NoSuchContainer:
+		return &MonitorError{Kind: ErrContainerGone, Err: err}
+	case *docker.Error:
+		if e.Status == http.StatusNotFound {
+			return &MonitorError{Kind: ErrContainerGone, Err: err}
+		}
+	}
+
+	return &MonitorError{Kind: ErrDaemonUnreachable, Err: err}
+}
+
+// sample sends the latest stats received from stream on every tick
+// of interval, so sampling doesn't depend on cadence of stats stream,
+// ticks without new stats since the previous tick are skipped, stats
+// that are not sent when stream ends are returned with their read time
+func (m *Monitor) sample(in <-chan *docker.Stats, send func(Stats)) (*docker.Stats, time.Time) {
+	var latest *docker.Stats
+
+	clock := m.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	interval := time.Duration(atomic.LoadInt64(&m.interval))
+	if interval <= 0 {
 		for s := range in {
-			if i%m.interval != 0 {
-				i++
+			m.send(s, m.readTime(s, clock), send)
+		}
+
+		return nil, time.Time{}
+	}
+
+	now := clock.Now()
+
+	next := now
+	if m.aligned {
+		next = next.Truncate(interval)
+	}
+
+	next = next.Add(m.offset)
+	if !next.After(now) {
+		next = nextSample(next, now, interval)
+	}
+
+	timer := clock.After(next.Sub(clock.Now()))
+
+	// triggered are waiting for the next stats to be sent right away
+	triggered := []chan struct{}{}
+	defer func() {
+		for _, done := range triggered {
+			close(done)
+		}
+	}()
+
+	sendTriggered := func() {
+		m.send(latest, m.readTime(latest, clock), send)
+		latest = nil
+
+		for _, done := range triggered {
+			close(done)
+		}
+
+		triggered = triggered[:0]
+	}
+
+	for {
+		select {
+		case done := <-m.triggers:
+			triggered = append(triggered, done)
+			if latest == nil {
 				continue
 			}
 
-			ch <- Stats{
-				App:   m.app,
-				Task:  m.task,
-				Stats: *s,
+			sendTriggered()
+		case s, ok := <-in:
+			if !ok {
+				if latest == nil {
+					return nil, time.Time{}
+				}
+
+				read := m.readTime(latest, clock)
+				if m.aligned {
+					read = next.Add(-m.offset)
+				}
+
+				return latest, read
+			}
+
+			if latest != nil {
+				statsPool.Put(latest)
+			}
+
+			latest = s
+
+			if len(triggered) == 0 {
+				continue
+			}
+
+			sendTriggered()
+		case now := <-timer:
+			tick := next
+
+			interval = time.Duration(atomic.LoadInt64(&m.interval))
+			if interval > 0 {
+				next = nextSample(next, now, interval)
+			} else {
+				next = now.Add(time.Second)
 			}
 
-			i++
+			timer = clock.After(next.Sub(clock.Now()))
+
+			if latest == nil {
+				continue
+			}
+
+			read := m.readTime(latest, clock)
+			if m.aligned {
+				read = tick.Add(-m.offset)
+			}
+
+			m.send(latest, read, send)
+			latest = nil
 		}
-	}()
+	}
+}
 
-	return m.client.Stats(docker.StatsOptions{
-		ID:     m.id,
-		Stats:  in,
-		Stream: true,
-	})
+// sampleNow makes running monitor send its latest stats right away
+// instead of on the next tick, or as soon as they arrive if they were
+// sent already, returned channel is closed once sample is sent or
+// monitor stops, false is returned if monitor doesn't take samples
+func (m *Monitor) sampleNow(ctx context.Context) (<-chan struct{}, bool) {
+	done := make(chan struct{})
+
+	select {
+	case m.triggers <- done:
+		return done, true
+	case <-ctx.Done():
+		return nil, false
+	}
 }
 
-func extractApp(c *docker.Container) (app string) {
-	app = extractEnv(c, "CHRONOS_JOB_NAME")
-	if app != "" {
-		return
+// readTime returns read time of stats from docker, so delayed stats
+// are timestamped when they were read, local time is used for stats
+// without read time and for stats read too far from local clock
+func (m *Monitor) readTime(s *docker.Stats, clock Clock) time.Time {
+	now := clock.Now()
+
+	if s.Read.IsZero() {
+		return now
 	}
-	app = extractEnv(c, "MARATHON_APP_ID")
-	if app != "" {
-		app = strings.TrimPrefix(app, "/")
-		return
+
+	skew := now.Sub(s.Read)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if m.maxSkew <= 0 || skew <= m.maxSkew {
+		return s.Read
 	}
 
-	matches := imageNameRegex.FindStringSubmatch(c.Config.Image)
-	if matches == nil || len(matches) < 1 {
-		app = ""
-		return
+	atomic.AddUint64(&selfCounters.skewedSamples, 1)
+
+	if !m.skewed {
+		m.skewed = true
+		containerFields(m.id, m.app, m.task).Logf(LogWarn, "stats from docker are read %s away from local clock, using local time", skew)
 	}
-	app = matches[0]
 
-	return
+	return now
 }
 
-func extractEnv(c *docker.Container, envVar string) string {
-	envPrefix := envVar + "="
-	for _, e := range c.Config.Env {
-		if strings.HasPrefix(e, envPrefix) {
-			return strings.TrimPrefix(e, envPrefix)
-		}
+// send sends stats as sample read at specified time
+// and returns stats to the pool
+func (m *Monitor) send(s *docker.Stats, read time.Time, send func(Stats)) {
+	sample := newStats(m.app, m.task, m.image, s)
+	sample.Meta = m.meta
+	sample.Time = read
+
+	statsPool.Put(s)
+
+	send(sample)
+}
+
+// nextSample returns time of the next sample to send after specified
+// time, samples are sent at the same offset every interval, so delays
+// of ticks and stats stream don't accumulate
+func nextSample(next time.Time, read time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return read
 	}
 
-	return ""
+	return next.Add(interval * (read.Sub(next)/interval + 1))
 }
 
+// maxSanitized is the max number of memoized sanitized names,
+// an arbitrary memoized name is forgotten for every new one over it
+const maxSanitized = 4096
+
+var (
+	sanitized      sync.Map
+	sanitizedCount int64
+)
+
+// sanitizeForGraphite replaces characters that have special meaning
+// in graphite with underscores, results are memoized, since names
+// of the same containers are sanitized over and over
 func sanitizeForGraphite(s string) string {
-	return strings.Replace(strings.Replace(s, ".", "_", -1), "/", "_", -1)
+	if !graphiteHostile(s) {
+		return s
+	}
+
+	if r, ok := sanitized.Load(s); ok {
+		return r.(string)
+	}
+
+	b := strings.Builder{}
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if isGraphiteHostile(s[i]) {
+			b.WriteByte('_')
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+
+	r := b.String()
+
+	if _, loaded := sanitized.LoadOrStore(s, r); !loaded && atomic.AddInt64(&sanitizedCount, 1) > maxSanitized {
+		sanitized.Range(func(k, _ interface{}) bool {
+			if k == s {
+				return true
+			}
+
+			if _, ok := sanitized.LoadAndDelete(k); ok {
+				atomic.AddInt64(&sanitizedCount, -1)
+			}
+
+			return false
+		})
+	}
+
+	return r
+}
+
+// graphiteHostile checks whether string has characters to sanitize
+func graphiteHostile(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if isGraphiteHostile(s[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isGraphiteHostile checks whether character separates path
+// components or fields of graphite plaintext protocol
+func isGraphiteHostile(c byte) bool {
+	switch c {
+	case '.', '/', ' ', '\t', ':':
+		return true
+	}
+
+	return false
 }
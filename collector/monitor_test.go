@@ -1,9 +1,12 @@
 package collector
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/fsouza/go-dockerclient"
 	"testing"
+	"time"
 )
 
 type fakeMonitorDockerClient struct {
@@ -11,7 +14,7 @@ type fakeMonitorDockerClient struct {
 	env    []string
 }
 
-func (f fakeMonitorDockerClient) InspectContainer(id string) (*docker.Container, error) {
+func (f fakeMonitorDockerClient) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
 	return &docker.Container{
 		Config: &docker.Config{
 			Labels: f.labels,
@@ -144,9 +147,9 @@ func TestLabelExtraction(t *testing.T) {
 	}
 
 	for c, e := range tests {
-		m, err := NewMonitor(c, "", 1)
+		m, err := NewMonitor(context.Background(), c, "", WithInterval(1))
 		if err != nil {
-			if err != e.err {
+			if !errors.Is(err, e.err) {
 				t.Errorf("expected error %q instead of %q for %#v", e.err, err, c)
 			}
 
@@ -168,3 +171,343 @@ func TestLabelExtraction(t *testing.T) {
 	}
 
 }
+
+func TestNextSample(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		read     time.Duration
+		interval time.Duration
+		next     time.Duration
+	}{
+		{0, 10 * time.Second, 10 * time.Second},
+		{1100 * time.Millisecond, 10 * time.Second, 10 * time.Second},
+		{10050 * time.Millisecond, 10 * time.Second, 20 * time.Second},
+		{35 * time.Second, 10 * time.Second, 40 * time.Second},
+		{3 * time.Second, 0, 3 * time.Second},
+	}
+
+	for _, test := range tests {
+		next := nextSample(start, start.Add(test.read), test.interval)
+		if expected := start.Add(test.next); !next.Equal(expected) {
+			t.Errorf("expected next sample after %s with interval %s at %s, got %s", test.read, test.interval, expected, next)
+		}
+	}
+}
+
+func TestMonitorSample(t *testing.T) {
+	m := &Monitor{app: "myapp", task: "mytask", interval: int64(50 * time.Millisecond)}
+
+	in := make(chan *docker.Stats)
+	sent := make(chan Stats, 100)
+	done := make(chan struct{})
+
+	go func() {
+		m.sample(in, func(s Stats) {
+			sent <- s
+		})
+
+		close(done)
+	}()
+
+	// stream much faster than interval
+	for i := 0; i < 100; i++ {
+		in <- &docker.Stats{Read: time.Now()}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	fast := len(sent)
+	if fast < 1 || fast > 5 {
+		t.Errorf("expected about 3 samples from fast stream, got %d", fast)
+	}
+
+	// stream slower than interval
+	for i := 0; i < 2; i++ {
+		time.Sleep(200 * time.Millisecond)
+		in <- &docker.Stats{Read: time.Now()}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	close(in)
+	<-done
+
+	if slow := len(sent) - fast; slow != 2 {
+		t.Errorf("expected 2 samples from slow stream, got %d", slow)
+	}
+}
+
+func TestSanitizeForGraphite(t *testing.T) {
+	tests := map[string]string{
+		"myapp":              "myapp",
+		"my.app/web":         "my_app_web",
+		"registry:5000/app":  "registry_5000_app",
+		"app with\tspaces":   "app_with_spaces",
+		"already_sanitized_": "already_sanitized_",
+	}
+
+	for s, expected := range tests {
+		for i := 0; i < 2; i++ {
+			if r := sanitizeForGraphite(s); r != expected {
+				t.Errorf("expected %q to be sanitized to %q, got %q", s, expected, r)
+			}
+		}
+	}
+}
+
+func TestSanitizeForGraphiteEviction(t *testing.T) {
+	for i := 0; i < maxSanitized*2; i++ {
+		s := fmt.Sprintf("app:%d", i)
+		if r := sanitizeForGraphite(s); r != fmt.Sprintf("app_%d", i) {
+			t.Fatalf("expected %q to be sanitized, got %q", s, r)
+		}
+	}
+
+	n := 0
+	sanitized.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+
+	if n > maxSanitized {
+		t.Errorf("expected at most %d memoized names, got %d", maxSanitized, n)
+	}
+
+	if _, ok := sanitized.Load(fmt.Sprintf("app:%d", maxSanitized*2-1)); !ok {
+		t.Errorf("expected the latest name to stay memoized")
+	}
+}
+
+func BenchmarkSanitizeForGraphite(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		sanitizeForGraphite("registry:5000/my.app")
+	}
+}
+
+// manualClock is a clock that ticks when test tells it to
+type manualClock struct {
+	now   time.Time
+	ticks chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	return c.ticks
+}
+
+func TestMonitorOptions(t *testing.T) {
+	extractor := IdentityExtractorFunc(func(c *docker.Container) (string, string, error) {
+		return "custom", "task", nil
+	})
+
+	clock := &manualClock{now: time.Unix(1000, 0), ticks: make(chan time.Time)}
+
+	m, err := NewMonitor(context.Background(), fakeMonitorDockerClient{}, "", WithInterval(10*time.Second), WithIdentityExtractor(extractor), WithClock(clock))
+	if err != nil {
+		t.Fatalf("error creating monitor: %s", err)
+	}
+
+	if m.app != "custom" || m.task != "task" {
+		t.Errorf("expected app custom and task task from extractor, got %s and %s", m.app, m.task)
+	}
+
+	in := make(chan *docker.Stats)
+	sent := make(chan Stats, 10)
+
+	go m.sample(in, func(s Stats) {
+		sent <- s
+	})
+
+	in <- &docker.Stats{Read: time.Unix(1001, 0)}
+	in <- &docker.Stats{Read: time.Unix(1002, 0)}
+	clock.ticks <- time.Unix(1010, 0)
+
+	s := <-sent
+	if !s.Time.Equal(time.Unix(1002, 0)) {
+		t.Errorf("expected the latest stats to be sent on tick, got stats read at %s", s.Time)
+	}
+
+	close(in)
+}
+
+// streamingDockerClient streams single sample and ends stats stream with err
+type streamingDockerClient struct {
+	fakeMonitorDockerClient
+	err error
+}
+
+func (f streamingDockerClient) Stats(opts docker.StatsOptions) error {
+	defer close(opts.Stats)
+
+	opts.Stats <- &docker.Stats{Read: time.Now()}
+
+	return f.err
+}
+
+func TestMonitorRun(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{nil, ErrContainerGone},
+		{&docker.NoSuchContainer{ID: "abcdef"}, ErrContainerGone},
+		{errors.New("connection refused"), ErrDaemonUnreachable},
+	}
+
+	for _, test := range tests {
+		m := &Monitor{app: "myapp", task: "mytask", client: streamingDockerClient{err: test.err}}
+
+		// monitor is run twice to check that it can be restarted
+		for i := 0; i < 2; i++ {
+			ch := make(chan Stats, 1)
+
+			err := m.Run(context.Background(), ch)
+			if !errors.Is(err, test.kind) {
+				t.Errorf("expected error of kind %q for stream error %v, got %v", test.kind, test.err, err)
+			}
+
+			if len(ch) != 1 {
+				t.Errorf("expected single sample to be sent, got %d", len(ch))
+			}
+		}
+	}
+}
+
+func TestMonitorFlushOnExit(t *testing.T) {
+	m := &Monitor{app: "myapp", task: "mytask", interval: int64(time.Hour), client: streamingDockerClient{}}
+
+	ch := make(chan Stats, 1)
+
+	err := m.Run(context.Background(), ch)
+	if !errors.Is(err, ErrContainerGone) {
+		t.Errorf("expected error of kind %q, got %v", ErrContainerGone, err)
+	}
+
+	// stream ends long before the first tick
+	if len(ch) != 1 {
+		t.Errorf("expected stats received before container exited to be sent, got %d samples", len(ch))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ch = make(chan Stats, 1)
+
+	m.Run(ctx, ch)
+	if len(ch) != 0 {
+		t.Errorf("expected no samples to be sent after ctx is done, got %d", len(ch))
+	}
+}
+
+func TestMonitorReadTime(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	m := &Monitor{app: "myapp", task: "mytask", maxSkew: time.Minute}
+
+	tests := []struct {
+		read     time.Time
+		expected time.Time
+	}{
+		// delayed stats are timestamped when docker read them
+		{time.Unix(970, 0), time.Unix(970, 0)},
+		{time.Unix(1030, 0), time.Unix(1030, 0)},
+		{time.Unix(700, 0), clock.now},
+		{time.Unix(1100, 0), clock.now},
+		{time.Time{}, clock.now},
+	}
+
+	for _, test := range tests {
+		if read := m.readTime(&docker.Stats{Read: test.read}, clock); !read.Equal(test.expected) {
+			t.Errorf("expected stats read at %s to be timestamped at %s, got %s", test.read, test.expected, read)
+		}
+	}
+
+	m.maxSkew = 0
+	if read := m.readTime(&docker.Stats{Read: time.Unix(700, 0)}, clock); !read.Equal(time.Unix(700, 0)) {
+		t.Errorf("expected read time of docker to be trusted without max skew, got %s", read)
+	}
+}
+
+// startingDockerClient inspects containers in states in order,
+// nil state fails inspection, the last state is repeated
+type startingDockerClient struct {
+	fakeMonitorDockerClient
+	states    []*docker.State
+	inspected int
+}
+
+func (f *startingDockerClient) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	state := f.states[len(f.states)-1]
+	if f.inspected < len(f.states) {
+		state = f.states[f.inspected]
+	}
+
+	f.inspected++
+
+	if state == nil {
+		return nil, errors.New("daemon is busy")
+	}
+
+	return &docker.Container{Config: &docker.Config{Labels: map[string]string{appLabel: "myapp"}}, State: *state}, nil
+}
+
+func TestMonitorInspectRetries(t *testing.T) {
+	created := &docker.State{Status: "created"}
+	running := &docker.State{Status: "running", Running: true, Pid: 42}
+
+	client := &startingDockerClient{states: []*docker.State{nil, created, running}}
+
+	m, err := NewMonitor(context.Background(), client, "", WithInspectRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("error creating monitor: %s", err)
+	}
+
+	if m.pid != 42 || client.inspected != 3 {
+		t.Errorf("expected monitor of started container after 3 inspections, got pid %d after %d", m.pid, client.inspected)
+	}
+
+	client = &startingDockerClient{states: []*docker.State{created}}
+
+	_, err = NewMonitor(context.Background(), client, "", WithInspectRetries(2, time.Millisecond))
+	if err == nil || client.inspected != 3 {
+		t.Errorf("expected error after 3 inspections of container that doesn't start, got %v after %d", err, client.inspected)
+	}
+
+	client = &startingDockerClient{states: []*docker.State{created}}
+
+	_, err = NewMonitor(context.Background(), client, "")
+	if err != nil || client.inspected != 1 {
+		t.Errorf("expected container to be monitored after 1 inspection without retries, got %v after %d", err, client.inspected)
+	}
+
+	for _, backoff := range []time.Duration{0, -time.Second} {
+		client = &startingDockerClient{states: []*docker.State{created}}
+
+		_, err = NewMonitor(context.Background(), client, "", WithInspectRetries(3, backoff))
+		if err != nil || client.inspected != 1 {
+			t.Errorf("expected container to be monitored after 1 inspection with backoff %s, got %v after %d", backoff, err, client.inspected)
+		}
+	}
+
+	m = &Monitor{client: goneDockerClient{}, clock: realClock{}, retries: 3, backoff: time.Millisecond}
+
+	_, err = m.inspect(context.Background(), "")
+	if !containerGone(err) {
+		t.Errorf("expected removed container to be gone right away, got %v", err)
+	}
+}
+
+// goneDockerClient doesn't know any containers
+type goneDockerClient struct {
+	fakeMonitorDockerClient
+}
+
+func (goneDockerClient) InspectContainerWithContext(id string, ctx context.Context) (*docker.Container, error) {
+	return nil, &docker.NoSuchContainer{ID: id}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTWriter is responsible for publishing samples as json
+// to mqtt topics built from topic template
+type MQTTWriter struct {
+	host     string
+	template string
+	qos      byte
+	client   mqtt.Client
+}
+
+// NewMQTTWriter creates new MQTTWriter with specified hostname, broker url
+// (tcp://, ssl:// or ws://), topic template, qos and optional tls config,
+// {host}, {app} and {task} placeholders are replaced in topic template
+func NewMQTTWriter(host string, broker string, template string, qos byte, tlsConfig *tls.Config) (*MQTTWriter, error) {
+	if qos > 2 {
+		return nil, fmt.Errorf("invalid mqtt qos: %d", qos)
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(broker)
+	opts.SetClientID("collectd-docker-" + host)
+	opts.SetAutoReconnect(true)
+
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client := mqtt.NewClient(opts)
+
+	t := client.Connect()
+	if t.Wait() && t.Error() != nil {
+		return nil, t.Error()
+	}
+
+	return &MQTTWriter{
+		host:     host,
+		template: template,
+		qos:      qos,
+		client:   client,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "mqtt",
+		Usage: "json messages to mqtt topic",
+		Options: append([]WriterOption{
+			{Name: "broker", Default: "tcp://127.0.0.1:1883", Usage: "mqtt broker url, ssl:// for tls"},
+			{Name: "topic", Default: "docker_stats/{host}/{app}/{task}", Usage: "mqtt topic template"},
+			{Name: "qos", Default: "0", Usage: "mqtt qos: 0, 1 or 2"},
+		}, tlsOptions...),
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			qos := p.int("qos")
+			if qos < 0 || qos > 2 {
+				p.fail("qos", fmt.Errorf("should be 0, 1 or 2, got %d", qos))
+			}
+
+			tlsConfig := p.tlsConfig()
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewMQTTWriter(host, p.string("broker"), p.string("topic"), byte(qos), tlsConfig)
+		},
+	})
+}
+
+func (w *MQTTWriter) Write(s Stats) error {
+	b, err := json.Marshal(newJSONSample(w.host, s))
+	if err != nil {
+		return err
+	}
+
+	topic := expandTemplate(w.template, w.host, s)
+
+	t := w.client.Publish(topic, w.qos, false, b)
+	t.Wait()
+
+	return t.Error()
+}
+
+// Flush is no-op, messages are published synchronously
+func (w *MQTTWriter) Flush() error {
+	return nil
+}
+
+// Close disconnects from mqtt broker
+func (w *MQTTWriter) Close() error {
+	w.client.Disconnect(250)
+	return nil
+}
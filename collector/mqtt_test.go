@@ -0,0 +1,15 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMQTTWriterInvalidQoS(t *testing.T) {
+	for _, qos := range []string{"3", "256", "-1", "-255"} {
+		_, err := NewWriter("mqtt", "myhost", WriterOptions{"broker": "tcp://127.0.0.1:1", "qos": qos})
+		if err == nil || !strings.Contains(err.Error(), "invalid option qos") {
+			t.Errorf("expected qos %s to be rejected, got error %v", qos, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// multiWriterFlushTimeout is how long flush waits for every writer,
+// writers that are stuck are reported as failed to flush
+const multiWriterFlushTimeout = 30 * time.Second
+
+// MultiWriter is responsible for writing stats to several writers,
+// each writer has its own buffer and goroutine, so slow or failing
+// writer doesn't stall the others, samples are dropped for a writer
+// when its buffer is full
+type MultiWriter struct {
+	outputs      []*multiWriterOutput
+	flushTimeout time.Duration
+	mutex        sync.Mutex
+	wg           sync.WaitGroup
+
+	// closing is held for writing by Close, flushes hold it
+	// for reading only, so they don't block writes while waiting
+	closing sync.RWMutex
+}
+
+// multiWriterItem is either a sample, a notification or a flush request
+type multiWriterItem struct {
+	stats        Stats
+	notification *Notification
+	flush        chan error
+}
+
+type multiWriterOutput struct {
+	writer   Writer
+	ch       chan multiWriterItem
+	dropped  uint64
+	dropping bool
+}
+
+// NewMultiWriter creates new MultiWriter with specified
+// buffer size for every writer and writers to write to
+func NewMultiWriter(buffer int, writers ...Writer) *MultiWriter {
+	m := &MultiWriter{flushTimeout: multiWriterFlushTimeout}
+
+	for _, w := range writers {
+		o := &multiWriterOutput{
+			writer: w,
+			ch:     make(chan multiWriterItem, buffer),
+		}
+
+		m.outputs = append(m.outputs, o)
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+
+			for i := range o.ch {
+				if i.flush != nil {
+					i.flush <- o.writer.Flush()
+					continue
+				}
+
+				if i.notification != nil {
+					err := Notify(o.writer, *i.notification)
+					if err != nil {
+						countWriteError()
+						LogFields{"app": i.notification.App, "task": i.notification.Task}.Logf(LogError, "error writing notification with %T: %s", o.writer, err)
+					}
+
+					continue
+				}
+
+				err := o.writer.Write(i.stats)
+				if err != nil {
+					countWriteError()
+					LogFields{"app": i.stats.App, "task": i.stats.Task}.Logf(LogError, "error writing stats with %T: %s", o.writer, err)
+				}
+			}
+		}()
+	}
+
+	return m
+}
+
+// Write enqueues stats for every writer, it never blocks and never
+// returns an error, errors of individual writers are logged
+func (m *MultiWriter) Write(s Stats) error {
+	m.closing.RLock()
+	defer m.closing.RUnlock()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, o := range m.outputs {
+		select {
+		case o.ch <- multiWriterItem{stats: s}:
+			if o.dropping {
+				infof("writer %T recovered, dropped %d samples", o.writer, o.dropped)
+				o.dropping = false
+			}
+		default:
+			o.dropped++
+			if !o.dropping {
+				warnf("writer %T is too slow, dropping samples", o.writer)
+				o.dropping = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// Notify enqueues notification for every writer, it never blocks,
+// notifications are dropped for writers with full buffers
+func (m *MultiWriter) Notify(n Notification) error {
+	m.closing.RLock()
+	defer m.closing.RUnlock()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, o := range m.outputs {
+		select {
+		case o.ch <- multiWriterItem{notification: &n}:
+		default:
+			o.dropped++
+			warnf("writer %T is too slow, dropping notification of %s/%s", o.writer, n.App, n.Task)
+		}
+	}
+
+	return nil
+}
+
+// Flush waits for buffered stats to be written and flushes every
+// writer, writers that don't flush in time are not waited for and
+// reported as failed, the first error is returned
+func (m *MultiWriter) Flush() error {
+	m.closing.RLock()
+	defer m.closing.RUnlock()
+
+	// writers are flushed concurrently, so one stuck
+	// writer doesn't eat into timeout of the others
+	errs := make(chan error, len(m.outputs))
+	for _, o := range m.outputs {
+		go func(o *multiWriterOutput) {
+			errs <- m.flushOutput(o)
+		}(o)
+	}
+
+	var err error
+	for range m.outputs {
+		if ferr := <-errs; err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
+
+// flushOutput queues flush for writer and waits for it
+// until flush timeout runs out
+func (m *MultiWriter) flushOutput(o *multiWriterOutput) error {
+	timeout := time.NewTimer(m.flushTimeout)
+	defer timeout.Stop()
+
+	result := make(chan error, 1)
+
+	select {
+	case o.ch <- multiWriterItem{flush: result}:
+	case <-timeout.C:
+		return fmt.Errorf("writer %T is too slow to flush", o.writer)
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-timeout.C:
+		return fmt.Errorf("writer %T didn't flush in %s", o.writer, m.flushTimeout)
+	}
+}
+
+// Close waits for buffered stats to be written and closes
+// every writer, the first error is returned
+func (m *MultiWriter) Close() error {
+	m.closing.Lock()
+	defer m.closing.Unlock()
+
+	for _, o := range m.outputs {
+		close(o.ch)
+	}
+
+	m.wg.Wait()
+
+	var err error
+	for _, o := range m.outputs {
+		cerr := o.writer.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (w blockingWriter) Write(s Stats) error {
+	<-w.unblock
+	return nil
+}
+
+func (w blockingWriter) Flush() error {
+	return nil
+}
+
+func (w blockingWriter) Close() error {
+	return nil
+}
+
+type countingWriter struct {
+	ch chan Stats
+}
+
+func (w countingWriter) Write(s Stats) error {
+	w.ch <- s
+	return nil
+}
+
+func TestMultiWriterSlowWriter(t *testing.T) {
+	slow := blockingWriter{unblock: make(chan struct{})}
+	fast := countingWriter{ch: make(chan Stats, 10)}
+
+	m := NewMultiWriter(1, slow, fast)
+
+	for i := 0; i < 5; i++ {
+		m.Write(Stats{App: "myapp"})
+		<-fast.ch
+	}
+
+	if m.outputs[0].dropped == 0 {
+		t.Errorf("expected samples to be dropped for slow writer")
+	}
+
+	if m.outputs[1].dropped != 0 {
+		t.Errorf("expected no samples to be dropped for fast writer, got %d", m.outputs[1].dropped)
+	}
+
+	close(slow.unblock)
+	m.Close()
+}
+
+func TestMultiWriterStuckWriter(t *testing.T) {
+	slow := blockingWriter{unblock: make(chan struct{})}
+	fast := &recordingWriter{}
+
+	m := NewMultiWriter(1, slow, fast)
+	m.flushTimeout = 50 * time.Millisecond
+
+	// the first sample blocks slow writer, the second fills its buffer
+	m.Write(Stats{App: "myapp"})
+	time.Sleep(10 * time.Millisecond)
+	m.Write(Stats{App: "myapp"})
+
+	done := make(chan error)
+	go func() {
+		m.Notify(Notification{App: "myapp", Task: "mytask"})
+		done <- m.Flush()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error flushing stuck writer")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected stuck writer not to block notifications and flushes")
+	}
+
+	close(slow.unblock)
+	m.Close()
+
+	if len(fast.notified) != 1 || fast.flushes != 1 {
+		t.Errorf("expected fast writer to get notification and flush, got %d and %d", len(fast.notified), fast.flushes)
+	}
+
+	// flush is queued for writer stuck with empty buffer
+	slow = blockingWriter{unblock: make(chan struct{})}
+	m = NewMultiWriter(1, slow)
+	m.flushTimeout = 50 * time.Millisecond
+
+	m.Write(Stats{App: "myapp"})
+	time.Sleep(10 * time.Millisecond)
+
+	if err := m.Flush(); err == nil {
+		t.Error("expected error flushing writer that doesn't flush in time")
+	}
+
+	close(slow.unblock)
+	m.Close()
+}
+
+func (w countingWriter) Flush() error {
+	return nil
+}
+
+func (w countingWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package collector
+
+// NamespaceWriter is responsible for prepending namespace to app names
+// of samples and notifications before they reach wrapped writer, so
+// metrics of several docker endpoints don't collide in backend, metrics
+// are not renamed, so the rest of pipeline works as usual
+type NamespaceWriter struct {
+	writer    Writer
+	namespace string
+}
+
+// NewNamespaceWriter creates new NamespaceWriter on top
+// of specified writer with namespace, like edge1.
+func NewNamespaceWriter(writer Writer, namespace string) NamespaceWriter {
+	return NamespaceWriter{
+		writer:    writer,
+		namespace: namespace,
+	}
+}
+
+func (w NamespaceWriter) Write(s Stats) error {
+	s.App = w.namespace + s.App
+	return w.writer.Write(s)
+}
+
+func (w NamespaceWriter) Notify(n Notification) error {
+	n.App = w.namespace + n.App
+	return Notify(w.writer, n)
+}
+
+func (w NamespaceWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w NamespaceWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,33 @@
+package collector
+
+import "testing"
+
+func TestNamespaceWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewNamespaceWriter(r, "edge1.")
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.CPU.Total = 42
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Notify(Notification{App: "myapp", Task: "mytask", Event: "die"})
+	if err != nil {
+		t.Fatalf("error writing notification: %s", err)
+	}
+
+	if len(r.written) != 1 || r.written[0].App != "edge1.myapp" {
+		t.Fatalf("expected sample of namespaced app, got %v", r.written)
+	}
+
+	if r.written[0].MetricsOnly || r.written[0].CPU.Total != 42 {
+		t.Errorf("expected container metrics to be kept, got %v", r.written[0])
+	}
+
+	if len(r.notified) != 1 || r.notified[0].App != "edge1.myapp" {
+		t.Errorf("expected notification of namespaced app, got %v", r.notified)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSWriter is responsible for publishing samples as json
+// to nats subjects named <prefix>.<app>.<task>
+type NATSWriter struct {
+	host   string
+	prefix string
+	conn   *nats.Conn
+}
+
+// NewNATSWriter creates new NATSWriter with specified
+// hostname, nats server url and subject prefix
+func NewNATSWriter(host string, url string, prefix string) (*NATSWriter, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSWriter{
+		host:   host,
+		prefix: prefix,
+		conn:   conn,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "nats",
+		Usage: "json messages to <prefix>.<app>.<task> nats subjects",
+		Options: []WriterOption{
+			{Name: "url", Default: "nats://127.0.0.1:4222", Usage: "nats server url"},
+			{Name: "prefix", Default: "metrics.docker", Usage: "prefix for nats subjects"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			return NewNATSWriter(host, o["url"], o["prefix"])
+		},
+	})
+}
+
+func (w *NATSWriter) Write(s Stats) error {
+	b, err := json.Marshal(newJSONSample(w.host, s))
+	if err != nil {
+		return err
+	}
+
+	return w.conn.Publish(w.prefix+"."+s.App+"."+s.Task, b)
+}
+
+// Flush waits for pending messages to be processed by nats server
+func (w *NATSWriter) Flush() error {
+	return w.conn.Flush()
+}
+
+// Close flushes pending messages and closes nats connection
+func (w *NATSWriter) Close() error {
+	err := w.conn.Flush()
+	w.conn.Close()
+	return err
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// serveNATS speaks just enough of nats protocol to accept
+// a single client and report messages it publishes
+func serveNATS(t *testing.T, l net.Listener, messages chan<- natsMessage) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "INFO {\"server_id\":\"test\",\"version\":\"2.0.0\",\"max_payload\":1048576}\r\n")
+	if err != nil {
+		t.Errorf("error writing nats info: %s", err)
+		return
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				t.Errorf("malformed nats publish: %q", line)
+				return
+			}
+
+			payload := make([]byte, size+2)
+			_, err = io.ReadFull(r, payload)
+			if err != nil {
+				return
+			}
+
+			messages <- natsMessage{subject: fields[1], payload: payload[:size]}
+		}
+	}
+}
+
+func TestNATSWriter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	messages := make(chan natsMessage, 1)
+	go serveNATS(t, l, messages)
+
+	w, err := NewNATSWriter("myhost", "nats://"+l.Addr().String(), "metrics.docker")
+	if err != nil {
+		t.Fatalf("error connecting to nats: %s", err)
+	}
+
+	defer w.Close()
+
+	s := Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)}
+	s.CPU.Total = 42
+
+	err = w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("error flushing writer: %s", err)
+	}
+
+	select {
+	case m := <-messages:
+		if m.subject != "metrics.docker.myapp.mytask" {
+			t.Errorf("expected subject metrics.docker.myapp.mytask, got %s", m.subject)
+		}
+
+		sample := jsonSample{}
+		err := json.Unmarshal(m.payload, &sample)
+		if err != nil {
+			t.Fatalf("error decoding message: %s", err)
+		}
+
+		if sample.Host != "myhost" || sample.Timestamp != 1431000000 || sample.Metrics["cpu.total"] != 42 {
+			t.Errorf("unexpected sample: %#v", sample)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected message to be published")
+	}
+}
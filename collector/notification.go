@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// Severity is severity of notification, it matches collectd severities
+type Severity int
+
+const (
+	// SeverityOkay is used for notifications about normal operation
+	SeverityOkay Severity = iota
+	// SeverityWarning is used for notifications that might need attention
+	SeverityWarning
+	// SeverityFailure is used for notifications that need attention
+	SeverityFailure
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityOkay:
+		return "okay"
+	case SeverityWarning:
+		return "warning"
+	case SeverityFailure:
+		return "failure"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Notification is an event of a task, like container start or death,
+// that is written along with metrics by writers that support it
+type Notification struct {
+	App      string
+	Task     string
+	Time     time.Time
+	Severity Severity
+	// Event is the name of event, like start or die
+	Event   string
+	Message string
+}
+
+// Notifier is implemented by writers that can write notifications,
+// wrapping writers implement it to pass notifications through
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Notify writes notification with writer if it implements Notifier,
+// notifications are silently skipped for writers that don't
+func Notify(w Writer, n Notification) error {
+	if notifier, ok := w.(Notifier); ok {
+		return notifier.Notify(n)
+	}
+
+	return nil
+}
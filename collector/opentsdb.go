@@ -0,0 +1,229 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenTSDBWriter is responsible for writing data
+// to wrapped writer in OpenTSDB telnet put format
+type OpenTSDBWriter struct {
+	host   string
+	writer io.Writer
+}
+
+// NewOpenTSDBWriter creates new OpenTSDBWriter
+// with specified hostname and writer
+func NewOpenTSDBWriter(host string, writer io.Writer) OpenTSDBWriter {
+	return OpenTSDBWriter{
+		host:   host,
+		writer: writer,
+	}
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "opentsdb",
+		Usage: "opentsdb telnet put protocol over tcp",
+		Options: append([]WriterOption{
+			{Name: "addr", Usage: "opentsdb host:port"},
+		}, tlsOptions...),
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			tlsConfig := p.tlsConfig()
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			conn, err := dialRedialConn("tcp", p.string("addr"), tlsConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			return newStreamWriter(conn, conn, func(w io.Writer) Writer {
+				return NewOpenTSDBWriter(host, w)
+			}), nil
+		},
+	})
+
+	RegisterWriter(WriterRegistration{
+		Name:  "opentsdb-http",
+		Usage: "opentsdb /api/put http endpoint",
+		Options: []WriterOption{
+			{Name: "url", Usage: "opentsdb base url, like http://opentsdb:4242"},
+			{Name: "timeout", Default: "10s", Usage: "timeout of put requests"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			timeout := p.duration("timeout")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewOpenTSDBHTTPWriter(host, p.string("url"), timeout), nil
+		},
+	})
+}
+
+func (w OpenTSDBWriter) Write(s Stats) error {
+	t := s.Time.Unix()
+	b := getBuffer()
+	defer putBuffer(b)
+
+	eachMetric(&s, func(k string, v uint64) {
+		b.WriteString("put docker_stats.")
+		b.WriteString(k)
+		b.WriteByte(' ')
+		appendInt(b, t)
+		b.WriteByte(' ')
+		appendUint(b, v)
+		b.WriteString(" host=")
+		b.WriteString(w.host)
+		b.WriteString(" app=")
+		b.WriteString(s.App)
+		b.WriteString(" task=")
+		b.WriteString(s.Task)
+		for k, v := range s.Meta {
+			if reservedOpenTSDBTag(k) {
+				continue
+			}
+
+			b.WriteByte(' ')
+			appendOpenTSDBTag(b, k)
+			b.WriteByte('=')
+			appendOpenTSDBTag(b, v)
+		}
+		b.WriteByte('\n')
+	})
+
+	_, err := w.writer.Write(b.Bytes())
+	return err
+}
+
+// Flush is no-op, OpenTSDBWriter doesn't buffer
+func (w OpenTSDBWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w OpenTSDBWriter) Close() error {
+	return nil
+}
+
+// openTSDBDataPoint is a single data point for OpenTSDB http api
+type openTSDBDataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     uint64            `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// OpenTSDBHTTPWriter is responsible for writing data
+// to OpenTSDB with /api/put http endpoint
+type OpenTSDBHTTPWriter struct {
+	host   string
+	url    string
+	client *http.Client
+}
+
+// NewOpenTSDBHTTPWriter creates new OpenTSDBHTTPWriter with specified
+// hostname, base url of OpenTSDB, like http://opentsdb:4242, and timeout
+// of put requests
+func NewOpenTSDBHTTPWriter(host string, url string, timeout time.Duration) OpenTSDBHTTPWriter {
+	return OpenTSDBHTTPWriter{
+		host:   host,
+		url:    strings.TrimSuffix(url, "/") + "/api/put",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w OpenTSDBHTTPWriter) Write(s Stats) error {
+	t := s.Time.Unix()
+	tags := map[string]string{}
+	for k, v := range s.Meta {
+		if reservedOpenTSDBTag(k) {
+			continue
+		}
+
+		tags[sanitizeOpenTSDBTag(k)] = sanitizeOpenTSDBTag(v)
+	}
+
+	tags["host"] = w.host
+	tags["app"] = s.App
+	tags["task"] = s.Task
+
+	points := []openTSDBDataPoint{}
+	for k, v := range intMetrics(s) {
+		points = append(points, openTSDBDataPoint{
+			Metric:    "docker_stats." + k,
+			Timestamp: t,
+			Value:     v,
+			Tags:      tags,
+		})
+	}
+
+	body, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from opentsdb: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Flush is no-op, OpenTSDBHTTPWriter doesn't buffer
+func (w OpenTSDBHTTPWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, OpenTSDBHTTPWriter has nothing to release
+func (w OpenTSDBHTTPWriter) Close() error {
+	return nil
+}
+
+// reservedOpenTSDBTag checks whether metadata key is one of tags
+// written for every metric, opentsdb rejects duplicate tag keys
+func reservedOpenTSDBTag(k string) bool {
+	return k == "host" || k == "app" || k == "task"
+}
+
+// isOpenTSDBTagChar checks whether character is allowed in opentsdb tags
+func isOpenTSDBTagChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '/'
+}
+
+// appendOpenTSDBTag writes tag key or value with characters
+// not allowed in opentsdb tags replaced with underscores
+func appendOpenTSDBTag(b *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if isOpenTSDBTagChar(s[i]) {
+			b.WriteByte(s[i])
+		} else {
+			b.WriteByte('_')
+		}
+	}
+}
+
+// sanitizeOpenTSDBTag replaces characters not allowed
+// in opentsdb tags with underscores
+func sanitizeOpenTSDBTag(s string) string {
+	b := bytes.Buffer{}
+	appendOpenTSDBTag(&b, s)
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenTSDBWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewOpenTSDBWriter("myhost", b)
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 42
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(intMetrics(s)) {
+		t.Errorf("expected %d lines, got %d", len(intMetrics(s)), len(lines))
+	}
+
+	expected := "put docker_stats.cpu.total 1431000000 42 host=myhost app=myapp task=mytask"
+	for _, l := range lines {
+		if l == expected {
+			return
+		}
+	}
+
+	t.Errorf("expected line %q in output:\n%s", expected, b.String())
+}
+
+func TestOpenTSDBWriterMeta(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewOpenTSDBWriter("myhost", b)
+
+	s := Stats{App: "myapp", Task: "mytask", Meta: map[string]string{"schedule": "R/2015-01-01T00:00:00Z/PT1H"}}
+	s.Time = time.Unix(1431000000, 0)
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := " task=mytask schedule=R/2015-01-01T00_00_00Z/PT1H\n"
+	if !strings.HasSuffix(b.String(), expected) {
+		t.Errorf("expected metadata to be written as sanitized tags, got:\n%s", b.String())
+	}
+}
+
+func TestOpenTSDBWriterReservedMeta(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewOpenTSDBWriter("myhost", b)
+
+	s := Stats{App: "myapp", Task: "mytask", Meta: map[string]string{"host": "label", "app": "label", "task": "label"}}
+	s.Time = time.Unix(1431000000, 0)
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if strings.Contains(b.String(), "=label") {
+		t.Errorf("expected metadata not to duplicate host, app and task tags, got:\n%s", b.String())
+	}
+}
+
+func TestOpenTSDBHTTPWriter(t *testing.T) {
+	points := []openTSDBDataPoint{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/put" {
+			t.Errorf("expected request to /api/put, got %s", r.URL.Path)
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&points)
+		if err != nil {
+			t.Errorf("error decoding data points: %s", err)
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer server.Close()
+
+	w := NewOpenTSDBHTTPWriter("myhost", server.URL+"/", time.Second)
+
+	s := Stats{App: "myapp", Task: "mytask", Meta: map[string]string{"host": "label", "team": "infra"}}
+	s.Time = time.Unix(1431000000, 0)
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(points) != len(intMetrics(s)) {
+		t.Fatalf("expected %d data points, got %d", len(intMetrics(s)), len(points))
+	}
+
+	expected := map[string]string{"host": "myhost", "app": "myapp", "task": "mytask", "team": "infra"}
+	for k, v := range expected {
+		if points[0].Tags[k] != v {
+			t.Errorf("expected tag %s=%s, got tags %v", k, v, points[0].Tags)
+		}
+	}
+}
+
+func TestOpenTSDBHTTPWriterTimeout(t *testing.T) {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+
+	defer server.Close()
+	defer close(done)
+
+	w := NewOpenTSDBHTTPWriter("myhost", server.URL, 50*time.Millisecond)
+
+	started := time.Now()
+
+	err := w.Write(Stats{App: "myapp", Task: "mytask", Time: time.Now()})
+	if err == nil {
+		t.Fatal("expected error writing to hung opentsdb")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected write to time out, took %s", elapsed)
+	}
+}
+
+func BenchmarkOpenTSDBWriter(b *testing.B) {
+	benchmarkWriter(b, NewOpenTSDBWriter("myhost", ioutil.Discard))
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pauseSet keeps containers and apps with paused monitoring
+type pauseSet struct {
+	mutex      sync.Mutex
+	containers map[string]bool
+	apps       map[string]bool
+}
+
+// PauseContainer pauses monitoring of containers with ids starting
+// with prefix: their samples and notifications are dropped until
+// monitoring is resumed, stats streams keep running, so resuming
+// is instant, pauses are not kept when collector restarts
+func (c *Collector) PauseContainer(prefix string) {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	if c.pauses.containers == nil {
+		c.pauses.containers = map[string]bool{}
+	}
+
+	c.pauses.containers[prefix] = true
+}
+
+// ResumeContainer resumes monitoring of containers paused with prefix,
+// false is returned if they were not paused
+func (c *Collector) ResumeContainer(prefix string) bool {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	paused := c.pauses.containers[prefix]
+	delete(c.pauses.containers, prefix)
+
+	return paused
+}
+
+// PauseApp pauses monitoring of all containers of app like PauseContainer
+func (c *Collector) PauseApp(app string) {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	if c.pauses.apps == nil {
+		c.pauses.apps = map[string]bool{}
+	}
+
+	c.pauses.apps[app] = true
+}
+
+// ResumeApp resumes monitoring of containers of app,
+// false is returned if app was not paused
+func (c *Collector) ResumeApp(app string) bool {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	paused := c.pauses.apps[app]
+	delete(c.pauses.apps, app)
+
+	return paused
+}
+
+// Paused returns sorted prefixes of ids of containers
+// and names of apps with paused monitoring
+func (c *Collector) Paused() ([]string, []string) {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	containers := make([]string, 0, len(c.pauses.containers))
+	for prefix := range c.pauses.containers {
+		containers = append(containers, prefix)
+	}
+
+	apps := make([]string, 0, len(c.pauses.apps))
+	for app := range c.pauses.apps {
+		apps = append(apps, app)
+	}
+
+	sort.Strings(containers)
+	sort.Strings(apps)
+
+	return containers, apps
+}
+
+// paused checks whether monitoring of container with id or its app is paused
+func (c *Collector) paused(id string, app string) bool {
+	c.pauses.mutex.Lock()
+	defer c.pauses.mutex.Unlock()
+
+	if c.pauses.apps[app] {
+		return true
+	}
+
+	for prefix := range c.pauses.containers {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPause(t *testing.T) {
+	c := &Collector{}
+
+	c.PauseContainer("0123")
+	c.PauseApp("db")
+
+	tests := []struct {
+		id     string
+		app    string
+		paused bool
+	}{
+		{"0123abcd", "web", true},
+		{"4567cdef", "web", false},
+		{"4567cdef", "db", true},
+	}
+
+	for _, test := range tests {
+		if paused := c.paused(test.id, test.app); paused != test.paused {
+			t.Errorf("expected %s of %s to be paused: %v, got %v", test.id, test.app, test.paused, paused)
+		}
+	}
+
+	containers, apps := c.Paused()
+	if !reflect.DeepEqual(containers, []string{"0123"}) || !reflect.DeepEqual(apps, []string{"db"}) {
+		t.Errorf("expected paused container 0123 and app db, got %v and %v", containers, apps)
+	}
+
+	if !c.ResumeContainer("0123") || !c.ResumeApp("db") {
+		t.Errorf("expected paused container and app to be resumed")
+	}
+
+	if c.ResumeApp("db") {
+		t.Errorf("expected app that is not paused not to be resumed")
+	}
+
+	if c.paused("0123abcd", "db") {
+		t.Errorf("expected nothing to be paused after resuming")
+	}
+}
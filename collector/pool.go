@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// maxPooledBuffer is the max capacity of buffers that are reused,
+// so rare huge samples don't keep memory forever
+const maxPooledBuffer = 64 << 10
+
+// statsPool reuses docker stats decoded from stats streams,
+// monitors copy them into samples and return them right away
+var statsPool = sync.Pool{
+	New: func() interface{} {
+		return &docker.Stats{}
+	},
+}
+
+// payloadPool reuses payloads that stats are decoded into
+var payloadPool = sync.Pool{
+	New: func() interface{} {
+		return &statsPayload{}
+	},
+}
+
+// bufferPool reuses buffers that writers format samples into
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// getBuffer returns empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	b := bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer returns buffer to the pool,
+// buffer should not be used afterwards
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() <= maxPooledBuffer {
+		bufferPool.Put(b)
+	}
+}
+
+// typedSource is value of data source of collectd type
+type typedSource struct {
+	name  string
+	value uint64
+}
+
+// typedValues groups metrics by collectd type and type instance for
+// typed collectd writer, groups are kept in order they are first seen
+type typedValues struct {
+	index   map[collectdValue]int
+	keys    []collectdValue
+	sources [][]typedSource
+}
+
+// add sets value of data source of type and type instance of cv
+func (t *typedValues) add(cv collectdValue, ds string, value uint64) {
+	i, ok := t.index[cv]
+	if !ok {
+		i = len(t.keys)
+		t.index[cv] = i
+		t.keys = append(t.keys, cv)
+
+		if len(t.sources) <= i {
+			t.sources = append(t.sources, nil)
+		}
+
+		t.sources[i] = t.sources[i][:0]
+	}
+
+	t.sources[i] = append(t.sources[i], typedSource{name: ds, value: value})
+}
+
+// typedValuesPool reuses groups of typed collectd writer
+var typedValuesPool = sync.Pool{
+	New: func() interface{} {
+		return &typedValues{index: map[collectdValue]int{}}
+	},
+}
+
+// getTypedValues returns empty groups from the pool
+func getTypedValues() *typedValues {
+	return typedValuesPool.Get().(*typedValues)
+}
+
+// putTypedValues empties groups and returns them to the
+// pool, groups should not be used afterwards
+func putTypedValues(t *typedValues) {
+	for cv := range t.index {
+		delete(t.index, cv)
+	}
+
+	t.keys = t.keys[:0]
+	typedValuesPool.Put(t)
+}
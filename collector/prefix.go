@@ -0,0 +1,49 @@
+package collector
+
+// PrefixWriter is responsible for adding prefix and suffix to names
+// of all metrics before they reach wrapped writer, so metrics can be
+// namespaced per environment without rewriting them in backend, only
+// metric names are changed, writers still put host, app and task
+// before them, collectd writer puts prefix into type instance
+type PrefixWriter struct {
+	writer Writer
+	prefix string
+	suffix string
+}
+
+// NewPrefixWriter creates new PrefixWriter on top of specified
+// writer with prefix and suffix for metric names
+func NewPrefixWriter(writer Writer, prefix string, suffix string) PrefixWriter {
+	return PrefixWriter{
+		writer: writer,
+		prefix: prefix,
+		suffix: suffix,
+	}
+}
+
+// Write passes sample with renamed metrics to wrapped writer,
+// container metrics are renamed as well and sample is written as
+// carrying metrics only, so writers don't add original names
+func (w PrefixWriter) Write(s Stats) error {
+	metrics := map[string]uint64{}
+	for k, v := range intMetrics(s) {
+		metrics[w.prefix+k+w.suffix] = v
+	}
+
+	s.Metrics = metrics
+	s.MetricsOnly = true
+
+	return w.writer.Write(s)
+}
+
+func (w PrefixWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w PrefixWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w PrefixWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewPrefixWriter(r, "containers.dc1.", ".raw")
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.CPU.Total = 42
+	s.Metrics = map[string]uint64{"custom": 3}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if len(r.written) != 1 {
+		t.Fatalf("expected 1 written sample, got %d", len(r.written))
+	}
+
+	metrics := intMetrics(r.written[0])
+
+	if metrics["containers.dc1.cpu.total.raw"] != 42 {
+		t.Errorf("expected renamed cpu.total to be 42, got %v", metrics)
+	}
+
+	if metrics["containers.dc1.custom.raw"] != 3 {
+		t.Errorf("expected renamed custom to be 3, got %v", metrics)
+	}
+
+	if _, ok := metrics["cpu.total"]; ok {
+		t.Errorf("unexpected original metric name in %v", metrics)
+	}
+}
+
+func TestPrefixWriterCollectd(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewPrefixWriter(NewCollectdWriter("myhost", b), "containers.dc1.", "")
+
+	s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+	s.Time = time.Unix(1431000000, 0)
+	s.Metrics = map[string]uint64{"custom": 3}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := "PUTVAL myhost/docker_stats.myapp.mytask/gauge-containers.dc1.custom 1431000000:3\n"
+	if b.String() != expected {
+		t.Errorf("expected prefix in type instance %q, got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// probeLabel is the label with command that is executed
+// in container every interval to get custom metrics
+const probeLabel = "collectd_docker_probe"
+
+// probeFamily is the family of metrics of probes
+const probeFamily = "probe"
+
+// maxProbeOutput is max size of output of probe that is parsed
+const maxProbeOutput = 64 << 10
+
+// SetExecProbes enables probes that containers declare with
+// collectd_docker_probe label, the command is executed in container
+// with docker exec every interval and its key value output becomes
+// metrics of app and task, it should be called before Run
+func (c *Collector) SetExecProbes(enabled bool) {
+	c.probes = enabled
+}
+
+// probe calls f every interval and sends metrics it returns until
+// ctx is done, f has to finish within interval, name of probe is logged
+func (c *Collector) probe(ctx context.Context, m *Monitor, name string, f func(ctx context.Context) (map[string]uint64, error)) {
+	fields := containerFields(m.id, m.app, m.task)
+
+	for {
+		interval := time.Duration(atomic.LoadInt64(&m.interval))
+		if interval < time.Second {
+			interval = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		t := time.Now()
+
+		probeCtx, cancel := context.WithTimeout(ctx, interval)
+		metrics, err := f(probeCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() == nil {
+				fields.Logf(LogWarn, "error running %s: %s", name, err)
+			}
+
+			continue
+		}
+
+		if len(metrics) > 0 && c.filtered(m.app) && !c.paused(m.id, m.app) {
+			c.send(Stats{App: m.app, Task: m.task, Meta: m.meta, Time: t, Metrics: metrics, MetricsOnly: true})
+		}
+	}
+}
+
+// execProbe executes command in container and parses its
+// output, command has to exit with zero status
+func (c *Collector) execProbe(ctx context.Context, id string, cmd []string) (map[string]uint64, error) {
+	exec, err := c.client.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context:      ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+
+	err = c.client.StartExec(exec.ID, docker.StartExecOptions{
+		OutputStream: out,
+		ErrorStream:  ioutil.Discard,
+		Context:      ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := c.client.InspectExec(exec.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if inspect.ExitCode != 0 {
+		return nil, fmt.Errorf("probe exited with status %d", inspect.ExitCode)
+	}
+
+	return parseProbeOutput(out)
+}
+
+// parseProbeOutput parses lines of key and non-negative value separated
+// by whitespace into metrics of probe family, like probe.requests,
+// empty lines and lines starting with # are skipped, fractions of
+// values are dropped
+func parseProbeOutput(r io.Reader) (map[string]uint64, error) {
+	metrics := map[string]uint64{}
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxProbeOutput))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of probe output is not key and value: %q", n, line)
+		}
+
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("line %d of probe output has invalid value: %q", n, line)
+		}
+
+		metrics[probeFamily+"."+sanitizeForGraphite(fields[0])] = uint64(value)
+	}
+
+	return metrics, scanner.Err()
+}
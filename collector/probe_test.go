@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProbeOutput(t *testing.T) {
+	metrics, err := parseProbeOutput(strings.NewReader(`
+# connections of the pool
+connections 12
+queue_depth	3.7
+
+lag.seconds 0
+`))
+	if err != nil {
+		t.Fatalf("error parsing probe output: %s", err)
+	}
+
+	expected := map[string]uint64{
+		"probe.connections": 12,
+		"probe.queue_depth": 3,
+		"probe.lag_seconds": 0,
+	}
+
+	if len(metrics) != len(expected) {
+		t.Errorf("expected %d metrics, got %v", len(expected), metrics)
+	}
+
+	for k, v := range expected {
+		if value, ok := metrics[k]; !ok || value != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	for _, invalid := range []string{"connections", "connections twelve", "connections -1", "a b c"} {
+		if _, err := parseProbeOutput(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errMalformedProto is returned for protobuf messages that cannot be parsed
+var errMalformedProto = errors.New("malformed protobuf message")
+
+// protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func appendProtoTag(b []byte, field uint64, wire uint64) []byte {
+	return appendUvarint(b, field<<3|wire)
+}
+
+func appendProtoVarint(b []byte, field uint64, v uint64) []byte {
+	b = appendProtoTag(b, field, protoVarint)
+	return appendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field uint64, v []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(b, buf[:n]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, v)
+	return append(b, buf[:n]...)
+}
+
+// walkProto calls fn for every top level field of protobuf message,
+// v is set for varint fields and b for length delimited fields
+func walkProto(msg []byte, fn func(field uint64, v uint64, b []byte)) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errMalformedProto
+		}
+
+		msg = msg[n:]
+
+		switch key & 7 {
+		case protoVarint:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return errMalformedProto
+			}
+
+			fn(key>>3, v, nil)
+			msg = msg[n:]
+		case protoBytes:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return errMalformedProto
+			}
+
+			fn(key>>3, 0, msg[n:n+int(l)])
+			msg = msg[n+int(l):]
+		case protoFixed32:
+			if len(msg) < 4 {
+				return errMalformedProto
+			}
+
+			fn(key>>3, uint64(binary.LittleEndian.Uint32(msg)), nil)
+			msg = msg[4:]
+		case protoFixed64:
+			if len(msg) < 8 {
+				return errMalformedProto
+			}
+
+			fn(key>>3, binary.LittleEndian.Uint64(msg), nil)
+			msg = msg[8:]
+		default:
+			return errMalformedProto
+		}
+	}
+
+	return nil
+}
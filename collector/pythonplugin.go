@@ -0,0 +1,67 @@
+package collector
+
+import "io"
+
+// PythonPluginNaming is the layout of collectd identifiers of python
+// docker plugin of collectd, plugin instance was container name there
+var PythonPluginNaming = CollectdNaming{
+	Plugin:         "docker",
+	PluginInstance: "{app}.{task}",
+	TypeInstance:   "{metric}",
+}
+
+// PythonPluginTypesDB has definitions of collectd types used
+// for container metrics, copied from types.db of python docker plugin
+var PythonPluginTypesDB = TypesDB{
+	"gauge":         {{"value", "GAUGE"}},
+	"cpu.usage":     {{"total", "DERIVE"}, {"kernel", "DERIVE"}, {"user", "DERIVE"}, {"system", "DERIVE"}},
+	"memory.usage":  {{"limit", "GAUGE"}, {"max", "GAUGE"}, {"total", "GAUGE"}},
+	"memory.stats":  {{"value", "GAUGE"}},
+	"network.usage": {{"rx_bytes", "DERIVE"}, {"rx_dropped", "DERIVE"}, {"rx_errors", "DERIVE"}, {"rx_packets", "DERIVE"}, {"tx_bytes", "DERIVE"}, {"tx_dropped", "DERIVE"}, {"tx_errors", "DERIVE"}, {"tx_packets", "DERIVE"}},
+}
+
+// pythonPluginValues maps container metrics to collectd values
+// that python docker plugin writes, memory stats are named like
+// docker names them, cpu time of the host is unknown
+var pythonPluginValues = map[string]collectdValue{
+	"cpu.total":  {"cpu.usage", "", "total"},
+	"cpu.system": {"cpu.usage", "", "kernel"},
+	"cpu.user":   {"cpu.usage", "", "user"},
+
+	"memory.limit": {"memory.usage", "", "limit"},
+	"memory.max":   {"memory.usage", "", "max"},
+	"memory.usage": {"memory.usage", "", "total"},
+
+	"memory.active_anon":   {"memory.stats", "total_active_anon", "value"},
+	"memory.active_file":   {"memory.stats", "total_active_file", "value"},
+	"memory.cache":         {"memory.stats", "total_cache", "value"},
+	"memory.inactive_anon": {"memory.stats", "total_inactive_anon", "value"},
+	"memory.inactive_file": {"memory.stats", "total_inactive_file", "value"},
+	"memory.mapped_file":   {"memory.stats", "total_mapped_file", "value"},
+	"memory.pg_fault":      {"memory.stats", "total_pgfault", "value"},
+	"memory.pg_in":         {"memory.stats", "total_pgpgin", "value"},
+	"memory.pg_out":        {"memory.stats", "total_pgpgout", "value"},
+	"memory.rss":           {"memory.stats", "total_rss", "value"},
+	"memory.rss_huge":      {"memory.stats", "total_rss_huge", "value"},
+	"memory.unevictable":   {"memory.stats", "total_unevictable", "value"},
+	"memory.writeback":     {"memory.stats", "total_writeback", "value"},
+
+	"net.rx_bytes":   {"network.usage", "", "rx_bytes"},
+	"net.rx_dropped": {"network.usage", "", "rx_dropped"},
+	"net.rx_errors":  {"network.usage", "", "rx_errors"},
+	"net.rx_packets": {"network.usage", "", "rx_packets"},
+	"net.tx_bytes":   {"network.usage", "", "tx_bytes"},
+	"net.tx_dropped": {"network.usage", "", "tx_dropped"},
+	"net.tx_errors":  {"network.usage", "", "tx_errors"},
+	"net.tx_packets": {"network.usage", "", "tx_packets"},
+}
+
+// NewPythonPluginCollectdWriter creates new CollectdWriter with specified
+// hostname and writer that writes container metrics with types and names
+// of python docker plugin of collectd, so dashboards and retention rules
+// built for it keep working, types db should have types of python plugin
+func NewPythonPluginCollectdWriter(host string, writer io.Writer, types TypesDB) CollectdWriter {
+	w := NewTypedCollectdWriter(host, writer, types).WithNaming(PythonPluginNaming)
+	w.values = pythonPluginValues
+	return w
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPythonPluginCollectdWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewPythonPluginCollectdWriter("myhost", b, PythonPluginTypesDB)
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 42
+	s.CPU.User = 30
+	s.CPU.System = 12
+	s.Memory.Usage = 100
+	s.Memory.RSS = 80
+	s.Network.RxBytes = 1
+	s.Network.TxBytes = 2
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := []string{
+		"PUTVAL myhost/docker-myapp.mytask/cpu.usage 1431000000:42:12:30:U",
+		"PUTVAL myhost/docker-myapp.mytask/memory.usage 1431000000:0:0:100",
+		"PUTVAL myhost/docker-myapp.mytask/memory.stats-total_rss 1431000000:80",
+		"PUTVAL myhost/docker-myapp.mytask/network.usage 1431000000:1:0:0:0:2:0:0:0",
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for _, e := range expected {
+		found := false
+		for _, l := range lines {
+			if l == e {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("expected line %q in output:\n%s", e, b.String())
+		}
+	}
+
+	err = PythonPluginTypesDB.validate(pythonPluginValues)
+	if err != nil {
+		t.Errorf("error validating types of python plugin: %s", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package collector
+
+// raceEnabled is set when tests run with race detector
+const raceEnabled = true
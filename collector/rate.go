@@ -0,0 +1,210 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rateStaleAfter is the age after which previous
+// sample of a task is not used to calculate rates
+const rateStaleAfter = 10 * time.Minute
+
+// rateSaveInterval is how often counters are saved to state file
+const rateSaveInterval = time.Minute
+
+// rateState is the last sample of a task saved in state file
+type rateState struct {
+	Time     time.Time         `json:"time"`
+	Counters map[string]uint64 `json:"counters"`
+}
+
+// RateWriter is responsible for converting counters of container
+// metrics to per second rates before they reach wrapped writer,
+// names of metrics are kept and gauges are passed as is
+type RateWriter struct {
+	writer Writer
+
+	mutex    sync.Mutex
+	previous map[string]Stats
+	pruned   time.Time
+
+	// state is the file counters are saved to, so rates
+	// can be calculated right after collector restarts
+	state string
+	saved time.Time
+}
+
+// NewRateWriter creates new RateWriter on top of specified writer
+func NewRateWriter(writer Writer) *RateWriter {
+	return &RateWriter{
+		writer:   writer,
+		previous: map[string]Stats{},
+	}
+}
+
+// NewPersistentRateWriter creates new RateWriter on top of specified
+// writer that saves counters to state file every minute and on close,
+// counters saved by previous collector are loaded from it
+func NewPersistentRateWriter(writer Writer, state string) (*RateWriter, error) {
+	w := NewRateWriter(writer)
+	w.state = state
+
+	b, err := ioutil.ReadFile(state)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	saved := map[string]rateState{}
+
+	err = json.Unmarshal(b, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("error loading rate state from %s: %s", state, err)
+	}
+
+	for key, p := range saved {
+		w.previous[key] = Stats{Time: p.Time, Metrics: p.Counters}
+	}
+
+	return w, nil
+}
+
+// Write passes sample with counters replaced by rates to wrapped writer,
+// counters are left out of the first sample of a task and after they
+// are reset, since there is nothing to calculate rates from
+func (w *RateWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	t := s.Time
+	key := s.App + "/" + s.Task
+
+	current := intMetrics(s)
+
+	var previous map[string]uint64
+	elapsed := time.Duration(0)
+	if p, ok := w.previous[key]; ok {
+		elapsed = t.Sub(p.Time)
+		if elapsed > 0 && elapsed < rateStaleAfter {
+			previous = p.Metrics
+		}
+	}
+
+	metrics := map[string]uint64{}
+	for k, v := range current {
+		if !counterMetrics[k] {
+			metrics[k] = v
+			continue
+		}
+
+		before, ok := previous[k]
+		if !ok || v < before {
+			continue
+		}
+
+		metrics[k] = uint64(float64(v-before) / elapsed.Seconds())
+	}
+
+	w.previous[key] = Stats{Time: s.Time, Metrics: current}
+	w.prune(t)
+
+	if w.state != "" && t.Sub(w.saved) >= rateSaveInterval {
+		w.saved = t
+		if err := w.save(); err != nil {
+			warnf("error saving rate state: %s", err)
+		}
+	}
+
+	s.Metrics = metrics
+	s.MetricsOnly = true
+
+	return w.writer.Write(s)
+}
+
+func (w *RateWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w *RateWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *RateWriter) Close() error {
+	if w.state != "" {
+		w.mutex.Lock()
+		err := w.save()
+		w.mutex.Unlock()
+
+		if err != nil {
+			warnf("error saving rate state: %s", err)
+		}
+	}
+
+	return w.writer.Close()
+}
+
+// save writes counters of tasks to state file
+func (w *RateWriter) save() error {
+	saved := make(map[string]rateState, len(w.previous))
+	for key, p := range w.previous {
+		counters := map[string]uint64{}
+		for k, v := range p.Metrics {
+			if counterMetrics[k] {
+				counters[k] = v
+			}
+		}
+
+		saved[key] = rateState{Time: p.Time, Counters: counters}
+	}
+
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(w.state, b)
+}
+
+// replaceFile replaces contents of file at once with a temporary file
+// next to it, so the file is never left half written if collector is killed
+func replaceFile(file string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), file)
+}
+
+// prune forgets stale samples of tasks that are gone
+func (w *RateWriter) prune(t time.Time) {
+	if t.Sub(w.pruned) < rateStaleAfter {
+		return
+	}
+
+	w.pruned = t
+
+	for key, p := range w.previous {
+		if t.Sub(p.Time) >= rateStaleAfter {
+			delete(w.previous, key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewRateWriter(r)
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(offset time.Duration, cpu uint64, memory uint64) {
+		s := Stats{App: "myapp", Task: "mytask"}
+		s.Time = start.Add(offset)
+		s.CPU.Total = cpu
+		s.Memory.Usage = memory
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	write(0, 100, 10)
+	write(10*time.Second, 600, 20)
+	write(20*time.Second, 50, 30)
+
+	if len(r.written) != 3 {
+		t.Fatalf("expected 3 written samples, got %d", len(r.written))
+	}
+
+	for i, c := range []struct {
+		cpu    uint64
+		hasCPU bool
+		memory uint64
+	}{
+		{0, false, 10},
+		{50, true, 20},
+		{0, false, 30},
+	} {
+		m := intMetrics(r.written[i])
+
+		cpu, ok := m["cpu.total"]
+		if ok != c.hasCPU || cpu != c.cpu {
+			t.Errorf("sample %d: expected cpu.total %d (present: %v), got %d (present: %v)", i, c.cpu, c.hasCPU, cpu, ok)
+		}
+
+		if m["memory.usage"] != c.memory {
+			t.Errorf("sample %d: expected memory.usage %d, got %d", i, c.memory, m["memory.usage"])
+		}
+	}
+}
+
+func TestPersistentRateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rate")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	state := filepath.Join(dir, "rates.json")
+	start := time.Unix(1431000000, 0)
+
+	write := func(w *RateWriter, offset time.Duration, cpu uint64) {
+		s := Stats{App: "myapp", Task: "mytask"}
+		s.Time = start.Add(offset)
+		s.CPU.Total = cpu
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	r := &recordingWriter{}
+
+	w, err := NewPersistentRateWriter(r, state)
+	if err != nil {
+		t.Fatalf("error creating rate writer without state file: %s", err)
+	}
+
+	write(w, 0, 100)
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing rate writer: %s", err)
+	}
+
+	// restarted collector calculates rate from saved counters
+	w, err = NewPersistentRateWriter(r, state)
+	if err != nil {
+		t.Fatalf("error loading rate state: %s", err)
+	}
+
+	write(w, 10*time.Second, 600)
+
+	if cpu, ok := intMetrics(r.written[1])["cpu.total"]; !ok || cpu != 50 {
+		t.Errorf("expected cpu.total rate of 50 after restart, got %d (present: %v)", cpu, ok)
+	}
+
+	err = ioutil.WriteFile(state, []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("error writing state file: %s", err)
+	}
+
+	if _, err := NewPersistentRateWriter(r, state); err == nil {
+		t.Errorf("expected error loading invalid state file")
+	}
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// reachFamily is the family of metrics of reachability probes
+const reachFamily = "reach"
+
+// icmpPayload is sent in echo requests to recognize replies,
+// kernel picks ids of echo requests of unprivileged sockets
+var icmpPayload = []byte("collectd-docker")
+
+// publishedPort is tcp port of container published on the host
+type publishedPort struct {
+	// port is port inside of container, it is stable between restarts
+	port string
+	// addr is host:port that port is published on
+	addr string
+}
+
+// SetReachability enables probes of containers every interval, tcp
+// connects to published tcp ports and icmp pings ip of container,
+// availability and latency of probes become metrics of app and task,
+// it should be called before Run
+func (c *Collector) SetReachability(tcp bool, ping bool) {
+	c.reachTCP = tcp
+	c.reachICMP = ping
+}
+
+// startReach starts reachability probes of container
+// in background until ctx is done
+func (c *Collector) startReach(ctx context.Context, m *Monitor) {
+	ports := []publishedPort{}
+	if c.reachTCP {
+		ports = m.ports
+	}
+
+	ping := c.reachICMP && m.address != ""
+
+	if len(ports) == 0 && !ping {
+		return
+	}
+
+	fields := containerFields(m.id, m.app, m.task)
+	once := sync.Once{}
+
+	go c.probe(ctx, m, "reachability probes", func(ctx context.Context) (map[string]uint64, error) {
+		metrics := map[string]uint64{}
+		mutex := sync.Mutex{}
+		wg := sync.WaitGroup{}
+
+		record := func(name string, latency time.Duration, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				metrics[reachFamily+"."+name+".up"] = 0
+				return
+			}
+
+			metrics[reachFamily+"."+name+".up"] = 1
+			metrics[reachFamily+"."+name+".latency_us"] = uint64(latency / time.Microsecond)
+		}
+
+		for _, p := range ports {
+			wg.Add(1)
+			go func(p publishedPort) {
+				defer wg.Done()
+				latency, err := connectTCP(ctx, p.addr)
+				record("tcp_"+p.port, latency, err)
+			}(p)
+		}
+
+		if ping {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				latency, err := pingICMP(ctx, m.address)
+
+				var socketErr *icmpSocketError
+				if errors.As(err, &socketErr) {
+					once.Do(func() {
+						fields.Logf(LogWarn, "icmp probes are unavailable: %s, allow them with sysctl net.ipv4.ping_group_range", err)
+					})
+					return
+				}
+
+				record("icmp", latency, err)
+			}()
+		}
+
+		wg.Wait()
+
+		return metrics, nil
+	})
+}
+
+// connectTCP returns how long it takes to connect to addr
+func connectTCP(ctx context.Context, addr string) (time.Duration, error) {
+	dialer := net.Dialer{}
+
+	started := time.Now()
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+
+	latency := time.Since(started)
+	conn.Close()
+
+	return latency, nil
+}
+
+// icmpSocketError is returned when collector can't open icmp socket,
+// that doesn't tell anything about reachability of container
+type icmpSocketError struct {
+	err error
+}
+
+func (e *icmpSocketError) Error() string {
+	return e.err.Error()
+}
+
+// pingICMP returns round trip time of icmp echo to address, unprivileged
+// datagram icmp socket is used, so collector doesn't need raw sockets
+func pingICMP(ctx context.Context, address string) (time.Duration, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return 0, errors.New("invalid address " + address)
+	}
+
+	network, listen, protocol := "udp4", "0.0.0.0", 1
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		network, listen, protocol = "udp6", "::", 58
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		return 0, &icmpSocketError{err}
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	message, err := (&icmp.Message{
+		Type: request,
+		Body: &icmp.Echo{Seq: 1, Data: icmpPayload},
+	}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	started := time.Now()
+
+	_, err = conn.WriteTo(message, &net.UDPAddr{IP: ip})
+	if err != nil {
+		return 0, err
+	}
+
+	b := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(b)
+		if err != nil {
+			return 0, err
+		}
+
+		parsed, err := icmp.ParseMessage(protocol, b[:n])
+		if err != nil || parsed.Type != reply {
+			continue
+		}
+
+		echo, ok := parsed.Body.(*icmp.Echo)
+		if !ok || !bytes.Equal(echo.Data, icmpPayload) || !from.(*net.UDPAddr).IP.Equal(ip) {
+			continue
+		}
+
+		return time.Since(started), nil
+	}
+}
+
+// publishedPorts returns tcp ports of container published on the host,
+// ports published on all interfaces are probed on loopback
+func publishedPorts(container *docker.Container) []publishedPort {
+	if container.NetworkSettings == nil {
+		return nil
+	}
+
+	ports := []publishedPort{}
+	for port, bindings := range container.NetworkSettings.Ports {
+		if port.Proto() != "tcp" || len(bindings) == 0 {
+			continue
+		}
+
+		host := bindings[0].HostIP
+		switch host {
+		case "", "0.0.0.0":
+			host = "127.0.0.1"
+		case "::":
+			host = "::1"
+		}
+
+		ports = append(ports, publishedPort{
+			port: port.Port(),
+			addr: net.JoinHostPort(host, bindings[0].HostPort),
+		})
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].addr < ports[j].addr
+	})
+
+	return ports
+}
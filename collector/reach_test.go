@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestPublishedPorts(t *testing.T) {
+	container := &docker.Container{
+		NetworkSettings: &docker.NetworkSettings{
+			Ports: map[docker.Port][]docker.PortBinding{
+				"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}},
+				"9090/tcp": {{HostIP: "10.0.0.1", HostPort: "9090"}},
+				"5353/udp": {{HostIP: "0.0.0.0", HostPort: "5353"}},
+				"6379/tcp": nil,
+			},
+		},
+	}
+
+	ports := publishedPorts(container)
+
+	expected := []publishedPort{
+		{port: "9090", addr: "10.0.0.1:9090"},
+		{port: "8080", addr: "127.0.0.1:32768"},
+	}
+
+	if len(ports) != len(expected) {
+		t.Fatalf("expected ports %v, got %v", expected, ports)
+	}
+
+	for i := range expected {
+		if ports[i] != expected[i] {
+			t.Errorf("expected port %v, got %v", expected[i], ports[i])
+		}
+	}
+}
+
+func TestConnectTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+
+	addr := listener.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := connectTCP(ctx, addr); err != nil {
+		t.Errorf("expected listening port to be reachable, got %s", err)
+	}
+
+	listener.Close()
+
+	if _, err := connectTCP(ctx, addr); err == nil {
+		t.Errorf("expected closed port to be unreachable")
+	}
+}
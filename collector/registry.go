@@ -0,0 +1,310 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriterOptions are string options of a writer keyed by option name
+type WriterOptions map[string]string
+
+// WriterOption describes single option of a writer
+type WriterOption struct {
+	Name    string
+	Default string
+	Usage   string
+}
+
+// WriterFactory creates writer with specified hostname and options,
+// options have defaults applied and unknown options rejected
+type WriterFactory func(host string, options WriterOptions) (Writer, error)
+
+// WriterRegistration describes writer that can be created by name
+type WriterRegistration struct {
+	Name    string
+	Usage   string
+	Options []WriterOption
+	New     WriterFactory
+}
+
+var (
+	writersMutex sync.Mutex
+	writers      = map[string]WriterRegistration{}
+)
+
+// RegisterWriter makes writer available by name, it is supposed
+// to be called from init() of the package implementing writer,
+// registering the same name twice panics
+func RegisterWriter(r WriterRegistration) {
+	writersMutex.Lock()
+	defer writersMutex.Unlock()
+
+	if _, ok := writers[r.Name]; ok {
+		panic("writer " + r.Name + " is already registered")
+	}
+
+	writers[r.Name] = r
+}
+
+// unregisterWriter forgets writer with name, so tests
+// can register the same writer every time they run
+func unregisterWriter(name string) {
+	writersMutex.Lock()
+	defer writersMutex.Unlock()
+
+	delete(writers, name)
+}
+
+// RegisteredWriters returns registrations of all
+// known writers sorted by name
+func RegisteredWriters() []WriterRegistration {
+	writersMutex.Lock()
+	defer writersMutex.Unlock()
+
+	names := make([]string, 0, len(writers))
+	for name := range writers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	result := make([]WriterRegistration, 0, len(names))
+	for _, name := range names {
+		result = append(result, writers[name])
+	}
+
+	return result
+}
+
+// NewWriter creates registered writer by name with specified hostname
+// and options, writers may buffer samples until Flush is called
+func NewWriter(name string, host string, options WriterOptions) (Writer, error) {
+	writersMutex.Lock()
+	r, ok := writers[name]
+	writersMutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown writer: %s", name)
+	}
+
+	full := WriterOptions{}
+	for _, o := range r.Options {
+		full[o.Name] = o.Default
+	}
+
+	for k, v := range options {
+		if _, ok := full[k]; !ok {
+			return nil, fmt.Errorf("unknown option %q for writer %s", k, name)
+		}
+
+		full[k] = v
+	}
+
+	w, err := r.New(host, full)
+	if err != nil {
+		return nil, fmt.Errorf("error creating writer %s: %s", name, err)
+	}
+
+	return w, nil
+}
+
+// optionsParser parses typed options and remembers the first error
+type optionsParser struct {
+	options WriterOptions
+	err     error
+}
+
+func (p *optionsParser) string(name string) string {
+	return p.options[name]
+}
+
+func (p *optionsParser) strings(name string) []string {
+	v := p.options[name]
+	if v == "" {
+		return nil
+	}
+
+	result := []string{}
+	for _, s := range strings.Split(v, ",") {
+		result = append(result, strings.TrimSpace(s))
+	}
+
+	return result
+}
+
+func (p *optionsParser) int(name string) int {
+	v, err := strconv.Atoi(p.options[name])
+	p.fail(name, err)
+	return v
+}
+
+func (p *optionsParser) int64(name string) int64 {
+	v, err := strconv.ParseInt(p.options[name], 10, 64)
+	p.fail(name, err)
+	return v
+}
+
+func (p *optionsParser) bool(name string) bool {
+	v, err := strconv.ParseBool(p.options[name])
+	p.fail(name, err)
+	return v
+}
+
+func (p *optionsParser) duration(name string) time.Duration {
+	v, err := time.ParseDuration(p.options[name])
+	p.fail(name, err)
+	return v
+}
+
+// headers parses comma separated "Name: value" pairs
+func (p *optionsParser) headers(name string) map[string]string {
+	headers := map[string]string{}
+	for _, h := range p.strings(name) {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			p.fail(name, fmt.Errorf("header should look like \"Name: value\", got %q", h))
+			continue
+		}
+
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers
+}
+
+// tlsConfig creates tls config from tls, tls-ca, tls-cert and tls-key
+// options, nil is returned when tls is not enabled and none of files are set
+func (p *optionsParser) tlsConfig() *tls.Config {
+	ca, cert, key := p.options["tls-ca"], p.options["tls-cert"], p.options["tls-key"]
+	if !p.bool("tls") && ca == "" && cert == "" && key == "" {
+		return nil
+	}
+
+	config, err := NewTLSConfig(ca, cert, key)
+	p.fail("tls", err)
+
+	return config
+}
+
+func (p *optionsParser) fail(name string, err error) {
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid option %s: %s", name, err)
+	}
+}
+
+// tlsOptions are options understood by optionsParser.tlsConfig
+var tlsOptions = []WriterOption{
+	{Name: "tls", Default: "false", Usage: "use tls, implied by other tls options"},
+	{Name: "tls-ca", Usage: "ca file to pin server certificate to instead of system roots"},
+	{Name: "tls-cert", Usage: "client certificate file"},
+	{Name: "tls-key", Usage: "client key file"},
+}
+
+// streamWriter buffers output of writer that wraps io.Writer until
+// Flush is called and closes underlying connection on Close
+type streamWriter struct {
+	Writer
+	out    io.Writer
+	buf    *bufio.Writer
+	closer io.Closer
+}
+
+// newStreamWriter creates writer with create func on top of
+// buffered out and closes closer on Close if it is not nil
+func newStreamWriter(out io.Writer, closer io.Closer, create func(io.Writer) Writer) streamWriter {
+	buf := bufio.NewWriter(out)
+
+	return streamWriter{
+		Writer: create(buf),
+		out:    out,
+		buf:    buf,
+		closer: closer,
+	}
+}
+
+// Write discards buffered data on error, since
+// bufio.Writer doesn't accept writes after errors
+func (w streamWriter) Write(s Stats) error {
+	err := w.Writer.Write(s)
+	if err != nil {
+		w.buf.Reset(w.out)
+	}
+
+	return err
+}
+
+// Flush discards buffered data on error, since
+// bufio.Writer doesn't accept writes after errors
+func (w streamWriter) Flush() error {
+	err := w.Writer.Flush()
+	if err == nil {
+		err = w.buf.Flush()
+	}
+
+	if err != nil {
+		w.buf.Reset(w.out)
+	}
+
+	return err
+}
+
+// Notify writes notification and flushes buffered data
+// right away, so notifications are not delayed by buffering
+func (w streamWriter) Notify(n Notification) error {
+	err := Notify(w.Writer, n)
+	if err == nil {
+		err = w.buf.Flush()
+	}
+
+	if err != nil {
+		w.buf.Reset(w.out)
+	}
+
+	return err
+}
+
+func (w streamWriter) Close() error {
+	err := w.Flush()
+
+	cerr := w.Writer.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	if w.closer != nil {
+		cerr = w.closer.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// closingWriter closes underlying connection of wrapped writer
+type closingWriter struct {
+	Writer
+	closer io.Closer
+}
+
+func (w closingWriter) Notify(n Notification) error {
+	return Notify(w.Writer, n)
+}
+
+func (w closingWriter) Close() error {
+	err := w.Writer.Close()
+
+	cerr := w.closer.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,40 @@
+package collector
+
+import "testing"
+
+func TestNewWriterOptions(t *testing.T) {
+	received := WriterOptions{}
+
+	RegisterWriter(WriterRegistration{
+		Name: "test-options",
+		Options: []WriterOption{
+			{Name: "foo", Default: "bar"},
+			{Name: "baz"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			received = o
+			return NewCollectdWriter(host, nil), nil
+		},
+	})
+
+	defer unregisterWriter("test-options")
+
+	_, err := NewWriter("test-options", "myhost", WriterOptions{"baz": "qux"})
+	if err != nil {
+		t.Fatalf("error creating writer: %s", err)
+	}
+
+	if received["foo"] != "bar" || received["baz"] != "qux" {
+		t.Errorf("expected defaults to be merged with options, got %v", received)
+	}
+
+	_, err = NewWriter("test-options", "myhost", WriterOptions{"nope": "nope"})
+	if err == nil {
+		t.Errorf("expected error for unknown option")
+	}
+
+	_, err = NewWriter("test-nope", "myhost", nil)
+	if err == nil {
+		t.Errorf("expected error for unknown writer")
+	}
+}
@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// riemannMaxResponse is the max size of riemann response,
+// larger responses from misbehaving servers are rejected
+const riemannMaxResponse = 1 << 20
+
+// RiemannWriter is responsible for sending samples as riemann
+// events with protobuf over tcp, every metric is a separate event
+// with app and task attributes, connection is dialed again on the
+// next write after it fails
+type RiemannWriter struct {
+	host      string
+	addr      string
+	ttl       float32
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	mutex     sync.Mutex
+	conn      net.Conn
+}
+
+// NewRiemannWriter creates new RiemannWriter with specified hostname,
+// riemann tcp address, ttl of events, timeout of sending events along
+// with reading response and optional tls config
+func NewRiemannWriter(host string, addr string, ttl time.Duration, timeout time.Duration, tlsConfig *tls.Config) (*RiemannWriter, error) {
+	conn, err := dial("tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RiemannWriter{
+		host:      host,
+		addr:      addr,
+		ttl:       float32(ttl.Seconds()),
+		timeout:   timeout,
+		tlsConfig: tlsConfig,
+		conn:      conn,
+	}, nil
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "riemann",
+		Usage: "riemann events with protobuf over tcp",
+		Options: append([]WriterOption{
+			{Name: "addr", Default: "127.0.0.1:5555", Usage: "riemann tcp address"},
+			{Name: "ttl", Default: "0s", Usage: "ttl of riemann events, 0 to omit"},
+			{Name: "timeout", Default: "10s", Usage: "timeout of sending events and reading response"},
+		}, tlsOptions...),
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			ttl := p.duration("ttl")
+			timeout := p.duration("timeout")
+			tlsConfig := p.tlsConfig()
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewRiemannWriter(host, p.string("addr"), ttl, timeout, tlsConfig)
+		},
+	})
+}
+
+func (w *RiemannWriter) Write(s Stats) error {
+	t := s.Time.Unix()
+
+	msg := []byte{}
+	for k, v := range intMetrics(s) {
+		msg = appendProtoBytes(msg, 6, w.encodeEvent(s, k, t, v))
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		debugf("reconnecting to %s", w.addr)
+		atomic.AddUint64(&selfCounters.reconnects, 1)
+
+		conn, err := dial("tcp", w.addr, w.tlsConfig)
+		if err != nil {
+			return err
+		}
+
+		debugf("reconnected to %s", w.addr)
+
+		w.conn = conn
+	}
+
+	if w.timeout > 0 {
+		w.conn.SetDeadline(time.Now().Add(w.timeout))
+	}
+
+	frame := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+
+	_, err := w.conn.Write(append(frame, msg...))
+	if err == nil {
+		err = w.readResponse()
+	}
+
+	// events rejected by riemann leave connection usable
+	if _, rejected := err.(riemannError); err != nil && !rejected {
+		debugf("connection to %s failed: %s", w.addr, err)
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	return err
+}
+
+// riemannError is an error that riemann responded with
+type riemannError string
+
+func (e riemannError) Error() string {
+	return "riemann error: " + string(e)
+}
+
+// Flush is no-op, events are acknowledged on every write
+func (w *RiemannWriter) Flush() error {
+	return nil
+}
+
+// Close closes connection to riemann
+func (w *RiemannWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+func (w *RiemannWriter) encodeEvent(s Stats, k string, t int64, v uint64) []byte {
+	e := []byte{}
+
+	e = appendProtoVarint(e, 1, uint64(t))
+	e = appendProtoBytes(e, 3, []byte("docker_stats."+k))
+	e = appendProtoBytes(e, 4, []byte(w.host))
+
+	if w.ttl > 0 {
+		ttl := make([]byte, 4)
+		binary.LittleEndian.PutUint32(ttl, math.Float32bits(w.ttl))
+		e = append(appendProtoTag(e, 8, protoFixed32), ttl...)
+	}
+
+	e = appendProtoBytes(e, 9, encodeRiemannAttribute("app", s.App))
+	e = appendProtoBytes(e, 9, encodeRiemannAttribute("task", s.Task))
+
+	if v > math.MaxInt64 {
+		v = math.MaxInt64
+	}
+
+	// metric_sint64 uses zigzag encoding
+	e = appendProtoTag(e, 13, protoVarint)
+	e = appendVarint(e, int64(v))
+
+	return e
+}
+
+func (w *RiemannWriter) readResponse() error {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(w.conn, header)
+	if err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if size > riemannMaxResponse {
+		return fmt.Errorf("riemann response of %d bytes is over %d bytes", size, riemannMaxResponse)
+	}
+
+	msg := make([]byte, size)
+	_, err = io.ReadFull(w.conn, msg)
+	if err != nil {
+		return err
+	}
+
+	ok := false
+	reason := ""
+
+	err = walkProto(msg, func(field uint64, v uint64, b []byte) {
+		switch field {
+		case 2:
+			ok = v != 0
+		case 3:
+			reason = string(b)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return riemannError(reason)
+	}
+
+	return nil
+}
+
+func encodeRiemannAttribute(key string, value string) []byte {
+	b := appendProtoBytes(nil, 1, []byte(key))
+	return appendProtoBytes(b, 2, []byte(value))
+}
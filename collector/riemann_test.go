@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func fakeRiemannServer(t *testing.T, conn net.Conn, response []byte) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		t.Errorf("error reading riemann message header: %s", err)
+		return
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint32(header))
+	_, err = io.ReadFull(conn, msg)
+	if err != nil {
+		t.Errorf("error reading riemann message: %s", err)
+		return
+	}
+
+	if !bytes.Contains(msg, []byte("docker_stats.cpu.total")) {
+		t.Errorf("expected cpu.total event in riemann message")
+	}
+
+	binary.BigEndian.PutUint32(header, uint32(len(response)))
+	conn.Write(append(header, response...))
+}
+
+func TestRiemannWriter(t *testing.T) {
+	tests := map[string]bool{
+		string(appendProtoVarint(nil, 2, 1)):                                      true,
+		string(appendProtoBytes(appendProtoVarint(nil, 2, 0), 3, []byte("nope"))): false,
+	}
+
+	for response, ok := range tests {
+		client, server := net.Pipe()
+		w := &RiemannWriter{host: "myhost", conn: client}
+
+		go fakeRiemannServer(t, server, []byte(response))
+
+		err := w.Write(Stats{App: "myapp", Task: "mytask"})
+		if ok && err != nil {
+			t.Errorf("unexpected error for ok response: %s", err)
+		}
+
+		if !ok && err == nil {
+			t.Errorf("expected error for failed response")
+		}
+
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestRiemannWriterReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	go func() {
+		// the first connection is dropped without response
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		conn.Close()
+
+		conn, err = l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		fakeRiemannServer(t, conn, appendProtoVarint(nil, 2, 1))
+	}()
+
+	w, err := NewRiemannWriter("myhost", l.Addr().String(), 0, time.Second, nil)
+	if err != nil {
+		t.Fatalf("error creating riemann writer: %s", err)
+	}
+
+	defer w.Close()
+
+	if err := w.Write(Stats{App: "myapp", Task: "mytask"}); err == nil {
+		t.Fatal("expected error writing to dropped connection")
+	}
+
+	if err := w.Write(Stats{App: "myapp", Task: "mytask"}); err != nil {
+		t.Errorf("expected write after reconnecting to succeed, got %s", err)
+	}
+}
+
+func TestRiemannWriterTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	w := &RiemannWriter{host: "myhost", timeout: 50 * time.Millisecond, conn: client}
+
+	// server reads events, but never responds
+	go io.Copy(io.Discard, server)
+
+	done := make(chan error)
+	go func() {
+		done <- w.Write(Stats{App: "myapp", Task: "mytask"})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error without response from riemann")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected write to silent riemann to time out")
+	}
+
+	if w.conn != nil {
+		t.Error("expected timed out connection to be dropped")
+	}
+}
+
+func TestRiemannWriterLargeResponse(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	w := &RiemannWriter{host: "myhost", timeout: time.Second, conn: client}
+
+	go func() {
+		header := make([]byte, 4)
+		io.ReadFull(server, header)
+		io.ReadFull(server, make([]byte, binary.BigEndian.Uint32(header)))
+
+		binary.BigEndian.PutUint32(header, 1<<31)
+		server.Write(header)
+	}()
+
+	if err := w.Write(Stats{App: "myapp", Task: "mytask"}); err == nil {
+		t.Error("expected error with oversized response")
+	}
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// FilterRule is a rule that includes or excludes apps
+// with names matching regexp, it is managed at runtime
+type FilterRule struct {
+	// Action is either include or exclude
+	Action string `json:"action"`
+	// App is regexp of app names
+	App string `json:"app"`
+}
+
+// compiledRule is FilterRule with compiled regexp
+type compiledRule struct {
+	FilterRule
+	regexp *regexp.Regexp
+}
+
+// RuleSet is a set of filter rules that can be changed while collector
+// is running, rules are saved to file on every change if it is set,
+// it is safe for concurrent use
+type RuleSet struct {
+	file  string
+	mutex sync.Mutex
+	rules []compiledRule
+}
+
+// LoadRuleSet creates rule set with rules saved to file,
+// empty file makes rule set that is not saved
+func LoadRuleSet(file string) (*RuleSet, error) {
+	r := &RuleSet{file: file}
+	if file == "" {
+		return r, nil
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []FilterRule{}
+
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("error loading filter rules from %s: %s", file, err)
+	}
+
+	for _, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("error loading filter rules from %s: %s", file, err)
+		}
+
+		r.rules = append(r.rules, compiled)
+	}
+
+	return r, nil
+}
+
+// compileRule checks action of rule and compiles its regexp
+func compileRule(rule FilterRule) (compiledRule, error) {
+	if rule.Action != "include" && rule.Action != "exclude" {
+		return compiledRule{}, fmt.Errorf("unknown action of filter rule: %q", rule.Action)
+	}
+
+	re, err := regexp.Compile(rule.App)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("invalid regexp of filter rule: %s", err)
+	}
+
+	return compiledRule{FilterRule: rule, regexp: re}, nil
+}
+
+// Rules returns filter rules in order they were added
+func (r *RuleSet) Rules() []FilterRule {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rules := make([]FilterRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule.FilterRule)
+	}
+
+	return rules
+}
+
+// Add adds filter rule and saves rules, rules that
+// are already in the set are not added again
+func (r *RuleSet) Add(rule FilterRule) error {
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.rules {
+		if existing.FilterRule == rule {
+			return nil
+		}
+	}
+
+	r.rules = append(r.rules, compiled)
+
+	return r.save()
+}
+
+// Remove removes filter rule and saves rules,
+// false is returned if there was no such rule
+func (r *RuleSet) Remove(rule FilterRule) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.rules {
+		if existing.FilterRule == rule {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return true, r.save()
+		}
+	}
+
+	return false, nil
+}
+
+// save writes rules to file of rule set if it is set
+func (r *RuleSet) save() error {
+	if r.file == "" {
+		return nil
+	}
+
+	rules := make([]FilterRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule.FilterRule)
+	}
+
+	b, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(r.file, b)
+}
+
+// reason returns why app is excluded by rules, exclude rules take
+// precedence over include rules, false is returned if no rules match
+func (r *RuleSet) reason(app string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	included := false
+	for _, rule := range r.rules {
+		if !rule.regexp.MatchString(app) {
+			continue
+		}
+
+		if rule.Action == "exclude" {
+			return fmt.Sprintf("app %s matches exclude rule %s", app, rule.App), true
+		}
+
+		included = true
+	}
+
+	return "", included
+}
+
+// SetFilterRules sets rules that are checked before app filter, apps
+// matching include rules are monitored even if app filter excludes them,
+// samples of already monitored containers are filtered as well
+func (c *Collector) SetFilterRules(rules *RuleSet) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.rules = rules
+}
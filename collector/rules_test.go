@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRuleSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "rules.json")
+
+	r, err := LoadRuleSet(file)
+	if err != nil {
+		t.Fatalf("error loading rules without file: %s", err)
+	}
+
+	for _, rule := range []FilterRule{{"exclude", "^noisy"}, {"include", "^debug"}, {"exclude", "^noisy"}} {
+		err = r.Add(rule)
+		if err != nil {
+			t.Fatalf("error adding rule %v: %s", rule, err)
+		}
+	}
+
+	for _, rule := range []FilterRule{{"drop", "^noisy"}, {"exclude", "("}} {
+		if err := r.Add(rule); err == nil {
+			t.Errorf("expected error adding invalid rule %v", rule)
+		}
+	}
+
+	// rules are loaded by restarted collector
+	r, err = LoadRuleSet(file)
+	if err != nil {
+		t.Fatalf("error loading rules: %s", err)
+	}
+
+	expected := []FilterRule{{"exclude", "^noisy"}, {"include", "^debug"}}
+	if rules := r.Rules(); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected rules %v, got %v", expected, rules)
+	}
+
+	c := &Collector{}
+	c.SetAppFilter(nil, regexp.MustCompile("^debug"))
+	c.SetFilterRules(r)
+
+	for app, filtered := range map[string]bool{"noisy-web": false, "debug-web": true, "web": true} {
+		if c.filtered(app) != filtered {
+			t.Errorf("expected app %s to pass filter: %v", app, filtered)
+		}
+	}
+
+	removed, err := r.Remove(FilterRule{"exclude", "^noisy"})
+	if err != nil || !removed {
+		t.Fatalf("expected rule to be removed, got %v and error %v", removed, err)
+	}
+
+	if removed, _ := r.Remove(FilterRule{"exclude", "^noisy"}); removed {
+		t.Errorf("expected rule not to be removed twice")
+	}
+
+	if !c.filtered("noisy-web") {
+		t.Errorf("expected app to pass filter after exclude rule is removed")
+	}
+
+	err = ioutil.WriteFile(file, []byte(`[{"action": "drop", "app": "web"}]`), 0644)
+	if err != nil {
+		t.Fatalf("error writing rules: %s", err)
+	}
+
+	if _, err := LoadRuleSet(file); err == nil {
+		t.Errorf("expected error loading invalid rules")
+	}
+}
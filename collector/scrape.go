@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// scrapeLabel is the label with port and path of metrics endpoint
+// of application in container, like :8080/metrics
+const scrapeLabel = "collectd_docker_scrape"
+
+// scrapeFilterLabel is the label with regexp that names of scraped
+// metrics have to match to be forwarded, all are forwarded without it
+const scrapeFilterLabel = "collectd_docker_scrape_filter"
+
+// scrapeFamily is the family of metrics of scraped endpoints
+const scrapeFamily = "scrape"
+
+// maxScrapeOutput is max size of response of endpoint that is parsed
+const maxScrapeOutput = 1 << 20
+
+// maxScrapeMetrics is max number of metrics forwarded from a single
+// scrape, so endpoints with many series don't flood writers
+const maxScrapeMetrics = 1000
+
+// SetScraping enables scraping of endpoints that containers declare
+// with collectd_docker_scrape label, the endpoint is fetched from ip
+// of container every interval and its values become metrics of app
+// and task, it should be called before Run
+func (c *Collector) SetScraping(enabled bool) {
+	c.scraping = enabled
+}
+
+// startScrape starts scraping endpoint of container in background
+// until ctx is done, invalid labels are logged and ignored
+func (c *Collector) startScrape(ctx context.Context, m *Monitor) {
+	fields := containerFields(m.id, m.app, m.task)
+
+	url, err := scrapeURL(m.scrape, m.address)
+	if err != nil {
+		fields.Logf(LogWarn, "not scraping: %s", err)
+		return
+	}
+
+	var filter *regexp.Regexp
+	if m.scrapeFilter != "" {
+		filter, err = regexp.Compile(m.scrapeFilter)
+		if err != nil {
+			fields.Logf(LogWarn, "not scraping, invalid %s label: %s", scrapeFilterLabel, err)
+			return
+		}
+	}
+
+	client := &http.Client{}
+
+	go c.probe(ctx, m, "scrape of "+url, func(ctx context.Context) (map[string]uint64, error) {
+		return scrape(ctx, client, url, filter)
+	})
+}
+
+// scrapeURL makes url of endpoint from port and path
+// of scrape label and ip address of container
+func scrapeURL(target string, address string) (string, error) {
+	if !strings.HasPrefix(target, ":") {
+		return "", fmt.Errorf("%s label should be like :8080/metrics, got %q", scrapeLabel, target)
+	}
+
+	port, path := target[1:], "/"
+	if i := strings.Index(port, "/"); i != -1 {
+		port, path = port[:i], port[i:]
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("%s label has invalid port: %q", scrapeLabel, target)
+	}
+
+	// containers in host network have no address of their own
+	if address == "" {
+		address = "127.0.0.1"
+	}
+
+	return "http://" + net.JoinHostPort(address, port) + path, nil
+}
+
+// containerAddress returns ip address of container, the address
+// of the first network in name order is used for containers
+// in user defined networks
+func containerAddress(container *docker.Container) string {
+	if container.NetworkSettings == nil {
+		return ""
+	}
+
+	if container.NetworkSettings.IPAddress != "" {
+		return container.NetworkSettings.IPAddress
+	}
+
+	names := make([]string, 0, len(container.NetworkSettings.Networks))
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if address := container.NetworkSettings.Networks[name].IPAddress; address != "" {
+			return address
+		}
+	}
+
+	return ""
+}
+
+// scrape fetches endpoint and parses its response
+func scrape(ctx context.Context, client *http.Client, url string, filter *regexp.Regexp) (map[string]uint64, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+
+	return parseScrapeOutput(io.LimitReader(resp.Body, maxScrapeOutput), filter)
+}
+
+// parseScrapeOutput parses prometheus text format or plain lines of key
+// and value into metrics of scrape family, like scrape.requests_total,
+// values of labels are appended to names, negative and invalid values
+// are skipped, filter is matched against names before sanitizing
+func parseScrapeOutput(r io.Reader, filter *regexp.Regexp) (map[string]uint64, error) {
+	metrics := map[string]uint64{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ \t"); i != -1 {
+			name, rest = line[:i], line[i:]
+		}
+
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end == -1 {
+				continue
+			}
+
+			for _, value := range scrapeLabelValues(rest[1:end]) {
+				name += "." + value
+			}
+
+			rest = rest[end+1:]
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || value < 0 || value != value {
+			continue
+		}
+
+		if filter != nil && !filter.MatchString(name) {
+			continue
+		}
+
+		if len(metrics) >= maxScrapeMetrics {
+			return nil, fmt.Errorf("endpoint returned more than %d metrics, set %s label", maxScrapeMetrics, scrapeFilterLabel)
+		}
+
+		metrics[scrapeFamily+"."+sanitizeForGraphite(name)] = uint64(value)
+	}
+
+	return metrics, scanner.Err()
+}
+
+// scrapeLabelValues returns values of labels like code="200",method="get"
+// in order, escaped quotes in values are kept as they are
+func scrapeLabelValues(labels string) []string {
+	values := []string{}
+
+	for labels != "" {
+		start := strings.Index(labels, `"`)
+		if start == -1 {
+			break
+		}
+
+		end := start + 1
+		for end < len(labels) && (labels[end] != '"' || labels[end-1] == '\\') {
+			end++
+		}
+
+		if end >= len(labels) {
+			break
+		}
+
+		values = append(values, labels[start+1:end])
+		labels = labels[end+1:]
+	}
+
+	return values
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestParseScrapeOutput(t *testing.T) {
+	metrics, err := parseScrapeOutput(strings.NewReader(`
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="get"} 1027 1395066363000
+http_requests_total{code="500",method="get"} 3
+queue_depth 12.5
+temperature -3
+go_gc_seconds NaN
+`), nil)
+	if err != nil {
+		t.Fatalf("error parsing scrape output: %s", err)
+	}
+
+	expected := map[string]uint64{
+		"scrape.http_requests_total_200_get": 1027,
+		"scrape.http_requests_total_500_get": 3,
+		"scrape.queue_depth":                 12,
+	}
+
+	if len(metrics) != len(expected) {
+		t.Errorf("expected %d metrics, got %v", len(expected), metrics)
+	}
+
+	for k, v := range expected {
+		if value, ok := metrics[k]; !ok || value != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	metrics, err = parseScrapeOutput(strings.NewReader("requests 1\nerrors 2\n"), regexp.MustCompile("^err"))
+	if err != nil {
+		t.Fatalf("error parsing scrape output: %s", err)
+	}
+
+	if len(metrics) != 1 || metrics["scrape.errors"] != 2 {
+		t.Errorf("expected only filtered errors metric, got %v", metrics)
+	}
+
+	many := &strings.Builder{}
+	for i := 0; i <= maxScrapeMetrics; i++ {
+		fmt.Fprintf(many, "metric_%d 1\n", i)
+	}
+
+	if _, err := parseScrapeOutput(strings.NewReader(many.String()), nil); err == nil {
+		t.Errorf("expected error parsing more than %d metrics", maxScrapeMetrics)
+	}
+}
+
+func TestScrapeURL(t *testing.T) {
+	cases := map[[2]string]string{
+		{":8080/metrics", "172.17.0.2"}: "http://172.17.0.2:8080/metrics",
+		{":9100", "172.17.0.2"}:         "http://172.17.0.2:9100/",
+		{":8080/metrics", ""}:           "http://127.0.0.1:8080/metrics",
+		{":8080/stats", "fd00::2"}:      "http://[fd00::2]:8080/stats",
+	}
+
+	for in, expected := range cases {
+		url, err := scrapeURL(in[0], in[1])
+		if err != nil {
+			t.Errorf("error making url of %q: %s", in[0], err)
+		} else if url != expected {
+			t.Errorf("expected url of %v to be %s, got %s", in, expected, url)
+		}
+	}
+
+	for _, invalid := range []string{"8080/metrics", ":http/metrics", ":99999"} {
+		if _, err := scrapeURL(invalid, "172.17.0.2"); err == nil {
+			t.Errorf("expected error making url of %q", invalid)
+		}
+	}
+}
+
+func TestContainerAddress(t *testing.T) {
+	container := &docker.Container{
+		NetworkSettings: &docker.NetworkSettings{
+			Networks: map[string]docker.ContainerNetwork{
+				"frontend": {IPAddress: "10.0.1.2"},
+				"backend":  {IPAddress: "10.0.0.2"},
+			},
+		},
+	}
+
+	if address := containerAddress(container); address != "10.0.0.2" {
+		t.Errorf("expected address of the first network, got %q", address)
+	}
+
+	container.NetworkSettings.IPAddress = "172.17.0.2"
+
+	if address := containerAddress(container); address != "172.17.0.2" {
+		t.Errorf("expected address of the default network, got %q", address)
+	}
+
+	if address := containerAddress(&docker.Container{}); address != "" {
+		t.Errorf("expected no address without network settings, got %q", address)
+	}
+}
+
+func TestScrape(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintln(w, "connections 7")
+	}))
+
+	defer server.Close()
+
+	metrics, err := scrape(context.Background(), server.Client(), server.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatalf("error scraping: %s", err)
+	}
+
+	if metrics["scrape.connections"] != 7 {
+		t.Errorf("expected scraped connections, got %v", metrics)
+	}
+
+	if _, err := scrape(context.Background(), server.Client(), server.URL+"/missing", nil); err == nil {
+		t.Errorf("expected error scraping missing endpoint")
+	}
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+type shardFilter struct {
+	index int
+	count int
+}
+
+// ShardFilter matches containers that shard with index out of count
+// shards is responsible for, containers are split by consistent hash
+// of their ids, so changing count moves only containers of new shards
+func ShardFilter(index int, count int) Filter {
+	return shardFilter{index: index, count: count}
+}
+
+func (f shardFilter) Match(c *docker.Container) bool {
+	return shardOf(c.ID, f.count) == f.index
+}
+
+func (f shardFilter) String() string {
+	return fmt.Sprintf("shard %d of %d", f.index, f.count)
+}
+
+// ValidateShard returns error if there is no shard with index out of count
+func ValidateShard(index int, count int) error {
+	if count < 1 {
+		return fmt.Errorf("number of shards should be positive, got %d", count)
+	}
+
+	if index < 0 || index >= count {
+		return fmt.Errorf("shard should be from 0 to %d, got %d", count-1, index)
+	}
+
+	return nil
+}
+
+// SetShard makes collector monitor only containers that shard with index
+// out of count shards is responsible for, so several collectors can split
+// containers of the same docker endpoints, self metrics are reported with
+// shard in task, count of 1 monitors every container, it should be called
+// before Run
+func (c *Collector) SetShard(index int, count int) {
+	c.shard = index
+	c.shards = count
+}
+
+// containerFilter returns filter that containers have to match,
+// which is filter of collector limited to its shard
+func (c *Collector) containerFilter() Filter {
+	if c.shards <= 1 {
+		return c.filter
+	}
+
+	if c.filter == nil {
+		return ShardFilter(c.shard, c.shards)
+	}
+
+	return AllFilters(c.filter, ShardFilter(c.shard, c.shards))
+}
+
+// selfTaskName returns task of collector's own metrics,
+// shards report them separately to not overwrite each other
+func (c *Collector) selfTaskName() string {
+	if c.shards <= 1 {
+		return selfTask
+	}
+
+	return fmt.Sprintf("%s_shard_%d", selfTask, c.shard)
+}
+
+// shardOf returns shard out of count that container with id belongs to,
+// it is jump consistent hash of fnv hash of id, see arXiv:1406.2294
+func shardOf(id string, count int) int {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	key := h.Sum64()
+
+	b, j := int64(-1), int64(0)
+	for j < int64(count) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestShardOf(t *testing.T) {
+	ids := make([]string, 10000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%064x", i*7919)
+	}
+
+	counts := make([]int, 4)
+	for _, id := range ids {
+		counts[shardOf(id, len(counts))]++
+	}
+
+	for shard, count := range counts {
+		if count < 2000 || count > 3000 {
+			t.Errorf("expected about 2500 containers in shard %d, got %d", shard, count)
+		}
+	}
+
+	moved := 0
+	for _, id := range ids {
+		before, after := shardOf(id, 4), shardOf(id, 5)
+		if before == after {
+			continue
+		}
+
+		moved++
+
+		if after != 4 {
+			t.Errorf("expected container %s to move from shard %d to new shard, got shard %d", id, before, after)
+		}
+	}
+
+	if moved < 1500 || moved > 2500 {
+		t.Errorf("expected about 2000 containers to move to new shard, got %d", moved)
+	}
+
+	for _, id := range ids {
+		if shard := shardOf(id, 1); shard != 0 {
+			t.Fatalf("expected the only shard to be 0, got %d", shard)
+		}
+	}
+}
+
+func TestShardFilter(t *testing.T) {
+	c := &docker.Container{ID: "4f53e6b1c3a2"}
+
+	matched := 0
+	for i := 0; i < 3; i++ {
+		if ShardFilter(i, 3).Match(c) {
+			matched++
+		}
+	}
+
+	if matched != 1 {
+		t.Errorf("expected container to match exactly one shard, matched %d", matched)
+	}
+
+	col := &Collector{}
+	if col.containerFilter() != nil {
+		t.Errorf("expected no filter without shards, got %s", describeFilter(col.containerFilter()))
+	}
+
+	col.SetShard(1, 3)
+	col.SetFilter(LabelFilter("team", ""))
+
+	if expected, got := "(label team) and (shard 1 of 3)", describeFilter(col.containerFilter()); got != expected {
+		t.Errorf("expected filter %q, got %q", expected, got)
+	}
+
+	if expected, got := "self_shard_1", col.selfTaskName(); got != expected {
+		t.Errorf("expected self task %q, got %q", expected, got)
+	}
+}
+
+func TestValidateShard(t *testing.T) {
+	tests := []struct {
+		index int
+		count int
+		valid bool
+	}{
+		{0, 1, true},
+		{2, 3, true},
+		{3, 3, false},
+		{-1, 3, false},
+		{0, 0, false},
+	}
+
+	for _, test := range tests {
+		if err := ValidateShard(test.index, test.count); (err == nil) != test.valid {
+			t.Errorf("expected shard %d of %d to be valid %v, got error %v", test.index, test.count, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContainerSnapshot is the current view of collector of monitored
+// container: its identity and the last sample that was sent
+type ContainerSnapshot struct {
+	ID     string            `json:"id"`
+	App    string            `json:"app"`
+	Task   string            `json:"task"`
+	Image  string            `json:"image"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	Sample *SampleSnapshot   `json:"sample,omitempty"`
+}
+
+// SampleSnapshot is the last sample of container
+type SampleSnapshot struct {
+	Time    time.Time         `json:"time"`
+	Metrics map[string]uint64 `json:"metrics"`
+}
+
+// Snapshot returns views of monitored containers ordered by app and task
+func (c *Collector) Snapshot() []ContainerSnapshot {
+	c.mutex.Lock()
+	monitors := make([]*Monitor, 0, len(c.registered))
+	for _, m := range c.registered {
+		monitors = append(monitors, m)
+	}
+	c.mutex.Unlock()
+
+	snapshots := make([]ContainerSnapshot, 0, len(monitors))
+	for _, m := range monitors {
+		snapshots = append(snapshots, m.snapshot())
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].App != snapshots[j].App {
+			return snapshots[i].App < snapshots[j].App
+		}
+
+		if snapshots[i].Task != snapshots[j].Task {
+			return snapshots[i].Task < snapshots[j].Task
+		}
+
+		return snapshots[i].ID < snapshots[j].ID
+	})
+
+	return snapshots
+}
+
+// ContainerSnapshot returns view of monitored container with id or
+// unique prefix of id, false is returned if there is no such container
+func (c *Collector) ContainerSnapshot(id string) (ContainerSnapshot, bool) {
+	c.mutex.Lock()
+	var found *Monitor
+	for registered, m := range c.registered {
+		if registered == id {
+			found = m
+			break
+		}
+
+		if id != "" && strings.HasPrefix(registered, id) {
+			if found != nil {
+				c.mutex.Unlock()
+				return ContainerSnapshot{}, false
+			}
+
+			found = m
+		}
+	}
+	c.mutex.Unlock()
+
+	if found == nil {
+		return ContainerSnapshot{}, false
+	}
+
+	return found.snapshot(), true
+}
+
+// snapshot returns view of container of monitor
+func (m *Monitor) snapshot() ContainerSnapshot {
+	snapshot := ContainerSnapshot{
+		ID:    m.id,
+		App:   m.app,
+		Task:  m.task,
+		Image: m.image,
+		Meta:  m.meta,
+	}
+
+	if last, ok := m.lastSample(); ok {
+		snapshot.Sample = &SampleSnapshot{Time: last.Time, Metrics: intMetrics(last)}
+	}
+
+	return snapshot
+}
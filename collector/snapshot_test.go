@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := NewCollector(nil, &recordingWriter{}, time.Second)
+
+	web := &Monitor{id: "0123abcd", app: "web", task: "1", image: "web:1"}
+	db := &Monitor{id: "0123cdef", app: "db", task: "1", image: "postgres"}
+
+	s := Stats{App: "web", Task: "1", Time: time.Unix(1000, 0)}
+	s.Memory.Usage = 100
+	web.remember(s)
+
+	c.register(web.id, web)
+	c.register(db.id, db)
+
+	snapshots := c.Snapshot()
+	if len(snapshots) != 2 || snapshots[0].App != "db" || snapshots[1].App != "web" {
+		t.Fatalf("expected snapshots of db and web, got %v", snapshots)
+	}
+
+	if snapshots[0].Sample != nil {
+		t.Errorf("expected no sample of db yet, got %v", snapshots[0].Sample)
+	}
+
+	if sample := snapshots[1].Sample; sample == nil || !sample.Time.Equal(s.Time) || sample.Metrics["memory.usage"] != 100 {
+		t.Errorf("expected the last sample of web, got %v", sample)
+	}
+
+	for id, expected := range map[string]string{"0123ab": "web", "0123cdef": "db", "0123": "", "4567": ""} {
+		snapshot, ok := c.ContainerSnapshot(id)
+		if ok != (expected != "") || snapshot.App != expected {
+			t.Errorf("expected snapshot of %q for %s, got %q (found: %v)", expected, id, snapshot.App, ok)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolSegmentSize is the size after which new spool segment is started
+const spoolSegmentSize = 1 << 20
+
+// spoolReplayBatch is the number of replayed samples flushed at once,
+// on failure segment is truncated to the last flushed sample
+const spoolReplayBatch = 100
+
+// SpoolWriter is responsible for keeping samples that wrapped writer
+// failed to write in bounded on-disk spool and replaying them once
+// wrapped writer recovers, samples written since the last successful
+// flush are spooled when write or flush fails
+type SpoolWriter struct {
+	writer  Writer
+	dir     string
+	maxSize int64
+
+	mutex    sync.Mutex
+	pending  []Stats
+	segment  *os.File
+	size     int64
+	dropping bool
+
+	// replayMutex allows single replay at a time, replay only holds
+	// mutex while writing a batch, so writes proceed in between
+	replayMutex sync.Mutex
+}
+
+// NewSpoolWriter creates new SpoolWriter on top of specified writer with
+// spool directory and max total size of spool in bytes, samples left
+// in spool directory from previous runs are replayed too
+func NewSpoolWriter(writer Writer, dir string, maxSize int64) (*SpoolWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &SpoolWriter{
+		writer:  writer,
+		dir:     dir,
+		maxSize: maxSize,
+	}
+
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range segments {
+		info, err := os.Stat(s)
+		if err != nil {
+			return nil, err
+		}
+
+		w.size += info.Size()
+	}
+
+	return w, nil
+}
+
+func (w *SpoolWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, s)
+
+	err := w.writer.Write(s)
+	if err != nil {
+		return w.spillPending(err)
+	}
+
+	return nil
+}
+
+// Notify writes notification to wrapped writer,
+// notifications are not spooled when it fails
+func (w *SpoolWriter) Notify(n Notification) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return Notify(w.writer, n)
+}
+
+// Flush flushes wrapped writer and replays spooled samples on success,
+// on failure samples written since the last flush are spooled
+func (w *SpoolWriter) Flush() error {
+	w.mutex.Lock()
+
+	err := w.writer.Flush()
+	if err != nil {
+		err = w.spillPending(err)
+		w.mutex.Unlock()
+		return err
+	}
+
+	w.pending = w.pending[:0]
+
+	spooled := w.size > 0 || w.segment != nil
+
+	// current segment is closed to be replayed as well
+	if w.segment != nil {
+		w.segment.Close()
+		w.segment = nil
+	}
+
+	w.mutex.Unlock()
+
+	if !spooled {
+		return nil
+	}
+
+	return w.replay()
+}
+
+// Close flushes and closes wrapped writer,
+// spool is kept on disk for the next run
+func (w *SpoolWriter) Close() error {
+	err := w.Flush()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.segment != nil {
+		w.segment.Close()
+		w.segment = nil
+	}
+
+	cerr := w.writer.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// spillPending moves pending samples to spool, error of
+// wrapped writer is returned unless spooling fails as well
+func (w *SpoolWriter) spillPending(cause error) error {
+	for _, s := range w.pending {
+		err := w.spill(s)
+		if err != nil {
+			w.pending = w.pending[:0]
+			return fmt.Errorf("error spooling samples after %s: %s", cause, err)
+		}
+	}
+
+	w.pending = w.pending[:0]
+
+	return cause
+}
+
+func (w *SpoolWriter) spill(s Stats) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	b = append(b, '\n')
+
+	if w.size+int64(len(b)) > w.maxSize {
+		if !w.dropping {
+			warnf("spool %s is full, dropping samples", w.dir)
+			w.dropping = true
+		}
+
+		return nil
+	}
+
+	w.dropping = false
+
+	if w.segment == nil {
+		name := filepath.Join(w.dir, fmt.Sprintf("spool-%020d.jsonl", time.Now().UnixNano()))
+		w.segment, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := w.segment.Write(b)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	info, err := w.segment.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() >= spoolSegmentSize {
+		w.segment.Close()
+		w.segment = nil
+	}
+
+	return nil
+}
+
+// replay writes spooled segments to wrapped writer oldest first in batches,
+// segment is removed once all of its samples are written and flushed,
+// on failure only samples of flushed batches are removed from it
+func (w *SpoolWriter) replay() error {
+	w.replayMutex.Lock()
+	defer w.replayMutex.Unlock()
+
+	w.mutex.Lock()
+	segments, err := w.segments()
+	current := ""
+	if w.segment != nil {
+		current = w.segment.Name()
+	}
+	w.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range segments {
+		// segment spooled to after replay started is left for the next one
+		if name == current {
+			break
+		}
+
+		err := w.replaySegment(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *SpoolWriter) replaySegment(name string) error {
+	samples, ends, size, err := readSpoolSegment(name)
+	if err != nil {
+		return err
+	}
+
+	confirmed := int64(0)
+	for i := 0; i < len(samples); i += spoolReplayBatch {
+		end := i + spoolReplayBatch
+		if end > len(samples) {
+			end = len(samples)
+		}
+
+		err := w.replayBatch(samples[i:end])
+		if err != nil {
+			terr := w.truncateSegment(name, confirmed)
+			if terr != nil {
+				return fmt.Errorf("error truncating spool segment after %s: %s", err, terr)
+			}
+
+			return err
+		}
+
+		confirmed = ends[end-1]
+	}
+
+	err = os.Remove(name)
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.size -= size
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// replayBatch writes and flushes samples to wrapped writer, samples
+// written since the last flush are spooled again if it fails
+func (w *SpoolWriter) replayBatch(samples []Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, s := range samples {
+		err := w.writer.Write(s)
+		if err != nil {
+			return w.spillPending(err)
+		}
+	}
+
+	err := w.writer.Flush()
+	if err != nil {
+		return w.spillPending(err)
+	}
+
+	w.pending = w.pending[:0]
+
+	return nil
+}
+
+// truncateSegment removes first offset bytes of segment that were
+// already replayed, so they are not written again on the next replay
+func (w *SpoolWriter) truncateSegment(name string, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+
+	err = ioutil.WriteFile(tmp, b[offset:], 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	w.mutex.Lock()
+	w.size -= offset
+	w.mutex.Unlock()
+
+	return nil
+}
+
+func (w *SpoolWriter) segments() ([]string, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []string{}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "spool-") && strings.HasSuffix(f.Name(), ".jsonl") {
+			segments = append(segments, filepath.Join(w.dir, f.Name()))
+		}
+	}
+
+	sort.Strings(segments)
+
+	return segments, nil
+}
+
+// readSpoolSegment returns samples of segment with offsets
+// of their ends and total size of segment
+func readSpoolSegment(name string) ([]Stats, []int64, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	defer f.Close()
+
+	samples := []Stats{}
+	ends := []int64{}
+	size := int64(0)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), spoolSegmentSize)
+
+	for scanner.Scan() {
+		size += int64(len(scanner.Bytes())) + 1
+
+		s := Stats{}
+		err := json.Unmarshal(scanner.Bytes(), &s)
+		if err != nil {
+			// partially written sample at the end of segment is skipped
+			continue
+		}
+
+		samples = append(samples, s)
+		ends = append(ends, size)
+	}
+
+	return samples, ends, size, scanner.Err()
+}
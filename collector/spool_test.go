@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type flakyWriter struct {
+	recordingWriter
+	down bool
+}
+
+func (w *flakyWriter) Write(s Stats) error {
+	if w.down {
+		return errors.New("backend is down")
+	}
+
+	return w.recordingWriter.Write(s)
+}
+
+func (w *flakyWriter) Flush() error {
+	if w.down {
+		return errors.New("backend is down")
+	}
+
+	return w.recordingWriter.Flush()
+}
+
+// limitedWriter goes down after limit of writes
+type limitedWriter struct {
+	flakyWriter
+	limit int
+}
+
+func (w *limitedWriter) Write(s Stats) error {
+	if w.limit == 0 {
+		w.down = true
+	}
+
+	w.limit--
+
+	return w.flakyWriter.Write(s)
+}
+
+func TestSpoolWriterReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	f := &flakyWriter{down: true}
+	w, err := NewSpoolWriter(f, dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, app := range []string{"one", "two"} {
+		if w.Write(Stats{App: app}) == nil {
+			t.Errorf("expected write error when backend is down")
+		}
+	}
+
+	f.down = false
+
+	err = w.Write(Stats{App: "three"})
+	if err != nil {
+		t.Fatalf("unexpected write error: %s", err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("unexpected flush error: %s", err)
+	}
+
+	apps := []string{}
+	for _, s := range f.written {
+		apps = append(apps, s.App)
+	}
+
+	if len(apps) != 3 || apps[0] != "three" || apps[1] != "one" || apps[2] != "two" {
+		t.Errorf("expected spooled samples to be replayed after flush, got %v", apps)
+	}
+
+	segments, _ := w.segments()
+	if len(segments) != 0 {
+		t.Errorf("expected spool to be empty after replay, got %v", segments)
+	}
+}
+
+func TestSpoolWriterPartialReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	f := &limitedWriter{flakyWriter: flakyWriter{down: true}, limit: -1}
+	w, err := NewSpoolWriter(f, dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 250; i++ {
+		w.Write(Stats{App: fmt.Sprint(i)})
+	}
+
+	// second batch of replay fails half way through
+	f.down = false
+	f.limit = spoolReplayBatch + 50
+
+	if w.Flush() == nil {
+		t.Fatalf("expected flush error when backend goes down during replay")
+	}
+
+	f.down = false
+	f.limit = -1
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("unexpected flush error: %s", err)
+	}
+
+	if len(f.written) != 300 {
+		t.Fatalf("expected only unflushed batch to be replayed again, got %d samples", len(f.written))
+	}
+
+	for i, s := range f.written[150:] {
+		if s.App != fmt.Sprint(spoolReplayBatch+i) {
+			t.Fatalf("expected replay to resume after the last flushed batch, got %s at %d", s.App, i)
+		}
+	}
+
+	segments, _ := w.segments()
+	if len(segments) != 0 || w.size != 0 {
+		t.Errorf("expected spool to be empty after replay, got %v of size %d", segments, w.size)
+	}
+}
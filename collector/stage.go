@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"sync"
+)
+
+// Samples flow through collector in stages connected by channels:
+//
+//   - collect: every monitor runs in its own goroutine and sends
+//     samples to the queue of collector, see SetQueue
+//   - aggregate: the goroutine of collector reads the queue and runs
+//     the outermost writers, AggregateWriter needs container metrics
+//     before they are transformed, so it goes first
+//   - transform: RateWriter, ThresholdWriter, SuppressWriter,
+//     FamilyWriter and PrefixWriter rewrite and filter samples
+//   - write: MultiWriter runs every backend writer in its own goroutine
+//
+// StageWriter moves the writers it wraps into a goroutine of their own,
+// so every stage runs concurrently with the others. Writers of a stage
+// are only called from the goroutine of the stage, they don't contend
+// with other stages for cpu and locks.
+
+// StageWriter runs wrapped writer in its own goroutine, samples,
+// notifications and flushes are queued in order, unlike MultiWriter
+// it blocks when its queue is full, so backpressure reaches the
+// queue of collector where drop policy applies
+type StageWriter struct {
+	name   string
+	writer Writer
+	ch     chan multiWriterItem
+	mutex  sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// NewStageWriter creates new StageWriter with specified stage name
+// for logging, wrapped writer and size of the queue of the stage
+func NewStageWriter(name string, writer Writer, size int) *StageWriter {
+	w := &StageWriter{
+		name:   name,
+		writer: writer,
+		ch:     make(chan multiWriterItem, size),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// run passes queued items to wrapped writer until the queue is closed
+func (w *StageWriter) run() {
+	defer w.wg.Done()
+
+	for i := range w.ch {
+		if i.flush != nil {
+			i.flush <- w.writer.Flush()
+			continue
+		}
+
+		if i.notification != nil {
+			err := Notify(w.writer, *i.notification)
+			if err != nil {
+				countWriteError()
+				LogFields{"app": i.notification.App, "task": i.notification.Task, "stage": w.name}.Logf(LogError, "error writing notification: %s", err)
+			}
+
+			continue
+		}
+
+		err := w.writer.Write(i.stats)
+		if err != nil {
+			countWriteError()
+			LogFields{"app": i.stats.App, "task": i.stats.Task, "stage": w.name}.Logf(LogError, "error writing stats: %s", err)
+		}
+	}
+}
+
+// Write enqueues sample for the stage, it never returns an error,
+// errors of wrapped writer are logged by the stage
+func (w *StageWriter) Write(s Stats) error {
+	w.ch <- multiWriterItem{stats: s}
+	return nil
+}
+
+// Notify enqueues notification for the stage
+func (w *StageWriter) Notify(n Notification) error {
+	w.ch <- multiWriterItem{notification: &n}
+	return nil
+}
+
+// Len returns number of items waiting in the queue of the stage
+func (w *StageWriter) Len() int {
+	return len(w.ch)
+}
+
+// Flush waits for queued items to be written and flushes wrapped writer
+func (w *StageWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	result := make(chan error, 1)
+	w.ch <- multiWriterItem{flush: result}
+
+	return <-result
+}
+
+// Close waits for queued items to be written and closes wrapped writer,
+// the stage should not be written to afterwards
+func (w *StageWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	close(w.ch)
+	w.wg.Wait()
+
+	return w.writer.Close()
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestStageWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewStageWriter("test", r, 10)
+
+	w.Write(Stats{App: "one"})
+	w.Write(Stats{App: "two"})
+	w.Notify(Notification{App: "two", Event: "oom"})
+	w.Write(Stats{App: "three"})
+
+	err := w.Flush()
+	if err != nil {
+		t.Fatalf("error flushing stage: %s", err)
+	}
+
+	if len(r.written) != 3 || len(r.notified) != 1 || r.flushes != 1 {
+		t.Fatalf("expected 3 samples, 1 notification and 1 flush, got %d, %d and %d", len(r.written), len(r.notified), r.flushes)
+	}
+
+	for i, expected := range []string{"one", "two", "three"} {
+		if r.written[i].App != expected {
+			t.Errorf("expected app %s at %d, got %s", expected, i, r.written[i].App)
+		}
+	}
+
+	w.Write(Stats{App: "four"})
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing stage: %s", err)
+	}
+
+	if len(r.written) != 4 {
+		t.Errorf("expected queued sample to be written on close, got %d samples", len(r.written))
+	}
+}
+
+// benchmarkContainers is the number of containers in pipeline benchmarks
+const benchmarkContainers = 1000
+
+// newBenchmarkPipeline creates pipeline of transform and write stages,
+// with size > 0 stages are connected with StageWriter
+func newBenchmarkPipeline(size int) Writer {
+	var w Writer = NewCollectdWriter("myhost", ioutil.Discard)
+	if size > 0 {
+		w = NewStageWriter("write", w, size)
+	}
+
+	w = NewPrefixWriter(w, "prefix.", "")
+	w = NewRateWriter(w)
+	if size > 0 {
+		w = NewStageWriter("transform", w, size)
+	}
+
+	return NewAggregateWriter(w, time.Second)
+}
+
+func BenchmarkPipelineSync(b *testing.B) {
+	benchmarkPipeline(b, 0)
+}
+
+func BenchmarkPipelineStaged(b *testing.B) {
+	benchmarkPipeline(b, 1000)
+}
+
+// benchmarkPipeline writes samples of benchmarkContainers
+// containers round robin, every op is a single sample
+func benchmarkPipeline(b *testing.B, size int) {
+	w := newBenchmarkPipeline(size)
+
+	samples := make([]Stats, benchmarkContainers)
+	for i := range samples {
+		samples[i] = benchmarkSample()
+		samples[i].App = fmt.Sprintf("app%d", i%50)
+		samples[i].Task = fmt.Sprintf("task%d", i)
+	}
+
+	start := time.Unix(1431000000, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s := samples[i%len(samples)]
+		s.Time = start.Add(time.Duration(i/len(samples)) * time.Second)
+		s.CPU.Total += uint64(i)
+
+		w.Write(s)
+	}
+
+	err := w.Close()
+	if err != nil {
+		b.Fatalf("error closing pipeline: %s", err)
+	}
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// StaticIdentity is app and task of containers with names
+// or ids matching regexp, task can refer to groups of regexp
+// like $1, short container id is the task if it is empty
+type StaticIdentity struct {
+	Regexp *regexp.Regexp
+	App    string
+	Task   string
+}
+
+// StaticExtractor takes identity of containers from a table of patterns,
+// the first pattern matching name or id of container wins, it is meant
+// for legacy hand-run containers that can't be relabelled
+type StaticExtractor []StaticIdentity
+
+// LoadStaticIdentities parses identity file with lines of regexp, app
+// and optional task separated by whitespace, regexps are matched against
+// names of containers without leading slash and against full ids:
+//
+//	# legacy database started by hand years ago
+//	^legacy-db$      db      primary
+//	^legacy-web-(\d+) web    $1
+//
+// Empty lines and lines starting with # are skipped.
+func LoadStaticIdentities(path string) (StaticExtractor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	e := StaticExtractor{}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("line %d of %s is not regexp, app and optional task: %q", n, path, line)
+		}
+
+		re, err := regexp.Compile(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s has invalid regexp: %s", n, path, err)
+		}
+
+		identity := StaticIdentity{Regexp: re, App: fields[1]}
+		if len(fields) == 3 {
+			identity.Task = fields[2]
+		}
+
+		e = append(e, identity)
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	return e, nil
+}
+
+// Extract returns identity of the first pattern matching container
+func (e StaticExtractor) Extract(c *docker.Container) (app, task string, err error) {
+	name := strings.TrimPrefix(c.Name, "/")
+
+	for _, identity := range e {
+		for _, s := range []string{name, c.ID} {
+			match := identity.Regexp.FindStringSubmatchIndex(s)
+			if match == nil || s == "" {
+				continue
+			}
+
+			task = shortID(c.ID)
+			if identity.Task != "" {
+				task = string(identity.Regexp.ExpandString(nil, identity.Task, s, match))
+			}
+
+			return identity.App, task, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+func (StaticExtractor) String() string {
+	return "identity file"
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestStaticExtractor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"identities": `
+# legacy containers
+^legacy-db$        db     primary
+^legacy-web-(\d+)$ web    $1
+^0123abcd           cache
+`,
+		"invalid": "^legacy-db$\n",
+	})
+
+	e, err := LoadStaticIdentities(filepath.Join(dir, "identities"))
+	if err != nil {
+		t.Fatalf("error loading identities: %s", err)
+	}
+
+	tests := map[[2]string][2]string{
+		{"/legacy-db", "ffff0000ffff0000"}:    {"db", "primary"},
+		{"/legacy-web-3", "ffff0000ffff0000"}: {"web", "3"},
+		{"/redis", "0123abcdffff0000"}:        {"cache", "0123abcd"},
+		{"/legacy-db-2", "ffff0000ffff0000"}:  {"", ""},
+	}
+
+	for container, expected := range tests {
+		c := &docker.Container{Name: container[0], ID: container[1], Config: &docker.Config{}}
+
+		app, task, err := e.Extract(c)
+		if err != nil || app != expected[0] || task != expected[1] {
+			t.Errorf("expected app %q and task %q of %v, got %q, %q and error %v", expected[0], expected[1], container, app, task, err)
+		}
+	}
+
+	if _, err := LoadStaticIdentities(filepath.Join(dir, "invalid")); err == nil {
+		t.Errorf("expected error loading identities without app")
+	}
+}
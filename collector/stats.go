@@ -1,10 +1,264 @@
 package collector
 
-import "github.com/fsouza/go-dockerclient"
+import (
+	"time"
 
-// Stats represents singe stat from docker stats api for specific task
+	"github.com/fsouza/go-dockerclient"
+)
+
+// Stats represents singe stat from docker stats api for specific task,
+// it doesn't depend on how docker client library represents stats
 type Stats struct {
 	App   string
 	Task  string
-	Stats docker.Stats
+	Image string
+	// Meta is metadata of container, like owner of job, writers that
+	// support tags write it as tags, it is shared by samples of container
+	// and must not be modified
+	Meta map[string]string
+	// Time is when docker read stats of container
+	Time    time.Time
+	CPU     CPUStats
+	Memory  MemoryStats
+	Network NetworkStats
+	// Metrics are additional metrics keyed by metric name
+	Metrics map[string]uint64
+	// MetricsOnly is set for samples that don't come from docker
+	// stats api and only carry Metrics, like collector's own metrics,
+	// Time is still the time of the sample
+	MetricsOnly bool
+}
+
+// CPUStats are cpu time used by container in nanoseconds
+type CPUStats struct {
+	User   uint64
+	System uint64
+	Total  uint64
+}
+
+// MemoryStats are memory used by container in bytes,
+// page faults and page ins and outs are numbers of events
+type MemoryStats struct {
+	Limit        uint64
+	Max          uint64
+	Usage        uint64
+	ActiveAnon   uint64
+	ActiveFile   uint64
+	Cache        uint64
+	InactiveAnon uint64
+	InactiveFile uint64
+	MappedFile   uint64
+	PgFault      uint64
+	PgIn         uint64
+	PgOut        uint64
+	RSS          uint64
+	RSSHuge      uint64
+	Unevictable  uint64
+	Writeback    uint64
+}
+
+// NetworkStats are network counters of container summed over interfaces
+type NetworkStats struct {
+	RxBytes   uint64
+	RxDropped uint64
+	RxErrors  uint64
+	RxPackets uint64
+	TxBytes   uint64
+	TxDropped uint64
+	TxErrors  uint64
+	TxPackets uint64
+}
+
+// newStats converts stats from docker client
+// into sample of specified app, task and image
+func newStats(app, task, image string, d *docker.Stats) Stats {
+	return Stats{
+		App:   app,
+		Task:  task,
+		Image: image,
+		Time:  d.Read,
+		CPU: CPUStats{
+			User:   d.CPUStats.CPUUsage.UsageInUsermode,
+			System: d.CPUStats.CPUUsage.UsageInKernelmode,
+			Total:  d.CPUStats.CPUUsage.TotalUsage,
+		},
+		Memory: MemoryStats{
+			Limit:        d.MemoryStats.Limit,
+			Max:          d.MemoryStats.MaxUsage,
+			Usage:        d.MemoryStats.Usage,
+			ActiveAnon:   d.MemoryStats.Stats.TotalActiveAnon,
+			ActiveFile:   d.MemoryStats.Stats.TotalActiveFile,
+			Cache:        d.MemoryStats.Stats.TotalCache,
+			InactiveAnon: d.MemoryStats.Stats.TotalInactiveAnon,
+			InactiveFile: d.MemoryStats.Stats.TotalInactiveFile,
+			MappedFile:   d.MemoryStats.Stats.TotalMappedFile,
+			PgFault:      d.MemoryStats.Stats.TotalPgfault,
+			PgIn:         d.MemoryStats.Stats.TotalPgpgin,
+			PgOut:        d.MemoryStats.Stats.TotalPgpgout,
+			RSS:          d.MemoryStats.Stats.TotalRss,
+			RSSHuge:      d.MemoryStats.Stats.TotalRssHuge,
+			Unevictable:  d.MemoryStats.Stats.TotalUnevictable,
+			Writeback:    d.MemoryStats.Stats.TotalWriteback,
+		},
+		Network: NetworkStats{
+			RxBytes:   d.Network.RxBytes,
+			RxDropped: d.Network.RxDropped,
+			RxErrors:  d.Network.RxErrors,
+			RxPackets: d.Network.RxPackets,
+			TxBytes:   d.Network.TxBytes,
+			TxDropped: d.Network.TxDropped,
+			TxErrors:  d.Network.TxErrors,
+			TxPackets: d.Network.TxPackets,
+		},
+	}
+}
+
+// intMetrics returns integer metrics from stats keyed by metric name
+func intMetrics(s Stats) map[string]uint64 {
+	if s.MetricsOnly {
+		return s.Metrics
+	}
+
+	metrics := containerMetrics(s)
+	for k, v := range s.Metrics {
+		metrics[k] = v
+	}
+
+	return metrics
+}
+
+// containerMetricNames are names of metrics of docker stats
+// in the order of values returned by containerMetricValues
+var containerMetricNames = [...]string{
+	"cpu.user",
+	"cpu.system",
+	"cpu.total",
+
+	"memory.limit",
+	"memory.max",
+	"memory.usage",
+
+	"memory.active_anon",
+	"memory.active_file",
+	"memory.cache",
+	"memory.inactive_anon",
+	"memory.inactive_file",
+	"memory.mapped_file",
+	"memory.pg_fault",
+	"memory.pg_in",
+	"memory.pg_out",
+	"memory.rss",
+	"memory.rss_huge",
+	"memory.unevictable",
+	"memory.writeback",
+
+	"net.rx_bytes",
+	"net.rx_dropped",
+	"net.rx_errors",
+	"net.rx_packets",
+	"net.tx_bytes",
+	"net.tx_dropped",
+	"net.tx_errors",
+	"net.tx_packets",
+}
+
+// containerMetricValues returns values of metrics of docker stats,
+// an array is returned instead of a map, so it doesn't allocate
+func containerMetricValues(s *Stats) [len(containerMetricNames)]uint64 {
+	return [len(containerMetricNames)]uint64{
+		s.CPU.User,
+		s.CPU.System,
+		s.CPU.Total,
+
+		s.Memory.Limit,
+		s.Memory.Max,
+		s.Memory.Usage,
+
+		s.Memory.ActiveAnon,
+		s.Memory.ActiveFile,
+		s.Memory.Cache,
+		s.Memory.InactiveAnon,
+		s.Memory.InactiveFile,
+		s.Memory.MappedFile,
+		s.Memory.PgFault,
+		s.Memory.PgIn,
+		s.Memory.PgOut,
+		s.Memory.RSS,
+		s.Memory.RSSHuge,
+		s.Memory.Unevictable,
+		s.Memory.Writeback,
+
+		s.Network.RxBytes,
+		s.Network.RxDropped,
+		s.Network.RxErrors,
+		s.Network.RxPackets,
+		s.Network.TxBytes,
+		s.Network.TxDropped,
+		s.Network.TxErrors,
+		s.Network.TxPackets,
+	}
+}
+
+// containerMetrics returns integer metrics from docker stats
+func containerMetrics(s Stats) map[string]uint64 {
+	metrics := make(map[string]uint64, len(containerMetricNames))
+	for i, v := range containerMetricValues(&s) {
+		metrics[containerMetricNames[i]] = v
+	}
+
+	return metrics
+}
+
+// eachMetric calls f for every integer metric of stats, like iterating
+// over intMetrics, but without allocating a map for every sample
+func eachMetric(s *Stats, f func(name string, value uint64)) {
+	if !s.MetricsOnly {
+		for i, v := range containerMetricValues(s) {
+			name := containerMetricNames[i]
+			if _, ok := s.Metrics[name]; ok {
+				continue
+			}
+
+			f(name, v)
+		}
+	}
+
+	for k, v := range s.Metrics {
+		f(k, v)
+	}
+}
+
+// EachMetric calls f for every integer metric of sample, container
+// metrics are skipped for samples that only carry Metrics, so writers
+// outside of the package don't have to know whether sample is renamed
+func (s *Stats) EachMetric(f func(name string, value uint64)) {
+	eachMetric(s, f)
+}
+
+// counterMetrics are container metrics that only grow
+// for the lifetime of container, the rest are gauges
+var counterMetrics = map[string]bool{
+	"cpu.user":   true,
+	"cpu.system": true,
+	"cpu.total":  true,
+
+	"memory.pg_fault": true,
+	"memory.pg_in":    true,
+	"memory.pg_out":   true,
+
+	"net.rx_bytes":   true,
+	"net.rx_dropped": true,
+	"net.rx_errors":  true,
+	"net.rx_packets": true,
+	"net.tx_bytes":   true,
+	"net.tx_dropped": true,
+	"net.tx_errors":  true,
+	"net.tx_packets": true,
+
+	"events.start":   true,
+	"events.restart": true,
+	"events.stop":    true,
+	"events.die":     true,
+	"events.kill":    true,
+	"events.oom":     true,
 }
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestNewStats(t *testing.T) {
+	d := &docker.Stats{Read: time.Unix(1431000000, 0)}
+	d.CPUStats.CPUUsage.TotalUsage = 42
+	d.MemoryStats.Usage = 100
+	d.MemoryStats.Stats.TotalRss = 80
+	d.Network.TxBytes = 2
+
+	s := newStats("myapp", "mytask", "myimage", d)
+
+	if s.App != "myapp" || s.Task != "mytask" || s.Image != "myimage" || !s.Time.Equal(d.Read) {
+		t.Errorf("unexpected identity or time of sample: %#v", s)
+	}
+
+	expected := map[string]uint64{
+		"cpu.total":    42,
+		"memory.usage": 100,
+		"memory.rss":   80,
+		"net.tx_bytes": 2,
+	}
+
+	metrics := intMetrics(s)
+	for k, v := range expected {
+		if metrics[k] != v {
+			t.Errorf("expected %s to be %d, got %d", k, v, metrics[k])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// suppressedValue is the last written value of a metric
+type suppressedValue struct {
+	value   uint64
+	written time.Time
+}
+
+// SuppressWriter is responsible for skipping metrics with values that
+// haven't changed since they were written last time, unchanged values
+// are still written after max age passes to act as a heartbeat
+type SuppressWriter struct {
+	writer Writer
+	maxAge time.Duration
+
+	mutex  sync.Mutex
+	values map[string]map[string]suppressedValue
+	pruned time.Time
+}
+
+// NewSuppressWriter creates new SuppressWriter on top of specified writer
+// with max age after which unchanged values are written anyway
+func NewSuppressWriter(writer Writer, maxAge time.Duration) *SuppressWriter {
+	return &SuppressWriter{
+		writer: writer,
+		maxAge: maxAge,
+		values: map[string]map[string]suppressedValue{},
+	}
+}
+
+// Write passes sample with changed metrics to wrapped writer,
+// samples without changed metrics are skipped entirely
+func (w *SuppressWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	t := s.Time
+	key := s.App + "/" + s.Task
+
+	values := w.values[key]
+	if values == nil {
+		values = map[string]suppressedValue{}
+		w.values[key] = values
+	}
+
+	metrics := map[string]uint64{}
+	for k, v := range intMetrics(s) {
+		last, ok := values[k]
+		if ok && last.value == v && t.Sub(last.written) < w.maxAge {
+			continue
+		}
+
+		metrics[k] = v
+		values[k] = suppressedValue{value: v, written: t}
+	}
+
+	w.prune(t)
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	s.Metrics = metrics
+	s.MetricsOnly = true
+
+	return w.writer.Write(s)
+}
+
+func (w *SuppressWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w *SuppressWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *SuppressWriter) Close() error {
+	return w.writer.Close()
+}
+
+// prune forgets values written more than max age ago every max age,
+// they would be written on the next sample anyway and tasks that
+// are gone would be remembered forever otherwise
+func (w *SuppressWriter) prune(t time.Time) {
+	if t.Sub(w.pruned) < w.maxAge {
+		return
+	}
+
+	w.pruned = t
+
+	for key, values := range w.values {
+		for k, v := range values {
+			if t.Sub(v.written) >= w.maxAge {
+				delete(values, k)
+			}
+		}
+
+		if len(values) == 0 {
+			delete(w.values, key)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuppressWriter(t *testing.T) {
+	r := &recordingWriter{}
+	w := NewSuppressWriter(r, time.Minute)
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(offset time.Duration, a, b uint64) {
+		s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+		s.Time = start.Add(offset)
+		s.Metrics = map[string]uint64{"a": a, "b": b}
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	write(0, 1, 1)
+	write(10*time.Second, 1, 2)
+	write(20*time.Second, 1, 2)
+	write(time.Minute, 1, 2)
+
+	if len(r.written) != 3 {
+		t.Fatalf("expected 3 written samples, got %d", len(r.written))
+	}
+
+	if len(r.written[0].Metrics) != 2 {
+		t.Errorf("expected all metrics in the first sample, got %v", r.written[0].Metrics)
+	}
+
+	if m := r.written[1].Metrics; len(m) != 1 || m["b"] != 2 {
+		t.Errorf("expected only changed metric in the second sample, got %v", m)
+	}
+
+	// a is written after max age as a heartbeat, b was written later
+	if m := r.written[2].Metrics; len(m) != 1 || m["a"] != 1 {
+		t.Errorf("expected heartbeat of unchanged metric, got %v", m)
+	}
+}
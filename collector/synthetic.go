@@ -0,0 +1,175 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SyntheticPattern defines how load of synthetic containers changes
+type SyntheticPattern int
+
+const (
+	// SyntheticFlat keeps load at half of the peak
+	SyntheticFlat SyntheticPattern = iota
+	// SyntheticRamp grows load from zero to the peak every period
+	SyntheticRamp
+	// SyntheticSpike keeps load low with the peak at the start of every period
+	SyntheticSpike
+)
+
+// ParseSyntheticPattern parses synthetic pattern from its name: flat, ramp or spike
+func ParseSyntheticPattern(s string) (SyntheticPattern, error) {
+	switch s {
+	case "flat":
+		return SyntheticFlat, nil
+	case "ramp":
+		return SyntheticRamp, nil
+	case "spike":
+		return SyntheticSpike, nil
+	default:
+		return SyntheticFlat, fmt.Errorf("unknown synthetic pattern: %s", s)
+	}
+}
+
+const (
+	// syntheticTasks is the number of tasks in every synthetic app
+	syntheticTasks = 10
+	// syntheticMemory is memory limit of synthetic containers,
+	// memory usage is the limit at the peak
+	syntheticMemory = 1 << 30
+	// syntheticNetwork is bytes per second received at the peak,
+	// half as much is sent
+	syntheticNetwork = 1 << 20
+	// syntheticPacket is the size of synthetic packets
+	syntheticPacket = 1000
+)
+
+// SyntheticLoad fabricates samples of fake containers, samples depend
+// only on start time and times they are taken at, so runs with the same
+// settings produce the same samples, containers are grouped into
+// synthetic_<n> apps of 10 tasks and load of every container is shifted
+// within period, so apps don't peak all at once
+type SyntheticLoad struct {
+	containers int
+	pattern    SyntheticPattern
+	period     time.Duration
+	start      time.Time
+	last       time.Time
+	counters   []syntheticCounters
+}
+
+// syntheticCounters are cumulative metrics of synthetic container
+type syntheticCounters struct {
+	cpu    uint64
+	rx     uint64
+	faults uint64
+}
+
+// NewSyntheticLoad creates load of specified number of containers
+// with specified pattern repeated every period since start
+func NewSyntheticLoad(containers int, pattern SyntheticPattern, period time.Duration, start time.Time) *SyntheticLoad {
+	if period <= 0 {
+		period = time.Minute
+	}
+
+	return &SyntheticLoad{
+		containers: containers,
+		pattern:    pattern,
+		period:     period,
+		start:      start,
+		last:       start,
+		counters:   make([]syntheticCounters, containers),
+	}
+}
+
+// level returns load of container at specified time from 0 to 1
+func (l *SyntheticLoad) level(container int, t time.Time) float64 {
+	shift := l.period / time.Duration(l.containers) * time.Duration(container)
+
+	phase := float64((t.Sub(l.start)+shift)%l.period) / float64(l.period)
+	if phase < 0 {
+		phase++
+	}
+
+	switch l.pattern {
+	case SyntheticRamp:
+		return phase
+	case SyntheticSpike:
+		if phase < 0.1 {
+			return 1
+		}
+
+		return 0.1
+	default:
+		return 0.5
+	}
+}
+
+// Samples returns samples of all containers taken at specified time,
+// counters grow with load since the previous samples, times
+// before the previous samples don't advance counters
+func (l *SyntheticLoad) Samples(t time.Time) []Stats {
+	elapsed := t.Sub(l.last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	} else {
+		l.last = t
+	}
+
+	result := make([]Stats, 0, l.containers)
+
+	for i := 0; i < l.containers; i++ {
+		level := l.level(i, t)
+
+		counters := &l.counters[i]
+		counters.cpu += uint64(level * elapsed * float64(time.Second))
+		counters.rx += uint64(level * elapsed * syntheticNetwork)
+		counters.faults += uint64(level * elapsed * 1000)
+
+		usage := uint64(level * syntheticMemory)
+
+		result = append(result, Stats{
+			App:   "synthetic_" + strconv.Itoa(i/syntheticTasks),
+			Task:  strconv.Itoa(i % syntheticTasks),
+			Image: "synthetic",
+			Time:  t,
+			CPU: CPUStats{
+				User:   counters.cpu / 10 * 7,
+				System: counters.cpu / 10 * 3,
+				Total:  counters.cpu,
+			},
+			Memory: MemoryStats{
+				Limit:   syntheticMemory,
+				Max:     syntheticMemory,
+				Usage:   usage,
+				RSS:     usage / 4 * 3,
+				Cache:   usage / 4,
+				PgFault: counters.faults,
+			},
+			Network: NetworkStats{
+				RxBytes:   counters.rx,
+				TxBytes:   counters.rx / 2,
+				RxPackets: counters.rx / syntheticPacket,
+				TxPackets: counters.rx / 2 / syntheticPacket,
+			},
+		})
+	}
+
+	return result
+}
+
+// Run writes samples of all containers to writer
+// every interval until ctx is done
+func (l *SyntheticLoad) Run(ctx context.Context, interval time.Duration, w Writer) {
+	every(ctx, interval, func(t time.Time) {
+		for _, s := range l.Samples(t) {
+			err := w.Write(s)
+			if err != nil {
+				countWriteError()
+				LogFields{"app": s.App, "task": s.Task}.Logf(LogError, "error writing stats: %s", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyntheticLoad(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		pattern SyntheticPattern
+		usage   []uint64
+	}{
+		{SyntheticFlat, []uint64{syntheticMemory / 2, syntheticMemory / 2, syntheticMemory / 2}},
+		{SyntheticRamp, []uint64{syntheticMemory / 4, syntheticMemory / 2, syntheticMemory / 4 * 3}},
+		{SyntheticSpike, []uint64{syntheticMemory / 10, syntheticMemory / 10, syntheticMemory / 10}},
+	}
+
+	for _, test := range tests {
+		l := NewSyntheticLoad(20, test.pattern, 40*time.Second, start)
+
+		var cpu uint64
+		for i, usage := range test.usage {
+			samples := l.Samples(start.Add(time.Duration(i+1) * 10 * time.Second))
+			if len(samples) != 20 {
+				t.Fatalf("expected 20 samples, got %d", len(samples))
+			}
+
+			s := samples[0]
+			if s.App != "synthetic_0" || s.Task != "0" || samples[19].App != "synthetic_1" || samples[19].Task != "9" {
+				t.Errorf("unexpected identity of synthetic containers: %s.%s and %s.%s", s.App, s.Task, samples[19].App, samples[19].Task)
+			}
+
+			if s.Memory.Usage != usage {
+				t.Errorf("expected memory usage %d of pattern %d at step %d, got %d", usage, test.pattern, i, s.Memory.Usage)
+			}
+
+			if s.CPU.Total < cpu {
+				t.Errorf("expected cpu counter to grow, got %d after %d", s.CPU.Total, cpu)
+			}
+
+			cpu = s.CPU.Total
+		}
+	}
+}
+
+func TestSyntheticLoadDeterministic(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	a := NewSyntheticLoad(5, SyntheticRamp, time.Minute, start)
+	b := NewSyntheticLoad(5, SyntheticRamp, time.Minute, start)
+
+	for i := 1; i <= 3; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		if !reflect.DeepEqual(a.Samples(at), b.Samples(at)) {
+			t.Errorf("expected the same samples at %s", at)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// thresholdStaleAfter is the age after which state of
+// thresholds of a task that stopped reporting is forgotten
+const thresholdStaleAfter = 10 * time.Minute
+
+// Threshold is a rule that is breached when metric is above or below
+// value for a duration, value can be a percentage of another metric,
+// like in "memory.usage > 90% of memory.limit for 5m"
+type Threshold struct {
+	Metric string
+	// Above is set for > rules and unset for < rules
+	Above bool
+	Value float64
+	// Of is metric that value is a percentage of, empty for absolute values
+	Of string
+	// For is how long rule has to be breached to notify about it
+	For time.Duration
+}
+
+// thresholdFamilies are families of extra container metrics with names
+// that are only known at run time, like top.<command>.rss
+var thresholdFamilies = []string{topFamily, probeFamily, scrapeFamily, logFamily, reachFamily, highResolutionFamily}
+
+// knownThresholdMetric checks whether collector produces container
+// metric with name, so rules with typos are rejected at startup
+func knownThresholdMetric(name string) bool {
+	if name == endedMetric {
+		return true
+	}
+
+	for _, n := range containerMetricNames {
+		if n == name {
+			return true
+		}
+	}
+
+	for _, family := range thresholdFamilies {
+		if strings.HasPrefix(name, family+".") && len(name) > len(family)+1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseThreshold parses threshold rule like "cpu.total > 1000000000"
+// or "memory.usage > 90% of memory.limit for 5m", metrics have to be
+// produced by collector
+func ParseThreshold(s string) (Threshold, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return Threshold{}, fmt.Errorf("threshold should look like \"<metric> > <value> [for <duration>]\", got %q", s)
+	}
+
+	t := Threshold{Metric: fields[0]}
+
+	switch fields[1] {
+	case ">":
+		t.Above = true
+	case "<":
+		t.Above = false
+	default:
+		return Threshold{}, fmt.Errorf("unknown comparison %q in threshold %q, > or < are supported", fields[1], s)
+	}
+
+	value := fields[2]
+	rest := fields[3:]
+
+	if strings.HasSuffix(value, "%") {
+		if len(rest) < 2 || rest[0] != "of" {
+			return Threshold{}, fmt.Errorf("percentage should be followed by \"of <metric>\" in threshold %q", s)
+		}
+
+		value = strings.TrimSuffix(value, "%")
+		t.Of = rest[1]
+		rest = rest[2:]
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return Threshold{}, fmt.Errorf("invalid value in threshold %q: %s", s, err)
+	}
+
+	t.Value = v
+
+	if len(rest) > 0 {
+		if len(rest) != 2 || rest[0] != "for" {
+			return Threshold{}, fmt.Errorf("unexpected %q in threshold %q", strings.Join(rest, " "), s)
+		}
+
+		t.For, err = time.ParseDuration(rest[1])
+		if err != nil {
+			return Threshold{}, fmt.Errorf("invalid duration in threshold %q: %s", s, err)
+		}
+	}
+
+	for _, m := range []string{t.Metric, t.Of} {
+		if m != "" && !knownThresholdMetric(m) {
+			return Threshold{}, fmt.Errorf("unknown metric %q in threshold %q", m, s)
+		}
+	}
+
+	return t, nil
+}
+
+func (t Threshold) String() string {
+	op := "<"
+	if t.Above {
+		op = ">"
+	}
+
+	s := t.Metric + " " + op + " " + strconv.FormatFloat(t.Value, 'f', -1, 64)
+	if t.Of != "" {
+		s += "% of " + t.Of
+	}
+
+	if t.For > 0 {
+		s += " for " + t.For.String()
+	}
+
+	return s
+}
+
+// check returns value of rule metric, percentage if rule has
+// of metric, and whether rule is breached, ok is false if
+// sample doesn't have metrics to check rule
+func (t Threshold) check(metrics map[string]uint64) (value float64, breached bool, ok bool) {
+	v, ok := metrics[t.Metric]
+	if !ok {
+		return 0, false, false
+	}
+
+	value = float64(v)
+
+	if t.Of != "" {
+		of, ok := metrics[t.Of]
+		if !ok || of == 0 {
+			return 0, false, false
+		}
+
+		value = value * 100 / float64(of)
+	}
+
+	if t.Above {
+		return value, value > t.Value, true
+	}
+
+	return value, value < t.Value, true
+}
+
+// thresholdState tracks breach of a single threshold of a task
+type thresholdState struct {
+	since    time.Time
+	notified bool
+	seen     time.Time
+}
+
+// ThresholdWriter is responsible for checking thresholds against metrics
+// of every sample and for writing notifications to wrapped writer when
+// thresholds are breached for their durations and when they recover,
+// samples are passed to wrapped writer as is
+type ThresholdWriter struct {
+	writer     Writer
+	thresholds []Threshold
+
+	mutex  sync.Mutex
+	states map[string][]thresholdState
+	pruned time.Time
+}
+
+// NewThresholdWriter creates new ThresholdWriter on
+// top of specified writer with thresholds to check
+func NewThresholdWriter(writer Writer, thresholds []Threshold) *ThresholdWriter {
+	return &ThresholdWriter{
+		writer:     writer,
+		thresholds: thresholds,
+		states:     map[string][]thresholdState{},
+	}
+}
+
+func (w *ThresholdWriter) Write(s Stats) error {
+	err := w.check(s)
+	if err != nil {
+		return err
+	}
+
+	return w.writer.Write(s)
+}
+
+// check updates states of thresholds of task and notifies
+// about thresholds that are breached or recovered
+func (w *ThresholdWriter) check(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	t := s.Time
+	key := s.App + "/" + s.Task
+
+	states, ok := w.states[key]
+	if !ok {
+		states = make([]thresholdState, len(w.thresholds))
+		w.states[key] = states
+	}
+
+	metrics := intMetrics(s)
+
+	for i, threshold := range w.thresholds {
+		value, breached, ok := threshold.check(metrics)
+		if !ok {
+			continue
+		}
+
+		state := &states[i]
+		state.seen = t
+
+		if !breached {
+			if state.notified {
+				err := w.notify(s, t, SeverityOkay, fmt.Sprintf("threshold %s recovered, value is %s", threshold, formatThresholdValue(threshold, value)))
+				if err != nil {
+					return err
+				}
+			}
+
+			*state = thresholdState{seen: t}
+			continue
+		}
+
+		if state.since.IsZero() {
+			state.since = t
+		}
+
+		if !state.notified && t.Sub(state.since) >= threshold.For {
+			state.notified = true
+
+			err := w.notify(s, t, SeverityFailure, fmt.Sprintf("threshold %s is breached, value is %s", threshold, formatThresholdValue(threshold, value)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	w.prune(t)
+
+	return nil
+}
+
+func (w *ThresholdWriter) notify(s Stats, t time.Time, severity Severity, message string) error {
+	return Notify(w.writer, Notification{
+		App:      s.App,
+		Task:     s.Task,
+		Time:     t,
+		Severity: severity,
+		Event:    "threshold",
+		Message:  message,
+	})
+}
+
+// formatThresholdValue formats value of threshold metric for messages
+func formatThresholdValue(t Threshold, value float64) string {
+	if t.Of != "" {
+		return strconv.FormatFloat(value, 'f', 1, 64) + "% of " + t.Of
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (w *ThresholdWriter) Notify(n Notification) error {
+	return Notify(w.writer, n)
+}
+
+func (w *ThresholdWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *ThresholdWriter) Close() error {
+	return w.writer.Close()
+}
+
+// prune forgets states of tasks that are gone
+func (w *ThresholdWriter) prune(t time.Time) {
+	if t.Sub(w.pruned) < thresholdStaleAfter {
+		return
+	}
+
+	w.pruned = t
+
+	for key, states := range w.states {
+		stale := true
+		for _, state := range states {
+			if t.Sub(state.seen) < thresholdStaleAfter {
+				stale = false
+				break
+			}
+		}
+
+		if stale {
+			delete(w.states, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := map[string]Threshold{
+		"cpu.total > 100":                           {Metric: "cpu.total", Above: true, Value: 100},
+		"top.nginx.rss > 1000":                      {Metric: "top.nginx.rss", Above: true, Value: 1000},
+		"memory.usage > 90% of memory.limit for 5m": {Metric: "memory.usage", Above: true, Value: 90, Of: "memory.limit", For: 5 * time.Minute},
+		"net.rx_bytes < 1.5 for 30s":                {Metric: "net.rx_bytes", Value: 1.5, For: 30 * time.Second},
+	}
+
+	for s, expected := range tests {
+		threshold, err := ParseThreshold(s)
+		if err != nil {
+			t.Errorf("error parsing %q: %s", s, err)
+			continue
+		}
+
+		if threshold != expected {
+			t.Errorf("expected %#v for %q, got %#v", expected, s, threshold)
+		}
+
+		if parsed, err := ParseThreshold(threshold.String()); err != nil || parsed != threshold {
+			t.Errorf("expected %q to be parsed back from %q", s, threshold.String())
+		}
+	}
+
+	for _, s := range []string{"cpu.total", "cpu.total = 1", "memory.usage > 90%", "cpu.total > 1 during 5m", "cpu.throttled > 100", "memory.usage > 90% of memory.limt", "top. > 1"} {
+		if _, err := ParseThreshold(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestThresholdWriter(t *testing.T) {
+	threshold, err := ParseThreshold("memory.usage > 90% of memory.limit for 1m")
+	if err != nil {
+		t.Fatalf("error parsing threshold: %s", err)
+	}
+
+	r := &recordingWriter{}
+	w := NewThresholdWriter(r, []Threshold{threshold})
+
+	start := time.Unix(1431000000, 0)
+
+	write := func(offset time.Duration, usage uint64) {
+		s := Stats{App: "myapp", Task: "mytask"}
+		s.Time = start.Add(offset)
+		s.Memory.Usage = usage
+		s.Memory.Limit = 100
+
+		err := w.Write(s)
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	write(0, 95)
+	write(30*time.Second, 95)
+
+	if len(r.notified) != 0 {
+		t.Fatalf("expected no notifications before threshold duration, got %v", r.notified)
+	}
+
+	write(60*time.Second, 99)
+	write(90*time.Second, 99)
+
+	if len(r.notified) != 1 || r.notified[0].Severity != SeverityFailure {
+		t.Fatalf("expected single failure notification, got %v", r.notified)
+	}
+
+	write(120*time.Second, 50)
+
+	if len(r.notified) != 2 || r.notified[1].Severity != SeverityOkay {
+		t.Fatalf("expected recovery notification, got %v", r.notified)
+	}
+
+	if len(r.written) != 5 {
+		t.Errorf("expected all 5 samples to be written, got %d", len(r.written))
+	}
+}
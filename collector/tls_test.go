@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a throwaway certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert creates certificate signed by parent, self signed ca if parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes certificate and key as pem files to dir
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	key, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "ca", nil)
+	other := newTestCert(t, "other ca", nil)
+	server := newTestCert(t, "server", ca)
+	client := newTestCert(t, "collector", ca)
+
+	caFile, _ := ca.write(t, dir, "ca")
+	otherFile, _ := other.write(t, dir, "other")
+	certFile, keyFile := client.write(t, dir, "client")
+
+	presented := make(chan string, 1)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := ""
+		if len(r.TLS.PeerCertificates) > 0 {
+			name = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+
+		presented <- name
+	}))
+
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.der}, PrivateKey: server.key}},
+		ClientAuth:   tls.RequestClientCert,
+	}
+
+	// rejected handshakes are expected
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	s.StartTLS()
+	defer s.Close()
+
+	config, err := NewTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("error creating tls config: %s", err)
+	}
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+	resp, err := c.Get(s.URL)
+	if err != nil {
+		t.Fatalf("expected server signed by pinned ca to be trusted, got %s", err)
+	}
+
+	resp.Body.Close()
+
+	if name := <-presented; name != "collector" {
+		t.Errorf("expected client certificate of collector to be presented, got %q", name)
+	}
+
+	config, err = NewTLSConfig(otherFile, "", "")
+	if err != nil {
+		t.Fatalf("error creating tls config: %s", err)
+	}
+
+	c = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+	if _, err := c.Get(s.URL); err == nil {
+		t.Error("expected server signed by another ca to be rejected")
+	}
+
+	if _, err := NewTLSConfig(keyFile, "", ""); err == nil {
+		t.Error("expected error with ca file without certificates")
+	}
+
+	if _, err := NewTLSConfig("", certFile, ""); err == nil {
+		t.Error("expected error with client certificate without key")
+	}
+}
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// topFamily is the family of metrics of top processes
+const topFamily = "top"
+
+// topArgs are arguments of ps that docker runs for top of container,
+// docker client doesn't escape them in url, so they are escaped here
+var topArgs = url.QueryEscape("-eo pid,rss,time,comm")
+
+// topProcess is cpu and memory usage of processes with the same command
+type topProcess struct {
+	command   string
+	cpu       uint64
+	rss       uint64
+	processes uint64
+}
+
+// SetTopProcesses enables reporting of usage of n processes with the
+// most cpu time in every container with docker top every interval,
+// processes with the same command are counted together, 0 disables it,
+// it should be called before Run
+func (c *Collector) SetTopProcesses(n int) {
+	c.topProcesses = n
+}
+
+// top returns metrics of top processes of container
+func (c *Collector) top(ctx context.Context, id string) (map[string]uint64, error) {
+	result, err := c.client.TopContainer(id, topArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	processes, err := parseTop(result.Titles, result.Processes)
+	if err != nil {
+		return nil, err
+	}
+
+	return topMetrics(processes, c.topProcesses), nil
+}
+
+// parseTop parses output of ps into usage of commands
+func parseTop(titles []string, rows [][]string) ([]topProcess, error) {
+	columns := map[string]int{}
+	for i, title := range titles {
+		columns[title] = i
+	}
+
+	for _, title := range []string{"RSS", "TIME", "COMMAND"} {
+		if _, ok := columns[title]; !ok {
+			return nil, fmt.Errorf("top of container has no %s column: %v", title, titles)
+		}
+	}
+
+	commands := map[string]*topProcess{}
+	for _, row := range rows {
+		if len(row) != len(titles) {
+			continue
+		}
+
+		command := row[columns["COMMAND"]]
+
+		rss, err := strconv.ParseUint(row[columns["RSS"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rss of %s in top of container: %q", command, row[columns["RSS"]])
+		}
+
+		cpu, err := parsePSTime(row[columns["TIME"]])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu time of %s in top of container: %q", command, row[columns["TIME"]])
+		}
+
+		p, ok := commands[command]
+		if !ok {
+			p = &topProcess{command: command}
+			commands[command] = p
+		}
+
+		p.cpu += cpu
+		p.rss += rss << 10
+		p.processes++
+	}
+
+	processes := make([]topProcess, 0, len(commands))
+	for _, p := range commands {
+		processes = append(processes, *p)
+	}
+
+	return processes, nil
+}
+
+// topMetrics returns metrics of n commands with the most cpu time,
+// commands with more memory go first if cpu time is the same
+func topMetrics(processes []topProcess, n int) map[string]uint64 {
+	sort.Slice(processes, func(i, j int) bool {
+		if processes[i].cpu != processes[j].cpu {
+			return processes[i].cpu > processes[j].cpu
+		}
+
+		if processes[i].rss != processes[j].rss {
+			return processes[i].rss > processes[j].rss
+		}
+
+		return processes[i].command < processes[j].command
+	})
+
+	if len(processes) > n {
+		processes = processes[:n]
+	}
+
+	metrics := make(map[string]uint64, len(processes)*3)
+	for _, p := range processes {
+		prefix := topFamily + "." + sanitizeForGraphite(p.command)
+		metrics[prefix+".cpu_seconds"] = p.cpu
+		metrics[prefix+".rss"] = p.rss
+		metrics[prefix+".processes"] = p.processes
+	}
+
+	return metrics
+}
+
+// parsePSTime parses cpu time of ps like [DD-]HH:MM:SS into seconds
+func parsePSTime(s string) (uint64, error) {
+	days := uint64(0)
+	if i := strings.Index(s, "-"); i != -1 {
+		d, err := strconv.ParseUint(s[:i], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		days, s = d, s[i+1:]
+	}
+
+	seconds := uint64(0)
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		seconds = seconds*60 + n
+	}
+
+	return days*86400 + seconds, nil
+}
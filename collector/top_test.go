@@ -0,0 +1,41 @@
+package collector
+
+import "testing"
+
+func TestParseTop(t *testing.T) {
+	processes, err := parseTop([]string{"PID", "RSS", "TIME", "COMMAND"}, [][]string{
+		{"1", "2048", "00:00:01", "supervisord"},
+		{"12", "10240", "00:01:05", "nginx"},
+		{"13", "10240", "00:00:55", "nginx"},
+		{"14", "512", "1-00:00:00", "cron"},
+		{"15", "4096", "00:00:01", "bash"},
+	})
+	if err != nil {
+		t.Fatalf("error parsing top: %s", err)
+	}
+
+	metrics := topMetrics(processes, 2)
+
+	expected := map[string]uint64{
+		"top.cron.cpu_seconds":  86400,
+		"top.cron.rss":          512 << 10,
+		"top.cron.processes":    1,
+		"top.nginx.cpu_seconds": 120,
+		"top.nginx.rss":         20480 << 10,
+		"top.nginx.processes":   2,
+	}
+
+	if len(metrics) != len(expected) {
+		t.Errorf("expected %d metrics, got %v", len(expected), metrics)
+	}
+
+	for k, v := range expected {
+		if value, ok := metrics[k]; !ok || value != v {
+			t.Errorf("expected %s to be %d, got %v", k, v, metrics)
+		}
+	}
+
+	if _, err := parseTop([]string{"UID", "PID", "CMD"}, nil); err == nil {
+		t.Errorf("expected error parsing top without rss")
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"strings"
+)
+
+// Trigger makes monitors of containers with ids starting with prefix,
+// or all monitors if prefix is empty, send their samples right away
+// and flushes writer once they are written, so fresh metrics don't
+// wait for the next interval, it returns number of triggered monitors
+func (c *Collector) Trigger(ctx context.Context, prefix string) (int, error) {
+	c.mutex.Lock()
+	monitors := make([]*Monitor, 0, len(c.registered))
+	for id, m := range c.registered {
+		if strings.HasPrefix(id, prefix) {
+			monitors = append(monitors, m)
+		}
+	}
+	c.mutex.Unlock()
+
+	pending := make([]<-chan struct{}, 0, len(monitors))
+	for _, m := range monitors {
+		if done, ok := m.sampleNow(ctx); ok {
+			pending = append(pending, done)
+		}
+	}
+
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return len(pending), ctx.Err()
+		}
+	}
+
+	return len(pending), c.flush(ctx)
+}
+
+// flush flushes writer after samples that are already queued are written
+func (c *Collector) flush(ctx context.Context) error {
+	done := make(chan error, 1)
+
+	select {
+	case c.flushes <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestTrigger(t *testing.T) {
+	r := &recordingWriter{}
+	c := NewCollector(nil, r, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.write(ctx)
+
+	clock := &manualClock{now: time.Unix(1000, 0), ticks: make(chan time.Time)}
+
+	in := map[string]chan *docker.Stats{}
+	for _, id := range []string{"0123abcd", "4567cdef"} {
+		m := &Monitor{id: id, app: "myapp", task: id, interval: int64(time.Hour), clock: clock, triggers: make(chan chan struct{})}
+		in[id] = make(chan *docker.Stats)
+
+		c.register(id, m)
+
+		go m.sample(in[id], func(s Stats) {
+			c.send(s)
+		})
+	}
+
+	in["0123abcd"] <- &docker.Stats{Read: time.Unix(1001, 0)}
+
+	n, err := c.Trigger(ctx, "0123")
+	if err != nil {
+		t.Fatalf("error triggering samples: %s", err)
+	}
+
+	if n != 1 || len(r.written) != 1 || r.written[0].Task != "0123abcd" || r.flushes != 1 {
+		t.Errorf("expected single sample to be written and flushed, got %d triggered, %d written and %d flushes", n, len(r.written), r.flushes)
+	}
+
+	// monitor that sent its stats already waits for new ones
+	done := make(chan struct{})
+	go func() {
+		n, err = c.Trigger(ctx, "")
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	in["0123abcd"] <- &docker.Stats{Read: time.Unix(1002, 0)}
+	close(in["4567cdef"])
+
+	<-done
+
+	if err != nil {
+		t.Fatalf("error triggering samples: %s", err)
+	}
+
+	if n != 2 || len(r.written) != 2 || r.flushes != 2 {
+		t.Errorf("expected new stats to be written and flushed, got %d triggered, %d written and %d flushes", n, len(r.written), r.flushes)
+	}
+
+	close(in["0123abcd"])
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DataSource is a single data source of collectd type
+type DataSource struct {
+	Name string
+	Type string
+}
+
+// TypesDB maps collectd type names to their data sources,
+// as defined in collectd's types.db file
+type TypesDB map[string][]DataSource
+
+// collectdValue describes which collectd type, type instance
+// and data source of that type a metric is written as
+type collectdValue struct {
+	Type     string
+	Instance string
+	DS       string
+}
+
+// collectdValues maps container metrics to collectd values,
+// metrics that are not listed here are written as gauges
+var collectdValues = map[string]collectdValue{
+	"cpu.user":   {"cpu", "user", "value"},
+	"cpu.system": {"cpu", "system", "value"},
+	"cpu.total":  {"cpu", "total", "value"},
+
+	"memory.limit":         {"memory", "limit", "value"},
+	"memory.max":           {"memory", "max", "value"},
+	"memory.usage":         {"memory", "usage", "value"},
+	"memory.active_anon":   {"memory", "active_anon", "value"},
+	"memory.active_file":   {"memory", "active_file", "value"},
+	"memory.cache":         {"memory", "cache", "value"},
+	"memory.inactive_anon": {"memory", "inactive_anon", "value"},
+	"memory.inactive_file": {"memory", "inactive_file", "value"},
+	"memory.mapped_file":   {"memory", "mapped_file", "value"},
+	"memory.rss":           {"memory", "rss", "value"},
+	"memory.rss_huge":      {"memory", "rss_huge", "value"},
+	"memory.unevictable":   {"memory", "unevictable", "value"},
+	"memory.writeback":     {"memory", "writeback", "value"},
+	"memory.pg_fault":      {"vmpage_action", "pg_fault", "value"},
+	"memory.pg_in":         {"vmpage_io", "memory", "in"},
+	"memory.pg_out":        {"vmpage_io", "memory", "out"},
+
+	"net.rx_bytes":   {"if_octets", "", "rx"},
+	"net.tx_bytes":   {"if_octets", "", "tx"},
+	"net.rx_packets": {"if_packets", "", "rx"},
+	"net.tx_packets": {"if_packets", "", "tx"},
+	"net.rx_errors":  {"if_errors", "", "rx"},
+	"net.tx_errors":  {"if_errors", "", "tx"},
+	"net.rx_dropped": {"if_dropped", "", "rx"},
+	"net.tx_dropped": {"if_dropped", "", "tx"},
+}
+
+// DefaultTypesDB has definitions of collectd types
+// used for container metrics, copied from collectd's types.db
+var DefaultTypesDB = TypesDB{
+	"cpu":           {{"value", "DERIVE"}},
+	"gauge":         {{"value", "GAUGE"}},
+	"memory":        {{"value", "GAUGE"}},
+	"vmpage_action": {{"value", "DERIVE"}},
+	"vmpage_io":     {{"in", "DERIVE"}, {"out", "DERIVE"}},
+	"if_octets":     {{"rx", "DERIVE"}, {"tx", "DERIVE"}},
+	"if_packets":    {{"rx", "DERIVE"}, {"tx", "DERIVE"}},
+	"if_errors":     {{"rx", "DERIVE"}, {"tx", "DERIVE"}},
+	"if_dropped":    {{"rx", "DERIVE"}, {"tx", "DERIVE"}},
+}
+
+// LoadTypesDB parses collectd's types.db file, types used for
+// container metrics must be defined with the expected data sources
+func LoadTypesDB(path string) (TypesDB, error) {
+	db, err := parseTypesDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, db.validate(collectdValues)
+}
+
+// parseTypesDB parses collectd's types.db file without validation
+func parseTypesDB(path string) (TypesDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	db := TypesDB{}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		sources := []DataSource{}
+		for _, ds := range strings.Split(strings.Join(fields[1:], ""), ",") {
+			parts := strings.Split(ds, ":")
+			if len(parts) != 4 {
+				return nil, fmt.Errorf("invalid data source %q at %s:%d", ds, path, n)
+			}
+
+			sources = append(sources, DataSource{Name: parts[0], Type: parts[1]})
+		}
+
+		db[fields[0]] = sources
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// validate checks that types used for container metrics are defined
+// and have data sources container metrics are mapped to by values
+func (db TypesDB) validate(values map[string]collectdValue) error {
+	if _, ok := db["gauge"]; !ok {
+		return fmt.Errorf("type gauge is not defined in types.db")
+	}
+
+	for _, v := range values {
+		sources, ok := db[v.Type]
+		if !ok {
+			return fmt.Errorf("type %s is not defined in types.db", v.Type)
+		}
+
+		found := false
+		for _, ds := range sources {
+			if ds.Name == v.DS {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("type %s has no data source %s in types.db", v.Type, v.DS)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WavefrontWriter is responsible for writing data to wrapped
+// writer in wavefront data format, usually to wavefront proxy
+type WavefrontWriter struct {
+	host   string
+	writer io.Writer
+}
+
+// NewWavefrontWriter creates new WavefrontWriter
+// with specified hostname and writer
+func NewWavefrontWriter(host string, writer io.Writer) WavefrontWriter {
+	return WavefrontWriter{
+		host:   host,
+		writer: writer,
+	}
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "wavefront",
+		Usage: "wavefront data format to wavefront proxy",
+		Options: append([]WriterOption{
+			{Name: "addr", Default: "127.0.0.1:2878", Usage: "wavefront proxy host:port"},
+		}, tlsOptions...),
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			tlsConfig := p.tlsConfig()
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			conn, err := dialRedialConn("tcp", p.string("addr"), tlsConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			return newStreamWriter(conn, conn, func(w io.Writer) Writer {
+				return NewWavefrontWriter(host, w)
+			}), nil
+		},
+	})
+
+	RegisterWriter(WriterRegistration{
+		Name:  "wavefront-direct",
+		Usage: "wavefront direct ingestion",
+		Options: []WriterOption{
+			{Name: "url", Usage: "wavefront base url, like https://example.wavefront.com"},
+			{Name: "token", Usage: "wavefront api token"},
+			{Name: "timeout", Default: "10s", Usage: "timeout of ingestion requests"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			timeout := p.duration("timeout")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewWavefrontDirectWriter(host, p.string("url"), p.string("token"), timeout), nil
+		},
+	})
+}
+
+func (w WavefrontWriter) Write(s Stats) error {
+	_, err := w.writer.Write(formatWavefront(w.host, s))
+	return err
+}
+
+// Flush is no-op, WavefrontWriter doesn't buffer
+func (w WavefrontWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w WavefrontWriter) Close() error {
+	return nil
+}
+
+// WavefrontDirectWriter is responsible for writing data in wavefront
+// data format directly to wavefront /report http endpoint
+type WavefrontDirectWriter struct {
+	host   string
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWavefrontDirectWriter creates new WavefrontDirectWriter with specified
+// hostname, wavefront base url like https://example.wavefront.com, api token
+// and timeout of ingestion requests
+func NewWavefrontDirectWriter(host string, url string, token string, timeout time.Duration) WavefrontDirectWriter {
+	return WavefrontDirectWriter{
+		host:   host,
+		url:    strings.TrimSuffix(url, "/") + "/report",
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w WavefrontDirectWriter) Write(s Stats) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(formatWavefront(w.host, s)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", "Bearer "+w.token)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from wavefront: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Flush is no-op, WavefrontDirectWriter doesn't buffer
+func (w WavefrontDirectWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, WavefrontDirectWriter has nothing to release
+func (w WavefrontDirectWriter) Close() error {
+	return nil
+}
+
+// formatWavefront formats metrics of sample in wavefront data format
+// with source, app and task point tags followed by metadata
+func formatWavefront(host string, s Stats) []byte {
+	t := s.Time.Unix()
+	b := &bytes.Buffer{}
+
+	for k, v := range intMetrics(s) {
+		b.WriteString("docker_stats.")
+		b.WriteString(k)
+		b.WriteByte(' ')
+		appendUint(b, v)
+		b.WriteByte(' ')
+		appendInt(b, t)
+		appendWavefrontTag(b, "source", host)
+		appendWavefrontTag(b, "app", s.App)
+		appendWavefrontTag(b, "task", s.Task)
+		for k, v := range s.Meta {
+			if k == "source" || k == "app" || k == "task" {
+				continue
+			}
+
+			appendWavefrontTag(b, sanitizeWavefrontTagKey(k), v)
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+// appendWavefrontTag appends point tag with quoted value,
+// quotes in value are escaped and newlines are replaced
+func appendWavefrontTag(b *bytes.Buffer, k string, v string) {
+	b.WriteByte(' ')
+	b.WriteString(k)
+	b.WriteString("=\"")
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '"':
+			b.WriteString("\\\"")
+		case '\n':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	b.WriteByte('"')
+}
+
+// sanitizeWavefrontTagKey replaces characters not allowed
+// in keys of wavefront point tags with underscores
+func sanitizeWavefrontTagKey(k string) string {
+	b := []byte(k)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
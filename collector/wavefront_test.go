@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWavefrontWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWavefrontWriter("myhost", b)
+
+	s := Stats{App: `my"app`, Task: "mytask", Meta: map[string]string{"team name": "infra", "app": "label"}}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 42
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(intMetrics(s)) {
+		t.Errorf("expected %d lines, got %d", len(intMetrics(s)), len(lines))
+	}
+
+	expected := `docker_stats.cpu.total 42 1431000000 source="myhost" app="my\"app" task="mytask" team_name="infra"`
+	for _, l := range lines {
+		if l == expected {
+			return
+		}
+	}
+
+	t.Errorf("expected line %q in output:\n%s", expected, b.String())
+}
+
+func TestWavefrontDirectWriter(t *testing.T) {
+	body := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/report" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected request to %s with authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	defer server.Close()
+
+	w := NewWavefrontDirectWriter("myhost", server.URL+"/", "secret", time.Second)
+
+	err := w.Write(Stats{App: "myapp", Task: "mytask", Time: time.Unix(1431000000, 0)})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if !strings.Contains(body, `docker_stats.cpu.total 0 1431000000 source="myhost" app="myapp" task="mytask"`) {
+		t.Errorf("expected wavefront data in request, got:\n%s", body)
+	}
+}
+
+func TestWavefrontDirectWriterTimeout(t *testing.T) {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+
+	defer server.Close()
+	defer close(done)
+
+	w := NewWavefrontDirectWriter("myhost", server.URL, "secret", 50*time.Millisecond)
+
+	started := time.Now()
+
+	err := w.Write(Stats{App: "myapp", Task: "mytask", Time: time.Now()})
+	if err == nil {
+		t.Fatal("expected error writing to hung wavefront")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected write to time out, took %s", elapsed)
+	}
+}
@@ -0,0 +1,220 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookWriter is responsible for posting batches of samples
+// as json array to http endpoint, failed requests are retried
+// with exponential backoff
+type WebhookWriter struct {
+	host      string
+	url       string
+	headers   map[string]string
+	batchSize int
+	retries   int
+	client    *http.Client
+	// notificationsOnly makes samples skipped, for alerting webhooks
+	notificationsOnly bool
+
+	mutex  sync.Mutex
+	batch  []jsonSample
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// NotificationsOnly returns WebhookWriter that only posts notifications
+// and skips samples, for webhooks of chat or alerting services
+func (w *WebhookWriter) NotificationsOnly() *WebhookWriter {
+	w.notificationsOnly = true
+	return w
+}
+
+// NewWebhookWriter creates new WebhookWriter with specified hostname,
+// endpoint url, extra request headers (for example Authorization),
+// number of samples in a batch, flush interval for incomplete batches
+// and number of retries for failed requests
+func NewWebhookWriter(host string, url string, headers map[string]string, batchSize int, flushInterval time.Duration, retries int) *WebhookWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	w := &WebhookWriter{
+		host:      host,
+		url:       url,
+		headers:   headers,
+		batchSize: batchSize,
+		retries:   retries,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		batch:     make([]jsonSample, 0, batchSize),
+		done:      make(chan struct{}),
+	}
+
+	if flushInterval > 0 {
+		w.ticker = time.NewTicker(flushInterval)
+		go w.flushLoop()
+	}
+
+	return w
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "webhook",
+		Usage: "json arrays of samples posted to http endpoint",
+		Options: []WriterOption{
+			{Name: "url", Usage: "url to post json batches to"},
+			{Name: "headers", Usage: "comma separated headers to add to requests, like \"Authorization: Bearer token\""},
+			{Name: "batch-size", Default: "100", Usage: "number of samples in a batch"},
+			{Name: "flush-interval", Default: "10s", Usage: "interval to post incomplete batches"},
+			{Name: "retries", Default: "3", Usage: "number of retries for failed requests"},
+			{Name: "notifications-only", Default: "false", Usage: "only post notifications and skip samples"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			headers := p.headers("headers")
+			batchSize := p.int("batch-size")
+			flushInterval := p.duration("flush-interval")
+			retries := p.int("retries")
+			notificationsOnly := p.bool("notifications-only")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			w := NewWebhookWriter(host, p.string("url"), headers, batchSize, flushInterval, retries)
+			if notificationsOnly {
+				w = w.NotificationsOnly()
+			}
+
+			return w, nil
+		},
+	})
+}
+
+func (w *WebhookWriter) Write(s Stats) error {
+	if w.notificationsOnly {
+		return nil
+	}
+
+	w.mutex.Lock()
+	w.batch = append(w.batch, newJSONSample(w.host, s))
+	if len(w.batch) < w.batchSize {
+		w.mutex.Unlock()
+		return nil
+	}
+
+	body, err := w.take()
+	w.mutex.Unlock()
+
+	return w.postBatch(body, err)
+}
+
+// Notify posts notification right away as json array
+// with single object that has notification field
+func (w *WebhookWriter) Notify(n Notification) error {
+	body, err := json.Marshal([]jsonNotification{newJSONNotification(w.host, n)})
+	if err != nil {
+		return err
+	}
+
+	return w.postWithRetries(body)
+}
+
+// Flush posts incomplete batch of samples
+func (w *WebhookWriter) Flush() error {
+	w.mutex.Lock()
+	body, err := w.take()
+	w.mutex.Unlock()
+
+	return w.postBatch(body, err)
+}
+
+// Close stops periodic flushing and posts remaining samples
+func (w *WebhookWriter) Close() error {
+	if w.ticker != nil {
+		w.ticker.Stop()
+		close(w.done)
+	}
+
+	return w.Flush()
+}
+
+func (w *WebhookWriter) flushLoop() {
+	for {
+		select {
+		case <-w.ticker.C:
+			err := w.Flush()
+			if err != nil {
+				errorf("error flushing webhook batch: %s", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// take marshals and resets current batch, it must be called with
+// mutex held, while the body is posted without it, so retries
+// of a failed batch do not block writes of the next one
+func (w *WebhookWriter) take() ([]byte, error) {
+	if len(w.batch) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(w.batch)
+	w.batch = w.batch[:0]
+
+	return body, err
+}
+
+func (w *WebhookWriter) postBatch(body []byte, err error) error {
+	if err != nil || body == nil {
+		return err
+	}
+
+	return w.postWithRetries(body)
+}
+
+// postWithRetries posts body retrying with exponential backoff
+func (w *WebhookWriter) postWithRetries(body []byte) error {
+	backoff := time.Second
+	for i := 0; ; i++ {
+		err := w.post(body)
+		if err == nil || i >= w.retries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *WebhookWriter) post(body []byte) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response from webhook: %s", resp.Status)
+	}
+
+	return nil
+}
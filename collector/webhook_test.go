@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookWriterBatching(t *testing.T) {
+	batches := [][]jsonSample{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected authorization header, got %q", r.Header.Get("Authorization"))
+		}
+
+		batch := []jsonSample{}
+		err := json.NewDecoder(r.Body).Decode(&batch)
+		if err != nil {
+			t.Errorf("error decoding batch: %s", err)
+		}
+
+		batches = append(batches, batch)
+	}))
+
+	defer server.Close()
+
+	w := NewWebhookWriter("myhost", server.URL, map[string]string{"Authorization": "Bearer secret"}, 2, 0, 0)
+
+	for i := 0; i < 3; i++ {
+		err := w.Write(Stats{App: "myapp", Task: "mytask"})
+		if err != nil {
+			t.Fatalf("error writing stats: %s", err)
+		}
+	}
+
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("expected single batch of 2 samples before close, got %v", batches)
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if len(batches) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected incomplete batch to be posted on close, got %v", batches)
+	}
+
+	if batches[1][0].Host != "myhost" || batches[1][0].App != "myapp" {
+		t.Errorf("unexpected sample in batch: %#v", batches[1][0])
+	}
+}
+
+func TestWebhookWriterRetryDoesNotBlockWrites(t *testing.T) {
+	failed := make(chan struct{})
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			close(failed)
+		}
+	}))
+
+	defer server.Close()
+
+	w := NewWebhookWriter("myhost", server.URL, nil, 10, 0, 1)
+
+	err := w.Write(Stats{App: "myapp", Task: "mytask"})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- w.Flush()
+	}()
+
+	<-failed
+
+	start := time.Now()
+	err = w.Write(Stats{App: "myapp", Task: "mytask"})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected write not to wait for retry backoff, took %s", elapsed)
+	}
+
+	err = <-flushed
+	if err != nil {
+		t.Errorf("expected failed batch to be retried, got %s", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("error closing writer: %s", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("expected failed request, retry and final batch, got %d requests", requests)
+	}
+}
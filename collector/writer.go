@@ -1,11 +1,123 @@
 package collector
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
 )
 
-const collectdIntGaugeTemplate = "PUTVAL %s/docker_stats.%s.%s/gauge-%s %d:%d\n"
+const (
+	collectdNotificationTemplate = "PUTNOTIF severity=%s time=%d host=%s plugin=%s%s type=docker_event type_instance=%s message=%s\n"
+)
+
+// Writer is responsible for writing stats to monitoring backend,
+// custom writers can be made available by name with RegisterWriter
+type Writer interface {
+	// Write writes or buffers single sample
+	Write(s Stats) error
+	// Flush writes buffered samples if there are any
+	Flush() error
+	// Close flushes buffered samples and releases resources
+	Close() error
+}
+
+// expandTemplate replaces {host}, {app} and {task}
+// placeholders in template with values for stats
+func expandTemplate(template string, host string, s Stats) string {
+	return strings.NewReplacer("{host}", host, "{app}", s.App, "{task}", s.Task).Replace(template)
+}
+
+// nameTemplate is a template with {host}, {app}, {task} and {metric}
+// placeholders that is parsed once, so names are appended to
+// buffers on every write without allocations
+type nameTemplate []templatePart
+
+// templatePart is either literal text or a placeholder
+type templatePart struct {
+	literal     string
+	placeholder string
+}
+
+var templatePlaceholders = []string{"{host}", "{app}", "{task}", "{metric}"}
+
+// parseNameTemplate splits template into literals and placeholders
+func parseNameTemplate(template string) nameTemplate {
+	t := nameTemplate{}
+
+	for template != "" {
+		start, placeholder := -1, ""
+		for _, p := range templatePlaceholders {
+			i := strings.Index(template, p)
+			if i != -1 && (start == -1 || i < start) {
+				start, placeholder = i, p
+			}
+		}
+
+		if start == -1 {
+			t = append(t, templatePart{literal: template})
+			break
+		}
+
+		if start > 0 {
+			t = append(t, templatePart{literal: template[:start]})
+		}
+
+		t = append(t, templatePart{placeholder: placeholder})
+		template = template[start+len(placeholder):]
+	}
+
+	return t
+}
+
+// appendTo appends template expanded for stats and metric to buffer
+func (t nameTemplate) appendTo(b *bytes.Buffer, host string, s *Stats, metric string) {
+	for _, p := range t {
+		switch p.placeholder {
+		case "":
+			b.WriteString(p.literal)
+		case "{host}":
+			b.WriteString(host)
+		case "{app}":
+			b.WriteString(s.App)
+		case "{task}":
+			b.WriteString(s.Task)
+		case "{metric}":
+			b.WriteString(metric)
+		}
+	}
+}
+
+// appendInt appends decimal integer to buffer without allocations
+func appendInt(b *bytes.Buffer, v int64) {
+	var scratch [20]byte
+	b.Write(strconv.AppendInt(scratch[:0], v, 10))
+}
+
+// appendUint appends decimal unsigned integer to buffer without allocations
+func appendUint(b *bytes.Buffer, v uint64) {
+	var scratch [20]byte
+	b.Write(strconv.AppendUint(scratch[:0], v, 10))
+}
+
+// CollectdNaming describes layout of collectd identifiers, plugin and
+// plugin instance are templates with {host}, {app} and {task} placeholders,
+// type instance is a template with {metric} placeholder in addition,
+// empty plugin instance is omitted from identifiers
+type CollectdNaming struct {
+	Plugin         string
+	PluginInstance string
+	TypeInstance   string
+}
+
+// DefaultCollectdNaming is the layout of collectd identifiers
+// that is used unless configured otherwise
+var DefaultCollectdNaming = CollectdNaming{
+	Plugin:       "docker_stats.{app}.{task}",
+	TypeInstance: "{metric}",
+}
 
 // CollectdWriter is responsible for writing data
 // to wrapped writer in collectd exec plugin format
@@ -13,6 +125,14 @@ type CollectdWriter struct {
 	host     string
 	writer   io.Writer
 	interval int
+	types    TypesDB
+	// values maps metrics to collectd values, collectdValues if nil
+	values map[string]collectdValue
+	naming CollectdNaming
+	// plugin, pluginInstance and typeInstance are parsed templates of naming
+	plugin         nameTemplate
+	pluginInstance nameTemplate
+	typeInstance   nameTemplate
 }
 
 // NewCollectdWriter creates new CollectdWriter
@@ -21,61 +141,251 @@ func NewCollectdWriter(host string, writer io.Writer) CollectdWriter {
 	return CollectdWriter{
 		host:   host,
 		writer: writer,
-	}
+	}.WithNaming(DefaultCollectdNaming)
+}
+
+// NewTypedCollectdWriter creates new CollectdWriter with specified
+// hostname and writer that writes metrics with collectd types from
+// types db instead of writing every metric as a gauge
+func NewTypedCollectdWriter(host string, writer io.Writer, types TypesDB) CollectdWriter {
+	return CollectdWriter{
+		host:   host,
+		writer: writer,
+		types:  types,
+	}.WithNaming(DefaultCollectdNaming)
+}
+
+// WithNaming returns copy of CollectdWriter
+// with specified layout of collectd identifiers
+func (w CollectdWriter) WithNaming(naming CollectdNaming) CollectdWriter {
+	w.naming = naming
+	w.plugin = parseNameTemplate(naming.Plugin)
+	w.pluginInstance = parseNameTemplate(naming.PluginInstance)
+	w.typeInstance = parseNameTemplate(naming.TypeInstance)
+	return w
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "collectd",
+		Usage: "collectd exec plugin format on stdout",
+		Options: []WriterOption{
+			{Name: "typed", Default: "false", Usage: "write metrics with collectd types instead of gauges"},
+			{Name: "types-db", Usage: "collectd types.db to use for typed metrics instead of built-in types"},
+			{Name: "plugin", Default: DefaultCollectdNaming.Plugin, Usage: "plugin template, {host}, {app} and {task} are replaced"},
+			{Name: "plugin-instance", Default: DefaultCollectdNaming.PluginInstance, Usage: "plugin instance template, {host}, {app} and {task} are replaced"},
+			{Name: "type-instance", Default: DefaultCollectdNaming.TypeInstance, Usage: "type instance template, {metric} is replaced in addition"},
+			{Name: "compat", Usage: "write metrics like other docker plugins of collectd: python for python docker plugin"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			typed := p.bool("typed")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			naming := CollectdNaming{
+				Plugin:         p.string("plugin"),
+				PluginInstance: p.string("plugin-instance"),
+				TypeInstance:   p.string("type-instance"),
+			}
+
+			if naming.Plugin == "" {
+				return nil, fmt.Errorf("plugin template should not be empty")
+			}
+
+			compat := p.string("compat")
+			if compat != "" && compat != "python" {
+				return nil, fmt.Errorf("unknown compat mode: %s", compat)
+			}
+
+			// naming of python plugin is the default in compat mode
+			if compat == "python" && naming == DefaultCollectdNaming {
+				naming = PythonPluginNaming
+			}
+
+			values, types := collectdValues, DefaultTypesDB
+			if compat == "python" {
+				values, types = pythonPluginValues, PythonPluginTypesDB
+			}
+
+			if p.string("types-db") != "" {
+				db, err := parseTypesDB(p.string("types-db"))
+				if err == nil {
+					err = db.validate(values)
+				}
+
+				if err != nil {
+					return nil, err
+				}
+
+				types = db
+			}
+
+			return newStreamWriter(os.Stdout, nil, func(w io.Writer) Writer {
+				if compat == "python" {
+					return NewPythonPluginCollectdWriter(host, w, types).WithNaming(naming)
+				}
+
+				if typed {
+					return NewTypedCollectdWriter(host, w, types).WithNaming(naming)
+				}
+
+				return NewCollectdWriter(host, w).WithNaming(naming)
+			}), nil
+		},
+	})
 }
 
 func (w CollectdWriter) Write(s Stats) error {
+	if w.types != nil {
+		return w.writeTyped(s)
+	}
+
 	return w.writeInts(s)
 }
 
-func (w CollectdWriter) writeInts(s Stats) error {
-	metrics := map[string]uint64{
-		"cpu.user":   s.Stats.CPUStats.CPUUsage.UsageInUsermode,
-		"cpu.system": s.Stats.CPUStats.CPUUsage.UsageInKernelmode,
-		"cpu.total":  s.Stats.CPUStats.CPUUsage.TotalUsage,
-
-		"memory.limit": s.Stats.MemoryStats.Limit,
-		"memory.max":   s.Stats.MemoryStats.MaxUsage,
-		"memory.usage": s.Stats.MemoryStats.Usage,
-
-		"memory.active_anon":   s.Stats.MemoryStats.Stats.TotalActiveAnon,
-		"memory.active_file":   s.Stats.MemoryStats.Stats.TotalActiveFile,
-		"memory.cache":         s.Stats.MemoryStats.Stats.TotalCache,
-		"memory.inactive_anon": s.Stats.MemoryStats.Stats.TotalInactiveAnon,
-		"memory.inactive_file": s.Stats.MemoryStats.Stats.TotalInactiveFile,
-		"memory.mapped_file":   s.Stats.MemoryStats.Stats.TotalMappedFile,
-		"memory.pg_fault":      s.Stats.MemoryStats.Stats.TotalPgfault,
-		"memory.pg_in":         s.Stats.MemoryStats.Stats.TotalPgpgin,
-		"memory.pg_out":        s.Stats.MemoryStats.Stats.TotalPgpgout,
-		"memory.rss":           s.Stats.MemoryStats.Stats.TotalRss,
-		"memory.rss_huge":      s.Stats.MemoryStats.Stats.TotalRssHuge,
-		"memory.unevictable":   s.Stats.MemoryStats.Stats.TotalUnevictable,
-		"memory.writeback":     s.Stats.MemoryStats.Stats.TotalWriteback,
-
-		"net.rx_bytes":   s.Stats.Network.RxBytes,
-		"net.rx_dropped": s.Stats.Network.RxDropped,
-		"net.rx_errors":  s.Stats.Network.RxErrors,
-		"net.rx_packets": s.Stats.Network.RxPackets,
-		"net.tx_bytes":   s.Stats.Network.TxBytes,
-		"net.tx_dropped": s.Stats.Network.TxDropped,
-		"net.tx_errors":  s.Stats.Network.TxErrors,
-		"net.tx_packets": s.Stats.Network.TxPackets,
+// Notify writes notification with PUTNOTIF command,
+// event is written as type instance of docker_event type
+func (w CollectdWriter) Notify(n Notification) error {
+	s := Stats{App: n.App, Task: n.Task}
+
+	instance := ""
+	if pi := expandTemplate(w.naming.PluginInstance, w.host, s); pi != "" {
+		instance = " plugin_instance=" + pi
 	}
 
-	t := s.Stats.Read.Unix()
+	plugin := expandTemplate(w.naming.Plugin, w.host, s)
 
-	for k, v := range metrics {
-		err := w.writeInt(s, k, t, v)
-		if err != nil {
-			return err
+	_, err := fmt.Fprintf(w.writer, collectdNotificationTemplate, n.Severity, n.Time.Unix(), w.host, plugin, instance, n.Event, collectdQuote(n.Message))
+	return err
+}
+
+// collectdQuote quotes string for collectd exec plugin, newlines
+// are replaced with spaces, since commands are line based
+func collectdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s)
+	return `"` + s + `"`
+}
+
+// Flush is no-op, CollectdWriter doesn't buffer
+func (w CollectdWriter) Flush() error {
+	return nil
+}
+
+// Close is no-op, wrapped writer is owned by caller
+func (w CollectdWriter) Close() error {
+	return nil
+}
+
+// writeTyped writes metrics as values of collectd types, metrics
+// mapped to different data sources of the same type and type
+// instance are written together, missing data sources are unknown
+func (w CollectdWriter) writeTyped(s Stats) error {
+	t := s.Time.Unix()
+	b := getBuffer()
+	defer putBuffer(b)
+
+	mapping := w.values
+	if mapping == nil {
+		mapping = collectdValues
+	}
+
+	values := getTypedValues()
+	defer putTypedValues(values)
+
+	eachMetric(&s, func(k string, v uint64) {
+		cv, ok := mapping[k]
+		if !ok {
+			cv = collectdValue{Type: "gauge", Instance: k, DS: "value"}
 		}
+
+		ds := cv.DS
+		cv.DS = ""
+
+		values.add(cv, ds, v)
+	})
+
+	for i, cv := range values.keys {
+		sources, ok := w.types[cv.Type]
+		if !ok {
+			return fmt.Errorf("type %s is not defined in types.db", cv.Type)
+		}
+
+		w.appendPutval(b, &s, cv.Type, cv.Instance, t)
+		for j, ds := range sources {
+			if j > 0 {
+				b.WriteByte(':')
+			}
+
+			appendTypedSource(b, values.sources[i], ds.Name)
+		}
+
+		b.WriteByte('\n')
 	}
 
-	return nil
+	_, err := w.writer.Write(b.Bytes())
+	return err
+}
+
+// appendTypedSource appends value of data source with name,
+// U is appended for data sources that metrics don't have
+func appendTypedSource(b *bytes.Buffer, sources []typedSource, name string) {
+	for _, source := range sources {
+		if source.name == name {
+			appendUint(b, source.value)
+			return
+		}
+	}
+
+	b.WriteByte('U')
 }
 
-func (w CollectdWriter) writeInt(s Stats, k string, t int64, v uint64) error {
-	msg := fmt.Sprintf(collectdIntGaugeTemplate, w.host, s.App, s.Task, k, t, v)
-	_, err := w.writer.Write([]byte(msg))
+func (w CollectdWriter) writeInts(s Stats) error {
+	t := s.Time.Unix()
+	b := getBuffer()
+	defer putBuffer(b)
+
+	eachMetric(&s, func(k string, v uint64) {
+		w.appendPutval(b, &s, "gauge", k, t)
+		appendUint(b, v)
+		b.WriteByte('\n')
+	})
+
+	_, err := w.writer.Write(b.Bytes())
 	return err
 }
+
+// appendPutval appends PUTVAL command up to values,
+// which are appended by caller along with newline
+func (w CollectdWriter) appendPutval(b *bytes.Buffer, s *Stats, typ string, instance string, t int64) {
+	b.WriteString("PUTVAL ")
+	w.appendIdentifier(b, s, typ, instance)
+	b.WriteByte(' ')
+	appendInt(b, t)
+	b.WriteByte(':')
+}
+
+// appendIdentifier appends collectd identifier for type and type instance,
+// type instance is omitted when it's empty for types like if_octets,
+// plugin instance is omitted when it expands to empty string
+func (w CollectdWriter) appendIdentifier(b *bytes.Buffer, s *Stats, typ string, instance string) {
+	b.WriteString(w.host)
+	b.WriteByte('/')
+	w.plugin.appendTo(b, w.host, s, "")
+
+	n := b.Len()
+	b.WriteByte('-')
+	w.pluginInstance.appendTo(b, w.host, s, "")
+	if b.Len() == n+1 {
+		b.Truncate(n)
+	}
+
+	b.WriteByte('/')
+	b.WriteString(typ)
+
+	if instance != "" {
+		b.WriteByte('-')
+		w.typeInstance.appendTo(b, w.host, s, instance)
+	}
+}
@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedCollectdWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewTypedCollectdWriter("myhost", b, DefaultTypesDB)
+
+	s := Stats{App: "myapp", Task: "mytask"}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 42
+	s.Network.RxBytes = 1
+	s.Network.TxBytes = 2
+	s.Metrics = map[string]uint64{"custom": 3}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := []string{
+		"PUTVAL myhost/docker_stats.myapp.mytask/cpu-total 1431000000:42",
+		"PUTVAL myhost/docker_stats.myapp.mytask/if_octets 1431000000:1:2",
+		"PUTVAL myhost/docker_stats.myapp.mytask/vmpage_io-memory 1431000000:0:0",
+		"PUTVAL myhost/docker_stats.myapp.mytask/gauge-custom 1431000000:3",
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for _, e := range expected {
+		found := false
+		for _, l := range lines {
+			if l == e {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("expected line %q in output:\n%s", e, b.String())
+		}
+	}
+}
+
+func TestCollectdWriterNaming(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewCollectdWriter("myhost", b).WithNaming(CollectdNaming{
+		Plugin:         "docker",
+		PluginInstance: "{app}.{task}",
+		TypeInstance:   "{metric}",
+	})
+
+	s := Stats{App: "myapp", Task: "mytask", MetricsOnly: true}
+	s.Time = time.Unix(1431000000, 0)
+	s.Metrics = map[string]uint64{"custom": 3}
+
+	err := w.Write(s)
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	expected := "PUTVAL myhost/docker-myapp.mytask/gauge-custom 1431000000:3\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestCollectdWriterNotify(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewCollectdWriter("myhost", b)
+
+	err := w.Notify(Notification{
+		App:      "myapp",
+		Task:     "mytask",
+		Time:     time.Unix(1431000000, 0),
+		Severity: SeverityFailure,
+		Event:    "die",
+		Message:  `container "web" died`,
+	})
+	if err != nil {
+		t.Fatalf("error writing notification: %s", err)
+	}
+
+	expected := `PUTNOTIF severity=failure time=1431000000 host=myhost plugin=docker_stats.myapp.mytask type=docker_event type_instance=die message="container \"web\" died"` + "\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+// benchmarkSample returns sample with every container metric set
+func benchmarkSample() Stats {
+	s := Stats{App: "myapp", Task: "mytask", Image: "registry/myapp:1"}
+	s.Time = time.Unix(1431000000, 0)
+	s.CPU.Total = 123456789
+	s.Memory.Usage = 987654321
+	s.Network.RxBytes = 42
+	return s
+}
+
+// writeAllocsBudget is the max number of allocations a write of single
+// sample is allowed to make in steady state for writers on hot path
+var writeAllocsBudget = map[string]float64{
+	"collectd":       0,
+	"typed collectd": 0,
+	"opentsdb":       0,
+	"dogstatsd":      0,
+}
+
+func TestWriteAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("race detector allocates on its own")
+	}
+
+	writers := map[string]Writer{
+		"collectd":       NewCollectdWriter("myhost", ioutil.Discard),
+		"typed collectd": NewTypedCollectdWriter("myhost", ioutil.Discard, DefaultTypesDB),
+		"opentsdb":       NewOpenTSDBWriter("myhost", ioutil.Discard),
+		"dogstatsd":      NewDogStatsDWriter("myhost", ioutil.Discard),
+	}
+
+	s := benchmarkSample()
+
+	for name, w := range writers {
+		allocs := testing.AllocsPerRun(100, func() {
+			err := w.Write(s)
+			if err != nil {
+				t.Fatalf("error writing stats with %s: %s", name, err)
+			}
+		})
+
+		if allocs > writeAllocsBudget[name] {
+			t.Errorf("expected at most %v allocations per write with %s, got %v", writeAllocsBudget[name], name, allocs)
+		}
+	}
+}
+
+func BenchmarkCollectdWriter(b *testing.B) {
+	benchmarkWriter(b, NewCollectdWriter("myhost", ioutil.Discard))
+}
+
+func BenchmarkTypedCollectdWriter(b *testing.B) {
+	benchmarkWriter(b, NewTypedCollectdWriter("myhost", ioutil.Discard, DefaultTypesDB))
+}
+
+func benchmarkWriter(b *testing.B, w Writer) {
+	s := benchmarkSample()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := w.Write(s)
+		if err != nil {
+			b.Fatalf("error writing stats: %s", err)
+		}
+	}
+}
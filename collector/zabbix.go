@@ -0,0 +1,218 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zabbixHeader starts every message of zabbix sender protocol,
+// it is followed by little endian length of json payload
+var zabbixHeader = []byte("ZBXD\x01")
+
+// zabbixMaxResponse is max length of response payload,
+// responses of zabbix are short summaries of processed values
+const zabbixMaxResponse = 1 << 20
+
+// zabbixValue is a single value of trapper item
+type zabbixValue struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// zabbixRequest is sender data request with buffered values
+type zabbixRequest struct {
+	Request string        `json:"request"`
+	Data    []zabbixValue `json:"data"`
+}
+
+// zabbixResponse is response of zabbix server or proxy
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// ZabbixWriter is responsible for sending values of trapper items
+// to zabbix server or proxy with zabbix sender protocol, hosts and keys
+// of items are expanded from templates, values are buffered until flush
+type ZabbixWriter struct {
+	host    string
+	addr    string
+	hostKey nameTemplate
+	itemKey nameTemplate
+	timeout time.Duration
+
+	mutex  sync.Mutex
+	values []zabbixValue
+}
+
+// NewZabbixWriter creates new ZabbixWriter with specified hostname,
+// address of zabbix server or proxy, templates of zabbix host and item
+// key with {host}, {app}, {task} and {metric} placeholders and timeout
+// of sending values
+func NewZabbixWriter(host string, addr string, hostTemplate string, keyTemplate string, timeout time.Duration) *ZabbixWriter {
+	return &ZabbixWriter{
+		host:    host,
+		addr:    addr,
+		hostKey: parseNameTemplate(hostTemplate),
+		itemKey: parseNameTemplate(keyTemplate),
+		timeout: timeout,
+	}
+}
+
+func init() {
+	RegisterWriter(WriterRegistration{
+		Name:  "zabbix",
+		Usage: "zabbix trapper items sent to zabbix server or proxy",
+		Options: []WriterOption{
+			{Name: "addr", Default: "127.0.0.1:10051", Usage: "zabbix server or proxy host:port"},
+			{Name: "host", Default: "{host}", Usage: "zabbix host template, {host}, {app} and {task} are replaced"},
+			{Name: "key", Default: "docker.{metric}[{app},{task}]", Usage: "item key template, {metric} is replaced in addition"},
+			{Name: "timeout", Default: "10s", Usage: "timeout of sending values"},
+		},
+		New: func(host string, o WriterOptions) (Writer, error) {
+			p := optionsParser{options: o}
+			timeout := p.duration("timeout")
+			if p.err != nil {
+				return nil, p.err
+			}
+
+			return NewZabbixWriter(host, p.string("addr"), p.string("host"), p.string("key"), timeout), nil
+		},
+	})
+}
+
+func (w *ZabbixWriter) Write(s Stats) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	b := &bytes.Buffer{}
+	w.hostKey.appendTo(b, w.host, &s, "")
+	host := b.String()
+
+	clock := s.Time.Unix()
+
+	eachMetric(&s, func(name string, value uint64) {
+		b.Reset()
+		w.itemKey.appendTo(b, w.host, &s, name)
+
+		w.values = append(w.values, zabbixValue{
+			Host:  host,
+			Key:   b.String(),
+			Value: strconv.FormatUint(value, 10),
+			Clock: clock,
+		})
+	})
+
+	return nil
+}
+
+// Flush sends buffered values in a single request, values
+// are dropped if zabbix fails to process any of them
+func (w *ZabbixWriter) Flush() error {
+	w.mutex.Lock()
+	values := w.values
+	w.values = nil
+	w.mutex.Unlock()
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return w.send(values)
+}
+
+// Close is no-op, connections are only open while values are sent
+func (w *ZabbixWriter) Close() error {
+	return nil
+}
+
+func (w *ZabbixWriter) send(values []zabbixValue) error {
+	payload, err := json.Marshal(zabbixRequest{Request: "sender data", Data: values})
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", w.addr, w.timeout)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(w.timeout))
+
+	_, err = conn.Write(encodeZabbix(payload))
+	if err != nil {
+		return err
+	}
+
+	response, err := decodeZabbix(conn)
+	if err != nil {
+		return err
+	}
+
+	resp := zabbixResponse{}
+
+	err = json.Unmarshal(response, &resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.Response != "success" {
+		return fmt.Errorf("unexpected response from zabbix: %s %s", resp.Response, resp.Info)
+	}
+
+	// items that zabbix doesn't know are counted as failed
+	if !strings.Contains(resp.Info, "failed: 0;") {
+		return fmt.Errorf("zabbix failed to process values: %s", resp.Info)
+	}
+
+	return nil
+}
+
+// encodeZabbix prepends header of zabbix protocol to payload
+func encodeZabbix(payload []byte) []byte {
+	b := make([]byte, len(zabbixHeader)+8, len(zabbixHeader)+8+len(payload))
+	copy(b, zabbixHeader)
+	binary.LittleEndian.PutUint64(b[len(zabbixHeader):], uint64(len(payload)))
+
+	return append(b, payload...)
+}
+
+// decodeZabbix reads payload of zabbix protocol message
+func decodeZabbix(r io.Reader) ([]byte, error) {
+	header := make([]byte, len(zabbixHeader)+8)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:len(zabbixHeader)], zabbixHeader) {
+		return nil, fmt.Errorf("unexpected header of zabbix response: %q", header[:len(zabbixHeader)])
+	}
+
+	length := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if length > zabbixMaxResponse {
+		return nil, fmt.Errorf("zabbix response is too large: %d bytes", length)
+	}
+
+	payload := make([]byte, length)
+
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestZabbixWriter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+
+	defer l.Close()
+
+	requests := make(chan zabbixRequest, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		payload, err := decodeZabbix(conn)
+		if err != nil {
+			t.Errorf("error reading request: %s", err)
+			return
+		}
+
+		request := zabbixRequest{}
+		json.Unmarshal(payload, &request)
+		requests <- request
+
+		conn.Write(encodeZabbix([]byte(`{"response":"success","info":"processed: 1; failed: 0; total: 1; seconds spent: 0.000055"}`)))
+	}()
+
+	w := NewZabbixWriter("myhost", l.Addr().String(), "{host}", "docker.{metric}[{app},{task}]", time.Second)
+
+	err = w.Write(Stats{
+		App:         "myapp",
+		Task:        "mytask",
+		Time:        time.Unix(1500000000, 0),
+		Metrics:     map[string]uint64{"cpu.total": 42},
+		MetricsOnly: true,
+	})
+	if err != nil {
+		t.Fatalf("error writing stats: %s", err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatalf("error flushing: %s", err)
+	}
+
+	request := <-requests
+
+	expected := zabbixValue{Host: "myhost", Key: "docker.cpu.total[myapp,mytask]", Value: "42", Clock: 1500000000}
+
+	if request.Request != "sender data" || len(request.Data) != 1 || request.Data[0] != expected {
+		t.Errorf("unexpected request %#v", request)
+	}
+}